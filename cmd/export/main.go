@@ -0,0 +1,152 @@
+// Команда export выгружает сырые клики из Postgres в файл или stdout:
+//
+//	export -domain go.acme.com -code AAAAAAAAAA -code BBBBBBBBBB -from 2024-05-01 -to 2024-06-01 -format parquet -o clicks.parquet
+//	export -tag spring-sale -from 2024-05-01 -format ndjson -gzip -o spring.ndjson.gz
+//
+// Коды задаются явно и/или выборкой -owner и -tag по ссылкам домена.
+// Команда работает от имени оператора: права не проверяются, а явные коды
+// могут принадлежать и удалённым ссылкам.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/export"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	pgstore "github.com/Shyyw1e/ozon-bank-url-test/internal/storage/postgres"
+)
+
+type codesFlag []string
+
+func (c *codesFlag) String() string { return strings.Join(*c, ",") }
+
+func (c *codesFlag) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*c = append(*c, part)
+		}
+	}
+	return nil
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	var (
+		codes                 codesFlag
+		sel                   core.LinkSelector
+		from, to, format, out string
+		gz                    bool
+	)
+	flag.StringVar(&sel.Domain, "domain", "", "link domain from DOMAINS (default: the default domain)")
+	flag.Var(&codes, "code", "link code, repeatable or comma-separated")
+	flag.StringVar(&sel.Owner, "owner", "", "also export all links of this owner")
+	flag.StringVar(&sel.Tag, "tag", "", "also export all links with this tag")
+	flag.StringVar(&from, "from", "", "start, RFC3339 or YYYY-MM-DD (default: 7 days before -to)")
+	flag.StringVar(&to, "to", "", "end, exclusive, RFC3339 or YYYY-MM-DD (default: now)")
+	flag.StringVar(&format, "format", "csv", "csv|ndjson|parquet")
+	flag.BoolVar(&gz, "gzip", false, "gzip the output")
+	flag.StringVar(&out, "o", "-", "output file, - for stdout")
+	flag.Parse()
+
+	sel.Codes = codes
+	if err := run(sel, from, to, format, out, gz); err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		os.Exit(1)
+	}
+}
+
+func run(sel core.LinkSelector, from, to, format, out string, gz bool) error {
+	var (
+		domains *core.Domains
+		err     error
+	)
+	if v := os.Getenv("DOMAINS"); v != "" {
+		if domains, err = core.NewDomains(strings.Split(v, ",")); err != nil {
+			return fmt.Errorf("invalid DOMAINS: %w", err)
+		}
+	}
+	key, ok := domains.Key(sel.Domain)
+	if !ok {
+		return fmt.Errorf("unknown -domain %q", sel.Domain)
+	}
+	q := analytics.ClickQuery{Domain: key, Codes: sel.Codes}
+	if q.From, err = parseTime(from); err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	if q.To, err = parseTime(to); err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	o := export.Options{Gzip: gz}
+	if o.Format, err = export.ParseFormat(format); err != nil {
+		return err
+	}
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return fmt.Errorf("DATABASE_URL is required")
+	}
+	ps, err := pgstore.New(dsn)
+	if err != nil {
+		return err
+	}
+	defer ps.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if sel.Owner != "" || sel.Tag != "" {
+		// без Principal в контексте сервис отдаёт выборку без проверки прав
+		svc := core.NewShortener(ps, core.NewCode, core.WithDomains(domains, nil))
+		_, matched, err := svc.StatsLinks(ctx, core.LinkSelector{Domain: sel.Domain, Owner: sel.Owner, Tag: sel.Tag}, analytics.MaxExportCodes)
+		if err != nil {
+			return err
+		}
+		for _, code := range matched {
+			if !slices.Contains(q.Codes, code) {
+				q.Codes = append(q.Codes, code)
+			}
+		}
+	}
+	if err := q.Normalize(time.Now()); err != nil {
+		return fmt.Errorf("need 1-%d codes from -code, -owner or -tag and -from before -to: %w", analytics.MaxExportCodes, err)
+	}
+
+	var w io.WriteCloser = os.Stdout
+	if out != "-" {
+		if w, err = os.Create(out); err != nil {
+			return err
+		}
+	}
+
+	rows, err := export.Run(ctx, ps, q, w, o)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d clicks\n", rows)
+	return nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
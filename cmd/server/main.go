@@ -2,20 +2,39 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/export"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/geoip"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/topk"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/useragent"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/config"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/outbox"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/cache"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
 	pgstore "github.com/Shyyw1e/ozon-bank-url-test/internal/storage/postgres"
+	redisstore "github.com/Shyyw1e/ozon-bank-url-test/internal/storage/redis"
+	grpctransport "github.com/Shyyw1e/ozon-bank-url-test/internal/transport/grpc"
 	httptransport "github.com/Shyyw1e/ozon-bank-url-test/internal/transport/http"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
 	"github.com/Shyyw1e/ozon-bank-url-test/pkg/logger"
 )
 
@@ -29,10 +48,21 @@ func main() {
 	}
 
 	log := logger.New(cfg.LogLevel)
-	log.Info("config", "httpAddr", cfg.HTTPAddr, "storage", cfg.StorageBackend)
+	log.Info("config", "httpAddr", cfg.HTTPAddr, "grpcAddr", cfg.GRPCAddr, "storage", cfg.StorageBackend)
 
 	var store core.Store
+	var clicks analytics.Store
 	var closer func() error
+	var pg *pgstore.Store
+	var rs *redisstore.Store
+	var hooks webhook.Store
+	var keyStore auth.Store
+	var quotas core.QuotaStore
+	var roleStore authz.Store
+	var auditStore audit.Store
+	var modStore moderation.Store
+	var tenantDomains core.TenantDomainStore
+	defQuota := core.Quota{MaxLinks: cfg.QuotaMaxLinks, MaxMonthlyCreates: cfg.QuotaMaxMonthlyCreates}
 	switch cfg.StorageBackend {
 	case "postgres":
 		dsn := os.Getenv("DATABASE_URL")
@@ -46,14 +76,359 @@ func main() {
 			os.Exit(1)
 		}
 		store = ps
+		clicks = ps
 		closer = ps.Close
+		pg = ps
+		hooks = ps
+		keyStore = ps
+		ps.SetDefaultQuota(defQuota)
+		quotas = ps
+		roleStore = ps
+		auditStore = ps
+		modStore = ps
+		tenantDomains = ps
+	case "redis":
+		// Redis хранит только ссылки; клики, вебхуки, ключи, роли, аудит и
+		// модерация общие для всех реплик только в Postgres. Квоты не
+		// ведутся: их счётчики ведёт Create хранилища ссылок.
+		addr, dsn := os.Getenv("REDIS_URL"), os.Getenv("DATABASE_URL")
+		if addr == "" || dsn == "" {
+			log.Error("REDIS_URL and DATABASE_URL are required for redis")
+			os.Exit(1)
+		}
+		rs, err = redisstore.New(addr, redisstore.Options{Prefix: cfg.RedisKeyPrefix, TTL: cfg.RedisTTL})
+		if err != nil {
+			log.Error("redis connect failed", "err", err)
+			os.Exit(1)
+		}
+		ps, err := pgstore.New(dsn)
+		if err != nil {
+			_ = rs.Close()
+			log.Error("postgres connect failed", "err", err)
+			os.Exit(1)
+		}
+		store = rs
+		clicks = ps
+		closer = func() error { return errors.Join(rs.Close(), ps.Close()) }
+		pg = ps
+		hooks = ps
+		keyStore = ps
+		// link_shares ссылается на url_mappings, а ссылок там нет
+		roleStore = authz.WithoutLinkShares(ps)
+		auditStore = ps
+		modStore = ps
+		tenantDomains = ps
 	default:
-		store = memory.New()
+		ms := memory.New()
+		store = ms
+		clicks = ms
+		hooks = ms
+		keyStore = ms
+		ms.SetDefaultQuota(defQuota)
+		quotas = ms
+		roleStore = ms
+		auditStore = ms
+		modStore = ms
+		tenantDomains = ms
 		closer = func() error { return nil }
 	}
 
-	svc := core.NewShortener(store, core.NewCode)
-	handler := httptransport.NewRouter(log, svc)
+	var linkCache *cache.Store
+	if cfg.CacheSize > 0 {
+		linkCache = cache.New(store, cache.Options{Size: cfg.CacheSize, TTL: cfg.CacheTTL, NegativeTTL: cfg.CacheNegativeTTL})
+		store = linkCache
+		log.Info("link cache", "size", cfg.CacheSize, "ttl", cfg.CacheTTL, "negativeTTL", cfg.CacheNegativeTTL)
+	}
+
+	salt := []byte(cfg.VisitorSalt)
+	if len(salt) == 0 {
+		log.Warn("VISITOR_SALT is empty, using a random one: uniques will not merge across instances or restarts")
+		salt = make([]byte, 32)
+		_, _ = rand.Read(salt)
+	}
+	clickSecret := []byte(cfg.ClickIDSecret)
+	if len(clickSecret) == 0 {
+		log.Warn("CLICK_ID_SECRET is empty, using a random one: conversions for click ids issued before a restart or by other instances will be rejected")
+		clickSecret = make([]byte, 32)
+		_, _ = rand.Read(clickSecret)
+	}
+	clickIDs := analytics.NewClickIDs(clickSecret)
+
+	uaClassifier := useragent.Default()
+	if cfg.UARulesFile != "" {
+		if uaClassifier, err = useragent.Load(cfg.UARulesFile); err != nil {
+			log.Error("user-agent rules load failed", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+
+	enrichers := []analytics.Enricher{uaClassifier}
+	if cfg.GeoIPDB != "" {
+		geo, err := geoip.Open(log, cfg.GeoIPDB)
+		if err != nil {
+			log.Error("geoip open failed", "path", cfg.GeoIPDB, "err", err)
+			os.Exit(1)
+		}
+		defer geo.Close()
+		enrichers = append(enrichers, geo)
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			geo.Run(bgCtx, cfg.GeoIPReload)
+		}()
+	}
+
+	recorder := analytics.NewRecorder(log, clicks, analytics.Options{
+		BufferSize:    cfg.ClickBufferSize,
+		BatchSize:     cfg.ClickBatchSize,
+		FlushInterval: cfg.ClickFlushInterval,
+		VisitorSalt:   salt,
+		Enrichers:     enrichers,
+	})
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		recorder.Run(bgCtx)
+	}()
+
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		analytics.RunRetention(bgCtx, log, clicks, cfg.ClickRetention, time.Hour)
+	}()
+
+	hot := topk.New(time.Minute, 60, 100)
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		hot.RunMetrics(bgCtx, 5*time.Minute, 10, 15*time.Second)
+	}()
+
+	exports, err := export.NewJobs(log, clicks, export.JobsOptions{
+		Dir:     cfg.ExportDir,
+		TTL:     cfg.ExportTTL,
+		Workers: cfg.ExportWorkers,
+	})
+	if err != nil {
+		log.Error("export dir init failed", "dir", cfg.ExportDir, "err", err)
+		os.Exit(1)
+	}
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		exports.Run(bgCtx)
+	}()
+
+	// Между инстансами клики ходят через LISTEN/NOTIFY, поэтому только с Postgres.
+	streamOpts := stream.Options{Buffer: cfg.StreamBuffer}
+	if pg != nil {
+		streamOpts.Remote = pg
+	}
+	hub := stream.NewHub(log, streamOpts)
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		hub.Run(bgCtx, 100*time.Millisecond)
+	}()
+	if pg != nil {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			pg.Listen(bgCtx, log, stream.Channel, hub.Receive)
+		}()
+	}
+
+	// Изменения ссылок на других инстансах приходят от триггера
+	// url_mappings; после переподключения кеш сбрасывается, потому что
+	// уведомления за время обрыва потеряны.
+	if pg != nil && rs == nil && linkCache != nil {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			pg.ListenResync(bgCtx, log, cache.Channel, linkCache.Receive, linkCache.Purge)
+		}()
+	}
+	// С Redis изменения публикуют скрипты записи; ссылки, истёкшие по
+	// REDIS_TTL, кеш отпускает сам (cache.Expiring).
+	if rs != nil && linkCache != nil {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			rs.ListenResync(bgCtx, log, linkCache.Receive, linkCache.Purge)
+		}()
+	}
+
+	// Outbox пишет только Postgres: в памяти нечего терять при падении.
+	// Ссылки из Redis в него не попадают.
+	if pg != nil && rs == nil {
+		var pub outbox.Publisher = outbox.LogPublisher{Log: log}
+		if cfg.OutboxPublisher == "http" {
+			pub = outbox.HTTPPublisher{URL: cfg.OutboxURL, Client: &http.Client{Timeout: 10 * time.Second}}
+		}
+		relay := outbox.NewRelay(log, pg, pub, outbox.Options{})
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			relay.Run(bgCtx)
+		}()
+	}
+
+	dispatcher := webhook.NewDispatcher(log, hooks, webhook.Options{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Backoff:     cfg.WebhookBackoff,
+		Timeout:     cfg.WebhookTimeout,
+		AllowNets:   cfg.WebhookAllowNets,
+	})
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		dispatcher.Run(bgCtx)
+	}()
+
+	var domains *core.Domains
+	if len(cfg.Domains) > 0 {
+		if domains, err = core.NewDomains(cfg.Domains); err != nil {
+			log.Error("invalid DOMAINS", "err", err)
+			os.Exit(1)
+		}
+		log.Info("short domains", "default", domains.Default(), "domains", domains.Names())
+	}
+	if cfg.PublicBaseURL != nil && domains != nil && core.HostName(cfg.PublicBaseURL.Host) != domains.Default() {
+		log.Error("PUBLIC_BASE_URL host must be the default domain", "base", cfg.PublicBaseURL.Host, "default", domains.Default())
+		os.Exit(1)
+	}
+	if cfg.PublicBaseURL == nil {
+		log.Warn("PUBLIC_BASE_URL is empty: HTTP short links follow the request host, gRPC returns no short_url")
+	}
+
+	auditLog := audit.New(log, auditStore)
+	rbac := authz.New(roleStore, authz.Options{DefaultRole: authz.Role(cfg.RBACDefaultRole), Audit: auditLog})
+	// модератору нужен сервис, а сервису — его список запрещённых доменов
+	var mod *moderation.Moderator
+	svc := core.NewShortener(store, core.NewCode,
+		core.WithEvents(dispatcher),
+		core.WithAuthorizer(rbac),
+		core.WithAuditor(auditLog),
+		core.WithDomains(domains, tenantDomains),
+		core.WithPublicBaseURL(cfg.PublicBaseURL),
+		core.WithDestinationCheck(core.DestinationCheckFunc(func(ctx context.Context, normalized string) error {
+			return mod.CheckDestination(ctx, normalized)
+		})),
+	)
+	mod = moderation.New(log, modStore, svc, moderation.Options{
+		AutoDisable:  cfg.ModerationAutoDisable,
+		Window:       cfg.ModerationWindow,
+		Salt:         salt,
+		Interstitial: cfg.ModerationInterstitial,
+		Audit:        auditLog,
+	})
+	if err := mod.Load(context.Background()); err != nil {
+		log.Error("moderation load failed", "err", err)
+		os.Exit(1)
+	}
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		mod.Run(bgCtx, time.Minute)
+	}()
+
+	var keys *auth.Keys
+	if cfg.AuthEnabled {
+		keys = auth.NewKeys(keyStore)
+		if cfg.AuthBootstrapKey != "" {
+			if err := keys.Ensure(context.Background(), cfg.AuthBootstrapKey, "bootstrap", []core.Scope{core.ScopeAdmin}); err != nil {
+				log.Error("bootstrap api key failed", "err", err)
+				os.Exit(1)
+			}
+		}
+	} else {
+		log.Warn("AUTH_ENABLED is off: the API is open to anyone who can reach it")
+	}
+	var jwtVerifier *auth.JWTVerifier
+	if cfg.AuthEnabled && cfg.JWTJWKS != "" {
+		jwtVerifier, err = auth.NewJWTVerifier(context.Background(), log, auth.JWTOptions{
+			JWKS:        cfg.JWTJWKS,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			TenantClaim: cfg.JWTTenantClaim,
+			Refresh:     cfg.JWTRefresh,
+		})
+		if err != nil {
+			log.Error("jwks load failed", "src", cfg.JWTJWKS, "err", err)
+			os.Exit(1)
+		}
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			jwtVerifier.Run(bgCtx)
+		}()
+	}
+
+	var policies ratelimit.Policies
+	for _, p := range []struct {
+		dst        *ratelimit.Policy
+		name, spec string
+	}{
+		{&policies.Create, "create", cfg.RateLimitCreate},
+		{&policies.Resolve, "resolve", cfg.RateLimitResolve},
+		{&policies.Redirect, "redirect", cfg.RateLimitRedirect},
+		{&policies.Report, "report", cfg.RateLimitReport},
+	} {
+		if *p.dst, err = ratelimit.ParsePolicy(p.name, p.spec); err != nil {
+			log.Error("rate limit config invalid", "err", err)
+			os.Exit(1)
+		}
+	}
+	var limiter ratelimit.Limiter
+	if cfg.RateLimitBackend == "postgres" {
+		limiter = pg
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			t := time.NewTicker(10 * time.Minute)
+			defer t.Stop()
+			for {
+				select {
+				case <-bgCtx.Done():
+					return
+				case <-t.C:
+					if _, err := pg.PurgeRateLimits(bgCtx, time.Hour); err != nil {
+						log.Error("rate limit purge failed", "err", err)
+					}
+				}
+			}
+		}()
+	} else {
+		mem := ratelimit.NewMemory()
+		limiter = mem
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			mem.Run(bgCtx, time.Minute)
+		}()
+	}
+
+	handler := httptransport.NewRouter(log, svc,
+		httptransport.WithAuth(keys),
+		httptransport.WithJWT(jwtVerifier),
+		httptransport.WithRateLimit(limiter, policies),
+		httptransport.WithQuotas(quotas),
+		httptransport.WithRoles(rbac),
+		httptransport.WithAudit(auditLog),
+		httptransport.WithModeration(mod),
+		httptransport.WithClickRecorder(recorder),
+		httptransport.WithStats(clicks),
+		httptransport.WithHotLinks(hot),
+		httptransport.WithClickIDs(clickIDs),
+		httptransport.WithConversions(clicks),
+		httptransport.WithExports(clicks, exports),
+		httptransport.WithClickStream(hub),
+		httptransport.WithWebhooks(dispatcher),
+		httptransport.WithTrustedProxies(cfg.TrustedProxies),
+	)
 
 	srv := &http.Server{
 		Addr:              cfg.HTTPAddr,
@@ -69,6 +444,19 @@ func main() {
 		}
 	}()
 
+	grpcSrv := grpctransport.NewGRPCServer(log, svc,
+		grpctransport.WithStats(clicks),
+		grpctransport.WithClickStream(hub),
+		grpctransport.WithAuth(keys),
+		grpctransport.WithJWT(jwtVerifier),
+		grpctransport.WithRateLimit(limiter, policies),
+	)
+	if _, err := grpctransport.ListenAndServe(grpcSrv, cfg.GRPCAddr); err != nil {
+		log.Error("grpc listen failed", "err", err)
+		os.Exit(1)
+	}
+	log.Info("grpc listen", "addr", cfg.GRPCAddr)
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
@@ -77,7 +465,11 @@ func main() {
 	defer cancel()
 
 	log.Info("shutting down...")
+	hub.Close()
 	_ = srv.Shutdown(ctx)
+	grpcSrv.GracefulStop()
+	bgCancel()
+	bg.Wait()
 	if err := closer(); err != nil {
 		log.Error("store close error", "err", err)
 	}
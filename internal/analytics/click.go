@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Click — одно событие перехода по короткой ссылке.
+type Click struct {
+	// Domain — ключ домена ссылки (см. core.Domains), пусто — домен по
+	// умолчанию. Аналитика ведётся по паре (Domain, Code).
+	Domain    string
+	Code      string
+	At        time.Time
+	Referrer  string
+	UserAgent string
+	IP        string // перед записью в Sink анонимизируется
+	RequestID string
+	ClickID   string // пусто, если у ссылки не включён click id
+
+	// Заполняются обогащением в фоновом писателе; пустое значение
+	// попадает в разрезы как Unknown.
+	Country string // ISO 3166-1, например RU
+	Region  string // ISO 3166-2, например RU-MOW
+	City    string
+	Device  string
+	OS      string
+	Browser string
+	Bot     bool // краулер или превью ссылки; не входит в человеческие счётчики
+
+	// Visitor — VisitorHash, считается писателем до анонимизации IP и
+	// в хранилище попадает только внутри HLL-скетчей.
+	Visitor uint64
+}
+
+// Enricher дополняет клик производными полями. Вызывается фоновым
+// писателем, а не в редиректе, так что может быть относительно дорогим.
+type Enricher interface {
+	Enrich(c *Click)
+}
+
+// Sink — постоянное хранилище кликов (memory, postgres).
+type Sink interface {
+	WriteClicks(ctx context.Context, clicks []Click) error
+}
+
+// AnonymizeIP обнуляет хвост адреса: /24 для IPv4 и /48 для IPv6.
+func AnonymizeIP(raw string) string {
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
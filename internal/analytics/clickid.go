@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// ClickIDs выпускает и проверяет click id вида "<code>.<token>" (домен по
+// умолчанию) или "<domain>~<code>.<token>", где token — base64url от 8
+// случайных байт и 8 байт HMAC-SHA256(secret, [domain|]code|nonce).
+// ID самодостаточен: постбек конверсии проверяется без обращения к
+// хранилищу, а подделать ID для чужой ссылки без секрета нельзя.
+// Секрет должен совпадать на всех инстансах.
+type ClickIDs struct {
+	secret []byte
+}
+
+const (
+	clickNonceLen = 8
+	clickMACLen   = 8
+)
+
+func NewClickIDs(secret []byte) *ClickIDs {
+	return &ClickIDs{secret: secret}
+}
+
+// New выпускает ID для ссылки (domain — ключ домена, пусто — по
+// умолчанию).
+func (g *ClickIDs) New(domain, code string) (string, error) {
+	buf := make([]byte, clickNonceLen, clickNonceLen+clickMACLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	buf = append(buf, g.mac(domain, code, buf)...)
+	prefix := code
+	if domain != "" {
+		prefix = domain + "~" + code
+	}
+	return prefix + "." + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Parse проверяет подпись и возвращает ссылку, для которой выпущен ID.
+// ID без домена, в том числе выпущенные до появления доменов, относятся
+// к домену по умолчанию.
+func (g *ClickIDs) Parse(id string) (domain, code string, ok bool) {
+	i := strings.LastIndexByte(id, '.')
+	if i <= 0 {
+		return "", "", false
+	}
+	domain, code, found := strings.Cut(id[:i], "~")
+	if !found {
+		domain, code = "", id[:i]
+	} else if domain == "" {
+		return "", "", false
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(id[i+1:])
+	if err != nil || len(buf) != clickNonceLen+clickMACLen {
+		return "", "", false
+	}
+	if !hmac.Equal(buf[clickNonceLen:], g.mac(domain, code, buf[:clickNonceLen])) {
+		return "", "", false
+	}
+	return domain, code, true
+}
+
+func (g *ClickIDs) mac(domain, code string, nonce []byte) []byte {
+	m := hmac.New(sha256.New, g.secret)
+	if domain != "" {
+		m.Write([]byte(domain))
+		m.Write([]byte{0})
+	}
+	m.Write([]byte(code))
+	m.Write([]byte{0})
+	m.Write(nonce)
+	return m.Sum(nil)[:clickMACLen]
+}
@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Conversion — постбек рекламодателя: целевое действие после клика.
+type Conversion struct {
+	ClickID string
+	Domain  string // из ClickID
+	Code    string // из ClickID
+	Event   string
+	Value   float64
+	At      time.Time
+}
+
+var (
+	ErrBadClickID = errors.New("invalid click id")
+	ErrBadEvent   = errors.New("invalid conversion event")
+)
+
+// DefaultEvent подставляется, если постбек не указал событие.
+const DefaultEvent = "conversion"
+
+var eventRe = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// ValidEvent проверяет имя события конверсии.
+func ValidEvent(s string) bool {
+	return eventRe.MatchString(s)
+}
+
+// ConversionSink записывает конверсии. Запись идемпотентна по
+// (ClickID, Event): повтор постбека возвращает created=false.
+type ConversionSink interface {
+	WriteConversion(ctx context.Context, c Conversion) (created bool, err error)
+}
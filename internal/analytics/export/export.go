@@ -0,0 +1,209 @@
+// Package export выгружает сырые клики в CSV, NDJSON и Parquet потоково:
+// строки пишутся по мере чтения из хранилища, память не зависит от
+// размера выгрузки.
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+var ErrBadFormat = errors.New("unknown export format")
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return CSV, nil
+	case CSV, NDJSON, Parquet:
+		return f, nil
+	default:
+		return "", ErrBadFormat
+	}
+}
+
+// Options — формат файла и сжатие. Для CSV и NDJSON Gzip сжимает весь
+// поток (.csv.gz), для Parquet — страницы внутри файла кодеком GZIP, так
+// что файл остаётся читаемым обычными инструментами.
+type Options struct {
+	Format Format
+	Gzip   bool
+}
+
+// ContentType — MIME-тип результата с учётом сжатия.
+func (o Options) ContentType() string {
+	switch {
+	case o.Format == Parquet:
+		return "application/vnd.apache.parquet"
+	case o.Gzip:
+		return "application/gzip"
+	case o.Format == NDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Filename — имя файла для Content-Disposition и CLI.
+func (o Options) Filename(base string) string {
+	name := base + "." + string(o.Format)
+	if o.Gzip && o.Format != Parquet {
+		name += ".gz"
+	}
+	return name
+}
+
+// RowWriter пишет клики в выбранном формате. Close дописывает хвост
+// формата (футер Parquet, конец gzip), но не закрывает нижний io.Writer.
+type RowWriter interface {
+	Write(c analytics.Click) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, o Options) (RowWriter, error) {
+	if o.Format == Parquet {
+		return newParquetWriter(w, o.Gzip), nil
+	}
+	var zw *gzip.Writer
+	if o.Gzip {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	var rw RowWriter
+	switch o.Format {
+	case CSV, "":
+		rw = newCSVWriter(w)
+	case NDJSON:
+		rw = &ndjsonWriter{enc: json.NewEncoder(w)}
+	default:
+		return nil, ErrBadFormat
+	}
+	if zw != nil {
+		rw = &gzipRowWriter{RowWriter: rw, zw: zw}
+	}
+	return rw, nil
+}
+
+// Run выгружает выборку в w и возвращает число строк.
+func Run(ctx context.Context, src analytics.ClickScanner, q analytics.ClickQuery, w io.Writer, o Options) (int64, error) {
+	rw, err := NewWriter(w, o)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = src.ScanClicks(ctx, q, func(c analytics.Click) error {
+		n++
+		return rw.Write(c)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, rw.Close()
+}
+
+// columns — порядок и имена колонок во всех форматах. IP уже
+// анонимизирован при записи клика, visitor-хеш не хранится вовсе.
+var columns = []string{
+	"code", "clicked_at", "referrer", "user_agent", "ip", "request_id", "click_id",
+	"country", "region", "city", "device", "os", "browser", "bot",
+}
+
+// row — клик в виде строки выгрузки; порядок полей совпадает с columns.
+type row struct {
+	Code      string    `json:"code"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	RequestID string    `json:"request_id"`
+	ClickID   string    `json:"click_id"`
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	City      string    `json:"city"`
+	Device    string    `json:"device"`
+	OS        string    `json:"os"`
+	Browser   string    `json:"browser"`
+	Bot       bool      `json:"bot"`
+}
+
+func toRow(c analytics.Click) row {
+	return row{
+		Code: c.Code, ClickedAt: c.At.UTC(), Referrer: c.Referrer, UserAgent: c.UserAgent,
+		IP: c.IP, RequestID: c.RequestID, ClickID: c.ClickID,
+		Country: c.Country, Region: c.Region, City: c.City,
+		Device: c.Device, OS: c.OS, Browser: c.Browser, Bot: c.Bot,
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(c analytics.Click) error {
+	if !cw.header {
+		cw.header = true
+		if err := cw.w.Write(columns); err != nil {
+			return err
+		}
+	}
+	r := toRow(c)
+	return cw.w.Write([]string{
+		r.Code, r.ClickedAt.Format(time.RFC3339Nano), r.Referrer, r.UserAgent, r.IP, r.RequestID, r.ClickID,
+		r.Country, r.Region, r.City, r.Device, r.OS, r.Browser, strconv.FormatBool(r.Bot),
+	})
+}
+
+// Close пишет заголовок и для пустой выгрузки: файл без строк всё равно
+// описывает колонки.
+func (cw *csvWriter) Close() error {
+	if !cw.header {
+		cw.header = true
+		if err := cw.w.Write(columns); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(c analytics.Click) error {
+	return nw.enc.Encode(toRow(c))
+}
+
+func (nw *ndjsonWriter) Close() error { return nil }
+
+type gzipRowWriter struct {
+	RowWriter
+	zw *gzip.Writer
+}
+
+func (g *gzipRowWriter) Close() error {
+	if err := g.RowWriter.Close(); err != nil {
+		return err
+	}
+	return g.zw.Close()
+}
@@ -0,0 +1,216 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+var t0 = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func seed(t *testing.T, n int) *memory.Store {
+	t.Helper()
+	st := memory.New()
+	clicks := make([]analytics.Click, 0, n+1)
+	for i := 0; i < n; i++ {
+		clicks = append(clicks, analytics.Click{
+			Code: "AAAAAAAAAA", At: t0.Add(time.Duration(n-i) * time.Millisecond),
+			Referrer: "https://t.me/x", UserAgent: "UA, with comma", Country: "RU", Bot: i%3 == 0,
+		})
+	}
+	clicks = append(clicks, analytics.Click{Code: "BBBBBBBBBB", At: t0})
+	if err := st.WriteClicks(context.Background(), clicks); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func query() analytics.ClickQuery {
+	return analytics.ClickQuery{Codes: []string{"AAAAAAAAAA"}, From: t0, To: t0.Add(time.Hour)}
+}
+
+func TestRun_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Run(context.Background(), seed(t, 3), query(), &buf, Options{Format: CSV})
+	if err != nil || n != 3 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	recs, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 4 || strings.Join(recs[0], ",") != strings.Join(columns, ",") {
+		t.Fatalf("unexpected csv: %q", recs)
+	}
+	if recs[1][1] != t0.Add(time.Millisecond).Format(time.RFC3339Nano) || recs[1][3] != "UA, with comma" {
+		t.Fatalf("rows must be ordered by time and quoted: %q", recs[1])
+	}
+}
+
+func TestRun_NDJSONGzip(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Run(context.Background(), seed(t, 2), query(), &buf, Options{Format: NDJSON, Gzip: true}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(zr)
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines=%d, want 2", len(lines))
+	}
+	var r row
+	if err := json.Unmarshal([]byte(lines[0]), &r); err != nil || r.Code != "AAAAAAAAAA" || r.Country != "RU" {
+		t.Fatalf("bad row %q: %v", lines[0], err)
+	}
+}
+
+func TestRun_Parquet(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		var buf bytes.Buffer
+		n := parquetGroupRows + 5 // две группы строк
+		if _, err := Run(context.Background(), seed(t, n), query(), &buf, Options{Format: Parquet, Gzip: gz}); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		if !bytes.HasPrefix(b, parquetMagic) || !bytes.HasSuffix(b, parquetMagic) {
+			t.Fatal("missing PAR1 magic")
+		}
+		flen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+		meta := readStruct(t, bytes.NewReader(b[len(b)-8-flen:len(b)-8]))
+
+		if rows := meta[3].(int64); rows != int64(n) {
+			t.Fatalf("num_rows=%d, want %d", rows, n)
+		}
+		schema := meta[2].([]any)
+		if len(schema) != len(columns)+1 || string(schema[1].(map[int16]any)[4].([]byte)) != "code" {
+			t.Fatalf("unexpected schema: %v", schema)
+		}
+		groups := meta[4].([]any)
+		if len(groups) != 2 || groups[1].(map[int16]any)[3].(int64) != 5 {
+			t.Fatalf("unexpected row groups: %v", groups)
+		}
+
+		// Первая страница колонки code: заголовок, затем PLAIN byte array.
+		chunk := groups[0].(map[int16]any)[1].([]any)[0].(map[int16]any)[3].(map[int16]any)
+		r := bytes.NewReader(b[chunk[9].(int64):])
+		ph := readStruct(t, r)
+		page := make([]byte, ph[3].(int64))
+		_, _ = io.ReadFull(r, page)
+		if gz {
+			zr, err := gzip.NewReader(bytes.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, _ = io.ReadAll(zr)
+		}
+		if l := binary.LittleEndian.Uint32(page); string(page[4:4+l]) != "AAAAAAAAAA" {
+			t.Fatalf("first value %q", page[4:4+l])
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	jobs, err := NewJobs(slog.New(slog.NewTextHandler(io.Discard, nil)), seed(t, 4), JobsOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go jobs.Run(ctx)
+
+	job, err := jobs.Start("key1", query(), Options{Format: CSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ = jobs.Get(job.ID)
+		if job.Status == StatusDone || job.Status == StatusFailed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job.Status != StatusDone || job.Rows != 4 {
+		t.Fatalf("job=%+v", job)
+	}
+	f, _, err := jobs.Open(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recs, _ := csv.NewReader(f).ReadAll()
+	if len(recs) != 5 {
+		t.Fatalf("records=%d, want header + 4", len(recs))
+	}
+	if _, _, err := jobs.Open("nope"); err != ErrJobNotFound {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+// readStruct разбирает структуру Thrift compact protocol в map по id поля:
+// числа — int64, строки — []byte, списки — []any, структуры — map.
+func readStruct(t *testing.T, r *bytes.Reader) map[int16]any {
+	t.Helper()
+	out := make(map[int16]any)
+	var id int16
+	for {
+		h, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("truncated struct: %v", err)
+		}
+		if h == 0 {
+			return out
+		}
+		if d := int16(h >> 4); d != 0 {
+			id += d
+		} else {
+			v, _ := binary.ReadUvarint(r)
+			id = int16(v>>1) ^ -int16(v&1)
+		}
+		out[id] = readValue(t, r, h&0x0f)
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, typ byte) any {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 5, 6:
+		v, _ := binary.ReadUvarint(r)
+		return int64(v>>1) ^ -int64(v&1)
+	case 8:
+		n, _ := binary.ReadUvarint(r)
+		b := make([]byte, n)
+		_, _ = io.ReadFull(r, b)
+		return b
+	case 9:
+		h, _ := r.ReadByte()
+		n := uint64(h >> 4)
+		if n == 15 {
+			n, _ = binary.ReadUvarint(r)
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = readValue(t, r, h&0x0f)
+		}
+		return list
+	case 12:
+		return readStruct(t, r)
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
@@ -0,0 +1,260 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+var (
+	ErrJobNotFound = errors.New("export job not found")
+	ErrJobNotReady = errors.New("export job is not finished")
+	ErrQueueFull   = errors.New("export queue is full")
+)
+
+// Job — фоновая выгрузка. Результат лежит файлом в каталоге Jobs до
+// истечения TTL. Principal — ID создавшего задачу (пусто без
+// аутентификации); транспорт отдаёт задачу только ему и admin.
+type Job struct {
+	ID        string
+	Principal string
+	Query     analytics.ClickQuery
+	Options   Options
+	Status    Status
+	Rows      int64
+	Size      int64
+	Error     string
+	Created   time.Time
+	Finished  time.Time
+
+	path string
+}
+
+type JobsOptions struct {
+	Dir       string
+	TTL       time.Duration // сколько хранить готовый результат
+	Workers   int
+	QueueSize int
+}
+
+// Jobs выполняет выгрузки в фоне по несколько штук одновременно. Реестр
+// задач живёт в памяти инстанса: статус и файл доступны только там, где
+// задача создана, и теряются при перезапуске. Это сознательное
+// ограничение одного инстанса — при нескольких запросы к задачам нужно
+// направлять на один из них.
+type Jobs struct {
+	log   *slog.Logger
+	src   analytics.ClickScanner
+	dir   string
+	ttl   time.Duration
+	n     int
+	queue chan string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+const jobFilePrefix = "export-"
+
+func NewJobs(log *slog.Logger, src analytics.ClickScanner, opts JobsOptions) (*Jobs, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	// Файлы прошлого запуска без реестра уже никому не отдать.
+	stale, _ := filepath.Glob(filepath.Join(opts.Dir, jobFilePrefix+"*"))
+	for _, p := range stale {
+		_ = os.Remove(p)
+	}
+	return &Jobs{
+		log:   log,
+		src:   src,
+		dir:   opts.Dir,
+		ttl:   opts.TTL,
+		n:     opts.Workers,
+		queue: make(chan string, opts.QueueSize),
+		jobs:  make(map[string]*Job),
+	}, nil
+}
+
+// Start ставит выгрузку в очередь от имени principal. q должен быть уже
+// нормализован, а права на его коды — проверены.
+func (j *Jobs) Start(principal string, q analytics.ClickQuery, o Options) (Job, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return Job{}, err
+	}
+	job := &Job{
+		ID:        hex.EncodeToString(b[:]),
+		Principal: principal,
+		Query:     q,
+		Options:   o,
+		Status:    StatusQueued,
+		Created:   time.Now().UTC(),
+	}
+	job.path = filepath.Join(j.dir, jobFilePrefix+job.ID)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	select {
+	case j.queue <- job.ID:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	j.jobs[job.ID] = job
+	return *job, nil
+}
+
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Open открывает результат готовой задачи; закрыть файл должен вызывающий.
+func (j *Jobs) Open(id string) (*os.File, Job, error) {
+	job, ok := j.Get(id)
+	switch {
+	case !ok:
+		return nil, Job{}, ErrJobNotFound
+	case job.Status != StatusDone:
+		return nil, job, ErrJobNotReady
+	}
+	f, err := os.Open(job.path)
+	return f, job, err
+}
+
+// Run запускает воркеры и очистку просроченных результатов и ждёт отмены
+// ctx. Прерванные остановкой задачи помечаются как failed.
+func (j *Jobs) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < j.n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-j.queue:
+					j.run(ctx, id)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(j.ttl / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			j.expire(time.Now())
+		}
+	}
+}
+
+func (j *Jobs) run(ctx context.Context, id string) {
+	job := j.update(id, func(job *Job) { job.Status = StatusRunning })
+	if job == nil {
+		return
+	}
+
+	rows, size, err := j.write(ctx, job)
+	j.update(id, func(job *Job) {
+		job.Rows, job.Size, job.Finished = rows, size, time.Now().UTC()
+		if err != nil {
+			job.Status, job.Error = StatusFailed, err.Error()
+			return
+		}
+		job.Status = StatusDone
+	})
+	if err != nil {
+		_ = os.Remove(job.path)
+		j.log.Error("export job failed", "id", id, "err", err)
+		return
+	}
+	j.log.Info("export job done", "id", id, "rows", rows, "bytes", size)
+}
+
+// write пишет во временный файл и переименовывает его только после
+// успешного Close, чтобы недописанный результат никогда не отдавался.
+func (j *Jobs) write(ctx context.Context, job *Job) (int64, int64, error) {
+	tmp := job.path + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmp)
+
+	rows, err := Run(ctx, j.src, job.Query, f, job.Options)
+	if err != nil {
+		_ = f.Close()
+		return rows, 0, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return rows, 0, err
+	}
+	if err := f.Close(); err != nil {
+		return rows, 0, err
+	}
+	return rows, st.Size(), os.Rename(tmp, job.path)
+}
+
+// update меняет задачу под блокировкой и возвращает её копию.
+func (j *Jobs) update(id string, fn func(*Job)) *Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil
+	}
+	fn(job)
+	cp := *job
+	return &cp
+}
+
+func (j *Jobs) expire(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, job := range j.jobs {
+		if job.Finished.IsZero() || now.Sub(job.Finished) < j.ttl {
+			continue
+		}
+		_ = os.Remove(job.path)
+		delete(j.jobs, id)
+	}
+}
@@ -0,0 +1,365 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+// Минимальный писатель Parquet: плоская схема из обязательных колонок,
+// кодирование PLAIN, по одной data page v1 на колонку в группе строк.
+// Этого достаточно, чтобы файл читали pyarrow, DuckDB, Spark и т.п.;
+// группы строк сбрасываются по parquetGroupRows, так что память
+// ограничена одной группой.
+const parquetGroupRows = 10000
+
+// Значения из parquet.thrift.
+const (
+	pqBoolean   = 0
+	pqInt64     = 2
+	pqByteArray = 6
+
+	pqRequired = 0
+
+	pqConvUTF8            = 0
+	pqConvTimestampMillis = 9
+
+	pqEncPlain = 0
+	pqEncRLE   = 3
+
+	pqCodecUncompressed = 0
+	pqCodecGzip         = 2
+
+	pqDataPage = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+type pqKind int
+
+const (
+	pqString pqKind = iota
+	pqTime
+	pqBool
+)
+
+type pqColumn struct {
+	name string
+	kind pqKind
+	str  func(r *row) string
+
+	buf bytes.Buffer
+	n   int // значений в текущей группе; нужно для упаковки bool
+}
+
+func (c *pqColumn) physical() (typ, conv int32) {
+	switch c.kind {
+	case pqTime:
+		return pqInt64, pqConvTimestampMillis
+	case pqBool:
+		return pqBoolean, -1
+	default:
+		return pqByteArray, pqConvUTF8
+	}
+}
+
+type pqChunk struct {
+	typ          int32
+	name         string
+	offset       int64
+	compressed   int64
+	uncompressed int64
+	values       int64
+}
+
+type pqGroup struct {
+	chunks []pqChunk
+	rows   int64
+	size   int64
+}
+
+type parquetWriter struct {
+	w     io.Writer
+	off   int64
+	err   error
+	codec int32
+
+	cols   []*pqColumn
+	rows   int64 // в текущей группе
+	total  int64
+	groups []pqGroup
+}
+
+func newParquetWriter(w io.Writer, gz bool) *parquetWriter {
+	pw := &parquetWriter{w: w, codec: pqCodecUncompressed}
+	if gz {
+		pw.codec = pqCodecGzip
+	}
+	str := func(name string, f func(r *row) string) *pqColumn {
+		return &pqColumn{name: name, kind: pqString, str: f}
+	}
+	pw.cols = []*pqColumn{
+		str("code", func(r *row) string { return r.Code }),
+		{name: "clicked_at", kind: pqTime},
+		str("referrer", func(r *row) string { return r.Referrer }),
+		str("user_agent", func(r *row) string { return r.UserAgent }),
+		str("ip", func(r *row) string { return r.IP }),
+		str("request_id", func(r *row) string { return r.RequestID }),
+		str("click_id", func(r *row) string { return r.ClickID }),
+		str("country", func(r *row) string { return r.Country }),
+		str("region", func(r *row) string { return r.Region }),
+		str("city", func(r *row) string { return r.City }),
+		str("device", func(r *row) string { return r.Device }),
+		str("os", func(r *row) string { return r.OS }),
+		str("browser", func(r *row) string { return r.Browser }),
+		{name: "bot", kind: pqBool},
+	}
+	pw.write(parquetMagic)
+	return pw
+}
+
+func (pw *parquetWriter) Write(c analytics.Click) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	r := toRow(c)
+	var b [8]byte
+	for _, col := range pw.cols {
+		switch col.kind {
+		case pqString:
+			s := col.str(&r)
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+			col.buf.Write(b[:4])
+			col.buf.WriteString(s)
+		case pqTime:
+			binary.LittleEndian.PutUint64(b[:], uint64(r.ClickedAt.UnixMilli()))
+			col.buf.Write(b[:])
+		case pqBool:
+			// PLAIN для BOOLEAN — битовая упаковка, младший бит первым.
+			if col.n%8 == 0 {
+				col.buf.WriteByte(0)
+			}
+			if r.Bot {
+				col.buf.Bytes()[col.buf.Len()-1] |= 1 << (col.n % 8)
+			}
+		}
+		col.n++
+	}
+	pw.rows++
+	if pw.rows == parquetGroupRows {
+		pw.flushGroup()
+	}
+	return pw.err
+}
+
+func (pw *parquetWriter) Close() error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.rows > 0 {
+		pw.flushGroup()
+	}
+	footer := pw.footer()
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(footer)))
+	pw.write(footer)
+	pw.write(n[:])
+	pw.write(parquetMagic)
+	return pw.err
+}
+
+func (pw *parquetWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.off += int64(n)
+	pw.err = err
+}
+
+func (pw *parquetWriter) flushGroup() {
+	g := pqGroup{rows: pw.rows}
+	for _, col := range pw.cols {
+		data := col.buf.Bytes()
+		page := data
+		if pw.codec == pqCodecGzip {
+			var zb bytes.Buffer
+			zw := gzip.NewWriter(&zb)
+			_, _ = zw.Write(data)
+			_ = zw.Close()
+			page = zb.Bytes()
+		}
+
+		var t thriftWriter
+		t.begin()
+		t.i32(1, pqDataPage)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(page)))
+		t.beginStruct(5)
+		t.i32(1, int32(pw.rows))
+		t.i32(2, pqEncPlain)
+		t.i32(3, pqEncRLE)
+		t.i32(4, pqEncRLE)
+		t.endStruct()
+		header := t.end()
+
+		typ, _ := col.physical()
+		ch := pqChunk{
+			typ:          typ,
+			name:         col.name,
+			offset:       pw.off,
+			compressed:   int64(len(header) + len(page)),
+			uncompressed: int64(len(header) + len(data)),
+			values:       pw.rows,
+		}
+		pw.write(header)
+		pw.write(page)
+		g.chunks = append(g.chunks, ch)
+		g.size += ch.uncompressed
+
+		col.buf.Reset()
+		col.n = 0
+	}
+	pw.groups = append(pw.groups, g)
+	pw.total += pw.rows
+	pw.rows = 0
+}
+
+// footer кодирует FileMetaData.
+func (pw *parquetWriter) footer() []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1)
+
+	t.listHeader(2, thriftStruct, len(pw.cols)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(pw.cols)))
+	t.endElem()
+	for _, col := range pw.cols {
+		typ, conv := col.physical()
+		t.beginElem()
+		t.i32(1, typ)
+		t.i32(3, pqRequired)
+		t.binary(4, col.name)
+		if conv >= 0 {
+			t.i32(6, conv)
+		}
+		t.endElem()
+	}
+
+	t.i64(3, pw.total)
+
+	t.listHeader(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		t.beginElem()
+		t.listHeader(1, thriftStruct, len(g.chunks))
+		for _, ch := range g.chunks {
+			t.beginElem()
+			t.i64(2, ch.offset)
+			t.beginStruct(3)
+			t.i32(1, ch.typ)
+			t.listHeader(2, thriftI32, 1)
+			t.varint(zigzag32(pqEncPlain))
+			t.listHeader(3, thriftBinary, 1)
+			t.str(ch.name)
+			t.i32(4, pw.codec)
+			t.i64(5, ch.values)
+			t.i64(6, ch.uncompressed)
+			t.i64(7, ch.compressed)
+			t.i64(9, ch.offset)
+			t.endStruct()
+			t.endElem()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.endElem()
+	}
+
+	t.binary(6, "ozon-bank-url-test export")
+	return t.end()
+}
+
+// thriftWriter — кодировщик Thrift compact protocol ровно в том объёме,
+// который нужен метаданным Parquet.
+type thriftWriter struct {
+	buf  []byte
+	last []int16 // id последнего поля в каждой открытой структуре
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (t *thriftWriter) begin() { t.last = append(t.last, 0) }
+
+func (t *thriftWriter) end() []byte {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+	return t.buf
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	top := len(t.last) - 1
+	if d := id - t.last[top]; d > 0 && d <= 15 {
+		t.buf = append(t.buf, byte(d)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	t.last[top] = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func zigzag32(v int32) uint64 { return uint64(uint32((v << 1) ^ (v >> 31))) }
+func zigzag64(v int64) uint64 { return uint64((v << 1) ^ (v >> 63)) }
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag32(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag64(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.str(s)
+}
+
+func (t *thriftWriter) str(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() { t.end() }
+
+func (t *thriftWriter) listHeader(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elem)
+	t.varint(uint64(n))
+}
+
+func (t *thriftWriter) beginElem() { t.last = append(t.last, 0) }
+func (t *thriftWriter) endElem()   { t.end() }
@@ -0,0 +1,134 @@
+// Package geoip обогащает клики страной, регионом и городом по локальной
+// базе в формате MaxMind (GeoLite2/GeoIP2 City или Country). Во внешние
+// сервисы ничего не уходит. Файл базы перечитывается при изменении, так что
+// его можно обновлять (например, geoipupdate) без рестарта.
+package geoip
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type Resolver struct {
+	log  *slog.Logger
+	path string
+
+	mu      sync.RWMutex
+	db      *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+func Open(log *slog.Logger, path string) (*Resolver, error) {
+	r := &Resolver{log: log, path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Enrich реализует analytics.Enricher. Вызывается писателем до
+// анонимизации, поэтому видит полный IP клиента.
+func (r *Resolver) Enrich(c *analytics.Click) {
+	ip := net.ParseIP(c.IP)
+	if ip == nil {
+		return
+	}
+	var rec record
+
+	r.mu.RLock()
+	err := r.db.Lookup(ip, &rec)
+	r.mu.RUnlock()
+	if err != nil {
+		r.log.Debug("geoip lookup failed", "err", err)
+		return
+	}
+
+	c.Country = rec.Country.ISOCode
+	if len(rec.Subdivisions) > 0 && rec.Country.ISOCode != "" {
+		c.Region = rec.Country.ISOCode + "-" + rec.Subdivisions[0].ISOCode
+	}
+	c.City = rec.City.Names["en"]
+}
+
+// Run раз в every проверяет mtime и размер файла и перечитывает базу при
+// изменении. Ошибка перечитывания оставляет в работе старую базу.
+func (r *Resolver) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(r.path)
+			if err != nil {
+				r.log.Error("geoip stat failed", "path", r.path, "err", err)
+				continue
+			}
+			r.mu.RLock()
+			changed := !fi.ModTime().Equal(r.modTime) || fi.Size() != r.size
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				r.log.Error("geoip reload failed", "path", r.path, "err", err)
+				continue
+			}
+			r.log.Info("geoip database reloaded", "path", r.path)
+		}
+	}
+}
+
+func (r *Resolver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.db.Close()
+}
+
+// reload читает файл целиком в память (а не mmap), чтобы замена файла на
+// диске не влияла на уже открытую базу.
+func (r *Resolver) reload() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	buf, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	db, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.db
+	r.db, r.modTime, r.size = db, fi.ModTime(), fi.Size()
+	r.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package geoip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// city — запись в формате GeoIP2 City с нужными Enrich полями.
+func city(country, region, name string) map[string]any {
+	return map[string]any{
+		"country":      map[string]any{"iso_code": country},
+		"subdivisions": []any{map[string]any{"iso_code": region}},
+		"city":         map[string]any{"names": map[string]any{"en": name}},
+	}
+}
+
+// writeMMDB пишет минимальную IPv4-базу MaxMind DB (record size 24) с
+// записями для перечисленных подсетей.
+func writeMMDB(t *testing.T, path string, nets map[string]map[string]any) {
+	t.Helper()
+
+	type node struct {
+		child [2]*node
+		data  [2]int // смещение записи в секции данных, -1 — нет
+	}
+	newNode := func() *node { return &node{data: [2]int{-1, -1}} }
+	root := newNode()
+
+	prefixes := make([]string, 0, len(nets))
+	for p := range nets {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	var data bytes.Buffer
+	for _, p := range prefixes {
+		pfx := netip.MustParsePrefix(p)
+		off := data.Len()
+		data.Write(encode(nets[p]))
+
+		ip := binary.BigEndian.Uint32(pfx.Addr().AsSlice())
+		n := root
+		for i := 0; i < pfx.Bits(); i++ {
+			bit := ip >> (31 - i) & 1
+			if i == pfx.Bits()-1 {
+				n.data[bit] = off
+				break
+			}
+			if n.child[bit] == nil {
+				n.child[bit] = newNode()
+			}
+			n = n.child[bit]
+		}
+	}
+
+	var order []*node
+	index := map[*node]int{}
+	var walk func(n *node)
+	walk = func(n *node) {
+		index[n] = len(order)
+		order = append(order, n)
+		for _, c := range n.child {
+			if c != nil {
+				walk(c)
+			}
+		}
+	}
+	walk(root)
+
+	count := len(order)
+	var file bytes.Buffer
+	for _, n := range order {
+		for side := 0; side < 2; side++ {
+			v := count // нет данных
+			switch {
+			case n.child[side] != nil:
+				v = index[n.child[side]]
+			case n.data[side] >= 0:
+				v = count + 16 + n.data[side]
+			}
+			file.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+	file.WriteString("\xab\xcd\xefMaxMind.com")
+	file.Write(encode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               "GeoIP2-City",
+		"description":                 map[string]any{"en": "test"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+	}))
+
+	// Замена файла целиком, как это делает geoipupdate.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, file.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// encode кодирует значение в формат данных MaxMind DB.
+func encode(v any) []byte {
+	var b bytes.Buffer
+	switch v := v.(type) {
+	case string:
+		b.Write(control(2, len(v)))
+		b.WriteString(v)
+	case uint16:
+		b.Write(uintBytes(5, uint64(v)))
+	case uint32:
+		b.Write(uintBytes(6, uint64(v)))
+	case uint64:
+		b.Write(uintBytes(9, v))
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.Write(control(7, len(v)))
+		for _, k := range keys {
+			b.Write(encode(k))
+			b.Write(encode(v[k]))
+		}
+	case []any:
+		b.Write(control(11, len(v)))
+		for _, e := range v {
+			b.Write(encode(e))
+		}
+	default:
+		panic("mmdb: unsupported type")
+	}
+	return b.Bytes()
+}
+
+func uintBytes(typ int, v uint64) []byte {
+	var be [8]byte
+	binary.BigEndian.PutUint64(be[:], v)
+	n := bytes.TrimLeft(be[:], "\x00")
+	return append(control(typ, len(n)), n...)
+}
+
+// control — управляющий байт: тип в старших трёх битах (расширенные
+// типы — отдельным байтом), размер до 28 — в младших пяти.
+func control(typ, size int) []byte {
+	if size >= 29 {
+		panic("mmdb: size too large for the test encoder")
+	}
+	if typ <= 7 {
+		return []byte{byte(typ<<5 | size)}
+	}
+	return []byte{byte(size), byte(typ - 7)}
+}
+
+func TestResolver_LookupAndUnknown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeMMDB(t, path, map[string]map[string]any{
+		"81.2.69.0/24":   city("GB", "ENG", "London"),
+		"89.160.20.0/24": city("SE", "E", "Linköping"),
+	})
+	r, err := Open(testLogger(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	c := analytics.Click{IP: "81.2.69.160"}
+	r.Enrich(&c)
+	if c.Country != "GB" || c.Region != "GB-ENG" || c.City != "London" {
+		t.Fatalf("81.2.69.160: %q %q %q", c.Country, c.Region, c.City)
+	}
+	c = analytics.Click{IP: "89.160.20.1"}
+	r.Enrich(&c)
+	if c.Country != "SE" || c.City != "Linköping" {
+		t.Fatalf("89.160.20.1: %q %q", c.Country, c.City)
+	}
+
+	for _, ip := range []string{"10.0.0.1", "2001:db8::1", "not-an-ip", ""} {
+		c := analytics.Click{IP: ip}
+		r.Enrich(&c)
+		if c.Country != "" || c.Region != "" || c.City != "" {
+			t.Fatalf("%q should stay unknown: %q %q %q", ip, c.Country, c.Region, c.City)
+		}
+	}
+}
+
+func TestResolver_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeMMDB(t, path, map[string]map[string]any{"81.2.69.0/24": city("GB", "ENG", "London")})
+	r, err := Open(testLogger(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { r.Run(ctx, 5*time.Millisecond); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	country := func() string {
+		c := analytics.Click{IP: "81.2.69.160"}
+		r.Enrich(&c)
+		return c.Country
+	}
+	// mtime ставится явно: на грубых ФС две записи подряд могут его не
+	// изменить.
+	touch := func(at time.Time) {
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for country() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("country=%q, want %q after reload", country(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writeMMDB(t, path, map[string]map[string]any{"81.2.69.0/24": city("DE", "BE", "Berlin")})
+	touch(time.Now().Add(time.Minute))
+	waitFor("DE")
+
+	// Битый файл не заменяет рабочую базу.
+	if err := os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(time.Now().Add(2 * time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := country(); got != "DE" {
+		t.Fatalf("after a broken update country=%q, want the previous DE", got)
+	}
+}
@@ -0,0 +1,85 @@
+// Package hll — HyperLogLog для оценки числа уникальных посетителей.
+//
+// Точность фиксирована: p = 12, т.е. 4096 однобайтовых регистров (4 КБ на
+// скетч). Стандартная ошибка оценки 1.04/√4096 ≈ 1.6%: примерно в 68%
+// случаев оценка отличается от истины не больше чем на 1.6%, в 95% — не
+// больше чем на 3.3%. На малых множествах (до ~10 000) используется
+// linear counting, и ошибка там заметно меньше.
+//
+// Скетчи объединяются без потерь (поэлементный максимум регистров), так что
+// скетчи с разных инстансов и за разные дни можно сливать в один.
+package hll
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	precision = 12
+	registers = 1 << precision
+	version   = 1
+)
+
+var ErrBadSketch = errors.New("hll: malformed sketch")
+
+type Sketch struct {
+	reg [registers]uint8
+}
+
+func New() *Sketch { return &Sketch{} }
+
+// Add учитывает элемент по его 64-битному хешу. Хеш должен быть
+// равномерно распределён (например, префикс HMAC-SHA256).
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - precision)
+	w := hash<<precision | 1<<(precision-1)
+	rho := uint8(bits.LeadingZeros64(w) + 1)
+	if rho > s.reg[idx] {
+		s.reg[idx] = rho
+	}
+}
+
+func (s *Sketch) Merge(o *Sketch) {
+	for i, v := range o.reg {
+		if v > s.reg[i] {
+			s.reg[i] = v
+		}
+	}
+}
+
+func (s *Sketch) Estimate() uint64 {
+	const m = float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	var sum float64
+	zeros := 0
+	for _, v := range s.reg {
+		sum += 1 / float64(uint64(1)<<v)
+		if v == 0 {
+			zeros++
+		}
+	}
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+// MarshalBinary: байт версии, байт точности, затем регистры.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 2+registers)
+	out[0], out[1] = version, precision
+	copy(out[2:], s.reg[:])
+	return out, nil
+}
+
+func (s *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) != 2+registers || b[0] != version || b[1] != precision {
+		return ErrBadSketch
+	}
+	copy(s.reg[:], b[2:])
+	return nil
+}
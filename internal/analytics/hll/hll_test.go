@@ -0,0 +1,68 @@
+package hll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func hashOf(i int) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(i))
+	sum := sha256.Sum256(b[:])
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestEstimate_WithinErrorBound(t *testing.T) {
+	for _, n := range []int{100, 5000, 200000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add(hashOf(i))
+			s.Add(hashOf(i)) // повторы не влияют на оценку
+		}
+		got := float64(s.Estimate())
+		if rel := math.Abs(got-float64(n)) / float64(n); rel > 0.05 {
+			t.Fatalf("n=%d: estimate=%v, relative error %.3f > 5%%", n, got, rel)
+		}
+	}
+}
+
+func TestMerge_EqualsUnion(t *testing.T) {
+	a, b, union := New(), New(), New()
+	for i := 0; i < 30000; i++ {
+		h := hashOf(i)
+		if i%2 == 0 {
+			a.Add(h)
+		} else {
+			b.Add(h)
+		}
+		if i < 20000 {
+			b.Add(h)
+		}
+		union.Add(h)
+	}
+	a.Merge(b)
+	if a.Estimate() != union.Estimate() {
+		t.Fatalf("merge=%d, union=%d", a.Estimate(), union.Estimate())
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	s := New()
+	for i := 0; i < 1000; i++ {
+		s.Add(hashOf(i))
+	}
+	b, _ := s.MarshalBinary()
+
+	var got Sketch
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Estimate() != s.Estimate() {
+		t.Fatalf("estimate changed after round trip")
+	}
+	if err := got.UnmarshalBinary(b[:10]); err != ErrBadSketch {
+		t.Fatalf("short input: err=%v, want ErrBadSketch", err)
+	}
+}
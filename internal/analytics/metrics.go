@@ -0,0 +1,21 @@
+package analytics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	clicksRecorded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_clicks_recorded_total",
+		Help: "Clicks written to the analytics sink.",
+	})
+	clicksDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_clicks_dropped_total",
+		Help: "Clicks lost by the analytics pipeline, by reason (overflow|write_error).",
+	}, []string{"reason"})
+	clicksBuffered = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "shortener_clicks_buffer_len",
+		Help: "Clicks waiting in the analytics buffer.",
+	})
+)
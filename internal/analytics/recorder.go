@@ -0,0 +1,121 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Options struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	VisitorSalt   []byte
+	Enrichers     []Enricher
+}
+
+// Recorder принимает клики из горячего пути редиректа и пишет их в Sink
+// пачками в фоне. Record никогда не блокирует: при переполнении буфера
+// событие отбрасывается и учитывается в метрике.
+type Recorder struct {
+	log   *slog.Logger
+	sink  Sink
+	ch    chan Click
+	batch int
+	every time.Duration
+	salt  []byte
+	enr   []Enricher
+}
+
+func NewRecorder(log *slog.Logger, sink Sink, opts Options) *Recorder {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	return &Recorder{
+		log:   log,
+		sink:  sink,
+		ch:    make(chan Click, opts.BufferSize),
+		batch: opts.BatchSize,
+		every: opts.FlushInterval,
+		salt:  opts.VisitorSalt,
+		enr:   opts.Enrichers,
+	}
+}
+
+func (r *Recorder) Record(c Click) bool {
+	select {
+	case r.ch <- c:
+		return true
+	default:
+		clicksDropped.WithLabelValues("overflow").Inc()
+		return false
+	}
+}
+
+// Run — цикл фонового писателя. После отмены ctx дописывает то, что
+// осталось в буфере, и возвращается.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.every)
+	defer ticker.Stop()
+
+	buf := make([]Click, 0, r.batch)
+	for {
+		select {
+		case c := <-r.ch:
+			buf = append(buf, c)
+			if len(buf) >= r.batch {
+				buf = r.flush(buf)
+			}
+		case <-ticker.C:
+			buf = r.flush(buf)
+		case <-ctx.Done():
+			r.drain(buf)
+			return
+		}
+	}
+}
+
+func (r *Recorder) drain(buf []Click) {
+	for {
+		select {
+		case c := <-r.ch:
+			buf = append(buf, c)
+			if len(buf) >= r.batch {
+				buf = r.flush(buf)
+			}
+		default:
+			r.flush(buf)
+			return
+		}
+	}
+}
+
+func (r *Recorder) flush(buf []Click) []Click {
+	clicksBuffered.Set(float64(len(r.ch)))
+	if len(buf) == 0 {
+		return buf
+	}
+	for i := range buf {
+		for _, e := range r.enr {
+			e.Enrich(&buf[i])
+		}
+		buf[i].Visitor = VisitorHash(r.salt, buf[i])
+		buf[i].IP = AnonymizeIP(buf[i].IP)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.sink.WriteClicks(ctx, buf); err != nil {
+		r.log.Error("clicks write failed", "n", len(buf), "err", err)
+		clicksDropped.WithLabelValues("write_error").Add(float64(len(buf)))
+	} else {
+		clicksRecorded.Add(float64(len(buf)))
+	}
+	return buf[:0]
+}
@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sliceSink struct {
+	mu     sync.Mutex
+	clicks []Click
+}
+
+func (s *sliceSink) WriteClicks(ctx context.Context, clicks []Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+func (s *sliceSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clicks)
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestRecorder_FlushesAndAnonymizes(t *testing.T) {
+	sink := &sliceSink{}
+	rec := NewRecorder(testLogger(), sink, Options{BufferSize: 10, BatchSize: 2, FlushInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { rec.Run(ctx); close(done) }()
+
+	rec.Record(Click{Code: "AAAAAAAAAA", IP: "203.0.113.77"})
+	rec.Record(Click{Code: "AAAAAAAAAA", IP: "2001:db8:1:2::5"})
+	rec.Record(Click{Code: "BBBBBBBBBB", IP: "198.51.100.1"})
+
+	cancel()
+	<-done
+
+	if sink.len() != 3 {
+		t.Fatalf("written=%d, want 3", sink.len())
+	}
+	if got := sink.clicks[0].IP; got != "203.0.113.0" {
+		t.Fatalf("ipv4 not anonymized: %q", got)
+	}
+	if got := sink.clicks[1].IP; got != "2001:db8:1::" {
+		t.Fatalf("ipv6 not anonymized: %q", got)
+	}
+}
+
+func TestRecorder_OverflowDoesNotBlock(t *testing.T) {
+	rec := NewRecorder(testLogger(), &sliceSink{}, Options{BufferSize: 1})
+
+	if !rec.Record(Click{Code: "AAAAAAAAAA"}) {
+		t.Fatalf("first record must fit into the buffer")
+	}
+	if rec.Record(Click{Code: "AAAAAAAAAA"}) {
+		t.Fatalf("second record must be dropped")
+	}
+}
@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunRetention раз в every удаляет сырые клики старше keep. keep <= 0
+// отключает очистку.
+func RunRetention(ctx context.Context, log *slog.Logger, p Purger, keep, every time.Duration) {
+	if keep <= 0 {
+		return
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.PurgeClicks(ctx, time.Now().Add(-keep))
+			if err != nil {
+				log.Error("clicks retention failed", "err", err)
+				continue
+			}
+			if n > 0 {
+				log.Info("clicks retention", "deleted", n)
+			}
+		}
+	}
+}
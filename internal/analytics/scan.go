@@ -0,0 +1,47 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxExportCodes ограничивает число ссылок в одной выгрузке.
+const MaxExportCodes = 1000
+
+var ErrBadClickQuery = errors.New("invalid click query")
+
+// ClickQuery — выборка сырых кликов за [From, To) по одной или нескольким
+// ссылкам домена Domain (ключ, пусто — по умолчанию).
+type ClickQuery struct {
+	Domain string
+	Codes  []string
+	From   time.Time
+	To     time.Time
+}
+
+// Normalize подставляет умолчания (последние DefaultRange) и проверяет
+// выборку. Длину интервала не ограничивает: сырые клики всё равно живут
+// не дольше CLICK_RETENTION.
+func (q *ClickQuery) Normalize(now time.Time) error {
+	if len(q.Codes) == 0 || len(q.Codes) > MaxExportCodes {
+		return ErrBadClickQuery
+	}
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultRange)
+	}
+	q.From, q.To = q.From.UTC(), q.To.UTC()
+	if !q.From.Before(q.To) {
+		return ErrBadClickQuery
+	}
+	return nil
+}
+
+// ClickScanner отдаёт сырые клики по одному в порядке времени, не загружая
+// выборку в память целиком. Ошибка из fn прерывает обход и возвращается.
+type ClickScanner interface {
+	ScanClicks(ctx context.Context, q ClickQuery, fn func(Click) error) error
+}
@@ -0,0 +1,296 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/hll"
+)
+
+const (
+	DimReferrer = "referrer"
+	DimCountry  = "country"
+	DimCity     = "city"
+	DimDevice   = "device"
+	DimOS       = "os"
+	DimBrowser  = "browser"
+
+	Unknown = "unknown"
+	Direct  = "direct"
+
+	DefaultTop   = 10
+	MaxTop       = 100
+	DefaultRange = 7 * 24 * time.Hour
+	MaxRange     = 366 * 24 * time.Hour
+)
+
+var ErrBadRange = errors.New("invalid stats range")
+
+type StatsQuery struct {
+	Domain string // ключ домена, пусто — по умолчанию
+	Code   string
+	From   time.Time
+	To     time.Time
+	Top    int
+	// IncludeBots добавляет клики ботов в счётчики и топы. По умолчанию
+	// считаются только люди; Stats.Bots возвращается всегда.
+	IncludeBots bool
+}
+
+// Normalize подставляет умолчания (последние DefaultRange, DefaultTop) и
+// проверяет интервал.
+func (q *StatsQuery) Normalize(now time.Time) error {
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultRange)
+	}
+	q.From, q.To = q.From.UTC(), q.To.UTC()
+	if !q.From.Before(q.To) || q.To.Sub(q.From) > MaxRange {
+		return ErrBadRange
+	}
+	if q.Top <= 0 {
+		q.Top = DefaultTop
+	}
+	if q.Top > MaxTop {
+		q.Top = MaxTop
+	}
+	return nil
+}
+
+type Point struct {
+	At     time.Time
+	Clicks int64
+}
+
+type Count struct {
+	Value  string
+	Clicks int64
+}
+
+// Stats — агрегаты по ссылке за [From, To]. Total, Daily, Uniques и Top*
+// считаются по суточным роллапам (границы округляются до суток UTC),
+// Hourly — по часовым за точный интервал. Пустые корзины в рядах не
+// возвращаются. Uniques — оценка HyperLogLog, см. пакет hll; боты в неё
+// не входят никогда. Конверсии относятся к суткам постбека, а не клика.
+type Stats struct {
+	Domain       string
+	Code         string
+	Total        int64
+	Bots         int64
+	Uniques      int64
+	Hourly       []Point
+	Daily        []Point
+	DailyUniques []Point
+
+	Conversions      int64
+	ConversionValue  float64
+	DailyConversions []Point
+
+	TopReferrers []Count
+	TopCountries []Count
+	TopCities    []Count
+	TopDevices   []Count
+	TopOS        []Count
+	TopBrowsers  []Count
+}
+
+// SetTop раскладывает суммы по разрезам в соответствующие топы.
+func (st *Stats) SetTop(byDim map[string]map[string]int64, n int) {
+	st.TopReferrers = TopN(byDim[DimReferrer], n)
+	st.TopCountries = TopN(byDim[DimCountry], n)
+	st.TopCities = TopN(byDim[DimCity], n)
+	st.TopDevices = TopN(byDim[DimDevice], n)
+	st.TopOS = TopN(byDim[DimOS], n)
+	st.TopBrowsers = TopN(byDim[DimBrowser], n)
+}
+
+type StatsReader interface {
+	Stats(ctx context.Context, q StatsQuery) (Stats, error)
+}
+
+// Purger удаляет сырые клики и часовые роллапы старше before.
+// Суточные роллапы хранятся бессрочно.
+type Purger interface {
+	PurgeClicks(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Store — хранилище аналитики целиком: запись, чтение, выгрузка и очистка.
+type Store interface {
+	Sink
+	ConversionSink
+	StatsReader
+	ClickScanner
+	Purger
+}
+
+type BucketKey struct {
+	Domain string
+	Code   string
+	At     time.Time
+}
+
+type DimKey struct {
+	Domain    string
+	Code      string
+	Day       time.Time
+	Dimension string
+	Value     string
+}
+
+// Counts — клики людей и ботов в одной корзине роллапа.
+type Counts struct {
+	Humans int64
+	Bots   int64
+}
+
+func (c Counts) Add(o Counts) Counts {
+	return Counts{Humans: c.Humans + o.Humans, Bots: c.Bots + o.Bots}
+}
+
+// Clicks — значение для выдачи: только люди или люди вместе с ботами.
+func (c Counts) Clicks(includeBots bool) int64 {
+	if includeBots {
+		return c.Humans + c.Bots
+	}
+	return c.Humans
+}
+
+func countOf(c Click) Counts {
+	if c.Bot {
+		return Counts{Bots: 1}
+	}
+	return Counts{Humans: 1}
+}
+
+// Rollup — инкременты роллапов для одной пачки кликов. Хранилища
+// прибавляют их к накопленным значениям в той же транзакции, что и
+// вставку сырых событий.
+type Rollup struct {
+	Hourly  map[BucketKey]Counts
+	Daily   map[BucketKey]Counts
+	Dims    map[DimKey]Counts
+	Uniques map[BucketKey]*hll.Sketch // по суткам, только люди; сливаются с хранимыми
+}
+
+func BuildRollup(clicks []Click) Rollup {
+	r := Rollup{
+		Hourly:  make(map[BucketKey]Counts),
+		Daily:   make(map[BucketKey]Counts),
+		Dims:    make(map[DimKey]Counts),
+		Uniques: make(map[BucketKey]*hll.Sketch),
+	}
+	for _, c := range clicks {
+		day := Day(c.At)
+		n := countOf(c)
+		hk, dk := BucketKey{c.Domain, c.Code, Hour(c.At)}, BucketKey{c.Domain, c.Code, day}
+		r.Hourly[hk] = r.Hourly[hk].Add(n)
+		r.Daily[dk] = r.Daily[dk].Add(n)
+		for dim, val := range Dimensions(c) {
+			k := DimKey{c.Domain, c.Code, day, dim, val}
+			r.Dims[k] = r.Dims[k].Add(n)
+		}
+		if c.Visitor != 0 && !c.Bot {
+			k := BucketKey{c.Domain, c.Code, day}
+			if r.Uniques[k] == nil {
+				r.Uniques[k] = hll.New()
+			}
+			r.Uniques[k].Add(c.Visitor)
+		}
+	}
+	return r
+}
+
+type DaySketch struct {
+	Day    time.Time
+	Sketch *hll.Sketch
+}
+
+// Uniques сливает суточные скетчи в оценку за весь интервал и ряд по дням.
+func Uniques(days []DaySketch) (int64, []Point) {
+	sort.Slice(days, func(i, j int) bool { return days[i].Day.Before(days[j].Day) })
+	total := hll.New()
+	series := make([]Point, 0, len(days))
+	for _, d := range days {
+		total.Merge(d.Sketch)
+		series = append(series, Point{At: d.Day, Clicks: int64(d.Sketch.Estimate())})
+	}
+	return int64(total.Estimate()), series
+}
+
+// Dimensions — значения разрезов клика для роллапов.
+func Dimensions(c Click) map[string]string {
+	return map[string]string{
+		DimReferrer: ReferrerHost(c.Referrer),
+		DimCountry:  orUnknown(c.Country),
+		DimCity:     cityOf(c),
+		DimDevice:   orUnknown(c.Device),
+		DimOS:       orUnknown(c.OS),
+		DimBrowser:  orUnknown(c.Browser),
+	}
+}
+
+// ReferrerHost сводит Referer к хосту, чтобы разрез не разрастался от
+// уникальных путей и query.
+func ReferrerHost(ref string) string {
+	if ref == "" {
+		return Direct
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Hostname() == "" {
+		return Unknown
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func Hour(t time.Time) time.Time { return t.UTC().Truncate(time.Hour) }
+
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// TopN возвращает n самых частых значений, при равенстве — по алфавиту.
+// Нулевые значения пропускаются.
+func TopN(counts map[string]int64, n int) []Count {
+	out := make([]Count, 0, len(counts))
+	for v, c := range counts {
+		if c > 0 {
+			out = append(out, Count{Value: v, Clicks: c})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Clicks != out[j].Clicks {
+			return out[i].Clicks > out[j].Clicks
+		}
+		return out[i].Value < out[j].Value
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// cityOf добавляет к городу страну: одноимённые города разных стран не
+// должны складываться.
+func cityOf(c Click) string {
+	if c.City == "" {
+		return Unknown
+	}
+	if c.Country == "" {
+		return c.City
+	}
+	return c.City + ", " + c.Country
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return Unknown
+	}
+	return s
+}
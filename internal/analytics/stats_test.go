@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildRollup(t *testing.T) {
+	at := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	r := BuildRollup([]Click{
+		{Code: "AAAAAAAAAA", At: at, Referrer: "https://Example.com/a?b=c", Visitor: 1},
+		{Code: "AAAAAAAAAA", At: at, Bot: true, Visitor: 2},
+		{Code: "AAAAAAAAAA", At: at.Add(2 * time.Minute), Country: "RU"},
+	})
+
+	if n := r.Hourly[BucketKey{"", "AAAAAAAAAA", Hour(at)}]; n != (Counts{Humans: 1, Bots: 1}) {
+		t.Fatalf("hourly[23:00]=%+v, want 1 human and 1 bot", n)
+	}
+	if n := r.Daily[BucketKey{"", "AAAAAAAAAA", Day(at.Add(2 * time.Minute))}]; n.Humans != 1 {
+		t.Fatalf("daily[next day]=%+v, want 1", n)
+	}
+	if n := r.Dims[DimKey{"", "AAAAAAAAAA", Day(at), DimReferrer, "example.com"}]; n.Humans != 1 {
+		t.Fatalf("referrer host not normalized: %+v", r.Dims)
+	}
+	if n := r.Dims[DimKey{"", "AAAAAAAAAA", Day(at), DimDevice, Unknown}]; n.Humans != 1 {
+		t.Fatalf("empty device must roll up as %q: %+v", Unknown, r.Dims)
+	}
+	if est := r.Uniques[BucketKey{"", "AAAAAAAAAA", Day(at)}].Estimate(); est != 1 {
+		t.Fatalf("bots must not be counted as uniques: %d", est)
+	}
+}
+
+func TestStatsQuery_Normalize(t *testing.T) {
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	q := StatsQuery{Code: "AAAAAAAAAA"}
+	if err := q.Normalize(now); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if !q.To.Equal(now) || q.To.Sub(q.From) != DefaultRange || q.Top != DefaultTop {
+		t.Fatalf("unexpected defaults: %+v", q)
+	}
+
+	bad := StatsQuery{From: now, To: now.Add(-time.Hour)}
+	if err := bad.Normalize(now); err != ErrBadRange {
+		t.Fatalf("reversed range: err=%v, want ErrBadRange", err)
+	}
+}
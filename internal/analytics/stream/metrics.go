@@ -0,0 +1,25 @@
+package stream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	subscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "shortener_stream_subscribers",
+		Help: "Active click stream subscribers on this instance.",
+	})
+	subscribersEvicted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_stream_evicted_total",
+		Help: "Click stream subscribers disconnected for falling behind.",
+	})
+	eventsDelivered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_stream_events_delivered_total",
+		Help: "Click events handed to local subscribers.",
+	})
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_stream_events_dropped_total",
+		Help: "Click events not sent to other instances, by reason (outbox|notify_error).",
+	}, []string{"reason"})
+)
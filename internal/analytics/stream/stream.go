@@ -0,0 +1,263 @@
+// Package stream раздаёт события кликов подписчикам в реальном времени:
+// SSE и gRPC WatchClicks. Hub живёт в процессе; между инстансами события
+// ходят через Remote (Postgres LISTEN/NOTIFY).
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Channel — канал LISTEN/NOTIFY для событий кликов.
+const Channel = "shortener_clicks"
+
+// Event — клик в том виде, в каком он уходит подписчикам. Здесь нет ни IP,
+// ни User-Agent: поток видит любой, кому доступна ссылка.
+type Event struct {
+	Domain   string    `json:"domain,omitempty"` // ключ домена, пусто — по умолчанию
+	Code     string    `json:"code"`
+	At       time.Time `json:"at"`
+	Referrer string    `json:"referrer,omitempty"` // только хост
+	ClickID  string    `json:"click_id,omitempty"`
+}
+
+// Remote доставляет пачку событий другим инстансам.
+type Remote interface {
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+type Options struct {
+	// Buffer — сколько событий может отстать подписчик, прежде чем его
+	// отключат как медленного.
+	Buffer int
+	Remote Remote
+	// Outbox — очередь событий на отправку в Remote; при переполнении
+	// события другим инстансам не доходят.
+	Outbox int
+}
+
+// Hub — in-process pub/sub по ссылке (домен и код). Publish не блокирует никогда:
+// подписчик с полным буфером отключается, а не тормозит редирект.
+type Hub struct {
+	log      *slog.Logger
+	buffer   int
+	remote   Remote
+	outbox   chan Event
+	instance string
+
+	mu     sync.RWMutex
+	subs   map[link]map[*Subscription]struct{}
+	closed bool
+}
+
+// link — ключ подписки: один код на разных доменах — разные ссылки.
+type link struct {
+	domain, code string
+}
+
+func NewHub(log *slog.Logger, opts Options) *Hub {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+	if opts.Outbox <= 0 {
+		opts.Outbox = 4096
+	}
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	h := &Hub{
+		log:      log,
+		buffer:   opts.Buffer,
+		remote:   opts.Remote,
+		instance: hex.EncodeToString(id[:]),
+		subs:     make(map[link]map[*Subscription]struct{}),
+	}
+	if h.remote != nil {
+		h.outbox = make(chan Event, opts.Outbox)
+	}
+	return h
+}
+
+// Subscription — подписка на клики одной ссылки. C закрывается при Close
+// или при отключении медленного подписчика; различить их позволяет Evicted.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	link    link
+	hub     *Hub
+	evicted bool // защищён hub.mu
+}
+
+// Subscribe подписывается на клики ссылки domain (ключ домена) и code.
+func (h *Hub) Subscribe(domain, code string) *Subscription {
+	ch := make(chan Event, h.buffer)
+	k := link{domain, code}
+	s := &Subscription{C: ch, ch: ch, link: k, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return s
+	}
+	if h.subs[k] == nil {
+		h.subs[k] = make(map[*Subscription]struct{})
+	}
+	h.subs[k][s] = struct{}{}
+	subscribers.Inc()
+	return s
+}
+
+// Close отписывается; повторный вызов безопасен.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (s *Subscription) Evicted() bool {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+	return s.evicted
+}
+
+// Close закрывает все подписки, чтобы SSE и WatchClicks завершились до
+// остановки серверов; новые подписки после этого сразу закрыты.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, set := range h.subs {
+		for s := range set {
+			h.remove(s)
+		}
+	}
+}
+
+// remove вызывается под h.mu на запись: отправки идут под чтением, так что
+// закрытие канала с ними не пересекается.
+func (h *Hub) remove(s *Subscription) bool {
+	set := h.subs[s.link]
+	if _, ok := set[s]; !ok {
+		return false
+	}
+	delete(set, s)
+	if len(set) == 0 {
+		delete(h.subs, s.link)
+	}
+	close(s.ch)
+	subscribers.Dec()
+	return true
+}
+
+// Publish раздаёт событие локальным подписчикам и ставит его в очередь
+// другим инстансам.
+func (h *Hub) Publish(e Event) {
+	h.deliver(e)
+	if h.outbox == nil {
+		return
+	}
+	select {
+	case h.outbox <- e:
+	default:
+		eventsDropped.WithLabelValues("outbox").Inc()
+	}
+}
+
+func (h *Hub) deliver(e Event) {
+	var slow []*Subscription
+	h.mu.RLock()
+	for s := range h.subs[link{e.Domain, e.Code}] {
+		select {
+		case s.ch <- e:
+			eventsDelivered.Inc()
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range slow {
+		if h.remove(s) {
+			s.evicted = true
+			subscribersEvicted.Inc()
+		}
+	}
+}
+
+// wire — пачка событий в NOTIFY. Instance отсекает собственные события:
+// локальным подписчикам они уже доставлены.
+type wire struct {
+	Instance string  `json:"i"`
+	Events   []Event `json:"e"`
+}
+
+// maxPayload — с запасом меньше лимита NOTIFY в 8000 байт.
+const maxPayload = 7000
+
+// Receive принимает payload из Remote и раздаёт события локально.
+func (h *Hub) Receive(payload string) {
+	var w wire
+	if err := json.Unmarshal([]byte(payload), &w); err != nil {
+		h.log.Warn("bad stream payload", "err", err)
+		return
+	}
+	if w.Instance == h.instance {
+		return
+	}
+	for _, e := range w.Events {
+		h.deliver(e)
+	}
+}
+
+// Run отправляет очередь в Remote пачками не реже чем раз в every и
+// возвращается после отмены ctx. Без Remote сразу возвращается.
+func (h *Hub) Run(ctx context.Context, every time.Duration) {
+	if h.remote == nil {
+		return
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	var batch []Event
+	size := 0
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b, _ := json.Marshal(wire{Instance: h.instance, Events: batch})
+		nctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := h.remote.Notify(nctx, Channel, string(b)); err != nil {
+			h.log.Error("stream notify failed", "n", len(batch), "err", err)
+			eventsDropped.WithLabelValues("notify_error").Add(float64(len(batch)))
+		}
+		batch, size = batch[:0], 0
+	}
+	for {
+		select {
+		case e := <-h.outbox:
+			b, _ := json.Marshal(e)
+			if size+len(b) > maxPayload {
+				flush()
+			}
+			batch = append(batch, e)
+			size += len(b) + 1
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			flush()
+			return
+		}
+	}
+}
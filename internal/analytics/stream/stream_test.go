@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestHub_DeliversByCode(t *testing.T) {
+	h := NewHub(testLog, Options{Buffer: 4})
+	a, b := h.Subscribe("", "AAAAAAAAAA"), h.Subscribe("", "BBBBBBBBBB")
+	other := h.Subscribe("go.acme.com", "AAAAAAAAAA") // тот же код на другом домене
+	defer a.Close()
+	defer b.Close()
+	defer other.Close()
+
+	h.Publish(Event{Code: "AAAAAAAAAA", Referrer: "t.me"})
+	select {
+	case e := <-a.C:
+		if e.Referrer != "t.me" {
+			t.Fatalf("event=%+v", e)
+		}
+	default:
+		t.Fatal("subscriber of the code got nothing")
+	}
+	select {
+	case e := <-b.C:
+		t.Fatalf("other code got %+v", e)
+	case e := <-other.C:
+		t.Fatalf("same code on another domain got %+v", e)
+	default:
+	}
+}
+
+func TestHub_EvictsSlowConsumer(t *testing.T) {
+	h := NewHub(testLog, Options{Buffer: 2})
+	slow, fast := h.Subscribe("", "AAAAAAAAAA"), h.Subscribe("", "AAAAAAAAAA")
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		h.Publish(Event{Code: "AAAAAAAAAA"})
+		<-fast.C
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != 2 || !slow.Evicted() {
+		t.Fatalf("slow got %d events, evicted=%v; want 2 and true", n, slow.Evicted())
+	}
+	if fast.Evicted() {
+		t.Fatal("fast consumer must stay subscribed")
+	}
+	slow.Close() // повторное закрытие безопасно
+}
+
+// loopback — Remote, который раздаёт payload всем хабам, как NOTIFY.
+type loopback struct {
+	mu   sync.Mutex
+	hubs []*Hub
+}
+
+func (l *loopback) Notify(ctx context.Context, channel, payload string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, h := range l.hubs {
+		h.Receive(payload)
+	}
+	return nil
+}
+
+func TestHub_FanOutAcrossInstances(t *testing.T) {
+	remote := &loopback{}
+	a := NewHub(testLog, Options{Remote: remote})
+	b := NewHub(testLog, Options{Remote: remote})
+	remote.hubs = []*Hub{a, b}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx, 5*time.Millisecond)
+
+	local, peer := a.Subscribe("", "AAAAAAAAAA"), b.Subscribe("", "AAAAAAAAAA")
+	a.Publish(Event{Code: "AAAAAAAAAA", ClickID: "x"})
+
+	select {
+	case e := <-peer.C:
+		if e.ClickID != "x" {
+			t.Fatalf("peer event=%+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event did not reach the other instance")
+	}
+	<-local.C
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case e := <-local.C:
+		t.Fatalf("own event delivered twice: %+v", e)
+	default:
+	}
+}
+
+func TestHub_CloseEndsSubscriptions(t *testing.T) {
+	h := NewHub(testLog, Options{})
+	s := h.Subscribe("", "AAAAAAAAAA")
+	h.Close()
+	if _, ok := <-s.C; ok || s.Evicted() {
+		t.Fatal("subscription must be closed without eviction")
+	}
+	if _, ok := <-h.Subscribe("", "AAAAAAAAAA").C; ok {
+		t.Fatal("subscribe after Close must return a closed subscription")
+	}
+}
@@ -0,0 +1,32 @@
+package topk
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var hotLinks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "shortener_hot_link_clicks",
+	Help: "Estimated clicks of the hottest links over the metrics window; domain is empty for the default one.",
+}, []string{"domain", "code"})
+
+// RunMetrics раз в every выгружает топ-k за window в Prometheus. Серии
+// выпавших из топа кодов удаляются, чтобы кардинальность оставалась <= k.
+func (t *Tracker) RunMetrics(ctx context.Context, window time.Duration, k int, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hotLinks.Reset()
+			for _, e := range t.Top(window, k) {
+				hotLinks.WithLabelValues(e.Domain, e.Code).Set(float64(e.Clicks))
+			}
+		}
+	}
+}
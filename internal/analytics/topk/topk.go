@@ -0,0 +1,230 @@
+// Package topk находит самые «горячие» ссылки (домен и код) за скользящее
+// окно.
+//
+// Окно разбито на слоты по минуте. В каждом слоте — Count-Min Sketch
+// (оценка частоты, только завышает, не больше чем на ε·N с ε ≈ e/width)
+// и min-heap кандидатов, где держатся коды с наибольшими оценками. Запрос
+// за окно объединяет кандидатов нужных слотов и суммирует их оценки.
+// Observe стоит O(depth + log capacity) и не аллоцирует для уже известных
+// кодов, поэтому вызывается прямо из редиректа.
+package topk
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cmsWidth = 2048
+	cmsDepth = 4
+)
+
+type Entry struct {
+	Domain string // ключ домена, пусто — по умолчанию
+	Code   string
+	Clicks uint64
+}
+
+// linkKey склеивает домен и код в ключ скетча; "/" не встречается ни в
+// имени хоста, ни в коде.
+func linkKey(domain, code string) string {
+	return domain + "/" + code
+}
+
+type Tracker struct {
+	mu       sync.Mutex
+	slot     time.Duration
+	slots    []*bucket
+	capacity int
+	seed     maphash.Seed
+	now      func() time.Time
+}
+
+// New: slot — ширина слота, n — число слотов (максимальное окно slot*n),
+// capacity — сколько кандидатов держать в слоте.
+func New(slot time.Duration, n, capacity int) *Tracker {
+	t := &Tracker{
+		slot:     slot,
+		slots:    make([]*bucket, n),
+		capacity: capacity,
+		seed:     maphash.MakeSeed(),
+		now:      time.Now,
+	}
+	for i := range t.slots {
+		t.slots[i] = newBucket()
+	}
+	return t
+}
+
+func (t *Tracker) MaxWindow() time.Duration { return t.slot * time.Duration(len(t.slots)) }
+
+func (t *Tracker) Observe(domain, code string) {
+	key := linkKey(domain, code)
+	h1, h2 := t.hashes(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.current()
+	est := b.cms.add(h1, h2)
+	b.offer(key, est, t.capacity)
+}
+
+// Top возвращает до k ссылок с наибольшей оценкой кликов за window.
+func (t *Tracker) Top(window time.Duration, k int) []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := int((window + t.slot - 1) / t.slot)
+	if n > len(t.slots) {
+		n = len(t.slots)
+	}
+	start := t.now().Truncate(t.slot)
+	var live []*bucket
+	for i := 0; i < n; i++ {
+		want := start.Add(-time.Duration(i) * t.slot)
+		if b := t.slots[t.index(want)]; b.start.Equal(want) {
+			live = append(live, b)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	var out []Entry
+	for _, b := range live {
+		for _, c := range b.cand {
+			if _, ok := seen[c.code]; ok {
+				continue
+			}
+			seen[c.code] = struct{}{}
+			h1, h2 := t.hashes(c.code)
+			var sum uint64
+			for _, lb := range live {
+				sum += uint64(lb.cms.estimate(h1, h2))
+			}
+			domain, code, _ := strings.Cut(c.code, "/")
+			out = append(out, Entry{Domain: domain, Code: code, Clicks: sum})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Clicks != out[j].Clicks {
+			return out[i].Clicks > out[j].Clicks
+		}
+		if out[i].Code != out[j].Code {
+			return out[i].Code < out[j].Code
+		}
+		return out[i].Domain < out[j].Domain
+	})
+	if k > 0 && len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
+
+func (t *Tracker) current() *bucket {
+	start := t.now().Truncate(t.slot)
+	b := t.slots[t.index(start)]
+	if !b.start.Equal(start) {
+		b.reset(start)
+	}
+	return b
+}
+
+func (t *Tracker) index(start time.Time) int {
+	return int((start.UnixNano() / int64(t.slot)) % int64(len(t.slots)))
+}
+
+// hashes — два независимых хеша для двойного хеширования в CMS.
+func (t *Tracker) hashes(code string) (uint64, uint64) {
+	h := maphash.String(t.seed, code)
+	return h, h>>32 | h<<32 | 1
+}
+
+type bucket struct {
+	start time.Time
+	cms   countMin
+	cand  candidates
+	index map[string]int
+}
+
+func newBucket() *bucket {
+	return &bucket{index: make(map[string]int)}
+}
+
+func (b *bucket) reset(start time.Time) {
+	b.start = start
+	b.cms = countMin{}
+	b.cand = b.cand[:0]
+	clear(b.index)
+}
+
+func (b *bucket) offer(code string, est uint32, capacity int) {
+	if i, ok := b.index[code]; ok {
+		b.cand[i].count = est
+		heap.Fix(b, i)
+		return
+	}
+	if len(b.cand) < capacity {
+		heap.Push(b, candidate{code: code, count: est})
+		return
+	}
+	if est > b.cand[0].count {
+		delete(b.index, b.cand[0].code)
+		b.cand[0] = candidate{code: code, count: est}
+		b.index[code] = 0
+		heap.Fix(b, 0)
+	}
+}
+
+// heap.Interface поверх кандидатов с поддержкой индекса code -> позиция.
+func (b *bucket) Len() int           { return len(b.cand) }
+func (b *bucket) Less(i, j int) bool { return b.cand[i].count < b.cand[j].count }
+func (b *bucket) Swap(i, j int) {
+	b.cand[i], b.cand[j] = b.cand[j], b.cand[i]
+	b.index[b.cand[i].code] = i
+	b.index[b.cand[j].code] = j
+}
+func (b *bucket) Push(x any) {
+	c := x.(candidate)
+	b.index[c.code] = len(b.cand)
+	b.cand = append(b.cand, c)
+}
+func (b *bucket) Pop() any {
+	c := b.cand[len(b.cand)-1]
+	b.cand = b.cand[:len(b.cand)-1]
+	delete(b.index, c.code)
+	return c
+}
+
+type candidate struct {
+	code  string
+	count uint32
+}
+
+type candidates []candidate
+
+type countMin [cmsDepth][cmsWidth]uint32
+
+func (c *countMin) add(h1, h2 uint64) uint32 {
+	min := ^uint32(0)
+	for i := range c {
+		j := (h1 + uint64(i)*h2) % cmsWidth
+		c[i][j]++
+		if c[i][j] < min {
+			min = c[i][j]
+		}
+	}
+	return min
+}
+
+func (c *countMin) estimate(h1, h2 uint64) uint32 {
+	min := ^uint32(0)
+	for i := range c {
+		if v := c[i][(h1+uint64(i)*h2)%cmsWidth]; v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,65 @@
+package topk
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestTracker(c *clock) *Tracker {
+	t := New(time.Minute, 60, 20)
+	t.now = c.now
+	return t
+}
+
+func TestTop_FindsHeavyHittersAmongNoise(t *testing.T) {
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	tr := newTestTracker(c)
+
+	for i := 0; i < 5000; i++ {
+		tr.Observe("", fmt.Sprintf("noise%05d", i))
+		if i%5 == 0 {
+			tr.Observe("", "VIRAL00001")
+		}
+		if i%10 == 0 {
+			tr.Observe("", "VIRAL00002")
+		}
+	}
+
+	top := tr.Top(5*time.Minute, 2)
+	if len(top) != 2 || top[0].Code != "VIRAL00001" || top[1].Code != "VIRAL00002" {
+		t.Fatalf("unexpected top: %+v", top)
+	}
+	if top[0].Clicks < 1000 {
+		t.Fatalf("count-min must not underestimate: %+v", top[0])
+	}
+}
+
+func TestTop_SlidingWindow(t *testing.T) {
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	tr := newTestTracker(c)
+
+	for i := 0; i < 10; i++ {
+		tr.Observe("", "OLDHOT0001")
+	}
+	c.t = c.t.Add(3 * time.Minute)
+	tr.Observe("", "NEWHOT0001")
+	tr.Observe("go.acme.com", "NEWHOT0001") // другая ссылка с тем же кодом
+
+	if top := tr.Top(5*time.Minute, 10); len(top) != 3 || top[0].Code != "OLDHOT0001" || top[0].Clicks != 10 {
+		t.Fatalf("5m window: %+v", top)
+	}
+	want := []Entry{{Code: "NEWHOT0001", Clicks: 1}, {Domain: "go.acme.com", Code: "NEWHOT0001", Clicks: 1}}
+	if top := tr.Top(time.Minute, 10); len(top) != 2 || top[0] != want[0] || top[1] != want[1] {
+		t.Fatalf("1m window: %+v", top)
+	}
+
+	c.t = c.t.Add(2 * time.Hour)
+	if top := tr.Top(time.Hour, 10); len(top) != 0 {
+		t.Fatalf("expired slots must not be counted: %+v", top)
+	}
+}
@@ -0,0 +1,117 @@
+// Package useragent классифицирует клики по User-Agent: устройство, ОС,
+// браузер и бот/человек. Правила лежат в rules.txt (встроен в бинарник) и
+// могут быть заменены файлом через UA_RULES_FILE.
+package useragent
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+//go:embed rules.txt
+var defaultRules string
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+type Class struct {
+	Device  string
+	OS      string
+	Browser string
+	Bot     bool
+}
+
+type rule struct {
+	value, pattern string
+}
+
+type Classifier struct {
+	bots, devices, oses, browsers []rule
+}
+
+// Default — классификатор со встроенными правилами.
+func Default() *Classifier {
+	c, err := Parse(strings.NewReader(defaultRules))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func Load(path string) (*Classifier, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Classifier, error) {
+	c := &Classifier{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("useragent rules line %d: want <kind> <value> <pattern>", n)
+		}
+		ru := rule{value: fields[1], pattern: strings.ToLower(strings.Join(fields[2:], " "))}
+		switch fields[0] {
+		case "bot":
+			c.bots = append(c.bots, ru)
+		case "device":
+			c.devices = append(c.devices, ru)
+		case "os":
+			c.oses = append(c.oses, ru)
+		case "browser":
+			c.browsers = append(c.browsers, ru)
+		default:
+			return nil, fmt.Errorf("useragent rules line %d: unknown kind %q", n, fields[0])
+		}
+	}
+	return c, sc.Err()
+}
+
+func (c *Classifier) Classify(ua string) Class {
+	if ua == "" {
+		return Class{Device: analytics.Unknown, OS: analytics.Unknown, Browser: analytics.Unknown}
+	}
+	ua = strings.ToLower(ua)
+	if bot := match(c.bots, ua, ""); bot != "" {
+		return Class{Device: DeviceBot, OS: analytics.Unknown, Browser: bot, Bot: true}
+	}
+	return Class{
+		Device:  match(c.devices, ua, DeviceDesktop),
+		OS:      match(c.oses, ua, analytics.Unknown),
+		Browser: match(c.browsers, ua, analytics.Unknown),
+	}
+}
+
+// Enrich реализует analytics.Enricher.
+func (c *Classifier) Enrich(click *analytics.Click) {
+	cl := c.Classify(click.UserAgent)
+	click.Device, click.OS, click.Browser, click.Bot = cl.Device, cl.OS, cl.Browser, cl.Bot
+}
+
+func match(rules []rule, ua, def string) string {
+	for _, r := range rules {
+		if strings.Contains(ua, r.pattern) {
+			return r.value
+		}
+	}
+	return def
+}
@@ -0,0 +1,51 @@
+package useragent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		name string
+		ua   string
+		want Class
+	}{
+		{"chrome_windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Class{Device: DeviceDesktop, OS: "Windows", Browser: "Chrome"}},
+		{"safari_iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			Class{Device: DeviceMobile, OS: "iOS", Browser: "Safari"}},
+		{"edge_not_chrome",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			Class{Device: DeviceDesktop, OS: "Windows", Browser: "Edge"}},
+		{"ipad_tablet",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			Class{Device: DeviceTablet, OS: "iOS", Browser: "Safari"}},
+		{"slack_preview",
+			"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			Class{Device: DeviceBot, OS: "unknown", Browser: "Slack", Bot: true}},
+		{"telegram_preview",
+			"TelegramBot (like TwitterBot)",
+			Class{Device: DeviceBot, OS: "unknown", Browser: "Telegram", Bot: true}},
+		{"empty", "", Class{Device: "unknown", OS: "unknown", Browser: "unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(tt.ua); got != tt.want {
+				t.Fatalf("Classify()=%+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_RejectsBadLines(t *testing.T) {
+	if _, err := Parse(strings.NewReader("bot Slack\n")); err == nil {
+		t.Fatalf("expected error for a line without pattern")
+	}
+	if _, err := Parse(strings.NewReader("phone mobile android\n")); err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+}
@@ -0,0 +1,20 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// VisitorHash — приватный идентификатор посетителя для подсчёта уникальных:
+// HMAC-SHA256(salt, IP | User-Agent). Без соли по нему нельзя подобрать
+// IP. Хеш не зависит от дня, чтобы вернувшийся посетитель считался за
+// диапазон один раз; визиты перестают связываться только при смене соли.
+// Соль должна совпадать на всех инстансах, иначе скетчи не сольются.
+func VisitorHash(salt []byte, c Click) uint64 {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(c.IP))
+	mac.Write([]byte{0})
+	mac.Write([]byte(c.UserAgent))
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+}
@@ -9,6 +9,7 @@ package shortenerv1
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -23,8 +24,12 @@ const (
 
 // Запрос на сокращение
 type ShortenRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"` // исходный URL
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Url   string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"` // исходный URL
+	// имя query-параметра для click id; пусто — не добавлять
+	ClickIdParam string `protobuf:"bytes,2,opt,name=click_id_param,json=clickIdParam,proto3" json:"click_id_param,omitempty"`
+	// домен короткой ссылки; пусто — домен по умолчанию
+	Domain        string `protobuf:"bytes,3,opt,name=domain,proto3" json:"domain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -66,10 +71,27 @@ func (x *ShortenRequest) GetUrl() string {
 	return ""
 }
 
+func (x *ShortenRequest) GetClickIdParam() string {
+	if x != nil {
+		return x.ClickIdParam
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
 // Ответ на сокращение
 type ShortenResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // сгенерированный код
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Code   string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`     // сгенерированный код
+	Domain string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"` // домен ссылки; пусто, если домены не настроены
+	// полная короткая ссылка; пусто, если не задан PUBLIC_BASE_URL
+	ShortUrl      string `protobuf:"bytes,3,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -111,10 +133,25 @@ func (x *ShortenResponse) GetCode() string {
 	return ""
 }
 
+func (x *ShortenResponse) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ShortenResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
 // Запрос на получение оригинала
 type ResolveRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // короткий код
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`     // короткий код
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"` // пусто — домен по умолчанию
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -156,6 +193,13 @@ func (x *ResolveRequest) GetCode() string {
 	return ""
 }
 
+func (x *ResolveRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
 // Ответ с оригинальной ссылкой
 type ResolveResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -201,22 +245,741 @@ func (x *ResolveResponse) GetUrl() string {
 	return ""
 }
 
+// Запрос статистики; без from/to берутся последние 7 дней
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Top           int32                  `protobuf:"varint,4,opt,name=top,proto3" json:"top,omitempty"`                                    // размер топов по разрезам, по умолчанию 10
+	IncludeBots   bool                   `protobuf:"varint,5,opt,name=include_bots,json=includeBots,proto3" json:"include_bots,omitempty"` // по умолчанию боты исключены из счётчиков
+	Domain        string                 `protobuf:"bytes,6,opt,name=domain,proto3" json:"domain,omitempty"`                               // пусто — домен по умолчанию
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetStatsRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetStatsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetStatsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetStatsRequest) GetTop() int32 {
+	if x != nil {
+		return x.Top
+	}
+	return 0
+}
+
+func (x *GetStatsRequest) GetIncludeBots() bool {
+	if x != nil {
+		return x.IncludeBots
+	}
+	return false
+}
+
+func (x *GetStatsRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+// Точка временного ряда
+type StatsPoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsPoint) Reset() {
+	*x = StatsPoint{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsPoint) ProtoMessage() {}
+
+func (x *StatsPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsPoint.ProtoReflect.Descriptor instead.
+func (*StatsPoint) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StatsPoint) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *StatsPoint) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+// Значение разреза и число кликов
+type DimensionCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DimensionCount) Reset() {
+	*x = DimensionCount{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DimensionCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DimensionCount) ProtoMessage() {}
+
+func (x *DimensionCount) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DimensionCount.ProtoReflect.Descriptor instead.
+func (*DimensionCount) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DimensionCount) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *DimensionCount) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+// Статистика по коду
+type GetStatsResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Code         string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Total        int64                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Hourly       []*StatsPoint          `protobuf:"bytes,3,rep,name=hourly,proto3" json:"hourly,omitempty"`
+	Daily        []*StatsPoint          `protobuf:"bytes,4,rep,name=daily,proto3" json:"daily,omitempty"`
+	TopReferrers []*DimensionCount      `protobuf:"bytes,5,rep,name=top_referrers,json=topReferrers,proto3" json:"top_referrers,omitempty"`
+	TopCountries []*DimensionCount      `protobuf:"bytes,6,rep,name=top_countries,json=topCountries,proto3" json:"top_countries,omitempty"`
+	TopDevices   []*DimensionCount      `protobuf:"bytes,7,rep,name=top_devices,json=topDevices,proto3" json:"top_devices,omitempty"`
+	// оценка уникальных посетителей (HyperLogLog, стандартная ошибка ~1.6%)
+	Uniques          int64             `protobuf:"varint,8,opt,name=uniques,proto3" json:"uniques,omitempty"`
+	DailyUniques     []*StatsPoint     `protobuf:"bytes,9,rep,name=daily_uniques,json=dailyUniques,proto3" json:"daily_uniques,omitempty"`
+	Bots             int64             `protobuf:"varint,10,opt,name=bots,proto3" json:"bots,omitempty"` // клики ботов за интервал
+	TopOs            []*DimensionCount `protobuf:"bytes,11,rep,name=top_os,json=topOs,proto3" json:"top_os,omitempty"`
+	TopBrowsers      []*DimensionCount `protobuf:"bytes,12,rep,name=top_browsers,json=topBrowsers,proto3" json:"top_browsers,omitempty"`
+	TopCities        []*DimensionCount `protobuf:"bytes,13,rep,name=top_cities,json=topCities,proto3" json:"top_cities,omitempty"` // "Город, страна"
+	Conversions      int64             `protobuf:"varint,14,opt,name=conversions,proto3" json:"conversions,omitempty"`             // постбеки за интервал, по суткам постбека
+	ConversionValue  float64           `protobuf:"fixed64,15,opt,name=conversion_value,json=conversionValue,proto3" json:"conversion_value,omitempty"`
+	DailyConversions []*StatsPoint     `protobuf:"bytes,16,rep,name=daily_conversions,json=dailyConversions,proto3" json:"daily_conversions,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetStatsResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetStatsResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetHourly() []*StatsPoint {
+	if x != nil {
+		return x.Hourly
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetDaily() []*StatsPoint {
+	if x != nil {
+		return x.Daily
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopReferrers() []*DimensionCount {
+	if x != nil {
+		return x.TopReferrers
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopCountries() []*DimensionCount {
+	if x != nil {
+		return x.TopCountries
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopDevices() []*DimensionCount {
+	if x != nil {
+		return x.TopDevices
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetUniques() int64 {
+	if x != nil {
+		return x.Uniques
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetDailyUniques() []*StatsPoint {
+	if x != nil {
+		return x.DailyUniques
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetBots() int64 {
+	if x != nil {
+		return x.Bots
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetTopOs() []*DimensionCount {
+	if x != nil {
+		return x.TopOs
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopBrowsers() []*DimensionCount {
+	if x != nil {
+		return x.TopBrowsers
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopCities() []*DimensionCount {
+	if x != nil {
+		return x.TopCities
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetConversions() int64 {
+	if x != nil {
+		return x.Conversions
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetConversionValue() float64 {
+	if x != nil {
+		return x.ConversionValue
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetDailyConversions() []*StatsPoint {
+	if x != nil {
+		return x.DailyConversions
+	}
+	return nil
+}
+
+// Подписка на клики по коду
+type WatchClicksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"` // пусто — домен по умолчанию
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchClicksRequest) Reset() {
+	*x = WatchClicksRequest{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchClicksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchClicksRequest) ProtoMessage() {}
+
+func (x *WatchClicksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchClicksRequest.ProtoReflect.Descriptor instead.
+func (*WatchClicksRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchClicksRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *WatchClicksRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+// Клик в реальном времени; без IP и User-Agent
+type ClickEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	Referrer      string                 `protobuf:"bytes,3,opt,name=referrer,proto3" json:"referrer,omitempty"` // хост
+	ClickId       string                 `protobuf:"bytes,4,opt,name=click_id,json=clickId,proto3" json:"click_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickEvent) Reset() {
+	*x = ClickEvent{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickEvent) ProtoMessage() {}
+
+func (x *ClickEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickEvent.ProtoReflect.Descriptor instead.
+func (*ClickEvent) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ClickEvent) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *ClickEvent) GetReferrer() string {
+	if x != nil {
+		return x.Referrer
+	}
+	return ""
+}
+
+func (x *ClickEvent) GetClickId() string {
+	if x != nil {
+		return x.ClickId
+	}
+	return ""
+}
+
+// Запрос QR-кода; пустые поля — значения по умолчанию
+type GetQRCodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`         // пусто — домен по умолчанию
+	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`         // png (по умолчанию) | svg
+	Size          int32                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`            // сторона в пикселях, 64..2048, по умолчанию 256
+	Margin        *int32                 `protobuf:"varint,5,opt,name=margin,proto3,oneof" json:"margin,omitempty"`  // рамка в модулях, 0..16, по умолчанию 4
+	Ecc           string                 `protobuf:"bytes,6,opt,name=ecc,proto3" json:"ecc,omitempty"`               // коррекция ошибок L|M|Q|H, по умолчанию M
+	Foreground    string                 `protobuf:"bytes,7,opt,name=foreground,proto3" json:"foreground,omitempty"` // hex RRGGBB[AA], по умолчанию 000000
+	Background    string                 `protobuf:"bytes,8,opt,name=background,proto3" json:"background,omitempty"` // hex RRGGBB[AA] или transparent, по умолчанию ffffff
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQRCodeRequest) Reset() {
+	*x = GetQRCodeRequest{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQRCodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQRCodeRequest) ProtoMessage() {}
+
+func (x *GetQRCodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQRCodeRequest.ProtoReflect.Descriptor instead.
+func (*GetQRCodeRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetQRCodeRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *GetQRCodeRequest) GetMargin() int32 {
+	if x != nil && x.Margin != nil {
+		return *x.Margin
+	}
+	return 0
+}
+
+func (x *GetQRCodeRequest) GetEcc() string {
+	if x != nil {
+		return x.Ecc
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetForeground() string {
+	if x != nil {
+		return x.Foreground
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetBackground() string {
+	if x != nil {
+		return x.Background
+	}
+	return ""
+}
+
+// Картинка QR-кода
+type GetQRCodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         []byte                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // image/png | image/svg+xml
+	ShortUrl      string                 `protobuf:"bytes,3,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`          // закодированная ссылка
+	Etag          string                 `protobuf:"bytes,4,opt,name=etag,proto3" json:"etag,omitempty"`                                  // не меняется, пока не меняются ссылка и опции
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQRCodeResponse) Reset() {
+	*x = GetQRCodeResponse{}
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQRCodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQRCodeResponse) ProtoMessage() {}
+
+func (x *GetQRCodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_shortener_v1_shortener_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQRCodeResponse.ProtoReflect.Descriptor instead.
+func (*GetQRCodeResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_shortener_v1_shortener_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetQRCodeResponse) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *GetQRCodeResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetQRCodeResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *GetQRCodeResponse) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
 var File_internal_api_shortener_v1_shortener_proto protoreflect.FileDescriptor
 
 const file_internal_api_shortener_v1_shortener_proto_rawDesc = "" +
 	"\n" +
-	")internal/api/shortener/v1/shortener.proto\x12\fshortener.v1\"\"\n" +
+	")internal/api/shortener/v1/shortener.proto\x12\fshortener.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"`\n" +
 	"\x0eShortenRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"%\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12$\n" +
+	"\x0eclick_id_param\x18\x02 \x01(\tR\fclickIdParam\x12\x16\n" +
+	"\x06domain\x18\x03 \x01(\tR\x06domain\"Z\n" +
 	"\x0fShortenResponse\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\"$\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x1b\n" +
+	"\tshort_url\x18\x03 \x01(\tR\bshortUrl\"<\n" +
 	"\x0eResolveRequest\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\"#\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\"#\n" +
 	"\x0fResolveResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url2\x9b\x01\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\"\xce\x01\n" +
+	"\x0fGetStatsRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x10\n" +
+	"\x03top\x18\x04 \x01(\x05R\x03top\x12!\n" +
+	"\finclude_bots\x18\x05 \x01(\bR\vincludeBots\x12\x16\n" +
+	"\x06domain\x18\x06 \x01(\tR\x06domain\"P\n" +
+	"\n" +
+	"StatsPoint\x12*\n" +
+	"\x02at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\">\n" +
+	"\x0eDimensionCount\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"\x97\x06\n" +
+	"\x10GetStatsResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x120\n" +
+	"\x06hourly\x18\x03 \x03(\v2\x18.shortener.v1.StatsPointR\x06hourly\x12.\n" +
+	"\x05daily\x18\x04 \x03(\v2\x18.shortener.v1.StatsPointR\x05daily\x12A\n" +
+	"\rtop_referrers\x18\x05 \x03(\v2\x1c.shortener.v1.DimensionCountR\ftopReferrers\x12A\n" +
+	"\rtop_countries\x18\x06 \x03(\v2\x1c.shortener.v1.DimensionCountR\ftopCountries\x12=\n" +
+	"\vtop_devices\x18\a \x03(\v2\x1c.shortener.v1.DimensionCountR\n" +
+	"topDevices\x12\x18\n" +
+	"\auniques\x18\b \x01(\x03R\auniques\x12=\n" +
+	"\rdaily_uniques\x18\t \x03(\v2\x18.shortener.v1.StatsPointR\fdailyUniques\x12\x12\n" +
+	"\x04bots\x18\n" +
+	" \x01(\x03R\x04bots\x123\n" +
+	"\x06top_os\x18\v \x03(\v2\x1c.shortener.v1.DimensionCountR\x05topOs\x12?\n" +
+	"\ftop_browsers\x18\f \x03(\v2\x1c.shortener.v1.DimensionCountR\vtopBrowsers\x12;\n" +
+	"\n" +
+	"top_cities\x18\r \x03(\v2\x1c.shortener.v1.DimensionCountR\ttopCities\x12 \n" +
+	"\vconversions\x18\x0e \x01(\x03R\vconversions\x12)\n" +
+	"\x10conversion_value\x18\x0f \x01(\x01R\x0fconversionValue\x12E\n" +
+	"\x11daily_conversions\x18\x10 \x03(\v2\x18.shortener.v1.StatsPointR\x10dailyConversions\"@\n" +
+	"\x12WatchClicksRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\"\x83\x01\n" +
+	"\n" +
+	"ClickEvent\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12\x1a\n" +
+	"\breferrer\x18\x03 \x01(\tR\breferrer\x12\x19\n" +
+	"\bclick_id\x18\x04 \x01(\tR\aclickId\"\xe4\x01\n" +
+	"\x10GetQRCodeRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x05R\x04size\x12\x1b\n" +
+	"\x06margin\x18\x05 \x01(\x05H\x00R\x06margin\x88\x01\x01\x12\x10\n" +
+	"\x03ecc\x18\x06 \x01(\tR\x03ecc\x12\x1e\n" +
+	"\n" +
+	"foreground\x18\a \x01(\tR\n" +
+	"foreground\x12\x1e\n" +
+	"\n" +
+	"background\x18\b \x01(\tR\n" +
+	"backgroundB\t\n" +
+	"\a_margin\"}\n" +
+	"\x11GetQRCodeResponse\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\fR\x05image\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x1b\n" +
+	"\tshort_url\x18\x03 \x01(\tR\bshortUrl\x12\x12\n" +
+	"\x04etag\x18\x04 \x01(\tR\x04etag2\x81\x03\n" +
 	"\tShortener\x12F\n" +
 	"\aShorten\x12\x1c.shortener.v1.ShortenRequest\x1a\x1d.shortener.v1.ShortenResponse\x12F\n" +
-	"\aResolve\x12\x1c.shortener.v1.ResolveRequest\x1a\x1d.shortener.v1.ResolveResponseBMZKgithub.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1;shortenerv1b\x06proto3"
+	"\aResolve\x12\x1c.shortener.v1.ResolveRequest\x1a\x1d.shortener.v1.ResolveResponse\x12I\n" +
+	"\bGetStats\x12\x1d.shortener.v1.GetStatsRequest\x1a\x1e.shortener.v1.GetStatsResponse\x12K\n" +
+	"\vWatchClicks\x12 .shortener.v1.WatchClicksRequest\x1a\x18.shortener.v1.ClickEvent0\x01\x12L\n" +
+	"\tGetQRCode\x12\x1e.shortener.v1.GetQRCodeRequest\x1a\x1f.shortener.v1.GetQRCodeResponseBMZKgithub.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1;shortenerv1b\x06proto3"
 
 var (
 	file_internal_api_shortener_v1_shortener_proto_rawDescOnce sync.Once
@@ -230,23 +993,52 @@ func file_internal_api_shortener_v1_shortener_proto_rawDescGZIP() []byte {
 	return file_internal_api_shortener_v1_shortener_proto_rawDescData
 }
 
-var file_internal_api_shortener_v1_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_internal_api_shortener_v1_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_internal_api_shortener_v1_shortener_proto_goTypes = []any{
-	(*ShortenRequest)(nil),  // 0: shortener.v1.ShortenRequest
-	(*ShortenResponse)(nil), // 1: shortener.v1.ShortenResponse
-	(*ResolveRequest)(nil),  // 2: shortener.v1.ResolveRequest
-	(*ResolveResponse)(nil), // 3: shortener.v1.ResolveResponse
+	(*ShortenRequest)(nil),        // 0: shortener.v1.ShortenRequest
+	(*ShortenResponse)(nil),       // 1: shortener.v1.ShortenResponse
+	(*ResolveRequest)(nil),        // 2: shortener.v1.ResolveRequest
+	(*ResolveResponse)(nil),       // 3: shortener.v1.ResolveResponse
+	(*GetStatsRequest)(nil),       // 4: shortener.v1.GetStatsRequest
+	(*StatsPoint)(nil),            // 5: shortener.v1.StatsPoint
+	(*DimensionCount)(nil),        // 6: shortener.v1.DimensionCount
+	(*GetStatsResponse)(nil),      // 7: shortener.v1.GetStatsResponse
+	(*WatchClicksRequest)(nil),    // 8: shortener.v1.WatchClicksRequest
+	(*ClickEvent)(nil),            // 9: shortener.v1.ClickEvent
+	(*GetQRCodeRequest)(nil),      // 10: shortener.v1.GetQRCodeRequest
+	(*GetQRCodeResponse)(nil),     // 11: shortener.v1.GetQRCodeResponse
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
 }
 var file_internal_api_shortener_v1_shortener_proto_depIdxs = []int32{
-	0, // 0: shortener.v1.Shortener.Shorten:input_type -> shortener.v1.ShortenRequest
-	2, // 1: shortener.v1.Shortener.Resolve:input_type -> shortener.v1.ResolveRequest
-	1, // 2: shortener.v1.Shortener.Shorten:output_type -> shortener.v1.ShortenResponse
-	3, // 3: shortener.v1.Shortener.Resolve:output_type -> shortener.v1.ResolveResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	12, // 0: shortener.v1.GetStatsRequest.from:type_name -> google.protobuf.Timestamp
+	12, // 1: shortener.v1.GetStatsRequest.to:type_name -> google.protobuf.Timestamp
+	12, // 2: shortener.v1.StatsPoint.at:type_name -> google.protobuf.Timestamp
+	5,  // 3: shortener.v1.GetStatsResponse.hourly:type_name -> shortener.v1.StatsPoint
+	5,  // 4: shortener.v1.GetStatsResponse.daily:type_name -> shortener.v1.StatsPoint
+	6,  // 5: shortener.v1.GetStatsResponse.top_referrers:type_name -> shortener.v1.DimensionCount
+	6,  // 6: shortener.v1.GetStatsResponse.top_countries:type_name -> shortener.v1.DimensionCount
+	6,  // 7: shortener.v1.GetStatsResponse.top_devices:type_name -> shortener.v1.DimensionCount
+	5,  // 8: shortener.v1.GetStatsResponse.daily_uniques:type_name -> shortener.v1.StatsPoint
+	6,  // 9: shortener.v1.GetStatsResponse.top_os:type_name -> shortener.v1.DimensionCount
+	6,  // 10: shortener.v1.GetStatsResponse.top_browsers:type_name -> shortener.v1.DimensionCount
+	6,  // 11: shortener.v1.GetStatsResponse.top_cities:type_name -> shortener.v1.DimensionCount
+	5,  // 12: shortener.v1.GetStatsResponse.daily_conversions:type_name -> shortener.v1.StatsPoint
+	12, // 13: shortener.v1.ClickEvent.at:type_name -> google.protobuf.Timestamp
+	0,  // 14: shortener.v1.Shortener.Shorten:input_type -> shortener.v1.ShortenRequest
+	2,  // 15: shortener.v1.Shortener.Resolve:input_type -> shortener.v1.ResolveRequest
+	4,  // 16: shortener.v1.Shortener.GetStats:input_type -> shortener.v1.GetStatsRequest
+	8,  // 17: shortener.v1.Shortener.WatchClicks:input_type -> shortener.v1.WatchClicksRequest
+	10, // 18: shortener.v1.Shortener.GetQRCode:input_type -> shortener.v1.GetQRCodeRequest
+	1,  // 19: shortener.v1.Shortener.Shorten:output_type -> shortener.v1.ShortenResponse
+	3,  // 20: shortener.v1.Shortener.Resolve:output_type -> shortener.v1.ResolveResponse
+	7,  // 21: shortener.v1.Shortener.GetStats:output_type -> shortener.v1.GetStatsResponse
+	9,  // 22: shortener.v1.Shortener.WatchClicks:output_type -> shortener.v1.ClickEvent
+	11, // 23: shortener.v1.Shortener.GetQRCode:output_type -> shortener.v1.GetQRCodeResponse
+	19, // [19:24] is the sub-list for method output_type
+	14, // [14:19] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_internal_api_shortener_v1_shortener_proto_init() }
@@ -254,13 +1046,14 @@ func file_internal_api_shortener_v1_shortener_proto_init() {
 	if File_internal_api_shortener_v1_shortener_proto != nil {
 		return
 	}
+	file_internal_api_shortener_v1_shortener_proto_msgTypes[10].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_api_shortener_v1_shortener_proto_rawDesc), len(file_internal_api_shortener_v1_shortener_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
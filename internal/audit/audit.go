@@ -0,0 +1,123 @@
+// Package audit — журнал аудита только на дописывание. Записи связаны в
+// цепочку хешей: каждая хранит хеш предыдущей, так что правка или
+// удаление записи в середине обнаруживается Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Entry — запись журнала. Seq идут подряд с 1.
+type Entry struct {
+	Seq         int64
+	At          time.Time
+	Actor       string // ID учётных данных; пусто — без аутентификации
+	ActorUser   string
+	ActorTenant string
+	Action      string
+	Outcome     string
+	Tenant      string
+	Target      string
+	Before      json.RawMessage // nil — объекта не было
+	After       json.RawMessage // nil — объекта не стало
+	RequestID   string
+	SourceIP    string
+	PrevHash    string // hex; у первой записи пусто
+	Hash        string
+}
+
+// Store дописывает и читает записи (memory, postgres).
+type Store interface {
+	// AppendAudit под блокировкой берёт хвост цепочки, запечатывает e
+	// через Seal и дописывает её.
+	AppendAudit(ctx context.Context, e Entry) (Entry, error)
+	// AuditEntries возвращает до q.Limit записей по возрастанию Seq.
+	AuditEntries(ctx context.Context, q Query) ([]Entry, error)
+}
+
+// Query — фильтр журнала. Пустые поля не ограничивают.
+type Query struct {
+	Actor    string
+	Tenant   string
+	Target   string
+	Action   string
+	Outcome  string
+	From, To time.Time
+	AfterSeq int64
+	Limit    int
+}
+
+// Match сообщает, подходит ли запись под фильтр (кроме Limit); для
+// реализаций Store.
+func (q Query) Match(e Entry) bool {
+	return e.Seq > q.AfterSeq &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Tenant == "" || e.Tenant == q.Tenant) &&
+		(q.Target == "" || e.Target == q.Target) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.Outcome == "" || e.Outcome == q.Outcome) &&
+		(q.From.IsZero() || !e.At.Before(q.From)) &&
+		(q.To.IsZero() || e.At.Before(q.To))
+}
+
+// Seal делает e следующей записью после prev (нулевая Entry — начало
+// цепочки). Время округляется до микросекунд, чтобы хеш пережил
+// хранение в Postgres.
+func Seal(prev Entry, e Entry) Entry {
+	e.Seq = prev.Seq + 1
+	e.PrevHash = prev.Hash
+	e.At = e.At.UTC().Truncate(time.Microsecond)
+	e.Hash = e.ComputeHash()
+	return e
+}
+
+// ComputeHash — SHA-256 от всех полей, кроме Hash. Каждое поле пишется
+// с длиной, так что границы полей не сдвинуть.
+func (e Entry) ComputeHash() string {
+	h := sha256.New()
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(e.Seq))
+	h.Write(n[:])
+	for _, f := range []string{
+		e.PrevHash,
+		e.At.UTC().Format(time.RFC3339Nano),
+		e.Actor, e.ActorUser, e.ActorTenant,
+		e.Action, e.Outcome, e.Tenant, e.Target,
+		string(e.Before), string(e.After),
+		e.RequestID, e.SourceIP,
+	} {
+		binary.BigEndian.PutUint64(n[:], uint64(len(f)))
+		h.Write(n[:])
+		h.Write([]byte(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChainError — место, где цепочка разорвана.
+type ChainError struct {
+	Seq    int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at seq %d: %s", e.Seq, e.Reason)
+}
+
+// VerifyNext проверяет, что e корректно продолжает цепочку после prev.
+func VerifyNext(prev, e Entry) error {
+	switch {
+	case e.Seq != prev.Seq+1:
+		return &ChainError{Seq: prev.Seq + 1, Reason: fmt.Sprintf("missing entries, next seq is %d", e.Seq)}
+	case e.PrevHash != prev.Hash:
+		return &ChainError{Seq: e.Seq, Reason: "prev_hash does not match the previous entry"}
+	case e.Hash != e.ComputeHash():
+		return &ChainError{Seq: e.Seq, Reason: "hash does not match the content"}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+// tampered отдаёт записи журнала после правки edit — как если бы их
+// изменили в базе в обход приложения.
+type tampered struct {
+	*memory.Store
+	edit func([]audit.Entry) []audit.Entry
+}
+
+func (t tampered) AuditEntries(ctx context.Context, q audit.Query) ([]audit.Entry, error) {
+	es, err := t.Store.AuditEntries(ctx, q)
+	if err != nil || t.edit == nil {
+		return es, err
+	}
+	return t.edit(es), nil
+}
+
+func TestLog_RecordsServiceChangesAndDetectsTampering(t *testing.T) {
+	st := memory.New()
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	l := audit.New(logger, st)
+	svc := core.NewShortener(st, core.NewCode, core.WithAuditor(l))
+
+	ctx := core.WithPrincipal(context.Background(), core.Principal{ID: "k1", Owner: "alice", Tenant: "acme", Scopes: []core.Scope{core.ScopeLinksWrite}})
+	ctx = core.WithRequestInfo(ctx, core.RequestInfo{ID: "req-1", IP: "203.0.113.5"})
+	link, err := svc.CreateLink(ctx, core.CreateRequest{URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdateSettings(ctx, "", link.Code, core.Settings{ClickIDParam: "clid"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, "", link.Code); err != nil {
+		t.Fatal(err)
+	}
+	bob := core.WithPrincipal(context.Background(), core.Principal{ID: "k2", Owner: "bob", Tenant: "acme"})
+	other, _ := svc.CreateLink(ctx, core.CreateRequest{URL: "https://example.com/b"})
+	if err := svc.Delete(bob, "", other.Code); err != core.ErrForbidden {
+		t.Fatalf("foreign delete err=%v", err)
+	}
+
+	es, err := l.Query(context.Background(), audit.Query{Target: link.Code})
+	if err != nil || len(es) != 3 {
+		t.Fatalf("entries for %s: %d, %v", link.Code, len(es), err)
+	}
+	upd := es[1]
+	if upd.Action != "link.update" || upd.Actor != "k1" || upd.ActorUser != "alice" || upd.RequestID != "req-1" || upd.SourceIP != "203.0.113.5" {
+		t.Fatalf("update entry=%+v", upd)
+	}
+	if strings.Contains(string(upd.Before), "clid") || !strings.Contains(string(upd.After), `"click_id_param":"clid"`) {
+		t.Fatalf("before=%s after=%s", upd.Before, upd.After)
+	}
+	if es[0].Before != nil || es[2].After != nil {
+		t.Fatalf("create must have no before, delete no after: %s / %s", es[0].Before, es[2].After)
+	}
+	denied, _ := l.Query(context.Background(), audit.Query{Outcome: core.AuditDenied})
+	if len(denied) != 1 || denied[0].Action != "link.delete" || denied[0].ActorUser != "bob" {
+		t.Fatalf("denied=%+v", denied)
+	}
+
+	v, err := l.Verify(context.Background())
+	if err != nil || v.Broken != nil || v.Entries != 5 || v.Head == "" {
+		t.Fatalf("verify intact log: %+v, %v", v, err)
+	}
+
+	tests := []struct {
+		name string
+		edit func([]audit.Entry) []audit.Entry
+		at   int64
+	}{
+		{"edited", func(es []audit.Entry) []audit.Entry {
+			es[1].After = []byte(`{"code":"X","url":"https://evil.example"}`)
+			return es
+		}, 2},
+		{"removed", func(es []audit.Entry) []audit.Entry {
+			return append(es[:2:2], es[3:]...)
+		}, 3},
+		{"rehashed", func(es []audit.Entry) []audit.Entry {
+			es[3].Actor = "someone"
+			es[3].Hash = es[3].ComputeHash()
+			return es
+		}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := audit.New(logger, tampered{Store: st, edit: tt.edit}).Verify(context.Background())
+			if err != nil || v.Broken == nil || v.Broken.Seq != tt.at {
+				t.Fatalf("verify=%+v, %v; want broken at %d", v, err, tt.at)
+			}
+		})
+	}
+}
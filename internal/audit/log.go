@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// Log реализует core.Auditor поверх Store.
+type Log struct {
+	log   *slog.Logger
+	store Store
+}
+
+func New(log *slog.Logger, store Store) *Log {
+	return &Log{log: log, store: store}
+}
+
+// Audit дописывает событие с вызывающим и источником запроса из
+// контекста. Запись не должна пропасть из-за отменённого запроса, поэтому
+// отмена контекста игнорируется. Ошибка записи только логируется и
+// считается в метрике: изменение уже выполнено.
+func (l *Log) Audit(ctx context.Context, ev core.AuditEvent) {
+	e := Entry{
+		At:      time.Now(),
+		Action:  ev.Action,
+		Outcome: ev.Outcome,
+		Tenant:  ev.Tenant,
+		Target:  ev.Target,
+	}
+	if p, ok := core.PrincipalFrom(ctx); ok {
+		e.Actor, e.ActorUser, e.ActorTenant = p.ID, p.Owner, p.Tenant
+	}
+	ri := core.RequestInfoFrom(ctx)
+	e.RequestID, e.SourceIP = ri.ID, ri.IP
+
+	var err error
+	if e.Before, err = marshal(ev.Before); err == nil {
+		e.After, err = marshal(ev.After)
+	}
+	if err == nil {
+		_, err = l.store.AppendAudit(context.WithoutCancel(ctx), e)
+	}
+	entriesTotal.WithLabelValues(e.Action, e.Outcome).Inc()
+	if err != nil {
+		failuresTotal.Inc()
+		l.log.Error("audit write failed", "action", e.Action, "target", e.Target, "err", err)
+		return
+	}
+	if e.Outcome == core.AuditDenied {
+		l.log.Warn("access denied", "actor", e.Actor, "action", e.Action, "tenant", e.Tenant, "target", e.Target)
+	}
+}
+
+func marshal(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Query читает журнал по фильтру.
+func (l *Log) Query(ctx context.Context, q Query) ([]Entry, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultLimit
+	}
+	q.Limit = min(q.Limit, maxLimit)
+	return l.store.AuditEntries(ctx, q)
+}
+
+// Verification — результат проверки цепочки. Head — хеш последней
+// записи: сохранённый снаружи, он позволяет заметить и отрезанный хвост.
+type Verification struct {
+	Entries int64
+	Head    string
+	Broken  *ChainError
+}
+
+// Verify проходит журнал целиком и проверяет цепочку.
+func (l *Log) Verify(ctx context.Context) (Verification, error) {
+	var v Verification
+	var prev Entry
+	for {
+		page, err := l.store.AuditEntries(ctx, Query{AfterSeq: prev.Seq, Limit: maxLimit})
+		if err != nil {
+			return Verification{}, err
+		}
+		for _, e := range page {
+			if err := VerifyNext(prev, e); err != nil {
+				if !errors.As(err, &v.Broken) {
+					return Verification{}, err
+				}
+				return v, nil
+			}
+			prev = e
+			v.Entries++
+			v.Head = e.Hash
+		}
+		if len(page) < maxLimit {
+			return v, nil
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package audit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	entriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_audit_entries_total",
+		Help: "Audit events, by action and outcome (ok|denied).",
+	}, []string{"action", "outcome"})
+	failuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_audit_write_failures_total",
+		Help: "Audit events that could not be written.",
+	})
+)
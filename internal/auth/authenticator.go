@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// Authenticator проверяет bearer-токен и возвращает вызывающего. Отказ —
+// ErrUnauthenticated, прочие ошибки — сбой проверки.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (core.Principal, error)
+}
+
+// Combine принимает и API-ключи, и JWT: токен с префиксом "sk_" идёт в
+// keys, остальные — в jwt. nil-аргумент выключает свой тип; если оба nil,
+// возвращается nil — аутентификация выключена.
+func Combine(keys *Keys, jwt *JWTVerifier) Authenticator {
+	if keys == nil && jwt == nil {
+		return nil
+	}
+	return combined{keys: keys, jwt: jwt}
+}
+
+type combined struct {
+	keys *Keys
+	jwt  *JWTVerifier
+}
+
+func (c combined) Authenticate(ctx context.Context, token string) (core.Principal, error) {
+	if strings.HasPrefix(token, tokenScheme+"_") {
+		if c.keys == nil {
+			return core.Principal{}, ErrUnauthenticated
+		}
+		return c.keys.Authenticate(ctx, token)
+	}
+	if c.jwt == nil {
+		return core.Principal{}, ErrUnauthenticated
+	}
+	return c.jwt.Authenticate(ctx, token)
+}
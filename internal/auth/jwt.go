@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+type JWTOptions struct {
+	JWKS     string // путь к файлу или http(s) URL
+	Issuer   string // обязателен
+	Audience string // обязателен
+	// TenantClaim — claim с тенантом, по умолчанию "tenant". Владелец —
+	// всегда sub.
+	TenantClaim string
+	Refresh     time.Duration // как часто перечитывать JWKS, по умолчанию 5m
+	Leeway      time.Duration // допуск расхождения часов, по умолчанию 30s
+}
+
+// minUnknownKidRefresh ограничивает внеплановое перечитывание JWKS, когда
+// приходит токен с незнакомым kid (ротация ключей у IdP).
+const minUnknownKidRefresh = 30 * time.Second
+
+var jwtMethods = []string{"RS256", "ES256", "HS256"}
+
+// JWTVerifier проверяет JWT по кэшированному JWKS.
+type JWTVerifier struct {
+	log  *slog.Logger
+	opts JWTOptions
+	src  jwksSource
+
+	mu      sync.RWMutex
+	keys    map[string]jwkKey // kid -> ключ
+	lastTry time.Time         // последнее внеплановое перечитывание
+	parser  *jwt.Parser
+}
+
+// NewJWTVerifier загружает JWKS сразу: без ключей сервис стартовать не должен.
+func NewJWTVerifier(ctx context.Context, log *slog.Logger, opts JWTOptions) (*JWTVerifier, error) {
+	if opts.JWKS == "" || opts.Issuer == "" || opts.Audience == "" {
+		return nil, errors.New("jwt: jwks, issuer and audience are required")
+	}
+	if opts.TenantClaim == "" {
+		opts.TenantClaim = "tenant"
+	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = 5 * time.Minute
+	}
+	if opts.Leeway <= 0 {
+		opts.Leeway = 30 * time.Second
+	}
+	v := &JWTVerifier{
+		log:  log,
+		opts: opts,
+		src:  newJWKSSource(opts.JWKS),
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(jwtMethods),
+			jwt.WithIssuer(opts.Issuer),
+			jwt.WithAudience(opts.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(opts.Leeway),
+		),
+	}
+	if err := v.reload(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Run перечитывает JWKS раз в Refresh до отмены ctx. При ошибке остаются
+// прежние ключи.
+func (v *JWTVerifier) Run(ctx context.Context) {
+	t := time.NewTicker(v.opts.Refresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := v.reload(ctx); err != nil && ctx.Err() == nil {
+				v.log.Error("jwks reload failed", "src", v.opts.JWKS, "err", err)
+			}
+		}
+	}
+}
+
+func (v *JWTVerifier) reload(ctx context.Context) error {
+	data, err := v.src.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *JWTVerifier) Authenticate(ctx context.Context, token string) (core.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return v.key(ctx, t)
+	})
+	if err != nil {
+		v.log.Debug("jwt rejected", "err", err)
+		return core.Principal{}, ErrUnauthenticated
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return core.Principal{}, ErrUnauthenticated
+	}
+	tenant, _ := claims[v.opts.TenantClaim].(string)
+	return core.Principal{
+		ID:     sub,
+		Name:   sub,
+		Owner:  sub,
+		Tenant: tenant,
+		Scopes: claimScopes(claims),
+	}, nil
+}
+
+// key подбирает ключ по kid и проверяет, что его тип соответствует alg
+// токена: иначе открытый RSA-ключ можно было бы выдать за HMAC-секрет.
+func (v *JWTVerifier) key(ctx context.Context, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	alg := t.Method.Alg()
+
+	k, ok := v.lookup(kid, alg)
+	if !ok && v.tryRefresh() {
+		if err := v.reload(ctx); err != nil {
+			v.log.Error("jwks reload failed", "src", v.opts.JWKS, "err", err)
+		}
+		k, ok = v.lookup(kid, alg)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no %s key for kid %q", alg, kid)
+	}
+	return k.key, nil
+}
+
+func (v *JWTVerifier) lookup(kid, alg string) (jwkKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if kid != "" {
+		k, ok := v.keys[kid]
+		return k, ok && k.fits(alg)
+	}
+	// Без kid подходит только единственный ключ нужного типа.
+	var found jwkKey
+	n := 0
+	for _, k := range v.keys {
+		if k.fits(alg) {
+			found, n = k, n+1
+		}
+	}
+	return found, n == 1
+}
+
+// tryRefresh разрешает внеплановое перечитывание не чаще
+// minUnknownKidRefresh, чтобы поток токенов с мусорным kid не долбил IdP.
+func (v *JWTVerifier) tryRefresh() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if time.Since(v.lastTry) < minUnknownKidRefresh {
+		return false
+	}
+	v.lastTry = time.Now()
+	return true
+}
+
+// claimScopes читает scope (строка через пробел) или scp (строка или
+// массив). Неизвестные scope отбрасываются.
+func claimScopes(claims jwt.MapClaims) []core.Scope {
+	var raw []string
+	for _, name := range []string{"scope", "scp"} {
+		switch v := claims[name].(type) {
+		case string:
+			raw = append(raw, strings.Fields(v)...)
+		case []any:
+			for _, s := range v {
+				if str, ok := s.(string); ok {
+					raw = append(raw, str)
+				}
+			}
+		}
+	}
+	var out []core.Scope
+	for _, s := range raw {
+		if sc := core.Scope(s); core.ValidScope(sc) {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
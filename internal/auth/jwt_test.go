@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path,
+		map[string]string{"kty": "RSA", "kid": "rsa1", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		map[string]string{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		map[string]string{"kty": "oct", "kid": "hs1", "k": b64(hmacKey)},
+	)
+	v, err := NewJWTVerifier(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), JWTOptions{
+		JWKS: path, Issuer: "https://idp.example", Audience: "shortener", TenantClaim: "org",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(mod func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": "https://idp.example", "aud": "shortener", "sub": "alice",
+			"org": "acme", "scope": "links:write stats:read bogus",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+	sign := func(m jwt.SigningMethod, kid string, key any, c jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(m, c)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	ctx := context.Background()
+
+	for name, tok := range map[string]string{
+		"RS256": sign(jwt.SigningMethodRS256, "rsa1", rsaKey, claims(nil)),
+		"ES256": sign(jwt.SigningMethodES256, "ec1", ecKey, claims(nil)),
+		"HS256": sign(jwt.SigningMethodHS256, "hs1", hmacKey, claims(nil)),
+	} {
+		p, err := v.Authenticate(ctx, tok)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if p.Owner != "alice" || p.Tenant != "acme" || !p.Can(core.ScopeLinksWrite) || !p.Can(core.ScopeStatsRead) || p.Can(core.ScopeAdmin) || len(p.Scopes) != 2 {
+			t.Fatalf("%s: principal=%+v", name, p)
+		}
+	}
+
+	otherRSA, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"wrong issuer":   sign(jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil" })),
+		"wrong audience": sign(jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["aud"] = "other" })),
+		"expired":        sign(jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		"no exp":         sign(jwt.SigningMethodRS256, "rsa1", rsaKey, claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+		"foreign key":    sign(jwt.SigningMethodRS256, "rsa1", otherRSA, claims(nil)),
+		// HS256 под kid RSA-ключа: открытый ключ не должен стать HMAC-секретом.
+		"alg confusion": sign(jwt.SigningMethodHS256, "rsa1", rsaKey.N.Bytes(), claims(nil)),
+		"unknown kid":   sign(jwt.SigningMethodRS256, "nope", rsaKey, claims(nil)),
+		"garbage":       "not.a.jwt",
+	}
+	for name, tok := range rejected {
+		if _, err := v.Authenticate(ctx, tok); err != ErrUnauthenticated {
+			t.Fatalf("%s: err=%v, want ErrUnauthenticated", name, err)
+		}
+	}
+
+	// Ротация: новый kid подхватывается перечитыванием JWKS без рестарта.
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, path, map[string]string{"kty": "RSA", "kid": "rsa2", "alg": "RS256", "n": b64(rotated.N.Bytes()), "e": b64(big.NewInt(int64(rotated.E)).Bytes())})
+	v.mu.Lock()
+	v.lastTry = time.Time{}
+	v.mu.Unlock()
+	if _, err := v.Authenticate(ctx, sign(jwt.SigningMethodRS256, "rsa2", rotated, claims(nil))); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+}
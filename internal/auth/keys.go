@@ -0,0 +1,192 @@
+// Package auth — API-ключи: выпуск, хранение в виде хеша, проверка и
+// отзыв.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrKeyNotFound     = errors.New("api key not found")
+	ErrDupKey          = errors.New("duplicate api key prefix")
+	ErrInvalidKey      = errors.New("invalid api key request")
+)
+
+// Ключ выглядит как "sk_<prefix>_<secret>": prefix (12 hex) хранится
+// открыто и по нему ключ ищется и отзывается, от secret хранится только
+// SHA-256. Секрет случайный и длинный, поэтому медленный KDF не нужен.
+const (
+	tokenScheme  = "sk"
+	prefixLen    = 12
+	minSecretLen = 32
+)
+
+// Key — запись о ключе без секрета.
+type Key struct {
+	Prefix     string
+	Name       string
+	Hash       []byte
+	Scopes     []core.Scope
+	CreatedAt  time.Time
+	ExpiresAt  time.Time // нулевое — бессрочный
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+// Active сообщает, можно ли пользоваться ключом в момент now.
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// Store хранит ключи (memory, postgres).
+type Store interface {
+	// CreateKey возвращает ErrDupKey при занятом префиксе.
+	CreateKey(ctx context.Context, k Key) error
+	GetKey(ctx context.Context, prefix string) (Key, bool, error)
+	ListKeys(ctx context.Context) ([]Key, error)
+	// RevokeKey и TouchKey возвращают ErrKeyNotFound.
+	RevokeKey(ctx context.Context, prefix string, at time.Time) error
+	TouchKey(ctx context.Context, prefix string, at time.Time) error
+}
+
+// touchEvery — как часто обновлять LastUsedAt одного ключа: запись на
+// каждый запрос не нужна.
+const touchEvery = time.Minute
+
+type Keys struct {
+	store Store
+
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+func NewKeys(store Store) *Keys {
+	return &Keys{store: store, touched: make(map[string]time.Time)}
+}
+
+// Issue выпускает ключ. Токен возвращается только здесь.
+func (ks *Keys) Issue(ctx context.Context, name string, scopes []core.Scope, expiresAt time.Time) (string, Key, error) {
+	if len(scopes) == 0 {
+		return "", Key{}, ErrInvalidKey
+	}
+	for _, s := range scopes {
+		if !core.ValidScope(s) {
+			return "", Key{}, ErrInvalidKey
+		}
+	}
+	now := time.Now().UTC()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return "", Key{}, ErrInvalidKey
+	}
+	for range 3 {
+		token := tokenScheme + "_" + randomHex(prefixLen/2) + "_" + randomHex(minSecretLen/2)
+		k, err := ks.register(ctx, token, name, scopes, expiresAt, now)
+		if errors.Is(err, ErrDupKey) {
+			continue
+		}
+		return token, k, err
+	}
+	return "", Key{}, ErrDupKey
+}
+
+// Ensure регистрирует заранее известный токен (например, начальный
+// admin-ключ из конфигурации), если ключа с его префиксом ещё нет.
+func (ks *Keys) Ensure(ctx context.Context, token, name string, scopes []core.Scope) error {
+	if _, _, ok := parseToken(token); !ok {
+		return ErrInvalidKey
+	}
+	_, err := ks.register(ctx, token, name, scopes, time.Time{}, time.Now().UTC())
+	if errors.Is(err, ErrDupKey) {
+		return nil
+	}
+	return err
+}
+
+func (ks *Keys) register(ctx context.Context, token, name string, scopes []core.Scope, expiresAt, now time.Time) (Key, error) {
+	prefix, _, _ := parseToken(token)
+	k := Key{
+		Prefix:    prefix,
+		Name:      name,
+		Hash:      hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := ks.store.CreateKey(ctx, k); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// Authenticate проверяет токен. Любая причина отказа — неизвестный,
+// отозванный, просроченный ключ — даёт ErrUnauthenticated.
+func (ks *Keys) Authenticate(ctx context.Context, token string) (core.Principal, error) {
+	prefix, _, ok := parseToken(token)
+	if !ok {
+		return core.Principal{}, ErrUnauthenticated
+	}
+	k, found, err := ks.store.GetKey(ctx, prefix)
+	if err != nil {
+		return core.Principal{}, err
+	}
+	now := time.Now().UTC()
+	if !found || subtle.ConstantTimeCompare(k.Hash, hashToken(token)) != 1 || !k.Active(now) {
+		return core.Principal{}, ErrUnauthenticated
+	}
+	ks.touch(ctx, prefix, now)
+	return core.Principal{ID: k.Prefix, Name: k.Name, Scopes: k.Scopes}, nil
+}
+
+func (ks *Keys) touch(ctx context.Context, prefix string, now time.Time) {
+	ks.mu.Lock()
+	if now.Sub(ks.touched[prefix]) < touchEvery {
+		ks.mu.Unlock()
+		return
+	}
+	ks.touched[prefix] = now
+	ks.mu.Unlock()
+	_ = ks.store.TouchKey(ctx, prefix, now) // LastUsedAt — подсказка, не повод отказать
+}
+
+func (ks *Keys) Revoke(ctx context.Context, prefix string) error {
+	return ks.store.RevokeKey(ctx, prefix, time.Now().UTC())
+}
+
+func (ks *Keys) List(ctx context.Context) ([]Key, error) {
+	return ks.store.ListKeys(ctx)
+}
+
+// parseToken разбирает "sk_<prefix>_<secret>".
+func parseToken(token string) (prefix, secret string, ok bool) {
+	scheme, rest, ok1 := strings.Cut(token, "_")
+	prefix, secret, ok2 := strings.Cut(rest, "_")
+	if !ok1 || !ok2 || scheme != tokenScheme || len(prefix) != prefixLen || len(secret) < minSecretLen {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(prefix); err != nil {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
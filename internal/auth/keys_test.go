@@ -0,0 +1,96 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+func TestKeys_IssueAuthenticateRevoke(t *testing.T) {
+	st := memory.New()
+	keys := auth.NewKeys(st)
+	ctx := context.Background()
+
+	token, k, err := keys.Issue(ctx, "crm", []core.Scope{core.ScopeLinksWrite}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "sk_"+k.Prefix+"_") {
+		t.Fatalf("token %q does not carry prefix %q", token, k.Prefix)
+	}
+	stored, _, _ := st.GetKey(ctx, k.Prefix)
+	if strings.Contains(string(stored.Hash), token) || len(stored.Hash) != 32 {
+		t.Fatal("token must be stored as a hash")
+	}
+
+	p, err := keys.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != k.Prefix || !p.Can(core.ScopeLinksWrite) || p.Can(core.ScopeStatsRead) {
+		t.Fatalf("principal=%+v", p)
+	}
+	if stored, _, _ := st.GetKey(ctx, k.Prefix); stored.LastUsedAt.IsZero() {
+		t.Fatal("last used not tracked")
+	}
+
+	forged := token[:len(token)-1] + "x"
+	for _, bad := range []string{"", "sk_nothex_xx", forged} {
+		if _, err := keys.Authenticate(ctx, bad); err != auth.ErrUnauthenticated {
+			t.Fatalf("Authenticate(%q) err=%v", bad, err)
+		}
+	}
+
+	if err := keys.Revoke(ctx, k.Prefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, token); err != auth.ErrUnauthenticated {
+		t.Fatalf("revoked key err=%v", err)
+	}
+	if err := keys.Revoke(ctx, "000000000000"); err != auth.ErrKeyNotFound {
+		t.Fatalf("revoke unknown err=%v", err)
+	}
+}
+
+func TestKeys_ExpiryScopesAndBootstrap(t *testing.T) {
+	st := memory.New()
+	keys := auth.NewKeys(st)
+	ctx := context.Background()
+
+	if _, _, err := keys.Issue(ctx, "x", []core.Scope{"links:delete"}, time.Time{}); err != auth.ErrInvalidKey {
+		t.Fatalf("unknown scope err=%v", err)
+	}
+	if _, _, err := keys.Issue(ctx, "x", []core.Scope{core.ScopeAdmin}, time.Now().Add(-time.Hour)); err != auth.ErrInvalidKey {
+		t.Fatalf("past expiry err=%v", err)
+	}
+
+	token, k, err := keys.Issue(ctx, "short", []core.Scope{core.ScopeStatsRead}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Active(time.Now()) || k.Active(time.Now().Add(2*time.Hour)) {
+		t.Fatal("expiry not applied")
+	}
+	if _, err := keys.Authenticate(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	boot := "sk_0123456789ab_" + strings.Repeat("s", 40)
+	for range 2 { // повторный запуск не должен падать
+		if err := keys.Ensure(ctx, boot, "bootstrap", []core.Scope{core.ScopeAdmin}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := keys.Authenticate(ctx, boot)
+	if err != nil || !p.Can(core.ScopeStatsRead) || !p.Can(core.ScopeLinksWrite) {
+		t.Fatalf("admin principal=%+v err=%v", p, err)
+	}
+	if err := keys.Ensure(ctx, "too-short", "x", nil); err != auth.ErrInvalidKey {
+		t.Fatalf("malformed bootstrap err=%v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package authz — роли внутри тенанта (viewer, editor, admin) и доступ к
+// отдельным ссылкам. RBAC реализует core.Authorizer и управление ролями.
+package authz
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrNotFound    = errors.New("role assignment not found")
+	// ErrSharesUnsupported — доступы к ссылкам выключены WithoutLinkShares.
+	ErrSharesUnsupported = errors.New("link shares are not supported with this link store")
+)
+
+// Role — роль пользователя в тенанте или на ссылке.
+//
+//	viewer — список ссылок тенанта и их статистика;
+//	editor — ещё создание ссылок и изменение своих;
+//	admin  — изменение любых ссылок тенанта, роли и доступы.
+//
+// На ссылке viewer даёт статистику, editor — ещё изменение и удаление.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// ValidRole сообщает, известна ли роль.
+func ValidRole(r Role) bool { return r.rank() > 0 }
+
+// Assignment — роль пользователя (sub токена) в тенанте или на ссылке.
+type Assignment struct {
+	User      string
+	Role      Role
+	CreatedAt time.Time
+}
+
+// Store хранит роли (memory, postgres).
+type Store interface {
+	TenantRole(ctx context.Context, tenant, user string) (Role, bool, error)
+	SetTenantRole(ctx context.Context, tenant string, a Assignment) error
+	// DeleteTenantRole и UnshareLink возвращают ErrNotFound.
+	DeleteTenantRole(ctx context.Context, tenant, user string) error
+	TenantRoles(ctx context.Context, tenant string) ([]Assignment, error)
+
+	LinkShare(ctx context.Context, domain, code, user string) (Role, bool, error)
+	// ShareLink возвращает core.ErrNotFound, если ссылки нет. Доступы
+	// удаляются вместе со ссылкой.
+	ShareLink(ctx context.Context, domain, code string, a Assignment) error
+	UnshareLink(ctx context.Context, domain, code, user string) error
+	LinkShares(ctx context.Context, domain, code string) ([]Assignment, error)
+}
+
+// WithoutLinkShares оставляет от s только роли в тенанте. Нужен, когда
+// ссылки лежат не там же, где роли (Redis): хранилище ролей не может ни
+// проверить, что ссылка есть, ни удалить доступы вместе с ней. ShareLink
+// отвечает ErrSharesUnsupported, доступов на ссылках ни у кого нет.
+func WithoutLinkShares(s Store) Store { return noShares{s} }
+
+type noShares struct{ Store }
+
+func (noShares) LinkShare(context.Context, string, string, string) (Role, bool, error) {
+	return "", false, nil
+}
+
+func (noShares) ShareLink(context.Context, string, string, Assignment) error {
+	return ErrSharesUnsupported
+}
+
+func (noShares) UnshareLink(context.Context, string, string, string) error { return ErrNotFound }
+
+func (noShares) LinkShares(context.Context, string, string) ([]Assignment, error) { return nil, nil }
+
+type Options struct {
+	// DefaultRole — роль пользователей тенанта без назначения; пусто —
+	// никаких прав, кроме своих уже созданных ссылок.
+	DefaultRole Role
+	// Audit получает изменения ролей и доступов и отказы в них.
+	Audit core.Auditor
+}
+
+type RBAC struct {
+	store Store
+	def   Role
+	audit core.Auditor
+}
+
+func New(store Store, opts Options) *RBAC {
+	return &RBAC{store: store, def: opts.DefaultRole, audit: opts.Audit}
+}
+
+// Authorize — общая проверка прав для HTTP и gRPC, её вызывает
+// core.Shortener (он же пишет отказы в журнал аудита). Admin сервиса может
+// всё. Учётные данные без тенанта (сервисные API-ключи) работают как
+// раньше: всё, кроме изменения чужих ссылок. Пользователь тенанта
+// действует только внутри своего тенанта; ничьи ссылки он может менять и
+// смотреть, как и раньше.
+func (a *RBAC) Authorize(ctx context.Context, p core.Principal, act core.Action, link core.Link) error {
+	if p.Can(core.ScopeAdmin) {
+		return nil
+	}
+	if p.Tenant == "" {
+		switch act {
+		case core.ActionUpdate, core.ActionDelete:
+			if !p.CanModify(link) {
+				return core.ErrForbidden
+			}
+		case core.ActionList:
+			if link.Tenant != "" {
+				return core.ErrForbidden
+			}
+		}
+		return nil
+	}
+	if link.Tenant == "" && act != core.ActionCreate && act != core.ActionList {
+		return nil
+	}
+	if link.Tenant != p.Tenant {
+		return core.ErrForbidden
+	}
+
+	role, err := a.role(ctx, p)
+	if err != nil {
+		return err
+	}
+	own := p.Owner != "" && link.Owner == p.Owner
+	var ok bool
+	switch act {
+	case core.ActionCreate:
+		ok = role.rank() >= RoleEditor.rank()
+	case core.ActionList:
+		ok = own || role.rank() >= RoleViewer.rank()
+	case core.ActionStats:
+		ok = own || role.rank() >= RoleViewer.rank()
+		if !ok {
+			ok, err = a.shared(ctx, link, p, RoleViewer)
+		}
+	case core.ActionUpdate, core.ActionDelete:
+		ok = role == RoleAdmin || own && role.rank() >= RoleEditor.rank()
+		if !ok {
+			ok, err = a.shared(ctx, link, p, RoleEditor)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return core.ErrForbidden
+	}
+	return nil
+}
+
+// role — роль вызывающего в его тенанте.
+func (a *RBAC) role(ctx context.Context, p core.Principal) (Role, error) {
+	if p.Owner == "" {
+		return "", nil
+	}
+	r, found, err := a.store.TenantRole(ctx, p.Tenant, p.Owner)
+	if err != nil || !found {
+		return a.def, err
+	}
+	return r, nil
+}
+
+func (a *RBAC) shared(ctx context.Context, link core.Link, p core.Principal, need Role) (bool, error) {
+	if link.Code == "" || p.Owner == "" {
+		return false, nil
+	}
+	r, found, err := a.store.LinkShare(ctx, link.Domain, link.Code, p.Owner)
+	return found && r.rank() >= need.rank(), err
+}
+
+// deny пишет отказ в управлении ролями в журнал аудита.
+func (a *RBAC) deny(ctx context.Context, action, tenant, target string) {
+	if a.audit != nil {
+		a.audit.Audit(ctx, core.AuditEvent{Action: action, Outcome: core.AuditDenied, Tenant: tenant, Target: target})
+	}
+}
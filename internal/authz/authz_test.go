@@ -0,0 +1,158 @@
+package authz_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+func user(id, tenant string) context.Context {
+	return core.WithPrincipal(context.Background(), core.Principal{
+		ID: id, Owner: id, Tenant: tenant,
+		Scopes: []core.Scope{core.ScopeLinksWrite, core.ScopeLinksRead, core.ScopeStatsRead},
+	})
+}
+
+func TestRBAC_RolesAndShares(t *testing.T) {
+	st := memory.New()
+	audits := audit.New(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), st)
+	rbac := authz.New(st, authz.Options{Audit: audits})
+	svc := core.NewShortener(st, core.NewCode, core.WithAuthorizer(rbac), core.WithAuditor(audits))
+	ctx := context.Background()
+
+	boss, alice, bob, eve := user("boss", "acme"), user("alice", "acme"), user("bob", "acme"), user("eve", "other")
+	for _, a := range []struct {
+		tenant, user string
+		role         authz.Role
+	}{{"acme", "boss", authz.RoleAdmin}, {"acme", "alice", authz.RoleEditor}, {"acme", "bob", authz.RoleViewer}} {
+		if err := rbac.Assign(ctx, a.tenant, a.user, a.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := svc.CreateLink(bob, core.CreateRequest{URL: "https://example.com/viewer"}); err != core.ErrForbidden {
+		t.Fatalf("viewer create err=%v, want ErrForbidden", err)
+	}
+	link, err := svc.CreateLink(alice, core.CreateRequest{URL: "https://example.com/alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.StatsLink(bob, "", link.Code); err != nil {
+		t.Fatalf("viewer stats err=%v", err)
+	}
+	if _, err := svc.StatsLink(eve, "", link.Code); err != core.ErrForbidden {
+		t.Fatalf("other tenant stats err=%v, want ErrForbidden", err)
+	}
+	if _, err := svc.UpdateSettings(bob, "", link.Code, core.Settings{ClickIDParam: "x"}); err != core.ErrForbidden {
+		t.Fatalf("viewer update err=%v, want ErrForbidden", err)
+	}
+	if err := rbac.Share(bob, link, "bob", authz.RoleEditor); err != core.ErrForbidden {
+		t.Fatalf("viewer sharing err=%v, want ErrForbidden", err)
+	}
+	if err := rbac.Share(alice, link, "bob", authz.RoleEditor); err != nil {
+		t.Fatalf("owner sharing err=%v", err)
+	}
+	if _, err := svc.UpdateSettings(bob, "", link.Code, core.Settings{ClickIDParam: "x"}); err != nil {
+		t.Fatalf("update with editor share err=%v", err)
+	}
+
+	if err := rbac.Assign(alice, "acme", "bob", authz.RoleAdmin); err != core.ErrForbidden {
+		t.Fatalf("editor assigning roles err=%v, want ErrForbidden", err)
+	}
+	if err := rbac.Assign(boss, "acme", "alice", authz.RoleViewer); err != nil {
+		t.Fatalf("tenant admin assign err=%v", err)
+	}
+	if err := svc.Delete(alice, "", link.Code); err != core.ErrForbidden {
+		t.Fatalf("demoted owner delete err=%v, want ErrForbidden", err)
+	}
+
+	links, _, err := svc.List(eve, core.ListRequest{})
+	if err != nil || len(links) != 0 {
+		t.Fatalf("other tenant list=%v, %v", links, err)
+	}
+	if links, _, err = svc.List(bob, core.ListRequest{}); err != nil || len(links) != 1 {
+		t.Fatalf("viewer list=%v, %v", links, err)
+	}
+
+	if err := svc.Delete(boss, "", link.Code); err != nil {
+		t.Fatalf("tenant admin delete err=%v", err)
+	}
+	if shares, _ := st.LinkShares(ctx, "", link.Code); len(shares) != 0 {
+		t.Fatalf("shares outlive the link: %v", shares)
+	}
+
+	denied, err := audits.Query(ctx, audit.Query{Outcome: core.AuditDenied})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range denied {
+		actions = append(actions, e.Action+":"+e.ActorUser)
+	}
+	want := "link.create:bob link.stats:eve link.update:bob link.share:bob role.assign:alice link.delete:alice"
+	if got := strings.Join(actions, " "); got != want {
+		t.Fatalf("denials in audit log:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestRBAC_DefaultRoleKeepsOwnership(t *testing.T) {
+	st := memory.New()
+	rbac := authz.New(st, authz.Options{DefaultRole: authz.RoleEditor})
+	svc := core.NewShortener(st, core.NewCode, core.WithAuthorizer(rbac))
+	alice, bob := user("alice", "acme"), user("bob", "acme")
+
+	link, err := svc.CreateLink(alice, core.CreateRequest{URL: "https://example.com/owned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(bob, "", link.Code); err != core.ErrForbidden {
+		t.Fatalf("foreign delete err=%v, want ErrForbidden", err)
+	}
+	if _, err := svc.UpdateSettings(alice, "", link.Code, core.Settings{ClickIDParam: "x"}); err != nil {
+		t.Fatalf("owner update err=%v", err)
+	}
+
+	service := core.WithPrincipal(context.Background(), core.Principal{ID: "svc", Scopes: []core.Scope{core.ScopeLinksWrite}})
+	if err := svc.Delete(service, "", link.Code); err != core.ErrForbidden {
+		t.Fatalf("service key deleting an owned link err=%v, want ErrForbidden", err)
+	}
+	anon, err := svc.CreateLink(service, core.CreateRequest{URL: "https://example.com/anon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(bob, "", anon.Code); err != nil {
+		t.Fatalf("unowned link delete err=%v", err)
+	}
+}
+
+func TestRBAC_WithoutLinkShares(t *testing.T) {
+	st := memory.New()
+	rbac := authz.New(authz.WithoutLinkShares(st), authz.Options{})
+	svc := core.NewShortener(st, core.NewCode, core.WithAuthorizer(rbac))
+	ctx, alice, bob := context.Background(), user("alice", "acme"), user("bob", "acme")
+	if err := rbac.Assign(ctx, "acme", "alice", authz.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := svc.CreateLink(alice, core.CreateRequest{URL: "https://example.com/redis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rbac.Share(alice, link, "bob", authz.RoleEditor); err != authz.ErrSharesUnsupported {
+		t.Fatalf("share err=%v, want ErrSharesUnsupported", err)
+	}
+	if err := svc.Delete(bob, "", link.Code); err != core.ErrForbidden {
+		t.Fatalf("delete without a share err=%v, want ErrForbidden", err)
+	}
+	if shares, err := rbac.Shares(alice, link); err != nil || len(shares) != 0 {
+		t.Fatalf("Shares = %v, %v", shares, err)
+	}
+}
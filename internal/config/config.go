@@ -4,7 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,15 +18,198 @@ type Config struct {
 	GRPCAddr        string  
 	LogLevel		string
 	StorageBackend	string
+
+	ClickBufferSize    int
+	ClickBatchSize     int
+	ClickFlushInterval time.Duration
+	ClickRetention     time.Duration
+	VisitorSalt        string
+	ClickIDSecret      string
+	UARulesFile        string
+	GeoIPDB            string
+	GeoIPReload        time.Duration
+	TrustedProxies     []netip.Prefix
+	ExportDir          string
+	ExportTTL          time.Duration
+	ExportWorkers      int
+	StreamBuffer       int
+	WebhookMaxAttempts int
+	WebhookTimeout     time.Duration
+	WebhookBackoff     time.Duration
+	WebhookAllowNets   []netip.Prefix
+	OutboxPublisher    string
+	OutboxURL          string
+	AuthEnabled        bool
+	AuthBootstrapKey   string
+	JWTJWKS            string
+	JWTIssuer          string
+	JWTAudience        string
+	JWTTenantClaim     string
+	JWTRefresh         time.Duration
+	RateLimitBackend   string
+	RateLimitCreate    string
+	RateLimitResolve   string
+	RateLimitRedirect  string
+	QuotaMaxLinks          int
+	QuotaMaxMonthlyCreates int
+	RBACDefaultRole        string
+	RateLimitReport        string
+
+	ModerationAutoDisable  int
+	ModerationWindow       time.Duration
+	ModerationInterstitial bool
+
+	// Domains — короткие домены, первый — по умолчанию; пусто — один
+	// домен, ссылки строятся от Host запроса.
+	Domains []string
+	// PublicBaseURL — публичный адрес для коротких ссылок; nil — строить
+	// от запроса (HTTP) и не отдавать short_url в gRPC.
+	PublicBaseURL *url.URL
+
+	// CacheSize — ссылок в кеше перед хранилищем; 0 — без кеша.
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+
+	// RedisTTL — время жизни ссылок в Redis; 0 — бессрочно.
+	RedisTTL       time.Duration
+	RedisKeyPrefix string
 }
 
 func Load() (*Config, error){
 	var cfg Config
 
+	clickBuffer, err := getenvInt("CLICK_BUFFER_SIZE", 10000)
+	if err != nil {
+		return nil, err
+	}
+	clickBatch, err := getenvInt("CLICK_BATCH_SIZE", 500)
+	if err != nil {
+		return nil, err
+	}
+	clickFlush, err := getenvDuration("CLICK_FLUSH_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	clickRetention, err := getenvDuration("CLICK_RETENTION", 90*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	geoipReload, err := getenvDuration("GEOIP_RELOAD_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	exportTTL, err := getenvDuration("EXPORT_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	exportWorkers, err := getenvInt("EXPORT_WORKERS", 2)
+	if err != nil {
+		return nil, err
+	}
+	streamBuffer, err := getenvInt("STREAM_BUFFER", 64)
+	if err != nil {
+		return nil, err
+	}
+	webhookAttempts, err := getenvInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, err
+	}
+	webhookTimeout, err := getenvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	webhookBackoff, err := getenvDuration("WEBHOOK_BACKOFF", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	quotaLinks, err := getenvInt("QUOTA_MAX_LINKS", 0)
+	if err != nil {
+		return nil, err
+	}
+	quotaCreates, err := getenvInt("QUOTA_MAX_MONTHLY_CREATES", 0)
+	if err != nil {
+		return nil, err
+	}
+	jwtRefresh, err := getenvDuration("JWT_JWKS_REFRESH", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	modAutoDisable, err := getenvInt("MODERATION_AUTO_DISABLE_REPORTS", 5)
+	if err != nil {
+		return nil, err
+	}
+	modWindow, err := getenvDuration("MODERATION_REPORT_WINDOW", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cacheSize, err := getenvInt("CACHE_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTL, err := getenvDuration("CACHE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cacheNegTTL, err := getenvDuration("CACHE_NEGATIVE_TTL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	redisTTL, err := getenvDuration("REDIS_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+	var trustedProxies, webhookAllowNets, domains, baseURL string
+
 	flag.StringVar(&cfg.HTTPAddr, "http-addr", getenv("HTTP_ADDR", ":8080"), "HTTP listen address")
 	flag.StringVar(&cfg.GRPCAddr, "grpc-addr", getenv("GRPC_ADDR", ":9090"), "gRPC listen address")
 	flag.StringVar(&cfg.LogLevel, "log-level", getenv("LOG_LEVEL", "INFO"), "log level: debug|info|warn|error")
-	flag.StringVar(&cfg.StorageBackend, "storage", getenv("STORAGE_BACKEND", "memory"), "storage backend: memory|postgres")
+	flag.StringVar(&cfg.StorageBackend, "storage", getenv("STORAGE_BACKEND", "memory"), "storage backend: memory|postgres|redis")
+	flag.IntVar(&cfg.ClickBufferSize, "click-buffer", clickBuffer, "max clicks waiting to be written")
+	flag.IntVar(&cfg.ClickBatchSize, "click-batch", clickBatch, "clicks per analytics write")
+	flag.DurationVar(&cfg.ClickFlushInterval, "click-flush", clickFlush, "max delay before buffered clicks are written")
+	flag.StringVar(&cfg.VisitorSalt, "visitor-salt", getenv("VISITOR_SALT", ""), "secret for visitor hashes, shared by all instances")
+	flag.StringVar(&cfg.ClickIDSecret, "click-id-secret", getenv("CLICK_ID_SECRET", ""), "secret for signing click ids, shared by all instances")
+	flag.StringVar(&cfg.UARulesFile, "ua-rules", getenv("UA_RULES_FILE", ""), "User-Agent rules file, empty uses built-in rules")
+	flag.StringVar(&cfg.GeoIPDB, "geoip-db", getenv("GEOIP_DB", ""), "MaxMind .mmdb file for click geo enrichment, empty disables it")
+	flag.DurationVar(&cfg.GeoIPReload, "geoip-reload", geoipReload, "how often to check the GeoIP file for changes")
+	flag.StringVar(&trustedProxies, "trusted-proxies", getenv("TRUSTED_PROXIES", ""), "comma-separated CIDRs/IPs of proxies allowed to set client IP headers")
+	flag.DurationVar(&cfg.ClickRetention, "click-retention", clickRetention, "how long raw clicks are kept, 0 keeps forever")
+	flag.StringVar(&cfg.ExportDir, "export-dir", getenv("EXPORT_DIR", filepath.Join(os.TempDir(), "shortener-exports")), "directory for background export results")
+	flag.DurationVar(&cfg.ExportTTL, "export-ttl", exportTTL, "how long finished exports are kept")
+	flag.IntVar(&cfg.ExportWorkers, "export-workers", exportWorkers, "concurrent background exports")
+	flag.IntVar(&cfg.StreamBuffer, "stream-buffer", streamBuffer, "click events a live subscriber may lag before it is disconnected")
+	flag.IntVar(&cfg.WebhookMaxAttempts, "webhook-max-attempts", webhookAttempts, "failed webhook attempts before a delivery goes to dead letters")
+	flag.DurationVar(&cfg.WebhookTimeout, "webhook-timeout", webhookTimeout, "timeout of a single webhook attempt")
+	flag.DurationVar(&cfg.WebhookBackoff, "webhook-backoff", webhookBackoff, "delay before the first webhook retry, doubled on each next one")
+	flag.StringVar(&webhookAllowNets, "webhook-allow-nets", getenv("WEBHOOK_ALLOW_NETS", ""), "comma-separated private CIDRs/IPs webhooks may be sent to")
+	flag.StringVar(&cfg.OutboxPublisher, "outbox-publisher", getenv("OUTBOX_PUBLISHER", "log"), "where the postgres outbox relay publishes link events: log|http")
+	flag.StringVar(&cfg.OutboxURL, "outbox-url", getenv("OUTBOX_URL", ""), "endpoint for OUTBOX_PUBLISHER=http")
+	flag.BoolVar(&cfg.AuthEnabled, "auth", getenv("AUTH_ENABLED", "") == "true", "require API keys on HTTP API and gRPC")
+	flag.StringVar(&cfg.AuthBootstrapKey, "auth-bootstrap-key", getenv("AUTH_BOOTSTRAP_KEY", ""), "admin API key (sk_<12 hex>_<secret>) registered at startup")
+	flag.StringVar(&cfg.JWTJWKS, "jwt-jwks", getenv("JWT_JWKS", ""), "JWKS file or URL; enables JWT bearer auth when AUTH_ENABLED")
+	flag.StringVar(&cfg.JWTIssuer, "jwt-issuer", getenv("JWT_ISSUER", ""), "required iss of accepted JWTs")
+	flag.StringVar(&cfg.JWTAudience, "jwt-audience", getenv("JWT_AUDIENCE", ""), "required aud of accepted JWTs")
+	flag.StringVar(&cfg.JWTTenantClaim, "jwt-tenant-claim", getenv("JWT_TENANT_CLAIM", "tenant"), "JWT claim holding the tenant")
+	flag.DurationVar(&cfg.JWTRefresh, "jwt-jwks-refresh", jwtRefresh, "how often to reload the JWKS")
+	flag.StringVar(&cfg.RateLimitBackend, "rate-limit-backend", getenv("RATE_LIMIT_BACKEND", "memory"), "rate limit counters: memory (per instance) | postgres (shared)")
+	flag.StringVar(&cfg.RateLimitCreate, "rate-limit-create", getenv("RATE_LIMIT_CREATE", ""), "link creation limit per client, e.g. 60/m or 10/s:50; empty disables")
+	flag.StringVar(&cfg.RateLimitResolve, "rate-limit-resolve", getenv("RATE_LIMIT_RESOLVE", ""), "JSON resolve limit per client")
+	flag.StringVar(&cfg.RateLimitRedirect, "rate-limit-redirect", getenv("RATE_LIMIT_REDIRECT", ""), "redirect limit per client IP")
+	flag.StringVar(&cfg.RateLimitReport, "rate-limit-report", getenv("RATE_LIMIT_REPORT", "10/h"), "abuse report limit per client IP")
+	flag.IntVar(&cfg.QuotaMaxLinks, "quota-max-links", quotaLinks, "default max active links per tenant, 0 = unlimited")
+	flag.IntVar(&cfg.QuotaMaxMonthlyCreates, "quota-max-monthly-creates", quotaCreates, "default max link creations per tenant per calendar month, 0 = unlimited")
+	flag.StringVar(&cfg.RBACDefaultRole, "rbac-default-role", getenv("RBAC_DEFAULT_ROLE", "editor"), "role of tenant users without an assignment: viewer|editor|admin|none")
+	flag.IntVar(&cfg.ModerationAutoDisable, "moderation-auto-disable-reports", modAutoDisable, "distinct reporters within the window that disable a link, 0 = never")
+	flag.DurationVar(&cfg.ModerationWindow, "moderation-report-window", modWindow, "window for counting reports towards auto-disable")
+	flag.StringVar(&baseURL, "public-base-url", getenv("PUBLIC_BASE_URL", ""), "public base URL of short links, e.g. https://sho.rt; empty derives it from the request")
+	flag.StringVar(&domains, "domains", getenv("DOMAINS", ""), "comma-separated short domains, the first one is the default; empty serves any Host")
+	flag.IntVar(&cfg.CacheSize, "cache-size", cacheSize, "links cached in memory in front of the store, 0 disables the cache")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", cacheTTL, "how long a cached link is served without checking the store")
+	flag.DurationVar(&cfg.CacheNegativeTTL, "cache-negative-ttl", cacheNegTTL, "how long an unknown code is remembered as missing")
+	flag.DurationVar(&cfg.RedisTTL, "redis-ttl", redisTTL, "lifetime of links in redis, 0 keeps them forever")
+	flag.StringVar(&cfg.RedisKeyPrefix, "redis-key-prefix", getenv("REDIS_KEY_PREFIX", "shortener:"), "prefix of all redis keys")
+	flag.BoolVar(&cfg.ModerationInterstitial, "moderation-interstitial", getenv("MODERATION_INTERSTITIAL", "") == "true", "show a warning page before redirecting reported links")
 
 	flag.Parse()
 	switch cfg.LogLevel {
@@ -31,6 +220,65 @@ func Load() (*Config, error){
 	default:
 		return nil, fmt.Errorf("invalid log level: %s", cfg.LogLevel)
 	}
+	if cfg.JWTJWKS != "" && (cfg.JWTIssuer == "" || cfg.JWTAudience == "") {
+		return nil, fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required with JWT_JWKS")
+	}
+	if cfg.QuotaMaxLinks < 0 || cfg.QuotaMaxMonthlyCreates < 0 {
+		return nil, fmt.Errorf("QUOTA_MAX_LINKS and QUOTA_MAX_MONTHLY_CREATES must not be negative")
+	}
+	if cfg.ModerationAutoDisable < 0 || cfg.ModerationWindow <= 0 {
+		return nil, fmt.Errorf("MODERATION_AUTO_DISABLE_REPORTS must not be negative and MODERATION_REPORT_WINDOW must be positive")
+	}
+	if cfg.StorageBackend == "redis" && (cfg.QuotaMaxLinks > 0 || cfg.QuotaMaxMonthlyCreates > 0) {
+		return nil, fmt.Errorf("tenant quotas are not supported with STORAGE_BACKEND=redis")
+	}
+	if cfg.RedisTTL < 0 {
+		return nil, fmt.Errorf("REDIS_TTL must not be negative")
+	}
+	if cfg.CacheSize < 0 || cfg.CacheTTL <= 0 || cfg.CacheNegativeTTL <= 0 {
+		return nil, fmt.Errorf("CACHE_SIZE must not be negative, CACHE_TTL and CACHE_NEGATIVE_TTL must be positive")
+	}
+	switch cfg.RBACDefaultRole {
+	case "viewer", "editor", "admin":
+	case "none":
+		cfg.RBACDefaultRole = ""
+	default:
+		return nil, fmt.Errorf("invalid RBAC_DEFAULT_ROLE: %s", cfg.RBACDefaultRole)
+	}
+	switch cfg.RateLimitBackend {
+	case "memory":
+	case "postgres":
+		if cfg.StorageBackend != "postgres" && cfg.StorageBackend != "redis" {
+			return nil, fmt.Errorf("RATE_LIMIT_BACKEND=postgres requires STORAGE_BACKEND=postgres or redis")
+		}
+	default:
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BACKEND: %s", cfg.RateLimitBackend)
+	}
+	switch cfg.OutboxPublisher {
+	case "log":
+	case "http":
+		if cfg.OutboxURL == "" {
+			return nil, fmt.Errorf("OUTBOX_URL is required for OUTBOX_PUBLISHER=http")
+		}
+	default:
+		return nil, fmt.Errorf("invalid OUTBOX_PUBLISHER: %s", cfg.OutboxPublisher)
+	}
+	if cfg.TrustedProxies, err = parsePrefixes(trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	if cfg.WebhookAllowNets, err = parsePrefixes(webhookAllowNets); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_NETS: %w", err)
+	}
+	if baseURL != "" {
+		if cfg.PublicBaseURL, err = parseBaseURL(baseURL); err != nil {
+			return nil, fmt.Errorf("invalid PUBLIC_BASE_URL: %w", err)
+		}
+	}
+	for _, d := range strings.Split(domains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			cfg.Domains = append(cfg.Domains, d)
+		}
+	}
 
 
 	return &cfg, nil
@@ -41,4 +289,70 @@ func getenv(key, def string) string {
 		return v
 	}
 	return def
-}
\ No newline at end of file
+}
+
+func getenvInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func getenvDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// parseBaseURL принимает абсолютный http(s) URL без query и фрагмента;
+// путь служит префиксом коротких ссылок.
+func parseBaseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("want http(s)://host[/path], got %q", s)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("user info, query and fragment are not allowed")
+	}
+	return u, nil
+}
+
+// parsePrefixes разбирает список CIDR через запятую; одиночный IP
+// считается сетью из одного адреса.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package core
+
+import "context"
+
+// AuditEvent — действие, которое сервисный слой пишет в журнал аудита.
+// Кто и откуда — берётся из контекста (Principal, RequestInfo).
+type AuditEvent struct {
+	Action  string // link.create, link.update, role.assign, …
+	Outcome string // AuditOK | AuditDenied
+	Tenant  string
+	Target  string // код ссылки или другой объект действия
+	Before  any    // состояние до; nil — не было
+	After   any    // состояние после; nil — не стало
+}
+
+const (
+	AuditOK     = "ok"
+	AuditDenied = "denied"
+)
+
+// Auditor записывает события аудита. Ошибки записи — забота реализации:
+// изменение уже выполнено, и откатывать его из-за журнала нельзя.
+type Auditor interface {
+	Audit(ctx context.Context, e AuditEvent)
+}
+
+// WithAuditor пишет в журнал каждое изменение ссылок и каждый отказ в
+// доступе к ним.
+func WithAuditor(a Auditor) Option {
+	return func(s *Shortener) { s.audit = a }
+}
+
+// LinkState — состояние ссылки в журнале аудита.
+type LinkState struct {
+	Domain       string `json:"domain,omitempty"`
+	Code         string `json:"code"`
+	URL          string `json:"url"`
+	Owner        string `json:"owner,omitempty"`
+	Tenant       string `json:"tenant,omitempty"`
+	ClickIDParam string `json:"click_id_param,omitempty"`
+	Disabled     bool   `json:"disabled,omitempty"`
+}
+
+func StateOf(l Link) *LinkState {
+	return &LinkState{Domain: l.Domain, Code: l.Code, URL: l.Original, Owner: l.Owner, Tenant: l.Tenant, ClickIDParam: l.ClickIDParam, Disabled: l.Disabled}
+}
+
+func (s *Shortener) record(ctx context.Context, e AuditEvent) {
+	if s.audit == nil {
+		return
+	}
+	if e.Outcome == "" {
+		e.Outcome = AuditOK
+	}
+	s.audit.Audit(ctx, e)
+}
+
+// RequestInfo — откуда пришёл запрос; транспорт кладёт его в контекст.
+type RequestInfo struct {
+	ID string
+	IP string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, ri RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, ri)
+}
+
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	ri, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return ri
+}
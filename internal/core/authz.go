@@ -0,0 +1,56 @@
+package core
+
+import "context"
+
+// Action — операция над ссылками, которую проверяет Authorizer.
+type Action string
+
+const (
+	ActionCreate Action = "link.create"
+	ActionUpdate Action = "link.update"
+	ActionDelete Action = "link.delete"
+	ActionStats  Action = "link.stats"
+	ActionList   Action = "link.list"
+)
+
+// Authorizer решает, может ли вызывающий выполнить действие над ссылкой.
+// Для ActionCreate link — создаваемая ссылка, для ActionList — образец
+// выборки: Tenant (и Owner, если список только своих ссылок). Отказ —
+// ErrForbidden.
+type Authorizer interface {
+	Authorize(ctx context.Context, p Principal, a Action, link Link) error
+}
+
+// WithAuthorizer включает проверку прав на создание, изменение, удаление,
+// статистику и список ссылок. Без него действует только владение
+// (Principal.CanModify).
+func WithAuthorizer(a Authorizer) Option {
+	return func(s *Shortener) { s.authz = a }
+}
+
+// authorize пропускает всё без Principal (аутентификация выключена).
+// Отказы пишутся в журнал аудита.
+func (s *Shortener) authorize(ctx context.Context, a Action, link Link) error {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil
+	}
+	err := s.check(ctx, p, a, link)
+	if err == ErrForbidden {
+		s.record(ctx, AuditEvent{Action: string(a), Outcome: AuditDenied, Tenant: link.Tenant, Target: link.Code})
+	}
+	return err
+}
+
+func (s *Shortener) check(ctx context.Context, p Principal, a Action, link Link) error {
+	if s.authz != nil {
+		return s.authz.Authorize(ctx, p, a, link)
+	}
+	switch a {
+	case ActionUpdate, ActionDelete:
+		if !p.CanModify(link) {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
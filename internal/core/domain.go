@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Domains — домены, на которых сервис отдаёт короткие ссылки; первый —
+// домен по умолчанию. Код уникален в пределах домена. В Link.Domain
+// хранится ключ: пусто — домен по умолчанию, иначе имя хоста, так что
+// ссылки, созданные до появления доменов, остаются на основном.
+//
+// Нулевой *Domains — один безымянный домен: короткие ссылки строятся от
+// Host запроса.
+type Domains struct {
+	names []string
+}
+
+func NewDomains(names []string) (*Domains, error) {
+	d := &Domains{}
+	for _, n := range names {
+		h := HostName(n)
+		if h == "" || strings.ContainsAny(h, "/ ") {
+			return nil, fmt.Errorf("invalid domain %q", n)
+		}
+		if slices.Contains(d.names, h) {
+			return nil, fmt.Errorf("duplicate domain %q", n)
+		}
+		d.names = append(d.names, h)
+	}
+	if len(d.names) == 0 {
+		return nil, fmt.Errorf("no domains")
+	}
+	return d, nil
+}
+
+// HostName приводит Host или имя домена к виду для сравнения: без порта,
+// в нижнем регистре, без завершающей точки.
+func HostName(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Default — имя домена по умолчанию; пусто для нулевого Domains.
+func (d *Domains) Default() string {
+	if d == nil {
+		return ""
+	}
+	return d.names[0]
+}
+
+func (d *Domains) Names() []string {
+	if d == nil {
+		return nil
+	}
+	return slices.Clone(d.names)
+}
+
+// Key возвращает ключ домена по имени хоста; пустое имя — домен по
+// умолчанию. ok=false — домен не обслуживается.
+func (d *Domains) Key(host string) (key string, ok bool) {
+	h := HostName(host)
+	if h == "" || h == d.Default() {
+		return "", true
+	}
+	if d == nil || !slices.Contains(d.names, h) {
+		return "", false
+	}
+	return h, true
+}
+
+// Host — имя хоста по ключу домена.
+func (d *Domains) Host(key string) string {
+	if key == "" {
+		return d.Default()
+	}
+	return key
+}
+
+// TenantDomainStore хранит домены, на которых тенант может создавать
+// ссылки (memory, postgres). nil — список не задан.
+type TenantDomainStore interface {
+	TenantDomains(ctx context.Context, tenant string) ([]string, error)
+	// SetTenantDomains с пустым списком снимает настройку.
+	SetTenantDomains(ctx context.Context, tenant string, domains []string) error
+}
+
+// WithDomains включает несколько доменов. tenants задаёт, куда могут
+// создавать ссылки пользователи тенантов; без него и для тенантов без
+// списка — только домен по умолчанию. Admin и ключи без тенанта могут
+// использовать любой домен.
+func WithDomains(d *Domains, tenants TenantDomainStore) Option {
+	return func(s *Shortener) { s.domains, s.tenantDomains = d, tenants }
+}
+
+// Domains — обслуживаемые домены; nil, если не настроены.
+func (s *Shortener) Domains() *Domains { return s.domains }
+
+// createDomain выбирает ключ домена новой ссылки и проверяет, что тенант
+// вызывающего может на нём создавать.
+func (s *Shortener) createDomain(ctx context.Context, host string) (string, error) {
+	key, ok := s.domains.Key(host)
+	if !ok {
+		return "", ErrUnknownDomain
+	}
+	p, ok := PrincipalFrom(ctx)
+	if !ok || p.Tenant == "" || p.Can(ScopeAdmin) {
+		return key, nil
+	}
+	allowed, err := s.allowedDomains(ctx, p.Tenant)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(allowed, s.domains.Host(key)) {
+		s.record(ctx, AuditEvent{Action: "link.create", Outcome: AuditDenied, Tenant: p.Tenant, Target: s.domains.Host(key)})
+		return "", ErrDomainNotAllowed
+	}
+	return key, nil
+}
+
+func (s *Shortener) allowedDomains(ctx context.Context, tenant string) ([]string, error) {
+	if s.tenantDomains != nil {
+		list, err := s.tenantDomains.TenantDomains(ctx, tenant)
+		if err != nil || list != nil {
+			return list, err
+		}
+	}
+	return []string{s.domains.Default()}, nil
+}
+
+// TenantDomains возвращает домены тенанта; custom=false — список не задан
+// и действует домен по умолчанию. Свой тенант может смотреть любой
+// пользователь, чужие — только admin.
+func (s *Shortener) TenantDomains(ctx context.Context, tenant string) (domains []string, custom bool, err error) {
+	if p, ok := PrincipalFrom(ctx); ok && p.Tenant != tenant && !p.Can(ScopeAdmin) {
+		return nil, false, ErrForbidden
+	}
+	if s.tenantDomains != nil {
+		list, err := s.tenantDomains.TenantDomains(ctx, tenant)
+		if err != nil || list != nil {
+			return list, list != nil, err
+		}
+	}
+	return []string{s.domains.Default()}, false, nil
+}
+
+// SetTenantDomains задаёт домены тенанта; пустой список возвращает домен
+// по умолчанию. Только для admin.
+func (s *Shortener) SetTenantDomains(ctx context.Context, tenant string, domains []string) ([]string, error) {
+	if p, ok := PrincipalFrom(ctx); ok && !p.Can(ScopeAdmin) {
+		s.record(ctx, AuditEvent{Action: "tenant.domains", Outcome: AuditDenied, Tenant: tenant, Target: tenant})
+		return nil, ErrForbidden
+	}
+	if s.tenantDomains == nil || tenant == "" {
+		return nil, ErrUnknownDomain
+	}
+	var list []string
+	for _, d := range domains {
+		key, ok := s.domains.Key(d)
+		if !ok || HostName(d) == "" {
+			return nil, ErrUnknownDomain
+		}
+		if h := s.domains.Host(key); !slices.Contains(list, h) {
+			list = append(list, h)
+		}
+	}
+	before, _, err := s.TenantDomains(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tenantDomains.SetTenantDomains(ctx, tenant, list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		list = []string{s.domains.Default()}
+	}
+	s.record(ctx, AuditEvent{Action: "tenant.domains", Tenant: tenant, Target: tenant, Before: before, After: list})
+	return list, nil
+}
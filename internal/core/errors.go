@@ -6,6 +6,14 @@ var (
 	ErrInvalidURL = errors.New("invalid url")
 	ErrNotFound   = errors.New("not found")
 	ErrConflict   = errors.New("conflict") // исчерпали попытки генерации
+
+	ErrInvalidSettings  = errors.New("invalid link settings")
+	ErrForbidden        = errors.New("forbidden") // ссылка принадлежит другому владельцу
+	ErrQuotaExceeded    = errors.New("quota exceeded") // см. QuotaError
+	ErrBannedDomain     = errors.New("destination domain is banned")
+	ErrUnknownDomain    = errors.New("unknown short domain")
+	ErrDomainNotAllowed = errors.New("short domain not allowed for tenant")
+	ErrTooManyLinks     = errors.New("too many links selected")
 	
 	ErrDupCode    = errors.New("duplicate code")
     ErrDupOrigin  = errors.New("duplicate original")
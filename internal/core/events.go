@@ -0,0 +1,28 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type EventType string
+
+const (
+	LinkCreated EventType = "link.created"
+	LinkUpdated EventType = "link.updated"
+	LinkDeleted EventType = "link.deleted"
+)
+
+// LinkEvent — изменение ссылки. Для LinkDeleted Link — состояние до
+// удаления.
+type LinkEvent struct {
+	Type EventType
+	Link Link
+	At   time.Time
+}
+
+// EventSink получает события после успешной записи в Store. Вызывается
+// синхронно из Shortener, поэтому не должен блокировать надолго.
+type EventSink interface {
+	PublishLinkEvent(ctx context.Context, e LinkEvent)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// flightTimeout ограничивает общий вызов store: он не наследует отмену
+// ни одного из ждущих.
+const flightTimeout = 10 * time.Second
+
+// flight склеивает одновременные чтения одной ссылки в один вызов store:
+// когда ссылка становится вирусной, холодный код иначе приходит в базу
+// тысячами одинаковых запросов.
+type flight struct {
+	mu    sync.Mutex
+	calls map[linkRef]*flightCall
+}
+
+type linkRef struct {
+	domain, code string
+}
+
+type flightCall struct {
+	done  chan struct{}
+	link  Link
+	found bool
+	err   error
+}
+
+// do возвращает результат fn для ref, запуская её, только если такой
+// вызов ещё не идёт. fn выполняется в своей горутине с контекстом без
+// отмены: ушедший по своему ctx вызывающий не обрывает её остальным.
+func (f *flight) do(ctx context.Context, ref linkRef, fn func(context.Context) (Link, bool, error)) (Link, bool, error) {
+	f.mu.Lock()
+	c, ok := f.calls[ref]
+	if ok {
+		lookups.WithLabelValues("coalesced").Inc()
+	} else {
+		if f.calls == nil {
+			f.calls = make(map[linkRef]*flightCall)
+		}
+		c = &flightCall{done: make(chan struct{})}
+		f.calls[ref] = c
+		lookups.WithLabelValues("leader").Inc()
+		go f.run(ctx, ref, c, fn)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.link, c.found, c.err
+	case <-ctx.Done():
+		abandoned.Inc()
+		return Link{}, false, ctx.Err()
+	}
+}
+
+func (f *flight) run(ctx context.Context, ref linkRef, c *flightCall, fn func(context.Context) (Link, bool, error)) {
+	defer func() {
+		f.mu.Lock()
+		if f.calls[ref] == c {
+			delete(f.calls, ref)
+		}
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flightTimeout)
+	defer cancel()
+	c.link, c.found, c.err = fn(ctx)
+}
+
+// forget отцепляет идущий вызов: пришедшие после записи не должны
+// получить прочитанное до неё.
+func (f *flight) forget(ref linkRef) {
+	f.mu.Lock()
+	delete(f.calls, ref)
+	f.mu.Unlock()
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+)
+
+// Link — короткая ссылка вместе с её настройками.
+type Link struct {
+	// Domain — ключ домена короткой ссылки, см. Domains; пусто — домен
+	// по умолчанию. Code уникален в пределах домена.
+	Domain   string
+	Code     string
+	Original string
+	// Owner и Tenant берутся из Principal при создании; пусто — ссылка
+	// ничья (создана анонимно или сервисным API-ключом).
+	Owner  string
+	Tenant string
+	// Disabled — ссылка отключена модератором: редирект не выполняется.
+	Disabled bool
+	Settings
+}
+
+// OriginKey — область, в которой один URL сокращается в одну ссылку: у
+// каждого владельца тенанта на каждом домене своя. Общая ссылка на всех
+// вернула бы вызывающему чужую ссылку, которую он не может ни смотреть,
+// ни менять.
+type OriginKey struct {
+	Tenant   string
+	Owner    string
+	Domain   string
+	Original string
+}
+
+func (l Link) OriginKey() OriginKey {
+	return OriginKey{Tenant: l.Tenant, Owner: l.Owner, Domain: l.Domain, Original: l.Original}
+}
+
+type Settings struct {
+	// ClickIDParam — имя query-параметра, под которым к URL назначения
+	// при каждом редиректе добавляется уникальный click id. Пусто — выключено.
+	ClickIDParam string
+	// Tags — метки ссылки для выборок (список, выгрузки), без повторов.
+	Tags []string
+}
+
+// MaxTags — сколько меток может быть у ссылки.
+const MaxTags = 20
+
+var (
+	paramRe = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+	tagRe   = regexp.MustCompile(`^[A-Za-z0-9_.:\-]{1,64}$`)
+)
+
+func ValidateSettings(s Settings) error {
+	if s.ClickIDParam != "" && !paramRe.MatchString(s.ClickIDParam) {
+		return ErrInvalidSettings
+	}
+	if len(s.Tags) > MaxTags {
+		return ErrInvalidSettings
+	}
+	for i, t := range s.Tags {
+		if !ValidTag(t) || slices.Contains(s.Tags[:i], t) {
+			return ErrInvalidSettings
+		}
+	}
+	return nil
+}
+
+// ValidTag сообщает, годится ли строка в метку: буквы, цифры и "_.:-",
+// не длиннее 64 символов.
+func ValidTag(t string) bool {
+	return tagRe.MatchString(t)
+}
+
+// HasTag сообщает, есть ли у ссылки метка.
+func (l Link) HasTag(t string) bool {
+	return slices.Contains(l.Tags, t)
+}
+
+// AppendParam дописывает name=value в query, не трогая порядок и
+// кодирование уже имеющихся параметров и фрагмент.
+func AppendParam(raw, name, value string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	kv := url.QueryEscape(name) + "=" + url.QueryEscape(value)
+	if u.RawQuery == "" {
+		u.RawQuery = kv
+	} else {
+		u.RawQuery += "&" + kv
+	}
+	u.ForceQuery = false
+	return u.String(), nil
+}
@@ -0,0 +1,17 @@
+package core
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_resolve_lookups_total",
+		Help: "Link lookups by code, by role (leader: called the store, coalesced: joined an in-flight call).",
+	}, []string{"role"})
+	abandoned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_resolve_abandoned_total",
+		Help: "Callers that stopped waiting for a shared lookup because their context ended.",
+	})
+)
@@ -2,9 +2,62 @@ package core
 
 import "context"
 
+// Store хранит ссылки. domain — ключ домена (см. Domains): код уникален в
+// пределах домена, оригинал — в пределах OriginKey.
 type Store interface {
-	GetByOriginal(ctx context.Context, original string) (code string, found bool, err error)
-	GetByCode(ctx context.Context, code string) (original string, found bool, err error)
-    Create(ctx context.Context, code, original string) error
+	GetByOriginal(ctx context.Context, k OriginKey) (code string, found bool, err error)
+	GetByCode(ctx context.Context, domain, code string) (link Link, found bool, err error)
+	Create(ctx context.Context, link Link) error
+	// Update сохраняет настройки существующей ссылки; Domain, Code и
+	// Original не меняются. Если кода нет — ErrNotFound.
+	Update(ctx context.Context, link Link) error
+	// Delete удаляет ссылку; код освобождается, а оригинал можно сократить
+	// заново. Если кода нет — ErrNotFound.
+	Delete(ctx context.Context, domain, code string) error
+	// SetDisabled включает или отключает ссылку и возвращает её. Если кода
+	// нет — ErrNotFound.
+	SetDisabled(ctx context.Context, domain, code string, disabled bool) (Link, error)
+	// List возвращает до f.Limit ссылок по возрастанию кода.
+	List(ctx context.Context, f LinkFilter) ([]Link, error)
 }
 
+// LinkFilter — выборка Store.List. Ссылки домена Domain и тенанта Tenant
+// (пустой — ничьи); AnyTenant снимает условие на тенанта. При непустом
+// Owner — только ссылки этого владельца, при непустом Tag — только с этой
+// меткой.
+type LinkFilter struct {
+	Domain    string
+	AnyTenant bool
+	Tenant    string
+	Owner     string
+	Tag       string
+	After     string // код, после которого продолжить
+	Limit     int
+}
+
+// Match проверяет ссылку домена f.Domain по условиям фильтра, кроме After
+// и Limit; для хранилищ, которые фильтруют на стороне приложения.
+func (f LinkFilter) Match(l Link) bool {
+	switch {
+	case !f.AnyTenant && l.Tenant != f.Tenant:
+		return false
+	case f.Owner != "" && l.Owner != f.Owner:
+		return false
+	case f.Tag != "" && !l.HasTag(f.Tag):
+		return false
+	}
+	return true
+}
+
+// DestinationChecker проверяет URL назначения новой ссылки, например по
+// списку запрещённых доменов. Отказ — ErrBannedDomain.
+type DestinationChecker interface {
+	CheckDestination(ctx context.Context, normalized string) error
+}
+
+// DestinationCheckFunc — DestinationChecker из функции.
+type DestinationCheckFunc func(ctx context.Context, normalized string) error
+
+func (f DestinationCheckFunc) CheckDestination(ctx context.Context, normalized string) error {
+	return f(ctx, normalized)
+}
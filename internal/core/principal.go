@@ -0,0 +1,67 @@
+package core
+
+import "context"
+
+// Scope — право доступа к API.
+type Scope string
+
+const (
+	ScopeLinksWrite Scope = "links:write"
+	ScopeLinksRead  Scope = "links:read"
+	ScopeStatsRead  Scope = "stats:read"
+	ScopeAdmin      Scope = "admin" // включает все остальные
+)
+
+var knownScopes = []Scope{ScopeLinksWrite, ScopeLinksRead, ScopeStatsRead, ScopeAdmin}
+
+// ValidScope сообщает, известен ли scope.
+func ValidScope(s Scope) bool {
+	for _, k := range knownScopes {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal — аутентифицированный вызывающий. ID — идентификатор
+// учётных данных (префикс API-ключа или sub токена). Owner и Tenant есть
+// только у пользователей из JWT; у сервисных API-ключей они пусты.
+type Principal struct {
+	ID     string
+	Name   string
+	Owner  string
+	Tenant string
+	Scopes []Scope
+}
+
+// Can сообщает, есть ли у вызывающего scope.
+func (p Principal) Can(s Scope) bool {
+	for _, have := range p.Scopes {
+		if have == s || have == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModify сообщает, может ли вызывающий менять ссылку: ничьи ссылки —
+// любой, свои — владелец в своём тенанте, любые — admin.
+func (p Principal) CanModify(l Link) bool {
+	if l.Owner == "" || p.Can(ScopeAdmin) {
+		return true
+	}
+	return l.Owner == p.Owner && l.Tenant == p.Tenant
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom возвращает вызывающего, если запрос аутентифицирован.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Quota — жёсткие лимиты тенанта. 0 — без ограничения.
+type Quota struct {
+	MaxLinks          int // одновременно существующих ссылок
+	MaxMonthlyCreates int // созданий за календарный месяц (UTC)
+}
+
+// QuotaUsage — потребление тенанта в текущем месяце против его квоты.
+type QuotaUsage struct {
+	Tenant         string
+	Quota          Quota
+	Custom         bool // квота задана админом, а не взята по умолчанию
+	ActiveLinks    int
+	MonthlyCreates int
+	Month          time.Time
+}
+
+// QuotaStore хранит квоты и счётчики. Сами счётчики ведёт Store: Create
+// проверяет квоту и учитывает ссылку в той же транзакции, Delete
+// освобождает место; ссылки без тенанта не учитываются.
+type QuotaStore interface {
+	QuotaUsage(ctx context.Context, tenant string, now time.Time) (QuotaUsage, error)
+	// SetQuota задаёт квоту тенанта; nil возвращает квоту по умолчанию.
+	SetQuota(ctx context.Context, tenant string, q *Quota) error
+}
+
+const (
+	QuotaActiveLinks    = "active_links"
+	QuotaMonthlyCreates = "monthly_creates"
+)
+
+// QuotaError — отказ Create по квоте; errors.Is(err, ErrQuotaExceeded).
+type QuotaError struct {
+	Tenant string
+	Kind   string // QuotaActiveLinks | QuotaMonthlyCreates
+	Limit  int
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Kind {
+	case QuotaMonthlyCreates:
+		return fmt.Sprintf("quota exceeded: tenant %q reached its limit of %d link creations this month", e.Tenant, e.Limit)
+	default:
+		return fmt.Sprintf("quota exceeded: tenant %q reached its limit of %d active links", e.Tenant, e.Limit)
+	}
+}
+
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// CheckQuota проверяет, можно ли тенанту создать ещё одну ссылку при
+// текущих счётчиках; для реализаций Store.
+func CheckQuota(tenant string, q Quota, active, monthly int) error {
+	if q.MaxLinks > 0 && active >= q.MaxLinks {
+		return &QuotaError{Tenant: tenant, Kind: QuotaActiveLinks, Limit: q.MaxLinks}
+	}
+	if q.MaxMonthlyCreates > 0 && monthly >= q.MaxMonthlyCreates {
+		return &QuotaError{Tenant: tenant, Kind: QuotaMonthlyCreates, Limit: q.MaxMonthlyCreates}
+	}
+	return nil
+}
+
+// MonthStart — начало календарного месяца t в UTC.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
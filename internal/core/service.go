@@ -1,6 +1,11 @@
 package core
 
-import "context"
+import (
+    "context"
+    "net/url"
+    "slices"
+    "time"
+)
 
 type CodeGenerator func(n int) (string, error)
 
@@ -8,64 +13,402 @@ type Shortener struct {
 	store Store
 	gen CodeGenerator
 	tries int
+	events []EventSink
+	authz Authorizer
+	audit Auditor
+	dest  DestinationChecker
+
+	domains       *Domains
+	tenantDomains TenantDomainStore
+	baseURL       *url.URL
+
+	flight flight
+}
+
+type Option func(*Shortener)
+
+// WithDestinationCheck проверяет URL каждой новой ссылки.
+func WithDestinationCheck(c DestinationChecker) Option {
+	return func(s *Shortener) { s.dest = c }
+}
+
+// WithEvents подписывает sinks на создание, изменение и удаление ссылок.
+func WithEvents(sinks ...EventSink) Option {
+	return func(s *Shortener) { s.events = append(s.events, sinks...) }
 }
 
-func NewShortener(store Store, gen CodeGenerator) *Shortener {
-	return &Shortener{store: store, gen: gen, tries: 6}
+func NewShortener(store Store, gen CodeGenerator, opts ...Option) *Shortener {
+	s := &Shortener{store: store, gen: gen, tries: 6}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 
+// CreateRequest — параметры создания ссылки. Settings применяются только
+// к новой ссылке: если URL уже сокращён на этом домене, возвращается
+// существующая как есть. Domain — имя домена, пусто — по умолчанию.
+type CreateRequest struct {
+    URL    string
+    Domain string
+    Settings
+}
+
 func (s *Shortener) Create(ctx context.Context, raw string) (string, error) {
-    normalized, err := ValidateURL(raw)
+    link, err := s.CreateLink(ctx, CreateRequest{URL: raw})
     if err != nil {
-        return "", ErrInvalidURL
+        return "", err
     }
+    return link.Code, nil
+}
 
-    if code, found, err := s.store.GetByOriginal(ctx, normalized); err != nil {
-        return "", err
-    } else if found {
-        return code, nil
+func (s *Shortener) CreateLink(ctx context.Context, req CreateRequest) (Link, error) {
+    normalized, err := ValidateURL(req.URL)
+    if err != nil {
+        return Link{}, ErrInvalidURL
+    }
+    if err := ValidateSettings(req.Settings); err != nil {
+        return Link{}, err
+    }
+
+    owner, tenant := "", ""
+    if p, ok := PrincipalFrom(ctx); ok {
+        owner, tenant = p.Owner, p.Tenant
+    }
+    if err := s.authorize(ctx, ActionCreate, Link{Original: normalized, Owner: owner, Tenant: tenant, Settings: req.Settings}); err != nil {
+        return Link{}, err
+    }
+    domain, err := s.createDomain(ctx, req.Domain)
+    if err != nil {
+        return Link{}, err
+    }
+
+    if s.dest != nil {
+        if err := s.dest.CheckDestination(ctx, normalized); err != nil {
+            return Link{}, err
+        }
+    }
+
+    key := OriginKey{Tenant: tenant, Owner: owner, Domain: domain, Original: normalized}
+    if link, found, err := s.existing(ctx, key); err != nil || found {
+        return link, err
     }
 
     for i := 0; i < s.tries; i++ {
         code, err := s.gen(CodeLen)
         if err != nil {
-            return "", err
+            return Link{}, err
         }
         if !IsValidCode(code) {
             continue
         }
 
-        err = s.store.Create(ctx, code, normalized)
+        link := Link{Domain: domain, Code: code, Original: normalized, Owner: owner, Tenant: tenant, Settings: req.Settings}
+        err = s.store.Create(ctx, link)
         switch err {
         case nil:
-            return code, nil
+            s.flight.forget(linkRef{domain, code})
+            s.record(ctx, AuditEvent{Action: "link.create", Tenant: link.Tenant, Target: link.Code, After: StateOf(link)})
+            s.emit(ctx, LinkCreated, link)
+            return link, nil
         case ErrDupCode:
             continue
         case ErrDupOrigin:
-            if c, found, e2 := s.store.GetByOriginal(ctx, normalized); e2 != nil {
-                return "", e2
+            if link, found, e2 := s.existing(ctx, key); e2 != nil {
+                return Link{}, e2
             } else if found {
-                return c, nil
+                return link, nil
             }
             continue
         default:
-            return "", err
+            return Link{}, err
         }
     }
-    return "", ErrConflict
+    return Link{}, ErrConflict
 }
 
-func (s *Shortener) Resolve(ctx context.Context, code string) (string, error) {
-    if !IsValidCode(code) {
-        return "", ErrNotFound
+// existing ищет URL, уже сокращённый тем же владельцем на домене, и
+// возвращает его ссылку целиком.
+func (s *Shortener) existing(ctx context.Context, k OriginKey) (Link, bool, error) {
+    code, found, err := s.store.GetByOriginal(ctx, k)
+    if err != nil || !found {
+        return Link{}, false, err
+    }
+    link, found, err := s.store.GetByCode(ctx, k.Domain, code)
+    if err != nil {
+        return Link{}, false, err
     }
-    original, found, err := s.store.GetByCode(ctx, code)
+    if !found {
+        // гонка с удалением не страшна: хватит кода и оригинала
+        return Link{Domain: k.Domain, Code: code, Original: k.Original, Owner: k.Owner, Tenant: k.Tenant}, true, nil
+    }
+    return link, true, nil
+}
+
+// Resolve возвращает оригинал ссылки на домене по умолчанию.
+func (s *Shortener) Resolve(ctx context.Context, code string) (string, error) {
+    link, err := s.ResolveLink(ctx, "", code)
     if err != nil {
         return "", err
     }
+    return link.Original, nil
+}
+
+// ResolveLink находит ссылку по имени домена (пусто — по умолчанию) и
+// коду. Необслуживаемый домен — ErrNotFound.
+func (s *Shortener) ResolveLink(ctx context.Context, domain, code string) (Link, error) {
+    key, ok := s.domains.Key(domain)
+    if !ok || !IsValidCode(code) {
+        return Link{}, ErrNotFound
+    }
+    link, found, err := s.flight.do(ctx, linkRef{key, code}, func(ctx context.Context) (Link, bool, error) {
+        return s.store.GetByCode(ctx, key, code)
+    })
+    if err != nil {
+        return Link{}, err
+    }
     if !found {
-        return "", ErrNotFound
+        return Link{}, ErrNotFound
+    }
+    return link, nil
+}
+
+// UpdateSettings заменяет настройки ссылки целиком.
+func (s *Shortener) UpdateSettings(ctx context.Context, domain, code string, settings Settings) (Link, error) {
+    if err := ValidateSettings(settings); err != nil {
+        return Link{}, err
+    }
+    link, err := s.permitted(ctx, ActionUpdate, domain, code)
+    if err != nil {
+        return Link{}, err
+    }
+    before := StateOf(link)
+    link.Settings = settings
+    if err := s.store.Update(ctx, link); err != nil {
+        return Link{}, err
+    }
+    s.flight.forget(linkRef{link.Domain, code})
+    s.record(ctx, AuditEvent{Action: "link.update", Tenant: link.Tenant, Target: code, Before: before, After: StateOf(link)})
+    s.emit(ctx, LinkUpdated, link)
+    return link, nil
+}
+
+// Delete удаляет ссылку по домену и коду.
+func (s *Shortener) Delete(ctx context.Context, domain, code string) error {
+    link, err := s.permitted(ctx, ActionDelete, domain, code)
+    if err != nil {
+        return err
+    }
+    if err := s.store.Delete(ctx, link.Domain, code); err != nil {
+        return err
+    }
+    s.flight.forget(linkRef{link.Domain, code})
+    s.record(ctx, AuditEvent{Action: "link.delete", Tenant: link.Tenant, Target: code, Before: StateOf(link)})
+    s.emit(ctx, LinkDeleted, link)
+    return nil
+}
+
+// SetDisabled отключает ссылку (редирект перестаёт работать) или
+// включает обратно; reason попадает в журнал аудита. Только для admin.
+func (s *Shortener) SetDisabled(ctx context.Context, domain, code string, disabled bool, reason string) (Link, error) {
+    if !IsValidCode(code) {
+        return Link{}, ErrNotFound
+    }
+    action := "link.restore"
+    if disabled {
+        action = "link.disable"
+    }
+    if p, ok := PrincipalFrom(ctx); ok && !p.Can(ScopeAdmin) {
+        s.record(ctx, AuditEvent{Action: action, Outcome: AuditDenied, Target: code})
+        return Link{}, ErrForbidden
+    }
+    before, err := s.ResolveLink(ctx, domain, code)
+    if err != nil {
+        return Link{}, err
+    }
+    link, err := s.store.SetDisabled(ctx, before.Domain, code, disabled)
+    if err != nil {
+        return Link{}, err
+    }
+    s.flight.forget(linkRef{link.Domain, code})
+    type change struct {
+        Disabled bool   `json:"disabled"`
+        Reason   string `json:"reason,omitempty"`
+    }
+    s.record(ctx, AuditEvent{
+        Action: action, Tenant: link.Tenant, Target: code,
+        Before: change{Disabled: before.Disabled},
+        After:  change{Disabled: disabled, Reason: reason},
+    })
+    s.emit(ctx, LinkUpdated, link)
+    return link, nil
+}
+
+// StatsLink находит ссылку и проверяет право смотреть её статистику и
+// поток кликов.
+func (s *Shortener) StatsLink(ctx context.Context, domain, code string) (Link, error) {
+    return s.permitted(ctx, ActionStats, domain, code)
+}
+
+// LinkSelector — ссылки домена Domain для выгрузки: перечисленные коды
+// и/или все ссылки владельца Owner и с меткой Tag.
+type LinkSelector struct {
+    Domain string
+    Codes  []string
+    Owner  string
+    Tag    string
+}
+
+// StatsLinks раскрывает выборку в коды ссылок, статистику которых может
+// смотреть вызывающий, и возвращает их вместе с ключом домена. Каждый
+// перечисленный код проверяется как в StatsLink: первый отказ прерывает
+// проверку. По Owner и Tag берутся ссылки, видимые в List, без тех, на
+// статистику которых нет права. Больше limit кодов — ErrTooManyLinks.
+func (s *Shortener) StatsLinks(ctx context.Context, sel LinkSelector, limit int) (string, []string, error) {
+    key, ok := s.domains.Key(sel.Domain)
+    if !ok {
+        return "", nil, ErrUnknownDomain
+    }
+    if len(sel.Codes) > limit {
+        return "", nil, ErrTooManyLinks
+    }
+    var codes []string
+    add := func(code string) error {
+        if slices.Contains(codes, code) {
+            return nil
+        }
+        if len(codes) == limit {
+            return ErrTooManyLinks
+        }
+        codes = append(codes, code)
+        return nil
+    }
+    for _, code := range sel.Codes {
+        if _, err := s.StatsLink(ctx, sel.Domain, code); err != nil {
+            return "", nil, err
+        }
+        if err := add(code); err != nil {
+            return "", nil, err
+        }
+    }
+    if sel.Owner == "" && sel.Tag == "" {
+        return key, codes, nil
+    }
+
+    p, authn := PrincipalFrom(ctx)
+    req := ListRequest{Domain: sel.Domain, Owner: sel.Owner, Tag: sel.Tag, Limit: maxListLimit}
+    for {
+        links, next, err := s.List(ctx, req)
+        if err != nil {
+            return "", nil, err
+        }
+        for _, link := range links {
+            // отказ здесь — не нарушение, а сужение выборки, в аудит не пишем
+            if authn {
+                if err := s.check(ctx, p, ActionStats, link); err == ErrForbidden {
+                    continue
+                } else if err != nil {
+                    return "", nil, err
+                }
+            }
+            if err := add(link.Code); err != nil {
+                return "", nil, err
+            }
+        }
+        if next == "" {
+            return key, codes, nil
+        }
+        req.After = next
+    }
+}
+
+// ListRequest — страница списка: ссылки домена Domain (пусто — по
+// умолчанию) по возрастанию кода после After. Непустые Owner и Tag
+// сужают выборку до ссылок владельца и ссылок с меткой.
+type ListRequest struct {
+    Domain string
+    Owner  string
+    Tag    string
+    After  string
+    Limit  int
+}
+
+const (
+    defaultListLimit = 50
+    maxListLimit     = 1000
+)
+
+// List возвращает ссылки, видимые вызывающему: admin и запросы без
+// аутентификации видят все, остальные — ссылки своего тенанта, а если на
+// это нет права — только свои. next — After следующей страницы, пусто на
+// последней.
+func (s *Shortener) List(ctx context.Context, req ListRequest) (links []Link, next string, err error) {
+    limit := req.Limit
+    if limit <= 0 {
+        limit = defaultListLimit
+    }
+    limit = min(limit, maxListLimit)
+    key, ok := s.domains.Key(req.Domain)
+    if !ok {
+        return nil, "", ErrUnknownDomain
+    }
+    f := LinkFilter{Domain: key, Tag: req.Tag, After: req.After, Limit: limit + 1}
+
+    p, ok := PrincipalFrom(ctx)
+    if !ok || p.Can(ScopeAdmin) {
+        f.AnyTenant = true
+    } else {
+        f.Tenant = p.Tenant
+        // отказ в списке тенанта — не нарушение, а переход к своим ссылкам
+        err = s.check(ctx, p, ActionList, Link{Tenant: p.Tenant})
+        if err == ErrForbidden && p.Owner != "" {
+            f.Owner = p.Owner
+            err = s.authorize(ctx, ActionList, Link{Tenant: p.Tenant, Owner: p.Owner})
+        }
+        if err != nil {
+            return nil, "", err
+        }
+    }
+
+    if req.Owner != "" {
+        // без права на список тенанта видны только свои ссылки
+        if f.Owner != "" && f.Owner != req.Owner {
+            return nil, "", nil
+        }
+        f.Owner = req.Owner
+    }
+
+    links, err = s.store.List(ctx, f)
+    if err != nil {
+        return nil, "", err
+    }
+    if len(links) > limit {
+        links = links[:limit]
+        next = links[limit-1].Code
+    }
+    return links, next, nil
+}
+
+// permitted находит ссылку и проверяет, что вызывающий из контекста может
+// выполнить над ней действие.
+func (s *Shortener) permitted(ctx context.Context, a Action, domain, code string) (Link, error) {
+    link, err := s.ResolveLink(ctx, domain, code)
+    if err != nil {
+        return Link{}, err
+    }
+    if err := s.authorize(ctx, a, link); err != nil {
+        return Link{}, err
+    }
+    return link, nil
+}
+
+func (s *Shortener) emit(ctx context.Context, typ EventType, link Link) {
+    if len(s.events) == 0 {
+        return
+    }
+    e := LinkEvent{Type: typ, Link: link, At: time.Now().UTC()}
+    for _, sink := range s.events {
+        sink.PublishLinkEvent(ctx, e)
     }
-    return original, nil
 }
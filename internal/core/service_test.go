@@ -2,15 +2,25 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 
 type fakeStore struct {
 	mu     sync.Mutex
-	byOrig map[string]string 
+	byOrig map[OriginKey]string 
 	byCode map[string]string 
+	settings map[string]Settings
+	owners   map[string][2]string // code -> owner, tenant
 
 	dupCodeLeft   int    
 	forceDupOrig  bool  
@@ -20,32 +30,43 @@ type fakeStore struct {
 
 func newFakeStore() *fakeStore {
 	return &fakeStore{
-		byOrig: make(map[string]string),
+		byOrig: make(map[OriginKey]string),
 		byCode: make(map[string]string),
+		settings: make(map[string]Settings),
+		owners:   make(map[string][2]string),
 	}
 }
 
-func (s *fakeStore) GetByOriginal(ctx context.Context, original string) (string, bool, error) {
+// ключи карт fakeStore, кроме byOrig, — домен и код через "|"
+func fk(domain, v string) string { return domain + "|" + v }
+
+func (s *fakeStore) GetByOriginal(ctx context.Context, k OriginKey) (string, bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	c, ok := s.byOrig[original]
+	c, ok := s.byOrig[k]
 	return c, ok, nil
 }
 
-func (s *fakeStore) GetByCode(ctx context.Context, code string) (string, bool, error) {
+func (s *fakeStore) GetByCode(ctx context.Context, domain, code string) (Link, bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	o, ok := s.byCode[code]
-	return o, ok, nil
+	k := fk(domain, code)
+	o, ok := s.byCode[k]
+	if !ok {
+		return Link{}, false, nil
+	}
+	ow := s.owners[k]
+	return Link{Domain: domain, Code: code, Original: o, Owner: ow[0], Tenant: ow[1], Settings: s.settings[k]}, true, nil
 }
 
-func (s *fakeStore) Create(ctx context.Context, code, original string) error {
+func (s *fakeStore) Create(ctx context.Context, link Link) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	code, original := fk(link.Domain, link.Code), link.OriginKey()
 
-	if s.forceDupOrig && original == s.existingOrig {
-		s.byOrig[s.existingOrig] = s.existingCode
-		s.byCode[s.existingCode] = s.existingOrig
+	if s.forceDupOrig && link.Original == s.existingOrig {
+		s.byOrig[original] = s.existingCode
+		s.byCode[fk(link.Domain, s.existingCode)] = s.existingOrig
 		return ErrDupOrigin
 	}
 
@@ -61,11 +82,71 @@ func (s *fakeStore) Create(ctx context.Context, code, original string) error {
 		return ErrDupCode
 	}
 
-	s.byOrig[original] = code
-	s.byCode[code] = original
+	s.byOrig[original] = link.Code
+	s.byCode[code] = link.Original
+	s.settings[code] = link.Settings
+	s.owners[code] = [2]string{link.Owner, link.Tenant}
+	return nil
+}
+
+func (s *fakeStore) Update(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := fk(link.Domain, link.Code)
+	if _, ok := s.byCode[k]; !ok {
+		return ErrNotFound
+	}
+	s.settings[k] = link.Settings
+	return nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, domain, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := fk(domain, code)
+	o, ok := s.byCode[k]
+	if !ok {
+		return ErrNotFound
+	}
+	ow := s.owners[k]
+	delete(s.byCode, k)
+	delete(s.byOrig, OriginKey{Tenant: ow[1], Owner: ow[0], Domain: domain, Original: o})
+	delete(s.settings, k)
 	return nil
 }
 
+func (s *fakeStore) SetDisabled(ctx context.Context, domain, code string, disabled bool) (Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := fk(domain, code)
+	o, ok := s.byCode[k]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+	// fakeStore не хранит флаг: тестам ядра хватает возвращённой ссылки
+	ow := s.owners[k]
+	return Link{Domain: domain, Code: code, Original: o, Owner: ow[0], Tenant: ow[1], Disabled: disabled, Settings: s.settings[k]}, nil
+}
+
+func (s *fakeStore) List(ctx context.Context, f LinkFilter) ([]Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Link
+	for k, o := range s.byCode {
+		domain, code, _ := strings.Cut(k, "|")
+		ow := s.owners[k]
+		link := Link{Domain: domain, Code: code, Original: o, Owner: ow[0], Tenant: ow[1], Settings: s.settings[k]}
+		if domain == f.Domain && code > f.After && f.Match(link) {
+			out = append(out, link)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	if len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
 func stubGen(seq ...string) CodeGenerator {
 	i := 0
 	return func(n int) (string, error) {
@@ -156,8 +237,8 @@ func TestCreate_InvalidURL_ReturnsErrInvalidURL(t *testing.T) {
 
 func TestResolve_Found(t *testing.T) {
 	store := newFakeStore()
-	store.byOrig["https://example.com/a"] = "AAAAAAAAAA"
-	store.byCode["AAAAAAAAAA"] = "https://example.com/a"
+	store.byOrig[OriginKey{Original: "https://example.com/a"}] = "AAAAAAAAAA"
+	store.byCode[fk("", "AAAAAAAAAA")] = "https://example.com/a"
 
 	svc := NewShortener(store, stubGen("ignored"))
 	u, err := svc.Resolve(context.Background(), "AAAAAAAAAA")
@@ -181,3 +262,409 @@ func TestResolve_NotFound(t *testing.T) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestCreateLink_KeepsSettings_UpdateSettings(t *testing.T) {
+	store := newFakeStore()
+	svc := NewShortener(store, stubGen("AAAAAAAAAA"))
+
+	link, err := svc.CreateLink(context.Background(), CreateRequest{
+		URL:      "https://example.com/landing",
+		Settings: Settings{ClickIDParam: "clid"},
+	})
+	if err != nil {
+		t.Fatalf("CreateLink err: %v", err)
+	}
+	got, err := svc.ResolveLink(context.Background(), "", link.Code)
+	if err != nil || got.ClickIDParam != "clid" {
+		t.Fatalf("ResolveLink=%+v, %v; want click_id_param kept", got, err)
+	}
+
+	if _, err := svc.UpdateSettings(context.Background(), "", link.Code, Settings{ClickIDParam: "bad param"}); err != ErrInvalidSettings {
+		t.Fatalf("expected ErrInvalidSettings, got %v", err)
+	}
+	upd, err := svc.UpdateSettings(context.Background(), "", link.Code, Settings{})
+	if err != nil || upd.ClickIDParam != "" {
+		t.Fatalf("UpdateSettings=%+v, %v; want click id disabled", upd, err)
+	}
+	if _, err := svc.UpdateSettings(context.Background(), "", "ZZZZZZZZZZ", Settings{}); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+type recordedEvents []LinkEvent
+
+func (r *recordedEvents) PublishLinkEvent(ctx context.Context, e LinkEvent) {
+	*r = append(*r, e)
+}
+
+func TestLifecycleEvents_AndDelete(t *testing.T) {
+	store := newFakeStore()
+	var got recordedEvents
+	svc := NewShortener(store, stubGen("AAAAAAAAAA"), WithEvents(&got))
+	ctx := context.Background()
+
+	code, err := svc.Create(ctx, "https://example.com/a")
+	if err != nil {
+		t.Fatalf("Create err: %v", err)
+	}
+	if _, err := svc.Create(ctx, "https://example.com/a"); err != nil {
+		t.Fatalf("repeat Create err: %v", err)
+	}
+	if _, err := svc.UpdateSettings(ctx, "", code, Settings{ClickIDParam: "clid"}); err != nil {
+		t.Fatalf("UpdateSettings err: %v", err)
+	}
+	if err := svc.Delete(ctx, "", code); err != nil {
+		t.Fatalf("Delete err: %v", err)
+	}
+	if err := svc.Delete(ctx, "", code); err != ErrNotFound {
+		t.Fatalf("second Delete: expected ErrNotFound, got %v", err)
+	}
+	if _, err := svc.Resolve(ctx, code); err != ErrNotFound {
+		t.Fatalf("Resolve after Delete: expected ErrNotFound, got %v", err)
+	}
+
+	want := []EventType{LinkCreated, LinkUpdated, LinkDeleted}
+	if len(got) != len(want) {
+		t.Fatalf("events=%+v, want %v", got, want)
+	}
+	for i, e := range got {
+		if e.Type != want[i] || e.Link.Code != code {
+			t.Fatalf("event %d = %+v, want %s for %s", i, e, want[i], code)
+		}
+	}
+	if got[2].Link.ClickIDParam != "clid" {
+		t.Fatalf("deleted event must carry the last state: %+v", got[2].Link)
+	}
+}
+
+func TestAppendParam(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://a.b/landing", "https://a.b/landing?clid=X1"},
+		{"https://a.b/landing?utm_source=tg&b=%2F", "https://a.b/landing?utm_source=tg&b=%2F&clid=X1"},
+		{"https://a.b/p#frag", "https://a.b/p?clid=X1#frag"},
+	}
+	for _, tt := range tests {
+		got, err := AppendParam(tt.in, "clid", "X1")
+		if err != nil || got != tt.want {
+			t.Fatalf("AppendParam(%q)=%q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestStatsLinks_CodesAndTag(t *testing.T) {
+	ctx := context.Background()
+	st := newFakeStore()
+	svc := NewShortener(st, stubGen("AAAAAAAAAA", "BBBBBBBBBB", "CCCCCCCCCC"))
+	for i, u := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		var tags []string
+		if i != 1 {
+			tags = []string{"spring", "promo"}
+		}
+		if _, err := svc.CreateLink(ctx, CreateRequest{URL: u, Settings: Settings{Tags: tags}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, codes, err := svc.StatsLinks(ctx, LinkSelector{Codes: []string{"CCCCCCCCCC", "BBBBBBBBBB"}, Tag: "spring"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CCCCCCCCCC", "BBBBBBBBBB", "AAAAAAAAAA"}; fmt.Sprint(codes) != fmt.Sprint(want) {
+		t.Fatalf("codes=%v want=%v", codes, want)
+	}
+	if _, _, err := svc.StatsLinks(ctx, LinkSelector{Codes: []string{"BBBBBBBBBB"}, Tag: "promo"}, 2); err != ErrTooManyLinks {
+		t.Fatalf("over limit: err=%v", err)
+	}
+	if _, codes, err := svc.StatsLinks(ctx, LinkSelector{Tag: "autumn"}, 10); err != nil || len(codes) != 0 {
+		t.Fatalf("unknown tag: codes=%v err=%v", codes, err)
+	}
+
+	for _, tags := range [][]string{{"a b"}, {"x", "x"}, {""}} {
+		if _, err := svc.UpdateSettings(ctx, "", "AAAAAAAAAA", Settings{Tags: tags}); err != ErrInvalidSettings {
+			t.Fatalf("tags %q: err=%v", tags, err)
+		}
+	}
+}
+
+func TestOwnership_EnforcedForPrincipals(t *testing.T) {
+	svc := NewShortener(newFakeStore(), NewCode)
+	alice := WithPrincipal(context.Background(), Principal{ID: "alice", Owner: "alice", Tenant: "acme", Scopes: []Scope{ScopeLinksWrite}})
+	bob := WithPrincipal(context.Background(), Principal{ID: "bob", Owner: "bob", Tenant: "acme", Scopes: []Scope{ScopeLinksWrite}})
+	admin := WithPrincipal(context.Background(), Principal{ID: "root", Scopes: []Scope{ScopeAdmin}})
+
+	link, err := svc.CreateLink(alice, CreateRequest{URL: "https://example.com/owned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Owner != "alice" || link.Tenant != "acme" {
+		t.Fatalf("owner=%q tenant=%q", link.Owner, link.Tenant)
+	}
+	if _, err := svc.UpdateSettings(bob, "", link.Code, Settings{ClickIDParam: "x"}); err != ErrForbidden {
+		t.Fatalf("foreign update err=%v, want ErrForbidden", err)
+	}
+	if err := svc.Delete(bob, "", link.Code); err != ErrForbidden {
+		t.Fatalf("foreign delete err=%v, want ErrForbidden", err)
+	}
+	if _, err := svc.UpdateSettings(alice, "", link.Code, Settings{ClickIDParam: "x"}); err != nil {
+		t.Fatalf("owner update err=%v", err)
+	}
+	if err := svc.Delete(admin, "", link.Code); err != nil {
+		t.Fatalf("admin delete err=%v", err)
+	}
+
+	anon, err := svc.CreateLink(context.Background(), CreateRequest{URL: "https://example.com/anon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(bob, "", anon.Code); err != nil {
+		t.Fatalf("unowned link delete err=%v", err)
+	}
+}
+
+func TestCreate_DedupeScopedToOwnerAndTenant(t *testing.T) {
+	svc := NewShortener(newFakeStore(), NewCode)
+	user := func(id, tenant string) context.Context {
+		return WithPrincipal(context.Background(), Principal{ID: id, Owner: id, Tenant: tenant, Scopes: []Scope{ScopeLinksWrite}})
+	}
+	const u = "https://example.com/shared"
+	codes := map[string]string{}
+	for _, who := range []struct{ id, tenant string }{{"alice", "acme"}, {"bob", "acme"}, {"alice", "other"}, {"", ""}} {
+		ctx := context.Background()
+		if who.id != "" {
+			ctx = user(who.id, who.tenant)
+		}
+		link, err := svc.CreateLink(ctx, CreateRequest{URL: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.Owner != who.id || link.Tenant != who.tenant {
+			t.Fatalf("%s@%s got link of %q@%q", who.id, who.tenant, link.Owner, link.Tenant)
+		}
+		for k, c := range codes {
+			if c == link.Code {
+				t.Fatalf("%s@%s reused the link of %s", who.id, who.tenant, k)
+			}
+		}
+		codes[who.id+"@"+who.tenant] = link.Code
+	}
+
+	again, err := svc.CreateLink(user("alice", "acme"), CreateRequest{URL: u})
+	if err != nil || again.Code != codes["alice@acme"] {
+		t.Fatalf("same owner: code=%q err=%v, want %q", again.Code, err, codes["alice@acme"])
+	}
+}
+
+// denyStats запрещает статистику перечисленных кодов.
+type denyStats map[string]bool
+
+func (d denyStats) Authorize(ctx context.Context, p Principal, a Action, link Link) error {
+	if a == ActionStats && d[link.Code] {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func TestStatsLinks_SelectorsAndAuthorization(t *testing.T) {
+	svc := NewShortener(newFakeStore(), stubGen("AAAAAAAAAA", "BBBBBBBBBB", "CCCCCCCCCC"), WithAuthorizer(denyStats{"BBBBBBBBBB": true}))
+	user := func(id, tenant string) context.Context {
+		return WithPrincipal(context.Background(), Principal{ID: id, Owner: id, Tenant: tenant, Scopes: []Scope{ScopeLinksWrite, ScopeStatsRead}})
+	}
+	alice, bob, eve := user("alice", "acme"), user("bob", "acme"), user("eve", "other")
+	for i, c := range []struct {
+		ctx  context.Context
+		tags []string
+	}{{alice, []string{"spring"}}, {alice, []string{"spring", "promo"}}, {bob, []string{"spring"}}} {
+		if _, err := svc.CreateLink(c.ctx, CreateRequest{URL: fmt.Sprintf("https://example.com/%d", i), Settings: Settings{Tags: c.tags}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		ctx   context.Context
+		sel   LinkSelector
+		limit int
+		want  []string
+		err   error
+	}{
+		{"tag skips links without stats access", alice, LinkSelector{Tag: "spring"}, 10, []string{"AAAAAAAAAA", "CCCCCCCCCC"}, nil},
+		{"owner and tag", alice, LinkSelector{Owner: "bob", Tag: "spring"}, 10, []string{"CCCCCCCCCC"}, nil},
+		{"codes and tag without duplicates", alice, LinkSelector{Codes: []string{"CCCCCCCCCC"}, Tag: "spring"}, 10, []string{"CCCCCCCCCC", "AAAAAAAAAA"}, nil},
+		{"explicit forbidden code", alice, LinkSelector{Codes: []string{"BBBBBBBBBB"}}, 10, nil, ErrForbidden},
+		{"explicit unknown code", alice, LinkSelector{Codes: []string{"ZZZZZZZZZZ"}}, 10, nil, ErrNotFound},
+		{"other tenant sees nothing", eve, LinkSelector{Tag: "spring"}, 10, nil, nil},
+		{"over the limit", alice, LinkSelector{Tag: "spring"}, 1, nil, ErrTooManyLinks},
+	} {
+		_, codes, err := svc.StatsLinks(tc.ctx, tc.sel, tc.limit)
+		if err != tc.err || fmt.Sprint(codes) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: codes=%v err=%v, want %v %v", tc.name, codes, err, tc.want, tc.err)
+		}
+	}
+
+	if err := ValidateSettings(Settings{Tags: []string{"a", "a"}}); err != ErrInvalidSettings {
+		t.Fatalf("duplicate tags err=%v", err)
+	}
+	if err := ValidateSettings(Settings{Tags: []string{"has space"}}); err != ErrInvalidSettings {
+		t.Fatalf("tag with a space err=%v", err)
+	}
+}
+
+type tenantDomainsMap map[string][]string
+
+func (m tenantDomainsMap) TenantDomains(ctx context.Context, tenant string) ([]string, error) {
+	return m[tenant], nil
+}
+
+func (m tenantDomainsMap) SetTenantDomains(ctx context.Context, tenant string, domains []string) error {
+	if len(domains) == 0 {
+		delete(m, tenant)
+	} else {
+		m[tenant] = domains
+	}
+	return nil
+}
+
+func TestDomains_SameCodeOnTwoDomainsAndTenantAllowList(t *testing.T) {
+	d, err := NewDomains([]string{"sho.rt", "go.acme.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewShortener(newFakeStore(), stubGen("AAAAAAAAAA", "AAAAAAAAAA", "BBBBBBBBBB"), WithDomains(d, tenantDomainsMap{}))
+	admin := WithPrincipal(context.Background(), Principal{ID: "root", Scopes: []Scope{ScopeAdmin}})
+	alice := WithPrincipal(context.Background(), Principal{ID: "alice", Owner: "alice", Tenant: "acme", Scopes: []Scope{ScopeLinksWrite}})
+
+	def, err := svc.CreateLink(admin, CreateRequest{URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := svc.CreateLink(admin, CreateRequest{URL: "https://example.com/b", Domain: "go.acme.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Code != acme.Code || def.Domain != "" || acme.Domain != "go.acme.com" {
+		t.Fatalf("def=%+v acme=%+v", def, acme)
+	}
+	if l, err := svc.ResolveLink(context.Background(), "GO.ACME.COM:443", acme.Code); err != nil || l.Original != acme.Original {
+		t.Fatalf("resolve on go.acme.com: %+v, %v", l, err)
+	}
+	if l, err := svc.ResolveLink(context.Background(), "sho.rt", def.Code); err != nil || l.Original != def.Original {
+		t.Fatalf("resolve on default: %+v, %v", l, err)
+	}
+	if _, err := svc.ResolveLink(context.Background(), "evil.example", def.Code); err != ErrNotFound {
+		t.Fatalf("unknown domain err=%v, want ErrNotFound", err)
+	}
+
+	if _, err := svc.CreateLink(alice, CreateRequest{URL: "https://example.com/c", Domain: "nope.example"}); err != ErrUnknownDomain {
+		t.Fatalf("unknown domain create err=%v", err)
+	}
+	if _, err := svc.CreateLink(alice, CreateRequest{URL: "https://example.com/c", Domain: "go.acme.com"}); err != ErrDomainNotAllowed {
+		t.Fatalf("tenant without list err=%v, want ErrDomainNotAllowed", err)
+	}
+	if _, err := svc.SetTenantDomains(alice, "acme", []string{"go.acme.com"}); err != ErrForbidden {
+		t.Fatalf("non-admin set err=%v", err)
+	}
+	if _, err := svc.SetTenantDomains(admin, "acme", []string{"go.acme.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if list, custom, err := svc.TenantDomains(alice, "acme"); err != nil || !custom || len(list) != 1 || list[0] != "go.acme.com" {
+		t.Fatalf("tenant domains: %v %v %v", list, custom, err)
+	}
+	if l, err := svc.CreateLink(alice, CreateRequest{URL: "https://example.com/c", Domain: "go.acme.com"}); err != nil || l.Domain != "go.acme.com" {
+		t.Fatalf("allowed create: %+v, %v", l, err)
+	}
+	if _, err := svc.CreateLink(alice, CreateRequest{URL: "https://example.com/d"}); err != ErrDomainNotAllowed {
+		t.Fatalf("default domain no longer allowed, err=%v", err)
+	}
+}
+
+func TestShortURL_FromBaseURL(t *testing.T) {
+	if _, ok := NewShortener(newFakeStore(), NewCode).ShortURL(Link{Code: "AAAAAAAAAA"}); ok {
+		t.Fatal("no base url must leave short url to the transport")
+	}
+	base, _ := url.Parse("http://sho.rt:8080/s/")
+	svc := NewShortener(newFakeStore(), NewCode, WithPublicBaseURL(base))
+	if got, _ := svc.ShortURL(Link{Code: "AAAAAAAAAA"}); got != "http://sho.rt:8080/s/AAAAAAAAAA" {
+		t.Fatalf("default domain: %q", got)
+	}
+	if got, _ := svc.ShortURL(Link{Domain: "go.acme.com", Code: "AAAAAAAAAA"}); got != "http://go.acme.com:8080/s/AAAAAAAAAA" {
+		t.Fatalf("other domain: %q", got)
+	}
+}
+
+// gatedStore держит GetByCode, пока не закрыт gate.
+type gatedStore struct {
+	*fakeStore
+	gate    chan struct{}
+	entered chan struct{}
+	calls   atomic.Int32
+	ctxErr  error
+}
+
+func (s *gatedStore) GetByCode(ctx context.Context, domain, code string) (Link, bool, error) {
+	if s.calls.Add(1) == 1 {
+		close(s.entered)
+	}
+	<-s.gate
+	s.ctxErr = ctx.Err()
+	return s.fakeStore.GetByCode(ctx, domain, code)
+}
+
+func TestResolveLink_CoalescesConcurrentLookups(t *testing.T) {
+	fs := newFakeStore()
+	st := &gatedStore{fakeStore: fs, gate: make(chan struct{}), entered: make(chan struct{})}
+	svc := NewShortener(st, func(n int) (string, error) { return "AAAAAAAAAA", nil })
+	if _, err := svc.Create(context.Background(), "https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// первый вызывающий уходит по своему ctx, общий вызов продолжается
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := svc.ResolveLink(leaderCtx, "", "AAAAAAAAAA")
+		leaderErr <- err
+	}()
+	<-st.entered
+	cancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Fatalf("leader err = %v, want context.Canceled", err)
+	}
+
+	const waiters = 20
+	joined := testutil.ToFloat64(lookups.WithLabelValues("coalesced"))
+	results := make(chan error, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			link, err := svc.ResolveLink(context.Background(), "", "AAAAAAAAAA")
+			if err == nil && link.Original != "https://example.com" {
+				err = fmt.Errorf("original = %q", link.Original)
+			}
+			results <- err
+		}()
+	}
+	for testutil.ToFloat64(lookups.WithLabelValues("coalesced"))-joined < waiters {
+		time.Sleep(time.Millisecond)
+	}
+	close(st.gate)
+	for i := 0; i < waiters; i++ {
+		if err := <-results; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := st.calls.Load(); n != 1 {
+		t.Fatalf("store calls = %d, want 1", n)
+	}
+	if st.ctxErr != nil {
+		t.Fatalf("shared call saw %v", st.ctxErr)
+	}
+
+	// следующий вызов идёт в store заново
+	if _, err := svc.ResolveLink(context.Background(), "", "AAAAAAAAAA"); err != nil {
+		t.Fatal(err)
+	}
+	if n := st.calls.Load(); n != 2 {
+		t.Fatalf("store calls = %d, want 2", n)
+	}
+}
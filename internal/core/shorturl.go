@@ -0,0 +1,32 @@
+package core
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// WithPublicBaseURL задаёт публичный адрес сервиса, от которого строятся
+// короткие ссылки (https://sho.rt или https://example.com/s). Хост базы —
+// домен по умолчанию; ссылки других доменов получают схему, порт и путь
+// базы с хостом своего домена.
+func WithPublicBaseURL(u *url.URL) Option {
+	return func(s *Shortener) { s.baseURL = u }
+}
+
+// ShortURL возвращает публичную короткую ссылку; ok=false — базовый адрес
+// не настроен, и транспорт строит ссылку сам.
+func (s *Shortener) ShortURL(link Link) (string, bool) {
+	if s.baseURL == nil {
+		return "", false
+	}
+	u := url.URL{Scheme: s.baseURL.Scheme, Host: s.baseURL.Host}
+	if link.Domain != "" {
+		u.Host = link.Domain
+		if port := s.baseURL.Port(); port != "" {
+			u.Host = net.JoinHostPort(link.Domain, port)
+		}
+	}
+	u.Path = strings.TrimSuffix(s.baseURL.Path, "/") + "/" + link.Code
+	return u.String(), true
+}
@@ -0,0 +1,29 @@
+package moderation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reportsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_abuse_reports_total",
+		Help: "Abuse reports received, by reason.",
+	}, []string{"reason"})
+	autoDisabledTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_moderation_auto_disabled_total",
+		Help: "Links disabled automatically after enough distinct reports.",
+	})
+	decisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_moderation_decisions_total",
+		Help: "Moderator decisions, by status set.",
+	}, []string{"status"})
+	blockedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_moderation_blocked_redirects_total",
+		Help: "Redirects refused or interrupted by moderation, by verdict (disabled|banned|warn).",
+	}, []string{"verdict"})
+	openCases = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "shortener_moderation_open_cases",
+		Help: "Cases waiting for a moderator, as of the last reload.",
+	})
+)
@@ -0,0 +1,132 @@
+// Package moderation — жалобы на ссылки, очередь модерации, отключение
+// ссылок и запрет доменов назначения.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidReason = errors.New("invalid report reason")
+	ErrInvalidDomain = errors.New("invalid domain")
+	ErrInvalidStatus = errors.New("invalid case status")
+	ErrCaseClosed    = errors.New("case is not open")
+	ErrNotFound      = errors.New("not found")
+)
+
+// Reason — причина жалобы.
+type Reason string
+
+const (
+	ReasonSpam     Reason = "spam"
+	ReasonPhishing Reason = "phishing"
+	ReasonMalware  Reason = "malware"
+	ReasonIllegal  Reason = "illegal"
+	ReasonOther    Reason = "other"
+)
+
+var Reasons = []Reason{ReasonSpam, ReasonPhishing, ReasonMalware, ReasonIllegal, ReasonOther}
+
+func ValidReason(r Reason) bool {
+	for _, k := range Reasons {
+		if r == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Status — состояние дела в очереди.
+//
+//	open      — есть жалобы, решения нет;
+//	disabled  — ссылка отключена модератором или автоматически;
+//	dismissed — жалобы отклонены или ссылка восстановлена.
+type Status string
+
+const (
+	StatusOpen      Status = "open"
+	StatusDisabled  Status = "disabled"
+	StatusDismissed Status = "dismissed"
+)
+
+func ValidStatus(s Status) bool {
+	return s == StatusOpen || s == StatusDisabled || s == StatusDismissed
+}
+
+// Report — одна жалоба. Reporter — хэш адреса отправителя с солью, сам
+// адрес не хранится.
+type Report struct {
+	Domain    string    `json:"domain,omitempty"`
+	Code      string    `json:"code"`
+	Reason    Reason    `json:"reason"`
+	Details   string    `json:"details,omitempty"`
+	Reporter  string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Case — дело по ссылке: все жалобы на неё и решение модератора. Domain —
+// ключ домена ссылки, как в core.Link.
+type Case struct {
+	Domain     string     `json:"domain,omitempty"`
+	Code       string     `json:"code"`
+	Status     Status     `json:"status"`
+	Reports    int        `json:"reports"`
+	FirstAt    time.Time  `json:"first_report_at,omitzero"`
+	LastAt     time.Time  `json:"last_report_at,omitzero"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// Ban — запрещённый домен назначения; запрет действует и на поддомены.
+type Ban struct {
+	Domain string    `json:"domain"`
+	Reason string    `json:"reason,omitempty"`
+	By     string    `json:"by,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Store хранит жалобы, дела и запреты (memory, postgres).
+type Store interface {
+	// AddReport сохраняет жалобу и возвращает дело: новое или отклонённое
+	// открывается (ResolvedAt сохраняется), отключённое остаётся как есть.
+	AddReport(ctx context.Context, r Report) (Case, error)
+	// Reporters — число разных отправителей жалоб на ссылку с момента since.
+	Reporters(ctx context.Context, domain, code string, since time.Time) (int, error)
+	// Cases возвращает дела по убыванию последней жалобы; пустой status —
+	// все дела.
+	Cases(ctx context.Context, status Status, limit int) ([]Case, error)
+	Case(ctx context.Context, domain, code string) (Case, bool, error)
+	Reports(ctx context.Context, domain, code string, limit int) ([]Report, error)
+	// ResolveCase ставит статус дела, создавая его при необходимости:
+	// модератор может отключить ссылку и без жалоб.
+	ResolveCase(ctx context.Context, c Case) (Case, error)
+
+	BanDomain(ctx context.Context, b Ban) error
+	// UnbanDomain возвращает ErrNotFound, если домен не запрещён.
+	UnbanDomain(ctx context.Context, domain string) error
+	Bans(ctx context.Context) ([]Ban, error)
+}
+
+// NormalizeDomain приводит домен к нижнему регистру без точек по краям и
+// проверяет, что это похоже на имя хоста.
+func NormalizeDomain(d string) (string, error) {
+	d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), ".")
+	if d == "" || len(d) > 253 || !strings.Contains(d, ".") && d != "localhost" {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range strings.Split(d, ".") {
+		if label == "" || len(label) > 63 {
+			return "", ErrInvalidDomain
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return "", ErrInvalidDomain
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,139 @@
+package moderation_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+func newModerator(t *testing.T, opts moderation.Options) (*moderation.Moderator, *core.Shortener) {
+	t.Helper()
+	st := memory.New()
+	var mod *moderation.Moderator
+	svc := core.NewShortener(st, core.NewCode, core.WithDestinationCheck(core.DestinationCheckFunc(
+		func(ctx context.Context, u string) error { return mod.CheckDestination(ctx, u) },
+	)))
+	mod = moderation.New(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), st, svc, opts)
+	return mod, svc
+}
+
+func TestReport_AutoDisableByDistinctReporters(t *testing.T) {
+	mod, svc := newModerator(t, moderation.Options{AutoDisable: 3, Window: time.Hour, Interstitial: true})
+	ctx := context.Background()
+	link, err := svc.CreateLink(ctx, core.CreateRequest{URL: "https://example.com/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mod.Report(ctx, "", link.Code, "nonsense", "", "10.0.0.1"); err != moderation.ErrInvalidReason {
+		t.Fatalf("bad reason err=%v", err)
+	}
+	if _, err := mod.Report(ctx, "", "nosuch", moderation.ReasonSpam, "", "10.0.0.1"); err != core.ErrNotFound {
+		t.Fatalf("unknown code err=%v", err)
+	}
+	// один и тот же отправитель считается один раз
+	for _, ip := range []string{"10.0.0.1", "10.0.0.1", "10.0.0.1", "10.0.0.2"} {
+		c, err := mod.Report(ctx, "", link.Code, moderation.ReasonPhishing, "", ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Status != moderation.StatusOpen {
+			t.Fatalf("after report from %s status=%s", ip, c.Status)
+		}
+	}
+	if v := mod.Verdict(mustLink(t, svc, link.Code)); v != moderation.Warn {
+		t.Fatalf("open case verdict=%s, want warn", v)
+	}
+
+	c, err := mod.Dismiss(ctx, "", link.Code, "looks fine")
+	if err != nil || c.Status != moderation.StatusDismissed || c.Reports != 4 {
+		t.Fatalf("dismiss: %+v err=%v", c, err)
+	}
+	if v := mod.Verdict(mustLink(t, svc, link.Code)); v != moderation.Allow {
+		t.Fatalf("dismissed verdict=%s", v)
+	}
+
+	// после отклонения старые жалобы не в счёт: нужны три новых отправителя
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if c, err = mod.Report(ctx, "", link.Code, moderation.ReasonPhishing, "", ip); err != nil || c.Status != moderation.StatusOpen {
+			t.Fatalf("reopened case: %+v err=%v", c, err)
+		}
+	}
+	if c, err = mod.Report(ctx, "", link.Code, moderation.ReasonMalware, "", "10.0.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Status != moderation.StatusDisabled || c.ResolvedBy != "auto-moderation" {
+		t.Fatalf("auto-disable: %+v", c)
+	}
+	got := mustLink(t, svc, link.Code)
+	if !got.Disabled || mod.Verdict(got) != moderation.Disabled {
+		t.Fatalf("link after auto-disable: %+v", got)
+	}
+	if _, err := mod.Dismiss(ctx, "", link.Code, ""); err != moderation.ErrCaseClosed {
+		t.Fatalf("dismiss disabled case err=%v", err)
+	}
+
+	if c, err = mod.Restore(ctx, "", link.Code, "false positive"); err != nil || c.Status != moderation.StatusDismissed {
+		t.Fatalf("restore: %+v err=%v", c, err)
+	}
+	if mustLink(t, svc, link.Code).Disabled {
+		t.Fatal("link still disabled after restore")
+	}
+}
+
+func TestBanDomain_BlocksCreateAndRedirect(t *testing.T) {
+	mod, svc := newModerator(t, moderation.Options{})
+	ctx := context.Background()
+	link, err := svc.CreateLink(ctx, core.CreateRequest{URL: "https://cdn.evil.example/payload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := core.WithPrincipal(ctx, core.Principal{ID: "u1", Scopes: []core.Scope{core.ScopeLinksWrite}})
+	if _, err := mod.BanDomain(user, "evil.example", ""); err != core.ErrForbidden {
+		t.Fatalf("non-admin ban err=%v", err)
+	}
+	if _, err := mod.BanDomain(ctx, "not a domain", ""); err != moderation.ErrInvalidDomain {
+		t.Fatalf("invalid domain err=%v", err)
+	}
+	if _, err := mod.BanDomain(ctx, "Evil.Example.", "malware"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{"https://evil.example/", "http://a.b.evil.example:8080/x"} {
+		if _, err := svc.CreateLink(ctx, core.CreateRequest{URL: u}); err != core.ErrBannedDomain {
+			t.Fatalf("create %s err=%v", u, err)
+		}
+	}
+	if _, err := svc.CreateLink(ctx, core.CreateRequest{URL: "https://notevil.example/"}); err != nil {
+		t.Fatalf("similar domain: %v", err)
+	}
+	if v := mod.Verdict(link); v != moderation.Banned {
+		t.Fatalf("existing link verdict=%s", v)
+	}
+
+	if err := mod.UnbanDomain(ctx, "evil.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mod.UnbanDomain(ctx, "evil.example"); err != moderation.ErrNotFound {
+		t.Fatalf("second unban err=%v", err)
+	}
+	if _, err := svc.CreateLink(ctx, core.CreateRequest{URL: "https://evil.example/"}); err != nil {
+		t.Fatalf("after unban: %v", err)
+	}
+}
+
+func mustLink(t *testing.T, svc *core.Shortener, code string) core.Link {
+	t.Helper()
+	l, err := svc.ResolveLink(context.Background(), "", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
@@ -0,0 +1,423 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+const maxDetails = 2000
+
+type Options struct {
+	// AutoDisable — сколько разных отправителей за Window отключают
+	// ссылку до решения модератора; 0 — не отключать автоматически.
+	AutoDisable int
+	Window      time.Duration
+	// Salt — секрет для хэша адреса отправителя, общий для всех реплик.
+	Salt []byte
+	// Interstitial — показывать предупреждение перед редиректом по
+	// ссылке с открытым делом.
+	Interstitial bool
+	// Audit получает решения по делам и запреты доменов.
+	Audit core.Auditor
+}
+
+// Verdict — что делать с редиректом.
+type Verdict int
+
+const (
+	Allow    Verdict = iota
+	Warn             // страница-предупреждение перед переходом
+	Disabled         // ссылка отключена
+	Banned           // домен назначения запрещён
+)
+
+// Moderator принимает жалобы и решения модераторов. Открытые дела и
+// запреты кэшируются в памяти для проверки редиректов; Run периодически
+// перечитывает их, чтобы увидеть решения, принятые на других репликах.
+type Moderator struct {
+	log   *slog.Logger
+	store Store
+	svc   *core.Shortener
+	opts  Options
+
+	mu   sync.RWMutex
+	open map[string]struct{}
+	bans map[string]Ban
+}
+
+func New(log *slog.Logger, store Store, svc *core.Shortener, opts Options) *Moderator {
+	if opts.Window <= 0 {
+		opts.Window = 24 * time.Hour
+	}
+	return &Moderator{
+		log:   log,
+		store: store,
+		svc:   svc,
+		opts:  opts,
+		open:  make(map[string]struct{}),
+		bans:  make(map[string]Ban),
+	}
+}
+
+// autoPrincipal — от его имени отключаются ссылки по жалобам; так
+// действие видно в журнале аудита.
+var autoPrincipal = core.Principal{ID: "moderation", Name: "auto-moderation", Scopes: []core.Scope{core.ScopeAdmin}}
+
+// Report принимает жалобу от клиента с адресом ip на ссылку домена
+// domain (имя хоста, пусто — по умолчанию). Возвращает core.ErrNotFound,
+// если ссылки нет.
+func (m *Moderator) Report(ctx context.Context, domain, code string, reason Reason, details, ip string) (Case, error) {
+	if !ValidReason(reason) {
+		return Case{}, ErrInvalidReason
+	}
+	link, err := m.svc.ResolveLink(ctx, domain, code)
+	if err != nil {
+		return Case{}, err
+	}
+	if r := []rune(details); len(r) > maxDetails {
+		details = string(r[:maxDetails])
+	}
+	c, err := m.store.AddReport(ctx, Report{
+		Domain:    link.Domain,
+		Code:      code,
+		Reason:    reason,
+		Details:   strings.TrimSpace(details),
+		Reporter:  m.fingerprint(ip),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return Case{}, err
+	}
+	reportsTotal.WithLabelValues(string(reason)).Inc()
+	if c.Status != StatusOpen {
+		return c, nil
+	}
+	m.mu.Lock()
+	m.open[caseKey(link.Domain, code)] = struct{}{}
+	m.mu.Unlock()
+
+	if m.opts.AutoDisable <= 0 || link.Disabled {
+		return c, nil
+	}
+	// после отклонения модератором считаются только новые жалобы
+	since := time.Now().Add(-m.opts.Window)
+	if c.ResolvedAt != nil && c.ResolvedAt.After(since) {
+		since = *c.ResolvedAt
+	}
+	n, err := m.store.Reporters(ctx, link.Domain, code, since)
+	if err != nil {
+		m.log.Error("count reporters failed", "code", code, "err", err)
+		return c, nil
+	}
+	if n < m.opts.AutoDisable {
+		return c, nil
+	}
+	note := fmt.Sprintf("%d distinct reports within %s", n, m.opts.Window)
+	// жалоба уже принята: отключение не должно сорваться из-за клиента
+	actx := core.WithPrincipal(context.WithoutCancel(ctx), autoPrincipal)
+	if c, err = m.resolve(actx, link.Domain, code, true, StatusDisabled, note); err != nil {
+		m.log.Error("auto-disable failed", "code", code, "err", err)
+		return c, nil
+	}
+	autoDisabledTotal.Inc()
+	m.log.Warn("link auto-disabled", "code", code, "reports", n)
+	return c, nil
+}
+
+// Disable отключает ссылку и закрывает дело. Только для admin.
+func (m *Moderator) Disable(ctx context.Context, domain, code, note string) (Case, error) {
+	return m.resolve(ctx, domain, code, true, StatusDisabled, note)
+}
+
+// Restore включает ссылку обратно; дело отклоняется.
+func (m *Moderator) Restore(ctx context.Context, domain, code, note string) (Case, error) {
+	return m.resolve(ctx, domain, code, false, StatusDismissed, note)
+}
+
+func (m *Moderator) resolve(ctx context.Context, domain, code string, disable bool, status Status, note string) (Case, error) {
+	link, err := m.svc.SetDisabled(ctx, domain, code, disable, note)
+	if err != nil {
+		return Case{}, err
+	}
+	return m.setStatus(ctx, link.Domain, code, status, note)
+}
+
+// Dismiss отклоняет жалобы по открытому делу, не трогая ссылку.
+func (m *Moderator) Dismiss(ctx context.Context, domain, code, note string) (Case, error) {
+	if err := m.admin(ctx, "report.dismiss", code); err != nil {
+		return Case{}, err
+	}
+	key, ok := m.svc.Domains().Key(domain)
+	if !ok {
+		return Case{}, ErrNotFound
+	}
+	c, ok, err := m.store.Case(ctx, key, code)
+	if err != nil {
+		return Case{}, err
+	}
+	if !ok {
+		return Case{}, ErrNotFound
+	}
+	if c.Status != StatusOpen {
+		return Case{}, ErrCaseClosed
+	}
+	if c, err = m.setStatus(ctx, key, code, StatusDismissed, note); err != nil {
+		return Case{}, err
+	}
+	m.record(ctx, "report.dismiss", code, nil, c)
+	return c, nil
+}
+
+func (m *Moderator) setStatus(ctx context.Context, domain, code string, status Status, note string) (Case, error) {
+	now := time.Now().UTC()
+	c, err := m.store.ResolveCase(ctx, Case{
+		Domain:     domain,
+		Code:       code,
+		Status:     status,
+		ResolvedBy: actor(ctx),
+		ResolvedAt: &now,
+		Note:       note,
+	})
+	if err != nil {
+		return Case{}, err
+	}
+	decisionsTotal.WithLabelValues(string(status)).Inc()
+	m.mu.Lock()
+	delete(m.open, caseKey(domain, code))
+	m.mu.Unlock()
+	return c, nil
+}
+
+// Cases — очередь модерации; пустой status — все дела.
+func (m *Moderator) Cases(ctx context.Context, status Status, limit int) ([]Case, error) {
+	if status != "" && !ValidStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	return m.store.Cases(ctx, status, limit)
+}
+
+// Case возвращает дело и последние жалобы по нему.
+func (m *Moderator) Case(ctx context.Context, domain, code string, limit int) (Case, []Report, error) {
+	key, ok := m.svc.Domains().Key(domain)
+	if !ok {
+		return Case{}, nil, ErrNotFound
+	}
+	c, ok, err := m.store.Case(ctx, key, code)
+	if err != nil {
+		return Case{}, nil, err
+	}
+	if !ok {
+		return Case{}, nil, ErrNotFound
+	}
+	reports, err := m.store.Reports(ctx, key, code, limit)
+	return c, reports, err
+}
+
+// BanDomain запрещает домен и его поддомены для новых ссылок; уже
+// созданные ссылки на него перестают редиректить.
+func (m *Moderator) BanDomain(ctx context.Context, domain, reason string) (Ban, error) {
+	d, err := NormalizeDomain(domain)
+	if err != nil {
+		return Ban{}, err
+	}
+	if err := m.admin(ctx, "domain.ban", d); err != nil {
+		return Ban{}, err
+	}
+	b := Ban{Domain: d, Reason: reason, By: actor(ctx), At: time.Now().UTC()}
+	if err := m.store.BanDomain(ctx, b); err != nil {
+		return Ban{}, err
+	}
+	m.mu.Lock()
+	m.bans[d] = b
+	m.mu.Unlock()
+	m.record(ctx, "domain.ban", d, nil, b)
+	return b, nil
+}
+
+func (m *Moderator) UnbanDomain(ctx context.Context, domain string) error {
+	d, err := NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	if err := m.admin(ctx, "domain.unban", d); err != nil {
+		return err
+	}
+	if err := m.store.UnbanDomain(ctx, d); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	prev := m.bans[d]
+	delete(m.bans, d)
+	m.mu.Unlock()
+	m.record(ctx, "domain.unban", d, prev, nil)
+	return nil
+}
+
+func (m *Moderator) Bans(ctx context.Context) ([]Ban, error) {
+	return m.store.Bans(ctx)
+}
+
+// CheckDestination реализует core.DestinationChecker.
+func (m *Moderator) CheckDestination(ctx context.Context, normalized string) error {
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return core.ErrInvalidURL
+	}
+	if m.banned(u.Hostname()) {
+		return core.ErrBannedDomain
+	}
+	return nil
+}
+
+// Verdict решает судьбу редиректа по ссылке.
+func (m *Moderator) Verdict(link core.Link) Verdict {
+	v := Allow
+	switch {
+	case link.Disabled:
+		v = Disabled
+	case m.bannedURL(link.Original):
+		v = Banned
+	case m.opts.Interstitial && m.isOpen(link.Domain, link.Code):
+		v = Warn
+	}
+	if v != Allow {
+		blockedTotal.WithLabelValues(v.String()).Inc()
+	}
+	return v
+}
+
+func (v Verdict) String() string {
+	switch v {
+	case Warn:
+		return "warn"
+	case Disabled:
+		return "disabled"
+	case Banned:
+		return "banned"
+	}
+	return "allow"
+}
+
+func (m *Moderator) isOpen(domain, code string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.open[caseKey(domain, code)]
+	return ok
+}
+
+// caseKey — ключ открытого дела в кэше; "/" не встречается ни в коде,
+// ни в имени домена.
+func caseKey(domain, code string) string { return domain + "/" + code }
+
+func (m *Moderator) bannedURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && m.banned(u.Hostname())
+}
+
+// banned проверяет хост и все его родительские домены.
+func (m *Moderator) banned(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.bans) == 0 {
+		return false
+	}
+	for host != "" {
+		if _, ok := m.bans[host]; ok {
+			return true
+		}
+		_, rest, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = rest
+	}
+	return false
+}
+
+// Load перечитывает открытые дела и запреты из хранилища.
+func (m *Moderator) Load(ctx context.Context) error {
+	cases, err := m.store.Cases(ctx, StatusOpen, 0)
+	if err != nil {
+		return err
+	}
+	bans, err := m.store.Bans(ctx)
+	if err != nil {
+		return err
+	}
+	open := make(map[string]struct{}, len(cases))
+	for _, c := range cases {
+		open[caseKey(c.Domain, c.Code)] = struct{}{}
+	}
+	banned := make(map[string]Ban, len(bans))
+	for _, b := range bans {
+		banned[b.Domain] = b
+	}
+	m.mu.Lock()
+	m.open, m.bans = open, banned
+	m.mu.Unlock()
+	openCases.Set(float64(len(open)))
+	return nil
+}
+
+func (m *Moderator) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := m.Load(ctx); err != nil {
+				m.log.Error("moderation reload failed", "err", err)
+			}
+		}
+	}
+}
+
+func (m *Moderator) fingerprint(ip string) string {
+	h := sha256.New()
+	h.Write(m.opts.Salt)
+	h.Write([]byte{0})
+	h.Write([]byte(ip))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// admin пропускает запросы без аутентификации и admin; отказ попадает в
+// журнал аудита.
+func (m *Moderator) admin(ctx context.Context, action, target string) error {
+	if p, ok := core.PrincipalFrom(ctx); ok && !p.Can(core.ScopeAdmin) {
+		if m.opts.Audit != nil {
+			m.opts.Audit.Audit(ctx, core.AuditEvent{Action: action, Outcome: core.AuditDenied, Target: target})
+		}
+		return core.ErrForbidden
+	}
+	return nil
+}
+
+func (m *Moderator) record(ctx context.Context, action, target string, before, after any) {
+	if m.opts.Audit == nil {
+		return
+	}
+	m.opts.Audit.Audit(ctx, core.AuditEvent{Action: action, Outcome: core.AuditOK, Target: target, Before: before, After: after})
+}
+
+func actor(ctx context.Context) string {
+	p, ok := core.PrincipalFrom(ctx)
+	if !ok {
+		return ""
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.ID
+}
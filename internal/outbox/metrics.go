@@ -0,0 +1,17 @@
+package outbox
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	published = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_outbox_published_total",
+		Help: "Outbox messages published.",
+	})
+	failures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_outbox_publish_failures_total",
+		Help: "Failed outbox publish attempts; the message is retried later.",
+	})
+)
@@ -0,0 +1,81 @@
+// Package outbox публикует события ссылок, записанные хранилищем в
+// outbox-таблицу в одной транзакции с самим изменением. Событие не
+// теряется, даже если процесс упал сразу после коммита: его опубликует
+// Relay, возможно повторно (at least once).
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// Message — строка outbox. Key — ссылка (см. LinkKey): события одного
+// ключа публикуются строго в порядке ID.
+type Message struct {
+	ID        int64
+	Key       string
+	Type      string
+	Payload   []byte // JSON
+	Attempts  int    // неудачных публикаций до этой
+	CreatedAt time.Time
+}
+
+// LinkKey — ключ событий ссылки: код для домена по умолчанию, иначе
+// "<домен>/<код>". Один код на разных доменах — разные ключи.
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + "/" + code
+}
+
+// Publisher доставляет сообщение во внешнюю систему. Ошибка — сообщение
+// будет повторено позже, вместе со всеми следующими по тому же ключу.
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+// Result — исход публикации одного сообщения пачки.
+type Result struct {
+	Published bool
+	RetryAt   time.Time // для неопубликованных
+	Error     string
+}
+
+// Source — хранилище с outbox-таблицей.
+type Source interface {
+	// RelayOutbox забирает до limit готовых сообщений, вызывает handle и в
+	// той же транзакции удаляет опубликованные, а остальные откладывает до
+	// RetryAt. handle возвращает по результату на сообщение. Параллельные
+	// вызовы (с разных инстансов) получают непересекающиеся ключи.
+	RelayOutbox(ctx context.Context, limit int, handle func(context.Context, []Message) []Result) (int, error)
+}
+
+// LinkPayload — тело сообщения о событии ссылки.
+type LinkPayload struct {
+	Type         string    `json:"type"`
+	Domain       string    `json:"domain,omitempty"`
+	Code         string    `json:"code"`
+	URL          string    `json:"url"`
+	ClickIDParam string    `json:"click_id_param,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	Disabled     bool      `json:"disabled,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// EncodeLinkEvent собирает тело сообщения; хранилища пишут его в outbox.
+func EncodeLinkEvent(typ core.EventType, link core.Link, at time.Time) ([]byte, error) {
+	return json.Marshal(LinkPayload{
+		Type:         string(typ),
+		Domain:       link.Domain,
+		Code:         link.Code,
+		URL:          link.Original,
+		ClickIDParam: link.ClickIDParam,
+		Tags:         link.Tags,
+		Disabled:     link.Disabled,
+		At:           at.UTC(),
+	})
+}
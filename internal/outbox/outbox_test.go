@@ -0,0 +1,161 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/outbox"
+)
+
+// fakeSource — outbox в памяти с той же семантикой, что и Postgres:
+// сообщение доступно, только если оно первое по своему ключу.
+type fakeSource struct {
+	msgs    []outbox.Message
+	retryAt map[int64]time.Time
+	nextID  int64
+}
+
+func (s *fakeSource) add(key, typ string) {
+	s.nextID++
+	s.msgs = append(s.msgs, outbox.Message{ID: s.nextID, Key: key, Type: typ, Payload: []byte(`{}`)})
+}
+
+func (s *fakeSource) RelayOutbox(ctx context.Context, limit int, handle func(context.Context, []outbox.Message) []outbox.Result) (int, error) {
+	heads := map[string]bool{}
+	seen := map[string]bool{}
+	for _, m := range s.msgs {
+		if !seen[m.Key] {
+			seen[m.Key] = true
+			heads[m.Key] = !s.retryAt[m.ID].After(time.Now())
+		}
+	}
+	var batch []outbox.Message
+	for _, m := range s.msgs {
+		if heads[m.Key] && len(batch) < limit {
+			batch = append(batch, m)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	results := handle(ctx, batch)
+	done := map[int64]bool{}
+	for i, r := range results {
+		if r.Published {
+			done[batch[i].ID] = true
+			continue
+		}
+		s.retryAt[batch[i].ID] = r.RetryAt
+		if r.Error != "" {
+			for j := range s.msgs {
+				if s.msgs[j].ID == batch[i].ID {
+					s.msgs[j].Attempts++
+				}
+			}
+		}
+	}
+	kept := s.msgs[:0]
+	for _, m := range s.msgs {
+		if !done[m.ID] {
+			kept = append(kept, m)
+		}
+	}
+	s.msgs = kept
+	return len(batch), nil
+}
+
+func TestRelay_KeepsOrderPerKeyOnFailure(t *testing.T) {
+	src := &fakeSource{retryAt: map[int64]time.Time{}}
+	src.add("A", "link.created")
+	src.add("B", "link.created")
+	src.add("A", "link.updated")
+	src.add("B", "link.deleted")
+	src.add("A", "link.deleted")
+
+	failA := true
+	pub := &outbox.MemoryPublisher{Fail: func(m outbox.Message) error {
+		if m.Key == "A" && failA {
+			return errors.New("broker down")
+		}
+		return nil
+	}}
+	relay := outbox.NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), src, pub, outbox.Options{
+		Backoff: time.Millisecond,
+	})
+	ctx := context.Background()
+
+	if _, err := relay.RelayOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := pub.Messages()
+	if len(got) != 2 || got[0].Key != "B" || got[1].Key != "B" || got[0].ID > got[1].ID {
+		t.Fatalf("published %+v, want only B in order", got)
+	}
+	if len(src.msgs) != 3 || src.msgs[0].Attempts != 1 || src.msgs[1].Attempts != 0 {
+		t.Fatalf("pending %+v: only the head of A counts an attempt", src.msgs)
+	}
+
+	failA = false
+	time.Sleep(5 * time.Millisecond)
+	if _, err := relay.RelayOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, m := range pub.Messages()[2:] {
+		ids = append(ids, m.ID)
+	}
+	if len(ids) != 3 || !sort.SliceIsSorted(ids, func(i, j int) bool { return ids[i] < ids[j] }) {
+		t.Fatalf("A published as %v, want 1,3,5", ids)
+	}
+	if len(src.msgs) != 0 {
+		t.Fatalf("outbox not drained: %+v", src.msgs)
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var gotID, gotType string
+	var gotBody outbox.LinkPayload
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, gotType = r.Header.Get("X-Outbox-Id"), r.Header.Get("X-Outbox-Type")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	payload, err := outbox.EncodeLinkEvent(core.LinkCreated, core.Link{Code: "abc", Original: "https://example.com"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := outbox.HTTPPublisher{URL: srv.URL}
+	m := outbox.Message{ID: 42, Key: "abc", Type: string(core.LinkCreated), Payload: payload}
+	if err := pub.Publish(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if gotID != "42" || gotType != "link.created" || gotBody.Code != "abc" || gotBody.URL != "https://example.com" {
+		t.Fatalf("id=%q type=%q body=%+v", gotID, gotType, gotBody)
+	}
+
+	status = http.StatusBadGateway
+	if err := pub.Publish(context.Background(), m); err == nil {
+		t.Fatal("non-2xx accepted")
+	}
+}
+
+func TestLinkKey(t *testing.T) {
+	if got := outbox.LinkKey("", "abc"); got != "abc" {
+		t.Fatalf("default domain key = %q", got)
+	}
+	if got := outbox.LinkKey("go.acme.com", "abc"); got != "go.acme.com/abc" {
+		t.Fatalf("domain key = %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// LogPublisher пишет сообщения в лог; для отладки и как заглушка.
+type LogPublisher struct {
+	Log *slog.Logger
+}
+
+func (p LogPublisher) Publish(ctx context.Context, m Message) error {
+	p.Log.Info("outbox event", "id", m.ID, "key", m.Key, "type", m.Type, "payload", string(m.Payload))
+	return nil
+}
+
+// HTTPPublisher отправляет POST с телом сообщения на URL. Ответ 2xx —
+// успех. X-Outbox-Id позволяет получателю отбросить повторы.
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p HTTPPublisher) Publish(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Id", strconv.FormatInt(m.ID, 10))
+	req.Header.Set("X-Outbox-Key", m.Key)
+	req.Header.Set("X-Outbox-Type", m.Type)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("outbox publish: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MemoryPublisher копит сообщения в памяти; для тестов. Fail, если задан,
+// вызывается перед сохранением и может вернуть ошибку.
+type MemoryPublisher struct {
+	Fail func(Message) error
+
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, m Message) error {
+	if p.Fail != nil {
+		if err := p.Fail(m); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, m)
+	return nil
+}
+
+// Messages возвращает копию опубликованного в порядке публикации.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.msgs...)
+}
@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Options struct {
+	Batch      int           // сообщений за транзакцию
+	Poll       time.Duration // пауза, когда outbox пуст
+	Timeout    time.Duration // на одну публикацию
+	Backoff    time.Duration // первая задержка повтора, дальше удваивается
+	MaxBackoff time.Duration
+}
+
+// Relay переносит сообщения из Source в Publisher.
+type Relay struct {
+	log  *slog.Logger
+	src  Source
+	pub  Publisher
+	opts Options
+}
+
+func NewRelay(log *slog.Logger, src Source, pub Publisher, opts Options) *Relay {
+	if opts.Batch <= 0 {
+		opts.Batch = 100
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	return &Relay{log: log, src: src, pub: pub, opts: opts}
+}
+
+// Run публикует сообщения до отмены ctx: пачками без паузы, пока они
+// есть, затем раз в Poll.
+func (r *Relay) Run(ctx context.Context) {
+	t := time.NewTicker(r.opts.Poll)
+	defer t.Stop()
+	for {
+		for ctx.Err() == nil {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.log.Error("outbox relay failed", "err", err)
+				}
+				break
+			}
+			if n < r.opts.Batch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RelayOnce обрабатывает одну пачку и возвращает её размер.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.src.RelayOutbox(ctx, r.opts.Batch, r.publish)
+}
+
+// publish публикует пачку по порядку. После первой неудачи по ключу
+// остальные сообщения этого ключа не отправляются, чтобы не обогнать
+// неопубликованное.
+func (r *Relay) publish(ctx context.Context, msgs []Message) []Result {
+	results := make([]Result, len(msgs))
+	blocked := make(map[string]time.Time)
+	for i, m := range msgs {
+		if retryAt, ok := blocked[m.Key]; ok {
+			results[i] = Result{RetryAt: retryAt}
+			continue
+		}
+		pctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+		err := r.pub.Publish(pctx, m)
+		cancel()
+		if err == nil {
+			results[i] = Result{Published: true}
+			published.Inc()
+			continue
+		}
+		failures.Inc()
+		retryAt := time.Now().Add(r.backoff(m.Attempts + 1))
+		blocked[m.Key] = retryAt
+		results[i] = Result{RetryAt: retryAt, Error: err.Error()}
+		r.log.Warn("outbox publish failed", "id", m.ID, "key", m.Key, "type", m.Type, "attempts", m.Attempts+1, "err", err)
+	}
+	return results
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.Backoff
+	for i := 1; i < attempts && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.opts.MaxBackoff)
+}
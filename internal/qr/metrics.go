@@ -0,0 +1,11 @@
+package qr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var renderedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "shortener_qr_rendered_total",
+	Help: "QR codes rendered, by format (png|svg); cache revalidations are not counted.",
+}, []string{"format"})
@@ -0,0 +1,260 @@
+// Package qr рисует QR-коды коротких ссылок в PNG и SVG. Кодировщик —
+// github.com/skip2/go-qrcode на чистом Go; рамку (quiet zone), масштаб и
+// цвета рисуем сами, чтобы отдавать ровно запрошенный размер.
+package qr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid qr options")
+	// ErrTooSmall — в size не помещается по пикселю на модуль.
+	ErrTooSmall = errors.New("qr size too small for content")
+)
+
+type Format string
+
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+)
+
+// ContentType — MIME-тип картинки.
+func (f Format) ContentType() string {
+	if f == SVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+const (
+	MinSize     = 64
+	MaxSize     = 2048
+	DefaultSize = 256
+	MaxMargin   = 16
+	// DefaultMargin — рамка в модулях, минимум по стандарту.
+	DefaultMargin = 4
+)
+
+// Options — как рисовать код. Нулевые поля заменяются значениями по
+// умолчанию в Normalize.
+type Options struct {
+	Format Format
+	// Size — сторона картинки в пикселях (для SVG — width/height).
+	Size int
+	// Margin — рамка в модулях; nil — DefaultMargin.
+	Margin *int
+	// Level — уровень коррекции ошибок: L, M (по умолчанию), Q, H.
+	Level string
+	// Foreground, Background — hex RRGGBB или RRGGBBAA, с # или без;
+	// "transparent" — прозрачный. По умолчанию чёрный на белом.
+	Foreground, Background string
+
+	fg, bg color.NRGBA
+}
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// ParseQuery разбирает ?format=png|svg&size=256&margin=4&ecc=M&fg=000000&bg=ffffff.
+func ParseQuery(q url.Values) (Options, error) {
+	o := Options{
+		Format:     Format(strings.ToLower(q.Get("format"))),
+		Level:      q.Get("ecc"),
+		Foreground: q.Get("fg"),
+		Background: q.Get("bg"),
+	}
+	if v := q.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("%w: size", ErrInvalidOptions)
+		}
+		o.Size = n
+	}
+	if v := q.Get("margin"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("%w: margin", ErrInvalidOptions)
+		}
+		o.Margin = &n
+	}
+	return o.Normalize()
+}
+
+// Normalize подставляет значения по умолчанию и проверяет границы.
+func (o Options) Normalize() (Options, error) {
+	switch o.Format {
+	case "":
+		o.Format = PNG
+	case PNG, SVG:
+	default:
+		return Options{}, fmt.Errorf("%w: format", ErrInvalidOptions)
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return Options{}, fmt.Errorf("%w: size must be %d..%d", ErrInvalidOptions, MinSize, MaxSize)
+	}
+	if o.Margin == nil {
+		m := DefaultMargin
+		o.Margin = &m
+	}
+	if *o.Margin < 0 || *o.Margin > MaxMargin {
+		return Options{}, fmt.Errorf("%w: margin must be 0..%d", ErrInvalidOptions, MaxMargin)
+	}
+	o.Level = strings.ToUpper(o.Level)
+	if o.Level == "" {
+		o.Level = "M"
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return Options{}, fmt.Errorf("%w: ecc must be L, M, Q or H", ErrInvalidOptions)
+	}
+	var err error
+	if o.fg, err = parseColor(o.Foreground, color.NRGBA{A: 0xff}); err != nil {
+		return Options{}, fmt.Errorf("%w: foreground color", ErrInvalidOptions)
+	}
+	if o.bg, err = parseColor(o.Background, color.NRGBA{0xff, 0xff, 0xff, 0xff}); err != nil {
+		return Options{}, fmt.Errorf("%w: background color", ErrInvalidOptions)
+	}
+	o.Foreground, o.Background = hexColor(o.fg), hexColor(o.bg)
+	return o, nil
+}
+
+// ETag — стабильный тег картинки: одинаковые контент и опции дают
+// одинаковые байты.
+func ETag(content string, o Options) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s|%d|%d|%s|%s|%s",
+		content, o.Format, o.Size, *o.Margin, o.Level, o.Foreground, o.Background)))
+	return `"` + hex.EncodeToString(h[:12]) + `"`
+}
+
+// Render рисует content. Options должны пройти Normalize.
+func Render(content string, o Options) ([]byte, error) {
+	q, err := qrcode.New(content, levels[o.Level])
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	bm := q.Bitmap()
+
+	// модули квадратные и целые: код с рамкой вписывается в size, остаток
+	// делится поровну по краям фоном
+	total := len(bm) + 2**o.Margin
+	scale := o.Size / total
+	if scale < 1 {
+		return nil, ErrTooSmall
+	}
+	offset := (o.Size-total*scale)/2 + *o.Margin*scale
+
+	var out []byte
+	if o.Format == SVG {
+		out = renderSVG(bm, o, scale, offset)
+	} else if out, err = renderPNG(bm, o, scale, offset); err != nil {
+		return nil, err
+	}
+	renderedTotal.WithLabelValues(string(o.Format)).Inc()
+	return out, nil
+}
+
+func renderPNG(bm [][]bool, o Options, scale, offset int) ([]byte, error) {
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{o.bg, o.fg})
+	for y, row := range bm {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			x0, y0 := offset+x*scale, offset+y*scale
+			for py := y0; py < y0+scale; py++ {
+				for px := x0; px < x0+scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG рисует тёмные модули одним path; соседние модули строки
+// сливаются в один отрезок.
+func renderSVG(bm [][]bool, o Options, scale, offset int) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, o.Size, o.Size)
+	if o.bg.A != 0 {
+		fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#%s"%s/>`, hexRGB(o.bg), opacity(o.bg))
+	}
+	fmt.Fprintf(&b, `<path fill="#%s"%s d="`, hexRGB(o.fg), opacity(o.fg))
+	for y, row := range bm {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv%dh-%dz", offset+start*scale, offset+y*scale, (x-start)*scale, scale, (x-start)*scale)
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes()
+}
+
+func parseColor(s string, def color.NRGBA) (color.NRGBA, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "#")
+	switch {
+	case s == "":
+		return def, nil
+	case s == "transparent":
+		return color.NRGBA{}, nil
+	case len(s) != 6 && len(s) != 8:
+		return color.NRGBA{}, ErrInvalidOptions
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidOptions
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+func hexRGB(c color.NRGBA) string {
+	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func hexColor(c color.NRGBA) string {
+	return hexRGB(c) + fmt.Sprintf("%02x", c.A)
+}
+
+func opacity(c color.NRGBA) string {
+	if c.A == 0xff {
+		return ""
+	}
+	return fmt.Sprintf(` fill-opacity="%.3g"`, float64(c.A)/255)
+}
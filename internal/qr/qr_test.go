@@ -0,0 +1,93 @@
+package qr_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/qr"
+)
+
+func TestRender_PNGSizeMarginAndColors(t *testing.T) {
+	o, err := qr.ParseQuery(url.Values{"size": {"300"}, "margin": {"2"}, "fg": {"#112233"}, "bg": {"ffffffff"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := qr.Render("https://sho.rt/AAAAAAAAAA", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Fatalf("bounds=%v, want 300x300", b)
+	}
+	// версия 2 (25 модулей) + рамка 2+2 = 29 модулей по 10px, остаток 5px
+	// по краям; левый верхний модуль — угол поискового узора
+	dark := color.NRGBAModel.Convert(img.At(5+2*10, 5+2*10)).(color.NRGBA)
+	if dark != (color.NRGBA{0x11, 0x22, 0x33, 0xff}) {
+		t.Fatalf("finder pattern pixel=%v", dark)
+	}
+	light := color.NRGBAModel.Convert(img.At(5+2*10-1, 5+2*10-1)).(color.NRGBA)
+	if light != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("margin pixel=%v", light)
+	}
+}
+
+func TestRender_SVGAndTransparentBackground(t *testing.T) {
+	o, err := qr.ParseQuery(url.Values{"format": {"svg"}, "bg": {"transparent"}, "ecc": {"h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := qr.Render("https://sho.rt/AAAAAAAAAA", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if !strings.HasPrefix(s, "<svg") || !strings.Contains(s, `width="256"`) || !strings.Contains(s, `<path fill="#000000" d="M`) {
+		t.Fatalf("svg=%.200s", s)
+	}
+	if strings.Contains(s, "<rect") {
+		t.Fatal("transparent background must not draw a rect")
+	}
+	if o.Format.ContentType() != "image/svg+xml" {
+		t.Fatalf("content type %q", o.Format.ContentType())
+	}
+}
+
+func TestParseQuery_RejectsBadOptions(t *testing.T) {
+	for _, q := range []url.Values{
+		{"format": {"gif"}},
+		{"size": {"10"}},
+		{"size": {"99999"}},
+		{"margin": {"-1"}},
+		{"ecc": {"X"}},
+		{"fg": {"red"}},
+		{"bg": {"12345"}},
+	} {
+		if _, err := qr.ParseQuery(q); !errors.Is(err, qr.ErrInvalidOptions) {
+			t.Errorf("%v: err=%v, want ErrInvalidOptions", q, err)
+		}
+	}
+}
+
+func TestRender_TooSmallAndETag(t *testing.T) {
+	o, _ := qr.ParseQuery(url.Values{"size": {"64"}, "ecc": {"H"}, "margin": {"16"}})
+	if _, err := qr.Render("https://sho.rt/"+strings.Repeat("x", 200), o); !errors.Is(err, qr.ErrTooSmall) {
+		t.Fatalf("err=%v, want ErrTooSmall", err)
+	}
+	a, _ := qr.ParseQuery(url.Values{})
+	b, _ := qr.ParseQuery(url.Values{"format": {"png"}, "size": {"256"}, "margin": {"4"}, "ecc": {"m"}, "fg": {"000000"}})
+	if qr.ETag("u", a) != qr.ETag("u", b) {
+		t.Fatal("equivalent options must share an ETag")
+	}
+	if qr.ETag("u", a) == qr.ETag("v", a) {
+		t.Fatal("different content must change the ETag")
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  int
+}
+
+// Memory — лимитер в памяти процесса: у каждой реплики свои корзины.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, p Policy) (Decision, error) {
+	now := m.now()
+	k := BucketKey(p, key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[k]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now}
+		m.buckets[k] = b
+	}
+	b.rate, b.burst = p.Rate, p.Burst
+	b.tokens = min(float64(p.Burst), b.tokens+now.Sub(b.last).Seconds()*p.Rate)
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return DecisionFor(p, b.tokens, allowed), nil
+}
+
+// Run раз в every удаляет полные корзины: они ничем не отличаются от
+// отсутствующих, а клиентов по IP может быть очень много.
+func (m *Memory) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.sweep()
+		}
+	}
+}
+
+func (m *Memory) sweep() {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= float64(b.burst) {
+			delete(m.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package ratelimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var decisions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "shortener_ratelimit_decisions_total",
+	Help: "Rate limit decisions, by policy and result (allowed|limited|error).",
+}, []string{"policy", "result"})
+
+// Observe учитывает решение в метрике; err — лимитер недоступен и запрос
+// пропущен.
+func Observe(p Policy, d Decision, err error) {
+	switch {
+	case err != nil:
+		decisions.WithLabelValues(p.Name, "error").Inc()
+	case d.Allowed:
+		decisions.WithLabelValues(p.Name, "allowed").Inc()
+	default:
+		decisions.WithLabelValues(p.Name, "limited").Inc()
+	}
+}
@@ -0,0 +1,135 @@
+// Package ratelimit — token bucket с раздельными политиками. Memory
+// считает в процессе, postgres.Store — общий счётчик для всех реплик.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// Policy — корзина на Burst токенов, пополняемая со скоростью Rate в
+// секунду. Нулевая политика не ограничивает.
+type Policy struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+func (p Policy) Enabled() bool { return p.Rate > 0 && p.Burst > 0 }
+
+// ParsePolicy разбирает "<n>/<s|m|h>[:burst]", например "60/m" или
+// "10/s:50". Burst по умолчанию равен n. Пустая строка — без ограничений.
+func ParsePolicy(name, s string) (Policy, error) {
+	if s == "" {
+		return Policy{Name: name}, nil
+	}
+	rate, burstStr, hasBurst := strings.Cut(s, ":")
+	nStr, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %q: want <n>/<s|m|h>[:burst]", s)
+	}
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q: bad count", s)
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Policy{}, fmt.Errorf("rate limit %q: bad unit", s)
+	}
+	burst := n
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst <= 0 {
+			return Policy{}, fmt.Errorf("rate limit %q: bad burst", s)
+		}
+	}
+	return Policy{Name: name, Rate: float64(n) / per.Seconds(), Burst: burst}, nil
+}
+
+// Policies — политики по видам запросов.
+type Policies struct {
+	Create   Policy // POST /api/v1/urls, gRPC Shorten
+	Resolve  Policy // GET /api/v1/urls/{code}, gRPC Resolve
+	Redirect Policy // GET /{code}
+	Report   Policy // POST /{code}/report
+}
+
+// Decision — результат попытки взять токен.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // для отказа: когда появится токен
+	Reset      time.Duration // когда корзина снова будет полной
+}
+
+// Limiter берёт токен из корзины key по политике p.
+type Limiter interface {
+	Allow(ctx context.Context, key string, p Policy) (Decision, error)
+}
+
+// Check берёт токен и учитывает решение в метриках. Без лимитера или с
+// выключенной политикой пропускает всё; при сбое лимитера тоже
+// пропускает: недоступная база не должна ронять редиректы.
+func Check(ctx context.Context, log *slog.Logger, l Limiter, p Policy, key string) (Decision, bool) {
+	if l == nil || !p.Enabled() {
+		return Decision{Allowed: true}, true
+	}
+	d, err := l.Allow(ctx, key, p)
+	Observe(p, d, err)
+	if err != nil {
+		log.Error("rate limit check failed", "policy", p.Name, "err", err)
+		return Decision{Allowed: true}, true
+	}
+	return d, d.Allowed
+}
+
+// ClientKey — ключ корзины клиента: тенант, иначе вызывающий (API-ключ
+// или sub), иначе IP.
+func ClientKey(ctx context.Context, ip string) string {
+	if p, ok := core.PrincipalFrom(ctx); ok {
+		if p.Tenant != "" {
+			return "tenant:" + p.Tenant
+		}
+		return "principal:" + p.ID
+	}
+	return "ip:" + ip
+}
+
+// DecisionFor собирает Decision по числу токенов после пополнения и
+// попытки взять один; для реализаций Limiter.
+func DecisionFor(p Policy, tokens float64, allowed bool) Decision {
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(p.Burst) - tokens) / p.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / p.Rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// BucketKey разводит корзины разных политик с одним ключом клиента.
+func BucketKey(p Policy, key string) string { return p.Name + "|" + key }
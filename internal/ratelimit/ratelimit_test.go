@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in    string
+		rate  float64
+		burst int
+		bad   bool
+	}{
+		{in: ""},
+		{in: "60/m", rate: 1, burst: 60},
+		{in: "10/s:50", rate: 10, burst: 50},
+		{in: "3600/h", rate: 1, burst: 3600},
+		{in: "10", bad: true},
+		{in: "0/s", bad: true},
+		{in: "5/d", bad: true},
+		{in: "5/s:x", bad: true},
+	}
+	for _, tt := range tests {
+		p, err := ParsePolicy("create", tt.in)
+		if (err != nil) != tt.bad {
+			t.Fatalf("ParsePolicy(%q) err=%v, bad=%v", tt.in, err, tt.bad)
+		}
+		if !tt.bad && (p.Rate != tt.rate || p.Burst != tt.burst) {
+			t.Fatalf("ParsePolicy(%q)=%+v", tt.in, p)
+		}
+	}
+}
+
+func TestMemory_BucketRefillsAndSweeps(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	p := Policy{Name: "create", Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if d, _ := m.Allow(ctx, "ip:a", p); !d.Allowed {
+			t.Fatalf("request %d denied: %+v", i, d)
+		}
+	}
+	d, _ := m.Allow(ctx, "ip:a", p)
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second {
+		t.Fatalf("third request: %+v, want denied with retry 1s", d)
+	}
+	if d, _ := m.Allow(ctx, "ip:b", p); !d.Allowed {
+		t.Fatal("other client must have its own bucket")
+	}
+	if d, _ := m.Allow(ctx, "ip:a", Policy{Name: "redirect", Rate: 1, Burst: 1}); !d.Allowed {
+		t.Fatal("other policy must have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if d, _ := m.Allow(ctx, "ip:a", p); !d.Allowed {
+		t.Fatalf("after refill: %+v", d)
+	}
+
+	now = now.Add(time.Minute)
+	m.sweep()
+	if n := len(m.buckets); n != 0 {
+		t.Fatalf("%d buckets left after sweep, want 0", n)
+	}
+}
@@ -0,0 +1,229 @@
+// Package cache — кеш ссылок перед любым core.Store. Редиректы читают
+// ссылку по коду на каждый запрос, а меняются ссылки редко, поэтому
+// GetByCode обслуживается из LRU в памяти инстанса, а записи идут в
+// backend и сбрасывают свой ключ.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+const (
+	DefaultTTL         = 5 * time.Minute
+	DefaultNegativeTTL = 5 * time.Second
+)
+
+// genShards — на сколько шардов делятся поколения ключей.
+const genShards = 256
+
+type Options struct {
+	// Size — сколько ссылок (и отсутствующих кодов) держать; обязателен.
+	Size int
+	// TTL — сколько живёт найденная ссылка; 0 — DefaultTTL. Ограничивает
+	// устаревание, если ссылку изменил другой инстанс.
+	TTL time.Duration
+	// NegativeTTL — сколько помнить, что кода нет; 0 — DefaultNegativeTTL.
+	// Короткий: код может создать другой инстанс.
+	NegativeTTL time.Duration
+}
+
+// Expiring — backend, где ссылки истекают сами (Redis TTL): запись в
+// кеше не должна пережить ссылку.
+type Expiring interface {
+	// GetByCodeTTL — GetByCode с оставшимся временем жизни; 0 — бессрочная.
+	GetByCodeTTL(ctx context.Context, domain, code string) (core.Link, bool, time.Duration, error)
+}
+
+// Store — core.Store с кешем GetByCode. Остальные чтения идут в backend
+// как есть.
+type Store struct {
+	core.Store
+	exp Expiring // nil — backend без срока жизни ссылок
+
+	size        int
+	ttl, negTTL time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List // от свежих к давним
+	items map[key]*list.Element
+	// gens — поколения шардов ключей: инвалидация растит поколение шарда
+	// своего ключа, Purge — всех. Чтение, начатое до инвалидации своего
+	// шарда, не кладёт в кеш то, что успело устареть; поток инвалидаций
+	// других ключей ему не мешает.
+	gens [genShards]uint64
+	seed maphash.Seed
+}
+
+type key struct {
+	domain, code string
+}
+
+type entry struct {
+	key     key
+	link    core.Link
+	found   bool
+	expires time.Time
+}
+
+func New(next core.Store, o Options) *Store {
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+	if o.NegativeTTL <= 0 {
+		o.NegativeTTL = DefaultNegativeTTL
+	}
+	exp, _ := next.(Expiring)
+	return &Store{
+		Store:  next,
+		exp:    exp,
+		size:   max(o.Size, 1),
+		ttl:    o.TTL,
+		negTTL: o.NegativeTTL,
+		now:    time.Now,
+		ll:     list.New(),
+		items:  make(map[key]*list.Element),
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+func (s *Store) shard(k key) int {
+	return int(maphash.String(s.seed, k.domain+"\x00"+k.code) % genShards)
+}
+
+func (s *Store) GetByCode(ctx context.Context, domain, code string) (core.Link, bool, error) {
+	k := key{domain, code}
+	s.mu.Lock()
+	if el, ok := s.items[k]; ok {
+		e := el.Value.(*entry)
+		if s.now().Before(e.expires) {
+			s.ll.MoveToFront(el)
+			s.mu.Unlock()
+			if e.found {
+				requests.WithLabelValues("hit").Inc()
+			} else {
+				requests.WithLabelValues("negative_hit").Inc()
+			}
+			return e.link, e.found, nil
+		}
+		s.remove(el)
+	}
+	gen := s.gens[s.shard(k)]
+	s.mu.Unlock()
+
+	requests.WithLabelValues("miss").Inc()
+	var (
+		link   core.Link
+		found  bool
+		remain time.Duration
+		err    error
+	)
+	if s.exp != nil {
+		link, found, remain, err = s.exp.GetByCodeTTL(ctx, domain, code)
+	} else {
+		link, found, err = s.Store.GetByCode(ctx, domain, code)
+	}
+	if err != nil {
+		return core.Link{}, false, err
+	}
+	s.put(gen, k, link, found, remain)
+	return link, found, nil
+}
+
+// put кладёт результат чтения; remain > 0 — сколько ссылке осталось жить
+// в backend, запись в кеше истечёт не позже.
+func (s *Store) put(gen uint64, k key, link core.Link, found bool, remain time.Duration) {
+	ttl := s.ttl
+	if !found {
+		ttl = s.negTTL
+	}
+	if remain > 0 {
+		ttl = min(ttl, remain)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen != s.gens[s.shard(k)] {
+		return
+	}
+	e := &entry{key: k, link: link, found: found, expires: s.now().Add(ttl)}
+	if el, ok := s.items[k]; ok {
+		el.Value = e
+		s.ll.MoveToFront(el)
+		return
+	}
+	s.items[k] = s.ll.PushFront(e)
+	for s.ll.Len() > s.size {
+		s.remove(s.ll.Back())
+		evictions.Inc()
+	}
+	entries.Set(float64(s.ll.Len()))
+}
+
+func (s *Store) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*entry).key)
+	entries.Set(float64(s.ll.Len()))
+}
+
+// Create сбрасывает запомненное отсутствие кода.
+func (s *Store) Create(ctx context.Context, link core.Link) error {
+	err := s.Store.Create(ctx, link)
+	if err == nil {
+		s.Invalidate(link.Domain, link.Code)
+	}
+	return err
+}
+
+func (s *Store) Update(ctx context.Context, link core.Link) error {
+	defer s.Invalidate(link.Domain, link.Code)
+	return s.Store.Update(ctx, link)
+}
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	defer s.Invalidate(domain, code)
+	return s.Store.Delete(ctx, domain, code)
+}
+
+func (s *Store) SetDisabled(ctx context.Context, domain, code string, disabled bool) (core.Link, error) {
+	defer s.Invalidate(domain, code)
+	return s.Store.SetDisabled(ctx, domain, code, disabled)
+}
+
+// Invalidate выбрасывает ссылку из кеша. Записи через Store вызывают его
+// сами; снаружи — для изменений в обход этого инстанса.
+func (s *Store) Invalidate(domain, code string) {
+	k := key{domain, code}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gens[s.shard(k)]++
+	if el, ok := s.items[k]; ok {
+		s.remove(el)
+	}
+	invalidations.WithLabelValues("key").Inc()
+}
+
+// Purge очищает кеш целиком, например когда пропущены инвалидации.
+func (s *Store) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.gens {
+		s.gens[i]++
+	}
+	s.ll.Init()
+	clear(s.items)
+	entries.Set(0)
+	invalidations.WithLabelValues("purge").Inc()
+}
+
+// Len — число записей в кеше, включая отсутствующие коды.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
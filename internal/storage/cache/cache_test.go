@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+)
+
+type countingStore struct {
+	core.Store
+	gets int
+}
+
+func (c *countingStore) GetByCode(ctx context.Context, domain, code string) (core.Link, bool, error) {
+	c.gets++
+	return c.Store.GetByCode(ctx, domain, code)
+}
+
+func TestStore_HitsNegativeTTLAndInvalidation(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{Store: memory.New()}
+	c := New(backend, Options{Size: 2, TTL: time.Minute, NegativeTTL: time.Second})
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	// отсутствие кода запоминается, пока не истёк NegativeTTL или код не создан
+	for i := 0; i < 3; i++ {
+		if _, found, err := c.GetByCode(ctx, "", "AAAAAAAAAA"); err != nil || found {
+			t.Fatalf("GetByCode = %v, %v", found, err)
+		}
+	}
+	if backend.gets != 1 {
+		t.Fatalf("backend gets = %d, want 1", backend.gets)
+	}
+	now = now.Add(2 * time.Second)
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if backend.gets != 2 {
+		t.Fatalf("negative entry not expired: gets = %d", backend.gets)
+	}
+	link := core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}
+	if err := c.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	got, found, _ := c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if !found || got.Original != link.Original {
+		t.Fatalf("after create: %+v, %v", got, found)
+	}
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if backend.gets != 3 {
+		t.Fatalf("backend gets = %d, want 3", backend.gets)
+	}
+
+	// запись через кеш сбрасывает ключ
+	if _, err := c.SetDisabled(ctx, "", "AAAAAAAAAA", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := c.GetByCode(ctx, "", "AAAAAAAAAA"); !got.Disabled {
+		t.Fatalf("stale link after SetDisabled: %+v", got)
+	}
+	if err := c.Delete(ctx, "", "AAAAAAAAAA"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := c.GetByCode(ctx, "", "AAAAAAAAAA"); found {
+		t.Fatal("deleted link still cached")
+	}
+
+	// тот же код на другом домене — другой ключ; размер ограничен
+	c.GetByCode(ctx, "go.acme.com", "AAAAAAAAAA")
+	c.GetByCode(ctx, "", "BBBBBBBBBB")
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+	before := backend.gets
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if backend.gets != before+1 {
+		t.Fatal("least recently used entry was not evicted")
+	}
+
+	c.Purge()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len after Purge = %d", n)
+	}
+}
+
+// Чтение, начатое до инвалидации, не должно вернуть в кеш старую ссылку.
+func TestStore_InvalidateDuringLoadIsNotOverwritten(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	c := New(backend, Options{Size: 10})
+	link := core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}
+	if err := backend.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+
+	k := key{"", link.Code}
+	c.mu.Lock()
+	gen := c.gens[c.shard(k)]
+	c.mu.Unlock()
+	stale, _, _ := backend.GetByCode(ctx, "", link.Code)
+	c.Invalidate("", link.Code)
+	c.put(gen, k, stale, true, 0)
+	if n := c.Len(); n != 0 {
+		t.Fatalf("stale load was cached, Len = %d", n)
+	}
+}
+
+// Инвалидации чужих ключей не мешают чтению класть ссылку в кеш.
+func TestStore_InvalidateOtherShardKeepsLoad(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	c := New(backend, Options{Size: 10})
+	link := core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}
+	if err := backend.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	k := key{"", link.Code}
+	other := key{"", "BBBBBBBBBB"}
+	for c.shard(other) == c.shard(k) {
+		other.code += "B"
+	}
+
+	c.mu.Lock()
+	gen := c.gens[c.shard(k)]
+	c.mu.Unlock()
+	loaded, _, _ := backend.GetByCode(ctx, "", link.Code)
+	c.Invalidate(other.domain, other.code)
+	c.put(gen, k, loaded, true, 0)
+	if n := c.Len(); n != 1 {
+		t.Fatalf("load dropped by an unrelated invalidation, Len = %d", n)
+	}
+}
+
+func TestStore_ReceiveInvalidatesKeyOrPurges(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	c := New(backend, Options{Size: 10})
+	for _, d := range []string{"", "go.acme.com"} {
+		if err := backend.Create(ctx, core.Link{Domain: d, Code: "AAAAAAAAAA", Original: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		c.GetByCode(ctx, d, "AAAAAAAAAA")
+	}
+	c.GetByCode(ctx, "", "BBBBBBBBBB")
+
+	// изменение на другом инстансе
+	if _, err := backend.SetDisabled(ctx, "go.acme.com", "AAAAAAAAAA", true); err != nil {
+		t.Fatal(err)
+	}
+	c.Receive(`{"domain":"go.acme.com","code":"AAAAAAAAAA"}`)
+	if got, _, _ := c.GetByCode(ctx, "go.acme.com", "AAAAAAAAAA"); !got.Disabled {
+		t.Fatal("link was not invalidated")
+	}
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len = %d, want 3", n)
+	}
+
+	c.Receive("")
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len after empty payload = %d, want 0", n)
+	}
+}
+
+type expiringStore struct {
+	*countingStore
+	remain time.Duration
+}
+
+func (e expiringStore) GetByCodeTTL(ctx context.Context, domain, code string) (core.Link, bool, time.Duration, error) {
+	link, found, err := e.GetByCode(ctx, domain, code)
+	return link, found, e.remain, err
+}
+
+// Ссылка, истекающая в backend раньше TTL кеша, не переживает его.
+func TestStore_TTLCappedByBackendExpiry(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{Store: memory.New()}
+	if err := backend.Create(ctx, core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	c := New(expiringStore{backend, 3 * time.Second}, Options{Size: 10, TTL: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	now = now.Add(2 * time.Second)
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if backend.gets != 1 {
+		t.Fatalf("backend gets = %d, want 1", backend.gets)
+	}
+	now = now.Add(2 * time.Second)
+	c.GetByCode(ctx, "", "AAAAAAAAAA")
+	if backend.gets != 2 {
+		t.Fatalf("entry outlived the backend TTL: gets = %d", backend.gets)
+	}
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_link_cache_requests_total",
+		Help: "Link cache lookups by code, by result (hit|negative_hit|miss).",
+	}, []string{"result"})
+	evictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_link_cache_evictions_total",
+		Help: "Links evicted from the cache to stay within its size.",
+	})
+	invalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_link_cache_invalidations_total",
+		Help: "Link cache invalidations, by kind (key|purge).",
+	}, []string{"kind"})
+	entries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "shortener_link_cache_entries",
+		Help: "Entries in the link cache, including negative ones.",
+	})
+)
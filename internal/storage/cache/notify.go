@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"encoding/json"
+)
+
+// Channel — канал LISTEN/NOTIFY, в который триггер url_mappings пишет
+// каждую изменённую ссылку (миграция 020).
+const Channel = "shortener_links"
+
+type notification struct {
+	Domain string `json:"domain"`
+	Code   string `json:"code"`
+}
+
+// Receive выбрасывает из кеша ссылку из уведомления. Пустой или
+// нераспознанный payload сбрасывает кеш целиком: лучше лишний промах, чем
+// устаревший редирект.
+func (s *Store) Receive(payload string) {
+	var n notification
+	if payload == "" || json.Unmarshal([]byte(payload), &n) != nil || n.Code == "" {
+		s.Purge()
+		return
+	}
+	s.Invalidate(n.Domain, n.Code)
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+)
+
+type auditLog struct {
+	mu      sync.Mutex
+	entries []audit.Entry
+}
+
+func (s *Store) AppendAudit(ctx context.Context, e audit.Entry) (audit.Entry, error) {
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+	var prev audit.Entry
+	if n := len(s.audit.entries); n > 0 {
+		prev = s.audit.entries[n-1]
+	}
+	e = audit.Seal(prev, e)
+	s.audit.entries = append(s.audit.entries, e)
+	return e, nil
+}
+
+func (s *Store) AuditEntries(ctx context.Context, q audit.Query) ([]audit.Entry, error) {
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+	var out []audit.Entry
+	for _, e := range s.audit.entries {
+		if len(out) == q.Limit {
+			break
+		}
+		if q.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/hll"
+)
+
+// clicks — сырые события и роллапы; защищены Store.mu.
+type clicks struct {
+	raw     []analytics.Click
+	hourly  map[analytics.BucketKey]analytics.Counts
+	daily   map[analytics.BucketKey]analytics.Counts
+	dims    map[analytics.DimKey]analytics.Counts
+	uniques map[analytics.BucketKey]*hll.Sketch
+
+	conversions map[convKey]analytics.Conversion
+}
+
+type convKey struct{ clickID, event string }
+
+func newClicks() clicks {
+	return clicks{
+		hourly:  make(map[analytics.BucketKey]analytics.Counts),
+		daily:   make(map[analytics.BucketKey]analytics.Counts),
+		dims:    make(map[analytics.DimKey]analytics.Counts),
+		uniques: make(map[analytics.BucketKey]*hll.Sketch),
+
+		conversions: make(map[convKey]analytics.Conversion),
+	}
+}
+
+func (s *Store) WriteClicks(ctx context.Context, batch []analytics.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks.raw = append(s.clicks.raw, batch...)
+	r := analytics.BuildRollup(batch)
+	for k, n := range r.Hourly {
+		s.clicks.hourly[k] = s.clicks.hourly[k].Add(n)
+	}
+	for k, n := range r.Daily {
+		s.clicks.daily[k] = s.clicks.daily[k].Add(n)
+	}
+	for k, n := range r.Dims {
+		s.clicks.dims[k] = s.clicks.dims[k].Add(n)
+	}
+	for k, sk := range r.Uniques {
+		if s.clicks.uniques[k] == nil {
+			s.clicks.uniques[k] = hll.New()
+		}
+		s.clicks.uniques[k].Merge(sk)
+	}
+	return nil
+}
+
+func (s *Store) WriteConversion(ctx context.Context, c analytics.Conversion) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := convKey{c.ClickID, c.Event}
+	if _, ok := s.clicks.conversions[k]; ok {
+		return false, nil
+	}
+	s.clicks.conversions[k] = c
+	return true, nil
+}
+
+func (s *Store) Stats(ctx context.Context, q analytics.StatsQuery) (analytics.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := analytics.Stats{Domain: q.Domain, Code: q.Code}
+	from, to := analytics.Day(q.From), analytics.Day(q.To)
+	if q.Top <= 0 {
+		q.Top = analytics.DefaultTop
+	}
+
+	for k, n := range s.clicks.hourly {
+		if k.Domain == q.Domain && k.Code == q.Code && !k.At.Before(q.From) && k.At.Before(q.To) && n.Clicks(q.IncludeBots) > 0 {
+			st.Hourly = append(st.Hourly, analytics.Point{At: k.At, Clicks: n.Clicks(q.IncludeBots)})
+		}
+	}
+	for k, n := range s.clicks.daily {
+		if k.Domain == q.Domain && k.Code == q.Code && inDays(k.At, from, to) {
+			st.Bots += n.Bots
+			if c := n.Clicks(q.IncludeBots); c > 0 {
+				st.Daily = append(st.Daily, analytics.Point{At: k.At, Clicks: c})
+				st.Total += c
+			}
+		}
+	}
+	sortPoints(st.Hourly)
+	sortPoints(st.Daily)
+
+	var days []analytics.DaySketch
+	for k, sk := range s.clicks.uniques {
+		if k.Domain == q.Domain && k.Code == q.Code && inDays(k.At, from, to) {
+			days = append(days, analytics.DaySketch{Day: k.At, Sketch: sk})
+		}
+	}
+	st.Uniques, st.DailyUniques = analytics.Uniques(days)
+
+	convDaily := make(map[time.Time]int64)
+	for _, c := range s.clicks.conversions {
+		if day := analytics.Day(c.At); c.Domain == q.Domain && c.Code == q.Code && inDays(day, from, to) {
+			convDaily[day]++
+			st.Conversions++
+			st.ConversionValue += c.Value
+		}
+	}
+	for day, n := range convDaily {
+		st.DailyConversions = append(st.DailyConversions, analytics.Point{At: day, Clicks: n})
+	}
+	sortPoints(st.DailyConversions)
+
+	byDim := make(map[string]map[string]int64)
+	for k, n := range s.clicks.dims {
+		if k.Domain != q.Domain || k.Code != q.Code || !inDays(k.Day, from, to) {
+			continue
+		}
+		if byDim[k.Dimension] == nil {
+			byDim[k.Dimension] = make(map[string]int64)
+		}
+		byDim[k.Dimension][k.Value] += n.Clicks(q.IncludeBots)
+	}
+	st.SetTop(byDim, q.Top)
+	return st, nil
+}
+
+// ScanClicks копирует выборку под блокировкой и обходит её уже без неё,
+// чтобы медленный потребитель не держал запись кликов.
+func (s *Store) ScanClicks(ctx context.Context, q analytics.ClickQuery, fn func(analytics.Click) error) error {
+	codes := make(map[string]bool, len(q.Codes))
+	for _, c := range q.Codes {
+		codes[c] = true
+	}
+	s.mu.RLock()
+	var out []analytics.Click
+	for _, c := range s.clicks.raw {
+		if c.Domain == q.Domain && codes[c.Code] && !c.At.Before(q.From) && c.At.Before(q.To) {
+			out = append(out, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	for _, c := range out {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) PurgeClicks(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.clicks.raw[:0]
+	for _, c := range s.clicks.raw {
+		if !c.At.Before(before) {
+			kept = append(kept, c)
+		}
+	}
+	n := int64(len(s.clicks.raw) - len(kept))
+	s.clicks.raw = kept
+
+	for k := range s.clicks.hourly {
+		if k.At.Before(before) {
+			delete(s.clicks.hourly, k)
+		}
+	}
+	return n, nil
+}
+
+func inDays(day, from, to time.Time) bool {
+	return !day.Before(from) && !day.After(to)
+}
+
+func sortPoints(ps []analytics.Point) {
+	sort.Slice(ps, func(i, j int) bool { return ps[i].At.Before(ps[j].At) })
+}
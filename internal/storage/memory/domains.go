@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+	"slices"
+)
+
+func (s *Store) TenantDomains(ctx context.Context, tenant string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.tenantDomains[tenant]), nil
+}
+
+func (s *Store) SetTenantDomains(ctx context.Context, tenant string, domains []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(domains) == 0 {
+		delete(s.tenantDomains, tenant)
+	} else {
+		s.tenantDomains[tenant] = slices.Clone(domains)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+)
+
+type apiKeys struct {
+	mu sync.Mutex
+	m  map[string]auth.Key // prefix -> key
+}
+
+func (s *Store) CreateKey(ctx context.Context, k auth.Key) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	if _, ok := s.keys.m[k.Prefix]; ok {
+		return auth.ErrDupKey
+	}
+	s.keys.m[k.Prefix] = k
+	return nil
+}
+
+func (s *Store) GetKey(ctx context.Context, prefix string) (auth.Key, bool, error) {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	k, ok := s.keys.m[prefix]
+	return k, ok, nil
+}
+
+func (s *Store) ListKeys(ctx context.Context) ([]auth.Key, error) {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	out := make([]auth.Key, 0, len(s.keys.m))
+	for _, k := range s.keys.m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *Store) RevokeKey(ctx context.Context, prefix string, at time.Time) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	k, ok := s.keys.m[prefix]
+	if !ok {
+		return auth.ErrKeyNotFound
+	}
+	if k.RevokedAt.IsZero() {
+		k.RevokedAt = at
+		s.keys.m[prefix] = k
+	}
+	return nil
+}
+
+func (s *Store) TouchKey(ctx context.Context, prefix string, at time.Time) error {
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	k, ok := s.keys.m[prefix]
+	if !ok {
+		return auth.ErrKeyNotFound
+	}
+	k.LastUsedAt = at
+	s.keys.m[prefix] = k
+	return nil
+}
@@ -3,49 +3,146 @@ package memory
 
 import (
 	"context"
+	"slices"
+	"sort"
 	"sync"
 
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
 )
 
 type Store struct {
     mu     sync.RWMutex
-    byOrig map[string]string // original -> code
-    byCode map[string]string // code -> original
+    byOrig map[core.OriginKey]string // (tenant, owner, domain, original) -> code
+    byCode map[linkKey]core.Link     // (domain, code) -> link
+
+    clicks clicks
+    hooks  webhooks
+    keys   apiKeys
+    quotas quotas
+    roles  roles
+    audit  auditLog
+    mod    moderationState
+
+    tenantDomains map[string][]string
 }
 
 func New() *Store {
     return &Store{
-        byOrig: make(map[string]string),
-        byCode: make(map[string]string),
+        byOrig: make(map[core.OriginKey]string),
+        byCode: make(map[linkKey]core.Link),
+        clicks: newClicks(),
+        hooks:  newWebhooks(),
+        keys:   apiKeys{m: make(map[string]auth.Key)},
+        quotas: newQuotas(),
+        roles:  newRoles(),
+        mod:    newModeration(),
+
+        tenantDomains: make(map[string][]string),
     }
 }
 
-func (s *Store) GetByOriginal(ctx context.Context, original string) (string, bool, error) {
+// linkKey — код в пределах домена.
+type linkKey struct {
+    domain, v string
+}
+
+func (s *Store) GetByOriginal(ctx context.Context, k core.OriginKey) (string, bool, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
-    code, ok := s.byOrig[original]
+    code, ok := s.byOrig[k]
     return code, ok, nil
 }
 
-func (s *Store) GetByCode(ctx context.Context, code string) (string, bool, error) {
+func (s *Store) GetByCode(ctx context.Context, domain, code string) (core.Link, bool, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
-    original, ok := s.byCode[code]
-    return original, ok, nil
+    link, ok := s.byCode[linkKey{domain, code}]
+    return link, ok, nil
 }
 
-func (s *Store) Create(ctx context.Context, code, original string) error {
+func (s *Store) Create(ctx context.Context, link core.Link) error {
     s.mu.Lock()
     defer s.mu.Unlock()
 
-    if _, ok := s.byOrig[original]; ok {
+    orig, code := link.OriginKey(), linkKey{link.Domain, link.Code}
+    if _, ok := s.byOrig[orig]; ok {
         return core.ErrDupOrigin
     }
     if _, ok := s.byCode[code]; ok {
         return core.ErrDupCode
     }
-    s.byOrig[original] = code
-    s.byCode[code] = original
+    if err := s.reserveQuota(link.Tenant); err != nil {
+        return err
+    }
+    link.Tags = slices.Clone(link.Tags)
+    s.byOrig[orig] = link.Code
+    s.byCode[code] = link
     return nil
 }
+
+func (s *Store) Update(ctx context.Context, link core.Link) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    k := linkKey{link.Domain, link.Code}
+    cur, ok := s.byCode[k]
+    if !ok {
+        return core.ErrNotFound
+    }
+    cur.Settings = link.Settings
+    cur.Tags = slices.Clone(link.Tags)
+    s.byCode[k] = cur
+    return nil
+}
+
+func (s *Store) SetDisabled(ctx context.Context, domain, code string, disabled bool) (core.Link, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    k := linkKey{domain, code}
+    link, ok := s.byCode[k]
+    if !ok {
+        return core.Link{}, core.ErrNotFound
+    }
+    link.Disabled = disabled
+    s.byCode[k] = link
+    return link, nil
+}
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    k := linkKey{domain, code}
+    link, ok := s.byCode[k]
+    if !ok {
+        return core.ErrNotFound
+    }
+    delete(s.byCode, k)
+    delete(s.byOrig, link.OriginKey())
+    s.releaseQuota(link.Tenant)
+    s.dropShares(k)
+    return nil
+}
+
+func (s *Store) List(ctx context.Context, f core.LinkFilter) ([]core.Link, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    var out []core.Link
+    for k, link := range s.byCode {
+        if k.domain != f.Domain || k.v <= f.After {
+            continue
+        }
+        if !f.Match(link) {
+            continue
+        }
+        out = append(out, link)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+    if len(out) > f.Limit {
+        out = out[:f.Limit]
+    }
+    return out, nil
+}
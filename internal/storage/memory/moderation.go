@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+)
+
+type moderationState struct {
+	mu      sync.Mutex
+	reports map[linkKey][]moderation.Report // (domain, code) -> жалобы по времени
+	cases   map[linkKey]moderation.Case
+	bans    map[string]moderation.Ban
+}
+
+func newModeration() moderationState {
+	return moderationState{
+		reports: make(map[linkKey][]moderation.Report),
+		cases:   make(map[linkKey]moderation.Case),
+		bans:    make(map[string]moderation.Ban),
+	}
+}
+
+func (s *Store) AddReport(ctx context.Context, r moderation.Report) (moderation.Case, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	k := linkKey{r.Domain, r.Code}
+	s.mod.reports[k] = append(s.mod.reports[k], r)
+
+	c, ok := s.mod.cases[k]
+	if !ok {
+		c = moderation.Case{Domain: r.Domain, Code: r.Code, Status: moderation.StatusOpen, FirstAt: r.CreatedAt}
+	}
+	if c.Status == moderation.StatusDismissed {
+		c.Status = moderation.StatusOpen
+	}
+	c.Reports++
+	c.LastAt = r.CreatedAt
+	s.mod.cases[k] = c
+	return c, nil
+}
+
+func (s *Store) Reporters(ctx context.Context, domain, code string, since time.Time) (int, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	seen := make(map[string]struct{})
+	for _, r := range s.mod.reports[linkKey{domain, code}] {
+		if !r.CreatedAt.Before(since) {
+			seen[r.Reporter] = struct{}{}
+		}
+	}
+	return len(seen), nil
+}
+
+func (s *Store) Cases(ctx context.Context, status moderation.Status, limit int) ([]moderation.Case, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	var out []moderation.Case
+	for _, c := range s.mod.cases {
+		if status == "" || c.Status == status {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastAt.Equal(out[j].LastAt) {
+			return out[i].LastAt.After(out[j].LastAt)
+		}
+		if out[i].Code != out[j].Code {
+			return out[i].Code < out[j].Code
+		}
+		return out[i].Domain < out[j].Domain
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *Store) Case(ctx context.Context, domain, code string) (moderation.Case, bool, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	c, ok := s.mod.cases[linkKey{domain, code}]
+	return c, ok, nil
+}
+
+func (s *Store) Reports(ctx context.Context, domain, code string, limit int) ([]moderation.Report, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	all := s.mod.reports[linkKey{domain, code}]
+	out := make([]moderation.Report, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, all[i])
+	}
+	return out, nil
+}
+
+func (s *Store) ResolveCase(ctx context.Context, c moderation.Case) (moderation.Case, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	k := linkKey{c.Domain, c.Code}
+	cur, ok := s.mod.cases[k]
+	if !ok {
+		cur = moderation.Case{Domain: c.Domain, Code: c.Code}
+	}
+	cur.Status, cur.ResolvedBy, cur.ResolvedAt, cur.Note = c.Status, c.ResolvedBy, c.ResolvedAt, c.Note
+	s.mod.cases[k] = cur
+	return cur, nil
+}
+
+func (s *Store) BanDomain(ctx context.Context, b moderation.Ban) error {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	s.mod.bans[b.Domain] = b
+	return nil
+}
+
+func (s *Store) UnbanDomain(ctx context.Context, domain string) error {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	if _, ok := s.mod.bans[domain]; !ok {
+		return moderation.ErrNotFound
+	}
+	delete(s.mod.bans, domain)
+	return nil
+}
+
+func (s *Store) Bans(ctx context.Context) ([]moderation.Ban, error) {
+	s.mod.mu.Lock()
+	defer s.mod.mu.Unlock()
+	out := make([]moderation.Ban, 0, len(s.mod.bans))
+	for _, b := range s.mod.bans {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Domain < out[j].Domain })
+	return out, nil
+}
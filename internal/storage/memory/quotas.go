@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// quotas защищены s.mu: проверка и учёт идут под той же блокировкой, что
+// и запись ссылки.
+type quotas struct {
+	def    core.Quota
+	custom map[string]core.Quota
+	active map[string]int
+	month  map[string]monthCount
+	now    func() time.Time
+}
+
+type monthCount struct {
+	month   time.Time
+	creates int
+}
+
+func newQuotas() quotas {
+	return quotas{
+		custom: make(map[string]core.Quota),
+		active: make(map[string]int),
+		month:  make(map[string]monthCount),
+		now:    time.Now,
+	}
+}
+
+// SetDefaultQuota задаёт квоту тенантов, для которых админ не задал свою.
+func (s *Store) SetDefaultQuota(q core.Quota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotas.def = q
+}
+
+func (s *Store) SetQuota(ctx context.Context, tenant string, q *core.Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q == nil {
+		delete(s.quotas.custom, tenant)
+	} else {
+		s.quotas.custom[tenant] = *q
+	}
+	return nil
+}
+
+func (s *Store) QuotaUsage(ctx context.Context, tenant string, now time.Time) (core.QuotaUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, custom := s.quota(tenant)
+	u := core.QuotaUsage{
+		Tenant:      tenant,
+		Quota:       q,
+		Custom:      custom,
+		ActiveLinks: s.quotas.active[tenant],
+		Month:       core.MonthStart(now),
+	}
+	if m := s.quotas.month[tenant]; m.month.Equal(u.Month) {
+		u.MonthlyCreates = m.creates
+	}
+	return u, nil
+}
+
+func (s *Store) quota(tenant string) (core.Quota, bool) {
+	if q, ok := s.quotas.custom[tenant]; ok {
+		return q, true
+	}
+	return s.quotas.def, false
+}
+
+// reserveQuota проверяет квоту и учитывает новую ссылку тенанта.
+// Вызывается под s.mu.
+func (s *Store) reserveQuota(tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	month := core.MonthStart(s.quotas.now())
+	m := s.quotas.month[tenant]
+	if !m.month.Equal(month) {
+		m = monthCount{month: month}
+	}
+	q, _ := s.quota(tenant)
+	if err := core.CheckQuota(tenant, q, s.quotas.active[tenant], m.creates); err != nil {
+		return err
+	}
+	m.creates++
+	s.quotas.month[tenant] = m
+	s.quotas.active[tenant]++
+	return nil
+}
+
+func (s *Store) releaseQuota(tenant string) {
+	if tenant != "" && s.quotas.active[tenant] > 0 {
+		s.quotas.active[tenant]--
+	}
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+type roles struct {
+	mu     sync.Mutex
+	tenant map[string]map[string]authz.Assignment // tenant -> user -> роль
+	shares map[string]map[string]authz.Assignment // shareKey -> user -> роль
+}
+
+func newRoles() roles {
+	return roles{
+		tenant: make(map[string]map[string]authz.Assignment),
+		shares: make(map[string]map[string]authz.Assignment),
+	}
+}
+
+func (s *Store) TenantRole(ctx context.Context, tenant, user string) (authz.Role, bool, error) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	a, ok := s.roles.tenant[tenant][user]
+	return a.Role, ok, nil
+}
+
+func (s *Store) SetTenantRole(ctx context.Context, tenant string, a authz.Assignment) error {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	setAssignment(s.roles.tenant, tenant, a)
+	return nil
+}
+
+func (s *Store) DeleteTenantRole(ctx context.Context, tenant, user string) error {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	return deleteAssignment(s.roles.tenant, tenant, user)
+}
+
+func (s *Store) TenantRoles(ctx context.Context, tenant string) ([]authz.Assignment, error) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	return listAssignments(s.roles.tenant[tenant]), nil
+}
+
+// shareKey — ссылка в s.roles.shares; ни домен, ни код не содержат "/".
+func shareKey(domain, code string) string { return domain + "/" + code }
+
+func (s *Store) LinkShare(ctx context.Context, domain, code, user string) (authz.Role, bool, error) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	a, ok := s.roles.shares[shareKey(domain, code)][user]
+	return a.Role, ok, nil
+}
+
+func (s *Store) ShareLink(ctx context.Context, domain, code string, a authz.Assignment) error {
+	// порядок блокировок как в Delete: сначала ссылки, потом роли
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.byCode[linkKey{domain, code}]; !ok {
+		return core.ErrNotFound
+	}
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	setAssignment(s.roles.shares, shareKey(domain, code), a)
+	return nil
+}
+
+func (s *Store) UnshareLink(ctx context.Context, domain, code, user string) error {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	return deleteAssignment(s.roles.shares, shareKey(domain, code), user)
+}
+
+func (s *Store) LinkShares(ctx context.Context, domain, code string) ([]authz.Assignment, error) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	return listAssignments(s.roles.shares[shareKey(domain, code)]), nil
+}
+
+// dropShares вызывается из Delete под s.mu.
+func (s *Store) dropShares(k linkKey) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+	delete(s.roles.shares, shareKey(k.domain, k.v))
+}
+
+func setAssignment(m map[string]map[string]authz.Assignment, key string, a authz.Assignment) {
+	if m[key] == nil {
+		m[key] = make(map[string]authz.Assignment)
+	}
+	if cur, ok := m[key][a.User]; ok {
+		a.CreatedAt = cur.CreatedAt
+	}
+	m[key][a.User] = a
+}
+
+func deleteAssignment(m map[string]map[string]authz.Assignment, key, user string) error {
+	if _, ok := m[key][user]; !ok {
+		return authz.ErrNotFound
+	}
+	delete(m[key], user)
+	return nil
+}
+
+func listAssignments(m map[string]authz.Assignment) []authz.Assignment {
+	out := make([]authz.Assignment, 0, len(m))
+	for _, a := range m {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].User < out[j].User })
+	return out
+}
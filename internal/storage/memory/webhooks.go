@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
+)
+
+type webhooks struct {
+	mu         sync.Mutex
+	subs       map[string]webhook.Subscription
+	deliveries map[string]webhook.Delivery
+}
+
+func newWebhooks() webhooks {
+	return webhooks{
+		subs:       make(map[string]webhook.Subscription),
+		deliveries: make(map[string]webhook.Delivery),
+	}
+}
+
+func (s *Store) CreateSubscription(ctx context.Context, sub webhook.Subscription) error {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	sub.Events = append([]string(nil), sub.Events...)
+	s.hooks.subs[sub.ID] = sub
+	return nil
+}
+
+func (s *Store) UpdateSubscription(ctx context.Context, sub webhook.Subscription) error {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	if _, ok := s.hooks.subs[sub.ID]; !ok {
+		return webhook.ErrNotFound
+	}
+	sub.Events = append([]string(nil), sub.Events...)
+	s.hooks.subs[sub.ID] = sub
+	return nil
+}
+
+func (s *Store) DeleteSubscription(ctx context.Context, id string) error {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	if _, ok := s.hooks.subs[id]; !ok {
+		return webhook.ErrNotFound
+	}
+	delete(s.hooks.subs, id)
+	for k, d := range s.hooks.deliveries {
+		if d.SubscriptionID == id {
+			delete(s.hooks.deliveries, k)
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetSubscription(ctx context.Context, id string) (webhook.Subscription, bool, error) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	sub, ok := s.hooks.subs[id]
+	return sub, ok, nil
+}
+
+func (s *Store) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	out := make([]webhook.Subscription, 0, len(s.hooks.subs))
+	for _, sub := range s.hooks.subs {
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *Store) EnqueueDeliveries(ctx context.Context, ds []webhook.Delivery) error {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	for _, d := range ds {
+		if _, ok := s.hooks.subs[d.SubscriptionID]; ok {
+			s.hooks.deliveries[d.ID] = d
+		}
+	}
+	return nil
+}
+
+func (s *Store) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	var due []webhook.Delivery
+	for _, d := range s.hooks.deliveries {
+		if d.Status == webhook.StatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		s.hooks.deliveries[d.ID] = d
+	}
+	return due, nil
+}
+
+func (s *Store) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	if _, ok := s.hooks.deliveries[d.ID]; !ok {
+		return webhook.ErrNotFound
+	}
+	d.Attempts = append([]webhook.Attempt(nil), d.Attempts...)
+	s.hooks.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *Store) GetDelivery(ctx context.Context, id string) (webhook.Delivery, bool, error) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	d, ok := s.hooks.deliveries[id]
+	return d, ok, nil
+}
+
+func (s *Store) ListDeliveries(ctx context.Context, q webhook.DeliveryQuery) ([]webhook.Delivery, error) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	var out []webhook.Delivery
+	for _, d := range s.hooks.deliveries {
+		if q.SubscriptionID != "" && d.SubscriptionID != q.SubscriptionID {
+			continue
+		}
+		if q.Status != "" && d.Status != q.Status {
+			continue
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS clicks (
+  id          BIGSERIAL   PRIMARY KEY,
+  code        VARCHAR(10) NOT NULL,
+  clicked_at  TIMESTAMPTZ NOT NULL,
+  referrer    TEXT        NOT NULL DEFAULT '',
+  user_agent  TEXT        NOT NULL DEFAULT '',
+  ip          TEXT        NOT NULL DEFAULT '',
+  request_id  TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS clicks_code_clicked_at_idx ON clicks (code, clicked_at);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS click_rollups_hourly (
+  code    VARCHAR(10) NOT NULL,
+  bucket  TIMESTAMPTZ NOT NULL,
+  clicks  BIGINT      NOT NULL,
+  PRIMARY KEY (code, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS click_rollups_daily (
+  code    VARCHAR(10) NOT NULL,
+  day     DATE        NOT NULL,
+  clicks  BIGINT      NOT NULL,
+  PRIMARY KEY (code, day)
+);
+
+CREATE TABLE IF NOT EXISTS click_dimensions_daily (
+  code       VARCHAR(10) NOT NULL,
+  day        DATE        NOT NULL,
+  dimension  TEXT        NOT NULL,
+  value      TEXT        NOT NULL,
+  clicks     BIGINT      NOT NULL,
+  PRIMARY KEY (code, day, dimension, value)
+);
+
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS device  TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS clicks_clicked_at_idx ON clicks (clicked_at);
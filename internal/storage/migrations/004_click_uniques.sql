@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS click_uniques_daily (
+  code    VARCHAR(10) NOT NULL,
+  day     DATE        NOT NULL,
+  sketch  BYTEA       NOT NULL,
+  PRIMARY KEY (code, day)
+);
@@ -0,0 +1,8 @@
+-- clicks в роллапах — только люди, bots — краулеры и превью ссылок.
+ALTER TABLE click_rollups_hourly   ADD COLUMN IF NOT EXISTS bots BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE click_rollups_daily    ADD COLUMN IF NOT EXISTS bots BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE click_dimensions_daily ADD COLUMN IF NOT EXISTS bots BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS os      TEXT    NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS browser TEXT    NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS bot     BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS city   TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS click_id_param TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS click_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS clicks_click_id_idx ON clicks (click_id) WHERE click_id <> '';
+
+CREATE TABLE IF NOT EXISTS conversions (
+  id            BIGSERIAL        PRIMARY KEY,
+  click_id      TEXT             NOT NULL,
+  code          VARCHAR(10)      NOT NULL,
+  event         TEXT             NOT NULL,
+  value         DOUBLE PRECISION NOT NULL DEFAULT 0,
+  converted_at  TIMESTAMPTZ      NOT NULL,
+  UNIQUE (click_id, event)
+);
+
+CREATE INDEX IF NOT EXISTS conversions_code_converted_at_idx ON conversions (code, converted_at);
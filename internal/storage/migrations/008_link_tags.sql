@@ -0,0 +1,3 @@
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS url_mappings_tags_idx ON url_mappings USING GIN (tags);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id          TEXT        PRIMARY KEY,
+  url         TEXT        NOT NULL,
+  secret      TEXT        NOT NULL,
+  events      JSONB       NOT NULL DEFAULT '[]',
+  active      BOOLEAN     NOT NULL DEFAULT TRUE,
+  created_at  TIMESTAMPTZ NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id               TEXT        PRIMARY KEY,
+  subscription_id  TEXT        NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+  event_id         TEXT        NOT NULL,
+  event_type       TEXT        NOT NULL,
+  payload          BYTEA       NOT NULL,
+  status           TEXT        NOT NULL,
+  tries            INT         NOT NULL DEFAULT 0,
+  attempts         JSONB       NOT NULL DEFAULT '[]',
+  next_attempt_at  TIMESTAMPTZ NOT NULL,
+  created_at       TIMESTAMPTZ NOT NULL,
+  updated_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+  ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_sub_created_idx
+  ON webhook_deliveries (subscription_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_dead_idx
+  ON webhook_deliveries (created_at DESC) WHERE status = 'dead';
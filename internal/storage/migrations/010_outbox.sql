@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  id            BIGSERIAL   PRIMARY KEY,
+  code          VARCHAR(10) NOT NULL,
+  type          TEXT        NOT NULL,
+  payload       JSONB       NOT NULL,
+  attempts      INT         NOT NULL DEFAULT 0,
+  last_error    TEXT        NOT NULL DEFAULT '',
+  available_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS outbox_code_id_idx ON outbox (code, id);
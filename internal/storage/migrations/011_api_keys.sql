@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  prefix        TEXT        PRIMARY KEY,
+  name          TEXT        NOT NULL DEFAULT '',
+  hash          BYTEA       NOT NULL,
+  scopes        TEXT        NOT NULL, -- через пробел
+  created_at    TIMESTAMPTZ NOT NULL,
+  expires_at    TIMESTAMPTZ,
+  last_used_at  TIMESTAMPTZ,
+  revoked_at    TIMESTAMPTZ
+);
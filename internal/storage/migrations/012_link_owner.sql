@@ -0,0 +1,4 @@
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS owner  TEXT NOT NULL DEFAULT '';
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS url_mappings_tenant_owner_idx ON url_mappings (tenant, owner) WHERE owner <> '';
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tenant_quotas (
+  tenant              TEXT        PRIMARY KEY,
+  max_links           INTEGER     NOT NULL DEFAULT 0,
+  max_monthly_creates INTEGER     NOT NULL DEFAULT 0,
+  updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS tenant_usage (
+  tenant          TEXT    PRIMARY KEY,
+  active_links    INTEGER NOT NULL DEFAULT 0,
+  month           DATE    NOT NULL,
+  monthly_creates INTEGER NOT NULL DEFAULT 0
+);
+
+-- ссылки, созданные до квот, занимают место сразу
+INSERT INTO tenant_usage (tenant, active_links, month)
+SELECT tenant, count(*), date_trunc('month', now() AT TIME ZONE 'UTC')::date
+FROM url_mappings WHERE tenant <> '' GROUP BY tenant
+ON CONFLICT (tenant) DO NOTHING;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tenant_roles (
+  tenant     TEXT        NOT NULL,
+  user_id    TEXT        NOT NULL,
+  role       TEXT        NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (tenant, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS link_shares (
+  code       TEXT        NOT NULL REFERENCES url_mappings (code) ON DELETE CASCADE,
+  user_id    TEXT        NOT NULL,
+  role       TEXT        NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (code, user_id)
+);
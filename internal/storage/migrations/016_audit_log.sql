@@ -0,0 +1,38 @@
+-- before/after — json, а не jsonb: хеш считается по исходному тексту
+CREATE TABLE IF NOT EXISTS audit_log (
+  seq          BIGINT      PRIMARY KEY,
+  at           TIMESTAMPTZ NOT NULL,
+  actor        TEXT        NOT NULL,
+  actor_user   TEXT        NOT NULL,
+  actor_tenant TEXT        NOT NULL,
+  action       TEXT        NOT NULL,
+  outcome      TEXT        NOT NULL,
+  tenant       TEXT        NOT NULL,
+  target       TEXT        NOT NULL,
+  before       JSON,
+  after        JSON,
+  request_id   TEXT        NOT NULL,
+  source_ip    TEXT        NOT NULL,
+  prev_hash    TEXT        NOT NULL,
+  hash         TEXT        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target, seq);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_idx ON audit_log (tenant, seq);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx  ON audit_log (actor, seq);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON audit_log
+  FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+  BEFORE TRUNCATE ON audit_log
+  FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,33 @@
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT false;
+
+-- жалобы переживают удаление ссылки: это история для модераторов
+CREATE TABLE IF NOT EXISTS abuse_reports (
+  id         BIGSERIAL   PRIMARY KEY,
+  code       TEXT        NOT NULL,
+  reason     TEXT        NOT NULL,
+  details    TEXT        NOT NULL DEFAULT '',
+  reporter   TEXT        NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS abuse_reports_code_idx ON abuse_reports (code, created_at);
+
+CREATE TABLE IF NOT EXISTS moderation_cases (
+  code        TEXT        PRIMARY KEY,
+  status      TEXT        NOT NULL,
+  reports     INTEGER     NOT NULL DEFAULT 0,
+  first_at    TIMESTAMPTZ,
+  last_at     TIMESTAMPTZ,
+  resolved_by TEXT        NOT NULL DEFAULT '',
+  resolved_at TIMESTAMPTZ,
+  note        TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS moderation_cases_status_idx ON moderation_cases (status, last_at DESC);
+
+CREATE TABLE IF NOT EXISTS banned_domains (
+  domain     TEXT        PRIMARY KEY,
+  reason     TEXT        NOT NULL DEFAULT '',
+  banned_by  TEXT        NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL
+);
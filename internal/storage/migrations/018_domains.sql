@@ -0,0 +1,41 @@
+-- Ссылка принадлежит домену: пустой domain — домен по умолчанию. Код и
+-- оригинал уникальны в пределах домена.
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE link_shares ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE abuse_reports ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE moderation_cases ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+
+-- Ключи пересоздаются один раз: признак — domain в первичном ключе
+-- url_mappings. Имена ограничений сохраняются, на них завязан разбор
+-- ошибок в хранилище.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_index i
+    JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+    WHERE i.indrelid = 'public.url_mappings'::regclass AND i.indisprimary AND a.attname = 'domain'
+  ) THEN
+    ALTER TABLE link_shares DROP CONSTRAINT IF EXISTS link_shares_code_fkey;
+    ALTER TABLE link_shares DROP CONSTRAINT IF EXISTS link_shares_pkey;
+    ALTER TABLE url_mappings DROP CONSTRAINT url_mappings_pkey;
+    ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_original_key;
+    ALTER TABLE url_mappings ADD CONSTRAINT url_mappings_pkey PRIMARY KEY (domain, code);
+    ALTER TABLE url_mappings ADD CONSTRAINT url_mappings_original_key UNIQUE (domain, original);
+    ALTER TABLE link_shares ADD CONSTRAINT link_shares_pkey PRIMARY KEY (domain, code, user_id);
+    ALTER TABLE link_shares ADD CONSTRAINT link_shares_code_fkey FOREIGN KEY (domain, code)
+      REFERENCES url_mappings (domain, code) ON DELETE CASCADE;
+    ALTER TABLE moderation_cases DROP CONSTRAINT IF EXISTS moderation_cases_pkey;
+    ALTER TABLE moderation_cases ADD CONSTRAINT moderation_cases_pkey PRIMARY KEY (domain, code);
+  END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS abuse_reports_code_idx;
+CREATE INDEX IF NOT EXISTS abuse_reports_link_idx ON abuse_reports (domain, code, created_at);
+
+-- Домены, на которых тенант может создавать ссылки; нет строки — только
+-- домен по умолчанию.
+CREATE TABLE IF NOT EXISTS tenant_domains (
+  tenant  TEXT   PRIMARY KEY,
+  domains TEXT[] NOT NULL
+);
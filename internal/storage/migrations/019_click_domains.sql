@@ -0,0 +1,35 @@
+-- Аналитика ключуется ссылкой целиком: один код на разных доменах — разные
+-- ссылки, возможно разных тенантов. Накопленные данные относятся к домену
+-- по умолчанию.
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE click_rollups_hourly ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE click_rollups_daily ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE click_dimensions_daily ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE click_uniques_daily ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE conversions ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+
+-- Ключи пересоздаются один раз: признак — domain в первичном ключе
+-- click_rollups_daily.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_index i
+    JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+    WHERE i.indrelid = 'public.click_rollups_daily'::regclass AND i.indisprimary AND a.attname = 'domain'
+  ) THEN
+    ALTER TABLE click_rollups_hourly DROP CONSTRAINT click_rollups_hourly_pkey;
+    ALTER TABLE click_rollups_hourly ADD CONSTRAINT click_rollups_hourly_pkey PRIMARY KEY (domain, code, bucket);
+    ALTER TABLE click_rollups_daily DROP CONSTRAINT click_rollups_daily_pkey;
+    ALTER TABLE click_rollups_daily ADD CONSTRAINT click_rollups_daily_pkey PRIMARY KEY (domain, code, day);
+    ALTER TABLE click_dimensions_daily DROP CONSTRAINT click_dimensions_daily_pkey;
+    ALTER TABLE click_dimensions_daily ADD CONSTRAINT click_dimensions_daily_pkey PRIMARY KEY (domain, code, day, dimension, value);
+    ALTER TABLE click_uniques_daily DROP CONSTRAINT click_uniques_daily_pkey;
+    ALTER TABLE click_uniques_daily ADD CONSTRAINT click_uniques_daily_pkey PRIMARY KEY (domain, code, day);
+  END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS clicks_code_clicked_at_idx;
+CREATE INDEX IF NOT EXISTS clicks_link_clicked_at_idx ON clicks (domain, code, clicked_at);
+DROP INDEX IF EXISTS conversions_code_converted_at_idx;
+CREATE INDEX IF NOT EXISTS conversions_link_converted_at_idx ON conversions (domain, code, converted_at);
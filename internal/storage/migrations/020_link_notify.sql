@@ -0,0 +1,34 @@
+-- Каждое изменение ссылки уведомляет все инстансы: они выбрасывают её из
+-- локального кеша. NOTIFY уходит при COMMIT, откаченные изменения молчат.
+-- Вставка тоже уведомляет: код мог быть закеширован как отсутствующий.
+CREATE OR REPLACE FUNCTION url_mappings_notify() RETURNS trigger AS $$
+DECLARE
+  r RECORD;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    r := OLD;
+  ELSE
+    r := NEW;
+  END IF;
+  PERFORM pg_notify('shortener_links', json_build_object('domain', r.domain, 'code', r.code)::text);
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS url_mappings_notify ON url_mappings;
+CREATE TRIGGER url_mappings_notify
+  AFTER INSERT OR UPDATE OR DELETE ON url_mappings
+  FOR EACH ROW EXECUTE FUNCTION url_mappings_notify();
+
+-- TRUNCATE не перечисляет строки: пустой payload — сбросить кеш целиком
+CREATE OR REPLACE FUNCTION url_mappings_notify_truncate() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('shortener_links', '');
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS url_mappings_notify_truncate ON url_mappings;
+CREATE TRIGGER url_mappings_notify_truncate
+  AFTER TRUNCATE ON url_mappings
+  FOR EACH STATEMENT EXECUTE FUNCTION url_mappings_notify_truncate();
@@ -0,0 +1,16 @@
+-- Один URL сокращается в одну ссылку в пределах тенанта, владельца и
+-- домена: общая на всех ссылка отдавала бы вызывающему чужую. Имя
+-- ограничения сохраняется, на него завязан разбор ошибок в хранилище.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint c
+    JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+    WHERE c.conrelid = 'public.url_mappings'::regclass
+      AND c.conname = 'url_mappings_original_key' AND a.attname = 'owner'
+  ) THEN
+    ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_original_key;
+    ALTER TABLE url_mappings ADD CONSTRAINT url_mappings_original_key UNIQUE (tenant, owner, domain, original);
+  END IF;
+END
+$$;
@@ -0,0 +1,7 @@
+-- События ключуются ссылкой целиком: один код на разных доменах — разные
+-- ссылки, их события не должны ждать друг друга. Накопленные события
+-- относятся к домену по умолчанию.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS outbox_code_id_idx;
+CREATE INDEX IF NOT EXISTS outbox_link_id_idx ON outbox (domain, code, id);
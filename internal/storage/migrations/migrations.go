@@ -0,0 +1,8 @@
+// Package migrations хранит SQL-схему. Те же файлы монтируются в
+// docker-entrypoint-initdb.d в docker-compose.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
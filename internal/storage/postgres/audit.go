@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+)
+
+const auditCols = `seq, at, actor, actor_user, actor_tenant, action, outcome, tenant, target,
+	before, after, request_id, source_ip, prev_hash, hash`
+
+// AppendAudit держит EXCLUSIVE-блокировку таблицы до коммита: цепочка
+// строго последовательна, а читать журнал она не мешает.
+func (s *Store) AppendAudit(ctx context.Context, e audit.Entry) (audit.Entry, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return audit.Entry{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE public.audit_log IN EXCLUSIVE MODE`); err != nil {
+		return audit.Entry{}, err
+	}
+	var prev audit.Entry
+	err = tx.QueryRowContext(ctx,
+		`SELECT seq, hash FROM public.audit_log ORDER BY seq DESC LIMIT 1`,
+	).Scan(&prev.Seq, &prev.Hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return audit.Entry{}, err
+	}
+
+	e = audit.Seal(prev, e)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.audit_log (`+auditCols+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::json, $11::json, $12, $13, $14, $15)`,
+		e.Seq, e.At, e.Actor, e.ActorUser, e.ActorTenant, e.Action, e.Outcome, e.Tenant, e.Target,
+		nullJSON(e.Before), nullJSON(e.After), e.RequestID, e.SourceIP, e.PrevHash, e.Hash,
+	)
+	if err != nil {
+		return audit.Entry{}, err
+	}
+	return e, tx.Commit()
+}
+
+func (s *Store) AuditEntries(ctx context.Context, q audit.Query) ([]audit.Entry, error) {
+	where := []string{"seq > $1"}
+	args := []any{q.AfterSeq}
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	for _, f := range []struct {
+		col, v string
+	}{
+		{"actor", q.Actor}, {"tenant", q.Tenant}, {"target", q.Target},
+		{"action", q.Action}, {"outcome", q.Outcome},
+	} {
+		if f.v != "" {
+			add(f.col+" = $%d", f.v)
+		}
+	}
+	if !q.From.IsZero() {
+		add("at >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		add("at < $%d", q.To)
+	}
+	args = append(args, q.Limit)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM public.audit_log WHERE %s ORDER BY seq LIMIT $%d`,
+		auditCols, strings.Join(where, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []audit.Entry
+	for rows.Next() {
+		var e audit.Entry
+		var before, after sql.NullString
+		if err := rows.Scan(&e.Seq, &e.At, &e.Actor, &e.ActorUser, &e.ActorTenant, &e.Action, &e.Outcome,
+			&e.Tenant, &e.Target, &before, &after, &e.RequestID, &e.SourceIP, &e.PrevHash, &e.Hash); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = []byte(before.String)
+		}
+		if after.Valid {
+			e.After = []byte(after.String)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func nullJSON(raw []byte) any {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
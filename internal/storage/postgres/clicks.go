@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/hll"
+)
+
+// WriteClicks вставляет сырые клики и прибавляет роллапы в одной
+// транзакции. Ключи роллапов сортируются, чтобы параллельные писатели
+// с разных инстансов брали блокировки в одном порядке.
+func (s *Store) WriteClicks(ctx context.Context, clicks []analytics.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertClicks(ctx, tx, clicks); err != nil {
+		return err
+	}
+	r := analytics.BuildRollup(clicks)
+	if err := upsertBuckets(ctx, tx, "click_rollups_hourly", "bucket", "timestamptz", r.Hourly); err != nil {
+		return err
+	}
+	if err := upsertBuckets(ctx, tx, "click_rollups_daily", "day", "date", r.Daily); err != nil {
+		return err
+	}
+	if err := upsertDims(ctx, tx, r.Dims); err != nil {
+		return err
+	}
+	if err := mergeUniques(ctx, tx, r.Uniques); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertClicks(ctx context.Context, tx *sql.Tx, clicks []analytics.Click) error {
+	n := len(clicks)
+	domains := make([]string, n)
+	codes := make([]string, n)
+	at := make([]time.Time, n)
+	refs := make([]string, n)
+	uas := make([]string, n)
+	ips := make([]string, n)
+	reqIDs := make([]string, n)
+	clickIDs := make([]string, n)
+	countries := make([]string, n)
+	regions := make([]string, n)
+	cities := make([]string, n)
+	devices := make([]string, n)
+	oses := make([]string, n)
+	browsers := make([]string, n)
+	bots := make([]bool, n)
+	for i, c := range clicks {
+		codes[i], at[i], refs[i], uas[i], ips[i], reqIDs[i] =
+			c.Code, c.At, c.Referrer, c.UserAgent, c.IP, c.RequestID
+		domains[i], clickIDs[i] = c.Domain, c.ClickID
+		countries[i], regions[i], cities[i] = c.Country, c.Region, c.City
+		devices[i], oses[i], browsers[i], bots[i] = c.Device, c.OS, c.Browser, c.Bot
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO public.clicks (code, clicked_at, referrer, user_agent, ip, request_id,
+			country, region, city, device, os, browser, bot, click_id, domain)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[], $6::text[],
+			$7::text[], $8::text[], $9::text[], $10::text[], $11::text[], $12::text[], $13::boolean[], $14::text[], $15::text[])`,
+		codes, at, refs, uas, ips, reqIDs, countries, regions, cities, devices, oses, browsers, bots, clickIDs, domains,
+	)
+	return err
+}
+
+func upsertBuckets(ctx context.Context, tx *sql.Tx, table, col, typ string, m map[analytics.BucketKey]analytics.Counts) error {
+	keys := make([]analytics.BucketKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortBucketKeys(keys)
+
+	domains := make([]string, len(keys))
+	codes := make([]string, len(keys))
+	at := make([]time.Time, len(keys))
+	humans := make([]int64, len(keys))
+	bots := make([]int64, len(keys))
+	for i, k := range keys {
+		domains[i], codes[i], at[i], humans[i], bots[i] = k.Domain, k.Code, k.At, m[k].Humans, m[k].Bots
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO public.`+table+` (domain, code, `+col+`, clicks, bots)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::`+typ+`[], $4::bigint[], $5::bigint[])
+		ON CONFLICT (domain, code, `+col+`) DO UPDATE SET
+			clicks = `+table+`.clicks + EXCLUDED.clicks,
+			bots   = `+table+`.bots + EXCLUDED.bots`,
+		domains, codes, at, humans, bots,
+	)
+	return err
+}
+
+func sortBucketKeys(keys []analytics.BucketKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Domain != keys[j].Domain {
+			return keys[i].Domain < keys[j].Domain
+		}
+		if keys[i].Code != keys[j].Code {
+			return keys[i].Code < keys[j].Code
+		}
+		return keys[i].At.Before(keys[j].At)
+	})
+}
+
+func upsertDims(ctx context.Context, tx *sql.Tx, m map[analytics.DimKey]analytics.Counts) error {
+	keys := make([]analytics.DimKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.Domain != b.Domain:
+			return a.Domain < b.Domain
+		case a.Code != b.Code:
+			return a.Code < b.Code
+		case !a.Day.Equal(b.Day):
+			return a.Day.Before(b.Day)
+		case a.Dimension != b.Dimension:
+			return a.Dimension < b.Dimension
+		default:
+			return a.Value < b.Value
+		}
+	})
+
+	n := len(keys)
+	domains := make([]string, n)
+	codes := make([]string, n)
+	days := make([]time.Time, n)
+	dims := make([]string, n)
+	vals := make([]string, n)
+	humans := make([]int64, n)
+	bots := make([]int64, n)
+	for i, k := range keys {
+		domains[i], codes[i], days[i], dims[i], vals[i] = k.Domain, k.Code, k.Day, k.Dimension, k.Value
+		humans[i], bots[i] = m[k].Humans, m[k].Bots
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO public.click_dimensions_daily (domain, code, day, dimension, value, clicks, bots)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::date[], $4::text[], $5::text[], $6::bigint[], $7::bigint[])
+		ON CONFLICT (domain, code, day, dimension, value) DO UPDATE SET
+			clicks = click_dimensions_daily.clicks + EXCLUDED.clicks,
+			bots   = click_dimensions_daily.bots + EXCLUDED.bots`,
+		domains, codes, days, dims, vals, humans, bots,
+	)
+	return err
+}
+
+// mergeUniques сливает скетчи пачки с сохранёнными. Сначала пробуем
+// вставить строку; если она уже есть, берём её FOR UPDATE и перезаписываем
+// объединением — так параллельные писатели не теряют регистры друг друга.
+func mergeUniques(ctx context.Context, tx *sql.Tx, m map[analytics.BucketKey]*hll.Sketch) error {
+	keys := make([]analytics.BucketKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortBucketKeys(keys)
+
+	for _, k := range keys {
+		sk := m[k]
+		b, _ := sk.MarshalBinary()
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO public.click_uniques_daily (domain, code, day, sketch) VALUES ($1, $2, $3::date, $4)
+			ON CONFLICT (domain, code, day) DO NOTHING`,
+			k.Domain, k.Code, k.At, b,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			continue
+		}
+
+		var stored []byte
+		if err := tx.QueryRowContext(ctx, `
+			SELECT sketch FROM public.click_uniques_daily
+			WHERE domain = $1 AND code = $2 AND day = $3::date FOR UPDATE`,
+			k.Domain, k.Code, k.At,
+		).Scan(&stored); err != nil {
+			return err
+		}
+		merged := hll.New()
+		if err := merged.UnmarshalBinary(stored); err != nil {
+			return err
+		}
+		merged.Merge(sk)
+		b, _ = merged.MarshalBinary()
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE public.click_uniques_daily SET sketch = $4
+			WHERE domain = $1 AND code = $2 AND day = $3::date`,
+			k.Domain, k.Code, k.At, b,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) WriteConversion(ctx context.Context, c analytics.Conversion) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.conversions (click_id, domain, code, event, value, converted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (click_id, event) DO NOTHING`,
+		c.ClickID, c.Domain, c.Code, c.Event, c.Value, c.At,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func (s *Store) Stats(ctx context.Context, q analytics.StatsQuery) (analytics.Stats, error) {
+	st := analytics.Stats{Domain: q.Domain, Code: q.Code}
+	from, to := analytics.Day(q.From), analytics.Day(q.To)
+
+	// Во всех запросах $1 — домен, $2 — код; $5 — включать ли ботов в
+	// счётчики.
+	var err error
+	st.Hourly, err = s.points(ctx, `
+		SELECT bucket, clicks + CASE WHEN $5 THEN bots ELSE 0 END AS n
+		FROM public.click_rollups_hourly
+		WHERE domain = $1 AND code = $2 AND bucket >= $3 AND bucket < $4 ORDER BY bucket`,
+		q.Domain, q.Code, q.From, q.To, q.IncludeBots)
+	if err != nil {
+		return st, err
+	}
+	st.Daily, err = s.points(ctx, `
+		SELECT day, clicks + CASE WHEN $5 THEN bots ELSE 0 END AS n
+		FROM public.click_rollups_daily
+		WHERE domain = $1 AND code = $2 AND day BETWEEN $3::date AND $4::date ORDER BY day`,
+		q.Domain, q.Code, from, to, q.IncludeBots)
+	if err != nil {
+		return st, err
+	}
+	for _, p := range st.Daily {
+		st.Total += p.Clicks
+	}
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(bots), 0) FROM public.click_rollups_daily
+		WHERE domain = $1 AND code = $2 AND day BETWEEN $3::date AND $4::date`,
+		q.Domain, q.Code, from, to,
+	).Scan(&st.Bots); err != nil {
+		return st, err
+	}
+
+	days, err := s.daySketches(ctx, q.Domain, q.Code, from, to)
+	if err != nil {
+		return st, err
+	}
+	st.Uniques, st.DailyUniques = analytics.Uniques(days)
+
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(value), 0) FROM public.conversions
+		WHERE domain = $1 AND code = $2 AND converted_at >= $3::date AND converted_at < $4::date + 1`,
+		q.Domain, q.Code, from, to,
+	).Scan(&st.Conversions, &st.ConversionValue); err != nil {
+		return st, err
+	}
+	st.DailyConversions, err = s.points(ctx, `
+		SELECT date_trunc('day', converted_at AT TIME ZONE 'UTC') AS day, COUNT(*)
+		FROM public.conversions
+		WHERE domain = $1 AND code = $2 AND converted_at >= $3::date AND converted_at < $4::date + 1
+		GROUP BY day ORDER BY day`,
+		q.Domain, q.Code, from, to)
+	if err != nil {
+		return st, err
+	}
+
+	if q.Top <= 0 {
+		q.Top = analytics.DefaultTop
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT dimension, value, n FROM (
+			SELECT dimension, value, n,
+			       row_number() OVER (PARTITION BY dimension ORDER BY n DESC, value) AS rn
+			FROM (
+				SELECT dimension, value, SUM(clicks + CASE WHEN $5 THEN bots ELSE 0 END) AS n
+				FROM public.click_dimensions_daily
+				WHERE domain = $1 AND code = $2 AND day BETWEEN $3::date AND $4::date
+				GROUP BY dimension, value
+			) sums
+			WHERE n > 0
+		) ranked
+		WHERE rn <= $6`,
+		q.Domain, q.Code, from, to, q.IncludeBots, q.Top)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	byDim := make(map[string]map[string]int64)
+	for rows.Next() {
+		var dim, val string
+		var n int64
+		if err := rows.Scan(&dim, &val, &n); err != nil {
+			return st, err
+		}
+		if byDim[dim] == nil {
+			byDim[dim] = make(map[string]int64)
+		}
+		byDim[dim][val] = n
+	}
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+	st.SetTop(byDim, q.Top)
+	return st, nil
+}
+
+func (s *Store) daySketches(ctx context.Context, domain, code string, from, to time.Time) ([]analytics.DaySketch, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT day, sketch FROM public.click_uniques_daily
+		WHERE domain = $1 AND code = $2 AND day BETWEEN $3::date AND $4::date`,
+		domain, code, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []analytics.DaySketch
+	for rows.Next() {
+		var d analytics.DaySketch
+		var b []byte
+		if err := rows.Scan(&d.Day, &b); err != nil {
+			return nil, err
+		}
+		d.Day = d.Day.UTC()
+		d.Sketch = hll.New()
+		if err := d.Sketch.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) points(ctx context.Context, query string, args ...any) ([]analytics.Point, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []analytics.Point
+	for rows.Next() {
+		var p analytics.Point
+		if err := rows.Scan(&p.At, &p.Clicks); err != nil {
+			return nil, err
+		}
+		if p.Clicks == 0 {
+			continue
+		}
+		p.At = p.At.UTC()
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) PurgeClicks(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM public.clicks WHERE clicked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM public.click_rollups_hourly WHERE bucket < $1`, before,
+	); err != nil {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import "context"
+
+// TenantDomains возвращает домены тенанта в порядке, в котором их задал
+// админ; nil — строки нет. Пустой список не хранится: SetTenantDomains
+// удаляет строку.
+func (s *Store) TenantDomains(ctx context.Context, tenant string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d FROM public.tenant_domains, unnest(domains) WITH ORDINALITY AS u(d, n)
+		WHERE tenant = $1 ORDER BY n`, tenant,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) SetTenantDomains(ctx context.Context, tenant string, domains []string) error {
+	if len(domains) == 0 {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM public.tenant_domains WHERE tenant = $1`, tenant)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.tenant_domains (tenant, domains) VALUES ($1, $2::text[])
+		ON CONFLICT (tenant) DO UPDATE SET domains = EXCLUDED.domains`,
+		tenant, domains,
+	)
+	return err
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+const keyCols = `prefix, name, hash, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+func (s *Store) CreateKey(ctx context.Context, k auth.Key) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.api_keys (prefix, name, hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		k.Prefix, k.Name, k.Hash, joinScopes(k.Scopes), k.CreatedAt, nullTime(k.ExpiresAt),
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return auth.ErrDupKey
+	}
+	return err
+}
+
+func (s *Store) GetKey(ctx context.Context, prefix string) (auth.Key, bool, error) {
+	k, err := scanKey(s.db.QueryRowContext(ctx,
+		`SELECT `+keyCols+` FROM public.api_keys WHERE prefix = $1`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Key{}, false, nil
+	}
+	if err != nil {
+		return auth.Key{}, false, err
+	}
+	return k, true, nil
+}
+
+func (s *Store) ListKeys(ctx context.Context) ([]auth.Key, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+keyCols+` FROM public.api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []auth.Key
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) RevokeKey(ctx context.Context, prefix string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE public.api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE prefix = $1`, prefix, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return auth.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (s *Store) TouchKey(ctx context.Context, prefix string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE public.api_keys SET last_used_at = $2 WHERE prefix = $1`, prefix, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return auth.ErrKeyNotFound
+	}
+	return nil
+}
+
+func scanKey(r rowScanner) (auth.Key, error) {
+	var k auth.Key
+	var scopes string
+	var expires, used, revoked sql.NullTime
+	if err := r.Scan(&k.Prefix, &k.Name, &k.Hash, &scopes, &k.CreatedAt, &expires, &used, &revoked); err != nil {
+		return k, err
+	}
+	for _, s := range strings.Fields(scopes) {
+		k.Scopes = append(k.Scopes, core.Scope(s))
+	}
+	k.ExpiresAt, k.LastUsedAt, k.RevokedAt = expires.Time, used.Time, revoked.Time
+	return k, nil
+}
+
+func joinScopes(scopes []core.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
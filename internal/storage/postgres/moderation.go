@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+)
+
+const caseCols = `domain, code, status, reports, first_at, last_at, resolved_by, resolved_at, note`
+
+func (s *Store) AddReport(ctx context.Context, r moderation.Report) (moderation.Case, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return moderation.Case{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.abuse_reports (domain, code, reason, details, reporter, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		r.Domain, r.Code, string(r.Reason), r.Details, r.Reporter, r.CreatedAt,
+	)
+	if err != nil {
+		return moderation.Case{}, err
+	}
+	c, err := scanCase(tx.QueryRowContext(ctx, `
+		INSERT INTO public.moderation_cases (domain, code, status, reports, first_at, last_at)
+		VALUES ($1, $2, 'open', 1, $3, $3)
+		ON CONFLICT (domain, code) DO UPDATE SET
+			reports  = moderation_cases.reports + 1,
+			first_at = COALESCE(moderation_cases.first_at, EXCLUDED.first_at),
+			last_at  = EXCLUDED.last_at,
+			status   = CASE WHEN moderation_cases.status = 'dismissed' THEN 'open' ELSE moderation_cases.status END
+		RETURNING `+caseCols,
+		r.Domain, r.Code, r.CreatedAt,
+	))
+	if err != nil {
+		return moderation.Case{}, err
+	}
+	return c, tx.Commit()
+}
+
+func (s *Store) Reporters(ctx context.Context, domain, code string, since time.Time) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT count(DISTINCT reporter) FROM public.abuse_reports
+		WHERE domain = $1 AND code = $2 AND created_at >= $3`, domain, code, since,
+	).Scan(&n)
+	return n, err
+}
+
+func (s *Store) Cases(ctx context.Context, status moderation.Status, limit int) ([]moderation.Case, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+caseCols+` FROM public.moderation_cases
+		WHERE $1 = '' OR status = $1
+		ORDER BY last_at DESC NULLS LAST, code, domain
+		LIMIT NULLIF($2, -1)`, string(status), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []moderation.Case{}
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) Case(ctx context.Context, domain, code string) (moderation.Case, bool, error) {
+	c, err := scanCase(s.db.QueryRowContext(ctx,
+		`SELECT `+caseCols+` FROM public.moderation_cases WHERE domain = $1 AND code = $2`, domain, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return moderation.Case{}, false, nil
+	}
+	return c, err == nil, err
+}
+
+func (s *Store) Reports(ctx context.Context, domain, code string, limit int) ([]moderation.Report, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT domain, code, reason, details, reporter, created_at FROM public.abuse_reports
+		WHERE domain = $1 AND code = $2 ORDER BY id DESC LIMIT NULLIF($3, -1)`, domain, code, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []moderation.Report{}
+	for rows.Next() {
+		var r moderation.Report
+		var reason string
+		if err := rows.Scan(&r.Domain, &r.Code, &reason, &r.Details, &r.Reporter, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Reason = moderation.Reason(reason)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) ResolveCase(ctx context.Context, c moderation.Case) (moderation.Case, error) {
+	return scanCase(s.db.QueryRowContext(ctx, `
+		INSERT INTO public.moderation_cases (domain, code, status, resolved_by, resolved_at, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (domain, code) DO UPDATE SET
+			status      = EXCLUDED.status,
+			resolved_by = EXCLUDED.resolved_by,
+			resolved_at = EXCLUDED.resolved_at,
+			note        = EXCLUDED.note
+		RETURNING `+caseCols,
+		c.Domain, c.Code, string(c.Status), c.ResolvedBy, c.ResolvedAt, c.Note,
+	))
+}
+
+func (s *Store) BanDomain(ctx context.Context, b moderation.Ban) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.banned_domains (domain, reason, banned_by, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain) DO UPDATE SET reason = EXCLUDED.reason, banned_by = EXCLUDED.banned_by, created_at = EXCLUDED.created_at`,
+		b.Domain, b.Reason, b.By, b.At,
+	)
+	return err
+}
+
+func (s *Store) UnbanDomain(ctx context.Context, domain string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM public.banned_domains WHERE domain = $1`, domain)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return moderation.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) Bans(ctx context.Context) ([]moderation.Ban, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT domain, reason, banned_by, created_at FROM public.banned_domains ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []moderation.Ban{}
+	for rows.Next() {
+		var b moderation.Ban
+		if err := rows.Scan(&b.Domain, &b.Reason, &b.By, &b.At); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func scanCase(r rowScanner) (moderation.Case, error) {
+	var c moderation.Case
+	var status string
+	var first, last, resolved sql.NullTime
+	if err := r.Scan(&c.Domain, &c.Code, &status, &c.Reports, &first, &last, &c.ResolvedBy, &resolved, &c.Note); err != nil {
+		return moderation.Case{}, err
+	}
+	c.Status = moderation.Status(status)
+	c.FirstAt, c.LastAt = first.Time, last.Time
+	if resolved.Valid {
+		t := resolved.Time
+		c.ResolvedAt = &t
+	}
+	return c, nil
+}
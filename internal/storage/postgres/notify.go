@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notify отправляет payload в канал LISTEN/NOTIFY. Postgres ограничивает
+// payload 8000 байтами.
+func (s *Store) Notify(ctx context.Context, channel, payload string) error {
+	_, err := s.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// Listen держит выделенное соединение с LISTEN channel и вызывает fn на
+// каждое уведомление, пока не отменён ctx. Обрыв соединения не фатален:
+// переподключается с экспоненциальной задержкой до 30 с. Уведомления,
+// пришедшие во время обрыва, теряются.
+func (s *Store) Listen(ctx context.Context, log *slog.Logger, channel string, fn func(payload string)) {
+	s.ListenResync(ctx, log, channel, fn, nil)
+}
+
+// ListenResync — Listen, который вызывает resync после каждого
+// подключения, когда LISTEN уже действует: всё, что пришло до этого
+// момента, могло потеряться, и подписчик должен восстановиться сам,
+// например сбросить кеш.
+func (s *Store) ListenResync(ctx context.Context, log *slog.Logger, channel string, fn func(payload string), resync func()) {
+	backoff := time.Second
+	for {
+		err := s.listen(ctx, channel, fn, func() {
+			backoff = time.Second
+			if resync != nil {
+				resync()
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("listen connection lost", "channel", channel, "err", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+func (s *Store) listen(ctx context.Context, channel string, fn func(string), connected func()) error {
+	conn, err := pgx.Connect(ctx, s.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, `LISTEN `+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/outbox"
+)
+
+func insertOutbox(ctx context.Context, tx *sql.Tx, typ core.EventType, link core.Link) error {
+	payload, err := outbox.EncodeLinkEvent(typ, link, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO public.outbox (domain, code, type, payload) VALUES ($1, $2, $3, $4)`,
+		link.Domain, link.Code, string(typ), payload,
+	)
+	return err
+}
+
+// RelayOutbox берёт первые по порядку сообщения ключей (ссылок: домен и
+// код) через SKIP LOCKED, а затем блокирует и остальные сообщения этих
+// ключей. Другой инстанс не возьмёт ключ, пока у него есть более раннее
+// сообщение, так что порядок внутри ссылки сохраняется при любом числе
+// relay. Публикация
+// идёт внутри транзакции: при падении до коммита сообщения будут
+// опубликованы повторно.
+func (s *Store) RelayOutbox(ctx context.Context, limit int, handle func(context.Context, []outbox.Message) []outbox.Result) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH heads AS (
+			SELECT o.domain, o.code FROM public.outbox o
+			WHERE o.available_at <= now()
+			  AND NOT EXISTS (SELECT 1 FROM public.outbox p WHERE p.domain = o.domain AND p.code = o.code AND p.id < o.id)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		SELECT id, domain, code, type, payload, attempts, created_at FROM public.outbox
+		WHERE (domain, code) IN (SELECT domain, code FROM heads)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var msgs []outbox.Message
+	for rows.Next() {
+		var m outbox.Message
+		var domain, code string
+		if err := rows.Scan(&m.ID, &domain, &code, &m.Type, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m.Key = outbox.LinkKey(domain, code)
+		msgs = append(msgs, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	results := handle(ctx, msgs)
+
+	var done []int64
+	for i, m := range msgs {
+		r := results[i]
+		if r.Published {
+			done = append(done, m.ID)
+			continue
+		}
+		// Сообщения, пропущенные из-за ошибки раньше по ключу, не
+		// считаются попыткой.
+		tried := 0
+		if r.Error != "" {
+			tried = 1
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE public.outbox
+			SET attempts = attempts + $2, available_at = $4,
+				last_error = CASE WHEN $3 = '' THEN last_error ELSE $3 END
+			WHERE id = $1`,
+			m.ID, tried, r.Error, r.RetryAt,
+		); err != nil {
+			return 0, err
+		}
+	}
+	if len(done) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM public.outbox WHERE id = ANY($1)`, done); err != nil {
+			return 0, err
+		}
+	}
+	return len(msgs), tx.Commit()
+}
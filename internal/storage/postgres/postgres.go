@@ -3,17 +3,26 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/migrations"
 )
 
 type Store struct {
-	db *sql.DB
+	db  *sql.DB
+	dsn string // для выделенных LISTEN-соединений
+
+	defQuota core.Quota
 }
 
 func New(dsn string) (*Store, error) {
@@ -25,39 +34,117 @@ func New(dsn string) (*Store, error) {
 	db.SetMaxIdleConns(20)
 	db.SetConnMaxLifetime(30 * time.Minute)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
-	var rel sql.NullString
-	if err := db.QueryRowContext(ctx,
-		`SELECT to_regclass('public.url_mappings')`,
-	).Scan(&rel); err != nil {
+	// отдельный таймаут: инстанс может ждать, пока соседний накатывает миграции
+	mctx, mcancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer mcancel()
+	if err := migrate(mctx, db); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
-	if !rel.Valid {
-		if _, err := db.ExecContext(ctx, `
-			CREATE TABLE IF NOT EXISTS public.url_mappings (
-				code       VARCHAR(10) PRIMARY KEY,
-				original   TEXT NOT NULL UNIQUE,
-				created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-			)`); err != nil {
-			_ = db.Close()
-			return nil, err
+	return &Store{db: db, dsn: dsn}, nil
+}
+
+// migrationLock — ключ сессионной advisory-блокировки, под которой
+// инстансы по очереди накатывают миграции.
+const migrationLock = 0x73686f72746e // "shortn"
+
+// migrate накатывает по порядку встроенные миграции, которых ещё нет в
+// schema_migrations, каждую в своей транзакции вместе с отметкой о ней.
+// Параллельно стартующие инстансы ждут друг друга на migrationLock. Все
+// миграции идемпотентны, так что база, размеченная до появления
+// schema_migrations, просто проходит их ещё раз.
+func migrate(ctx context.Context, db *sql.DB) error {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	defer func() {
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+		if err != nil {
+			// соединение с неснятой блокировкой в пул не возвращаем
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS public.schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM public.schema_migrations`)
+	if err != nil {
+		return err
+	}
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		version := strings.TrimSuffix(name, ".sql")
+		if applied[version] {
+			continue
+		}
+		stmt, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, conn, version, string(stmt)); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
 		}
 	}
-	return &Store{db: db}, nil
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, version, stmt string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO public.schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Store) Close() error { return s.db.Close() }
 
-func (s *Store) GetByOriginal(ctx context.Context, original string) (string, bool, error) {
+func (s *Store) GetByOriginal(ctx context.Context, k core.OriginKey) (string, bool, error) {
 	var code string
 	err := s.db.QueryRowContext(ctx,
-    	`SELECT code FROM public.url_mappings WHERE original = $1`, original,
+    	`SELECT code FROM public.url_mappings WHERE tenant = $1 AND owner = $2 AND domain = $3 AND original = $4`,
+		k.Tenant, k.Owner, k.Domain, k.Original,
 	).Scan(&code)
 	switch {
 	case err == nil:
@@ -69,37 +156,180 @@ func (s *Store) GetByOriginal(ctx context.Context, original string) (string, boo
 	}
 }
 
-func (s *Store) GetByCode(ctx context.Context, code string) (string, bool, error) {
-	var original string
+func (s *Store) GetByCode(ctx context.Context, domain, code string) (core.Link, bool, error) {
+	link := core.Link{Domain: domain, Code: code}
 	err := s.db.QueryRowContext(ctx,
-    	`SELECT original FROM public.url_mappings WHERE code = $1`, code,
-	).Scan(&original)
+		`SELECT original, owner, tenant, disabled, click_id_param, `+tagsCol+` FROM public.url_mappings WHERE domain = $1 AND code = $2`, domain, code,
+	).Scan(&link.Original, &link.Owner, &link.Tenant, &link.Disabled, &link.ClickIDParam, (*tags)(&link.Tags))
 	switch {
 	case err == nil:
-		return original, true, nil
+		return link, true, nil
 	case errors.Is(err, sql.ErrNoRows):
-		return "", false, nil
+		return core.Link{}, false, nil
 	default:
-		return "", false, err
+		return core.Link{}, false, err
+	}
+}
+
+// Create, Update и Delete пишут событие в outbox в той же транзакции.
+// Строка outbox вставляется после изменения url_mappings: блокировка
+// строки упорядочивает конкурентные изменения одного кода, и их ID в
+// outbox идут в том же порядке.
+func (s *Store) Create(ctx context.Context, link core.Link) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.reserveQuota(ctx, tx, link.Tenant); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO public.url_mappings(domain, code, original, owner, tenant, click_id_param, tags) VALUES ($1, $2, $3, $4, $5, $6, $7::text[])`,
+		link.Domain, link.Code, link.Original, link.Owner, link.Tenant, link.ClickIDParam, tagsArg(link.Tags),
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			switch pgErr.ConstraintName {
+			case "url_mappings_pkey":
+				return core.ErrDupCode
+			case "url_mappings_original_key":
+				return core.ErrDupOrigin
+			}
+		}
+		return err
+	}
+	if err := insertOutbox(ctx, tx, core.LinkCreated, link); err != nil {
+		return err
 	}
+	return tx.Commit()
 }
 
-func (s *Store) Create(ctx context.Context, code, original string) error {
-	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO public.url_mappings(code, original) VALUES ($1, $2)`,
-		code, original,
+func (s *Store) Update(ctx context.Context, link core.Link) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE public.url_mappings SET click_id_param = $3, tags = $4::text[] WHERE domain = $1 AND code = $2 RETURNING original, owner, tenant, disabled`,
+		link.Domain, link.Code, link.ClickIDParam, tagsArg(link.Tags),
+	).Scan(&link.Original, &link.Owner, &link.Tenant, &link.Disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, core.LinkUpdated, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) SetDisabled(ctx context.Context, domain, code string, disabled bool) (core.Link, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return core.Link{}, err
+	}
+	defer tx.Rollback()
+
+	link := core.Link{Domain: domain, Code: code, Disabled: disabled}
+	err = tx.QueryRowContext(ctx,
+		`UPDATE public.url_mappings SET disabled = $3 WHERE domain = $1 AND code = $2 RETURNING original, owner, tenant, click_id_param, `+tagsCol,
+		domain, code, disabled,
+	).Scan(&link.Original, &link.Owner, &link.Tenant, &link.ClickIDParam, (*tags)(&link.Tags))
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.Link{}, core.ErrNotFound
+	}
+	if err != nil {
+		return core.Link{}, err
+	}
+	if err := insertOutbox(ctx, tx, core.LinkUpdated, link); err != nil {
+		return core.Link{}, err
+	}
+	return link, tx.Commit()
+}
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	link := core.Link{Domain: domain, Code: code}
+	err = tx.QueryRowContext(ctx,
+		`DELETE FROM public.url_mappings WHERE domain = $1 AND code = $2 RETURNING original, owner, tenant, disabled, click_id_param, `+tagsCol, domain, code,
+	).Scan(&link.Original, &link.Owner, &link.Tenant, &link.Disabled, &link.ClickIDParam, (*tags)(&link.Tags))
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := releaseQuota(ctx, tx, link.Tenant); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, core.LinkDeleted, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) List(ctx context.Context, f core.LinkFilter) ([]core.Link, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT domain, code, original, owner, tenant, disabled, click_id_param, `+tagsCol+`
+		FROM public.url_mappings
+		WHERE domain = $6 AND ($1 OR tenant = $2) AND ($3 = '' OR owner = $3)
+		  AND ($7 = '' OR tags @> ARRAY[$7]::text[]) AND code > $4
+		ORDER BY code
+		LIMIT $5`,
+		f.AnyTenant, f.Tenant, f.Owner, f.After, f.Limit, f.Domain, f.Tag,
 	)
-	if err == nil {
-		return nil
-	}
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) && pgErr.Code == "23505" { 
-		switch pgErr.ConstraintName {
-		case "url_mappings_pkey":
-			return core.ErrDupCode
-		case "url_mappings_original_key":
-			return core.ErrDupOrigin
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []core.Link
+	for rows.Next() {
+		var l core.Link
+		if err := rows.Scan(&l.Domain, &l.Code, &l.Original, &l.Owner, &l.Tenant, &l.Disabled, &l.ClickIDParam, (*tags)(&l.Tags)); err != nil {
+			return nil, err
 		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// Метки хранятся в tags TEXT[] (GIN-индекс для выборки по метке), а
+// читаются строкой через пробел: в самих метках пробелов нет.
+const tagsCol = `array_to_string(tags, ' ')`
+
+type tags []string
+
+func (t *tags) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		*t = strings.Fields(v)
+	case []byte:
+		*t = strings.Fields(string(v))
+	case nil:
+		*t = nil
+	default:
+		return fmt.Errorf("tags: unexpected %T", src)
+	}
+	return nil
+}
+
+// tagsArg — значение для $n::text[]; nil ушёл бы как NULL.
+func tagsArg(t []string) []string {
+	if t == nil {
+		return []string{}
 	}
-	return err
+	return t
 }
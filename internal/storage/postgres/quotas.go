@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// SetDefaultQuota задаёт квоту тенантов без своей записи в tenant_quotas.
+// Вызывается при старте, до обслуживания запросов.
+func (s *Store) SetDefaultQuota(q core.Quota) { s.defQuota = q }
+
+func (s *Store) SetQuota(ctx context.Context, tenant string, q *core.Quota) error {
+	if q == nil {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM public.tenant_quotas WHERE tenant = $1`, tenant)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.tenant_quotas (tenant, max_links, max_monthly_creates)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant) DO UPDATE
+		SET max_links = EXCLUDED.max_links,
+		    max_monthly_creates = EXCLUDED.max_monthly_creates,
+		    updated_at = now()`,
+		tenant, q.MaxLinks, q.MaxMonthlyCreates,
+	)
+	return err
+}
+
+func (s *Store) QuotaUsage(ctx context.Context, tenant string, now time.Time) (core.QuotaUsage, error) {
+	month := core.MonthStart(now)
+	u := core.QuotaUsage{Tenant: tenant, Quota: s.defQuota, Month: month}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT max_links, max_monthly_creates FROM public.tenant_quotas WHERE tenant = $1`, tenant,
+	).Scan(&u.Quota.MaxLinks, &u.Quota.MaxMonthlyCreates)
+	switch {
+	case err == nil:
+		u.Custom = true
+	case !errors.Is(err, sql.ErrNoRows):
+		return core.QuotaUsage{}, err
+	}
+	err = s.db.QueryRowContext(ctx, `
+		SELECT active_links, CASE WHEN month = $2::date THEN monthly_creates ELSE 0 END
+		FROM public.tenant_usage WHERE tenant = $1`,
+		tenant, month.Format(time.DateOnly),
+	).Scan(&u.ActiveLinks, &u.MonthlyCreates)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return core.QuotaUsage{}, err
+	}
+	return u, nil
+}
+
+// reserveQuota проверяет квоту и учитывает новую ссылку тенанта в
+// транзакции Create. Строка tenant_usage блокируется до конца транзакции,
+// так что конкурентные создания одного тенанта не проскочат лимит; если
+// вставка ссылки потом упадёт, откат вернёт и счётчики.
+func (s *Store) reserveQuota(ctx context.Context, tx *sql.Tx, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	month := core.MonthStart(time.Now()).Format(time.DateOnly)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO public.tenant_usage (tenant, month) VALUES ($1, $2::date)
+		ON CONFLICT (tenant) DO NOTHING`, tenant, month,
+	); err != nil {
+		return err
+	}
+	var active, monthly int
+	q := s.defQuota
+	var maxLinks, maxMonthly sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+		SELECT u.active_links,
+		       CASE WHEN u.month = $2::date THEN u.monthly_creates ELSE 0 END,
+		       q.max_links, q.max_monthly_creates
+		FROM public.tenant_usage u
+		LEFT JOIN public.tenant_quotas q ON q.tenant = u.tenant
+		WHERE u.tenant = $1
+		FOR UPDATE OF u`, tenant, month,
+	).Scan(&active, &monthly, &maxLinks, &maxMonthly)
+	if err != nil {
+		return err
+	}
+	if maxLinks.Valid {
+		q = core.Quota{MaxLinks: int(maxLinks.Int64), MaxMonthlyCreates: int(maxMonthly.Int64)}
+	}
+	if err := core.CheckQuota(tenant, q, active, monthly); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.tenant_usage
+		SET active_links = active_links + 1,
+		    monthly_creates = CASE WHEN month = $2::date THEN monthly_creates + 1 ELSE 1 END,
+		    month = $2::date
+		WHERE tenant = $1`, tenant, month,
+	)
+	return err
+}
+
+func releaseQuota(ctx context.Context, tx *sql.Tx, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE public.tenant_usage SET active_links = greatest(active_links - 1, 0)
+		WHERE tenant = $1`, tenant,
+	)
+	return err
+}
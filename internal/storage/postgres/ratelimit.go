@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+)
+
+// refill — токены корзины после пополнения на момент запроса.
+const refill = `LEAST($3::float8, r.tokens + EXTRACT(EPOCH FROM now() - r.updated_at) * $2::float8)`
+
+// Allow — общий для реплик token bucket: пополнение и попытка взять
+// токен выполняются одним upsert под блокировкой строки корзины.
+// Таблица UNLOGGED: после сбоя базы корзины просто начнутся заново.
+func (s *Store) Allow(ctx context.Context, key string, p ratelimit.Policy) (ratelimit.Decision, error) {
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO public.rate_limits AS r (key, tokens, allowed, updated_at)
+		VALUES ($1, $3::float8 - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN `+refill+` >= 1 THEN `+refill+` - 1 ELSE `+refill+` END,
+			allowed = `+refill+` >= 1,
+			updated_at = now()
+		RETURNING r.tokens, r.allowed`,
+		ratelimit.BucketKey(p, key), p.Rate, p.Burst,
+	).Scan(&tokens, &allowed)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+	return ratelimit.DecisionFor(p, tokens, allowed), nil
+}
+
+// PurgeRateLimits удаляет корзины, не тронутые дольше idle.
+func (s *Store) PurgeRateLimits(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM public.rate_limits WHERE updated_at < now() - make_interval(secs => $1)`, idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
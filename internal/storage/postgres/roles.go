@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+func (s *Store) TenantRole(ctx context.Context, tenant, user string) (authz.Role, bool, error) {
+	return s.role(ctx, `SELECT role FROM public.tenant_roles WHERE tenant = $1 AND user_id = $2`, tenant, user)
+}
+
+func (s *Store) SetTenantRole(ctx context.Context, tenant string, a authz.Assignment) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.tenant_roles (tenant, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		tenant, a.User, string(a.Role), a.CreatedAt,
+	)
+	return err
+}
+
+func (s *Store) DeleteTenantRole(ctx context.Context, tenant, user string) error {
+	return s.deleteRole(ctx, `DELETE FROM public.tenant_roles WHERE tenant = $1 AND user_id = $2`, tenant, user)
+}
+
+func (s *Store) TenantRoles(ctx context.Context, tenant string) ([]authz.Assignment, error) {
+	return s.assignments(ctx, `
+		SELECT user_id, role, created_at FROM public.tenant_roles
+		WHERE tenant = $1 ORDER BY user_id`, tenant)
+}
+
+func (s *Store) LinkShare(ctx context.Context, domain, code, user string) (authz.Role, bool, error) {
+	return s.role(ctx, `SELECT role FROM public.link_shares WHERE domain = $1 AND code = $2 AND user_id = $3`, domain, code, user)
+}
+
+func (s *Store) ShareLink(ctx context.Context, domain, code string, a authz.Assignment) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO public.link_shares (domain, code, user_id, role, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (domain, code, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		domain, code, a.User, string(a.Role), a.CreatedAt,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return core.ErrNotFound
+	}
+	return err
+}
+
+func (s *Store) UnshareLink(ctx context.Context, domain, code, user string) error {
+	return s.deleteRole(ctx, `DELETE FROM public.link_shares WHERE domain = $1 AND code = $2 AND user_id = $3`, domain, code, user)
+}
+
+func (s *Store) LinkShares(ctx context.Context, domain, code string) ([]authz.Assignment, error) {
+	return s.assignments(ctx, `
+		SELECT user_id, role, created_at FROM public.link_shares
+		WHERE domain = $1 AND code = $2 ORDER BY user_id`, domain, code)
+}
+
+func (s *Store) role(ctx context.Context, query string, args ...any) (authz.Role, bool, error) {
+	var r string
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&r)
+	switch {
+	case err == nil:
+		return authz.Role(r), true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return "", false, nil
+	default:
+		return "", false, err
+	}
+}
+
+func (s *Store) deleteRole(ctx context.Context, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return authz.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) assignments(ctx context.Context, query string, args ...any) ([]authz.Assignment, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []authz.Assignment{}
+	for rows.Next() {
+		var a authz.Assignment
+		var r string
+		if err := rows.Scan(&a.User, &r, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Role = authz.Role(r)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+// scanFetchSize — сколько строк курсор отдаёт за один FETCH.
+const scanFetchSize = 1000
+
+// ScanClicks читает клики серверным курсором порциями по scanFetchSize,
+// так что память не растёт с размером выгрузки. Курсор живёт в
+// read-only транзакции, которая держится до конца обхода.
+func (s *Store) ScanClicks(ctx context.Context, q analytics.ClickQuery, fn func(analytics.Click) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DECLARE export_clicks NO SCROLL CURSOR FOR
+		SELECT domain, code, clicked_at, referrer, user_agent, ip, request_id, click_id,
+		       country, region, city, device, os, browser, bot
+		FROM public.clicks
+		WHERE domain = $1 AND code = ANY($2) AND clicked_at >= $3 AND clicked_at < $4
+		ORDER BY clicked_at, id`,
+		q.Domain, q.Codes, q.From, q.To,
+	); err != nil {
+		return err
+	}
+
+	fetch := `FETCH FORWARD ` + strconv.Itoa(scanFetchSize) + ` FROM export_clicks`
+	for {
+		n, err := fetchClicks(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < scanFetchSize {
+			return nil
+		}
+	}
+}
+
+func fetchClicks(ctx context.Context, tx *sql.Tx, fetch string, fn func(analytics.Click) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var c analytics.Click
+		if err := rows.Scan(&c.Domain, &c.Code, &c.At, &c.Referrer, &c.UserAgent, &c.IP, &c.RequestID, &c.ClickID,
+			&c.Country, &c.Region, &c.City, &c.Device, &c.OS, &c.Browser, &c.Bot); err != nil {
+			return n, err
+		}
+		c.At = c.At.UTC()
+		n++
+		if err := fn(c); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
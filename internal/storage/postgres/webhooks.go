@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
+)
+
+const subscriptionCols = `id, url, secret, events, active, created_at, updated_at`
+
+func (s *Store) CreateSubscription(ctx context.Context, sub webhook.Subscription) error {
+	events, err := json.Marshal(nonNil(sub.Events))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO public.webhook_subscriptions (`+subscriptionCols+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		sub.ID, sub.URL, sub.Secret, events, sub.Active, sub.CreatedAt, sub.UpdatedAt,
+	)
+	return err
+}
+
+func (s *Store) UpdateSubscription(ctx context.Context, sub webhook.Subscription) error {
+	events, err := json.Marshal(nonNil(sub.Events))
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE public.webhook_subscriptions
+		SET url = $2, events = $3, active = $4, updated_at = $5
+		WHERE id = $1`,
+		sub.ID, sub.URL, events, sub.Active, sub.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteSubscription(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM public.webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetSubscription(ctx context.Context, id string) (webhook.Subscription, bool, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+subscriptionCols+` FROM public.webhook_subscriptions WHERE id = $1`, id)
+	sub, err := scanSubscription(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook.Subscription{}, false, nil
+	}
+	if err != nil {
+		return webhook.Subscription{}, false, err
+	}
+	return sub, true, nil
+}
+
+func (s *Store) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+subscriptionCols+` FROM public.webhook_subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []webhook.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(r rowScanner) (webhook.Subscription, error) {
+	var sub webhook.Subscription
+	var events []byte
+	if err := r.Scan(&sub.ID, &sub.URL, &sub.Secret, &events, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		return sub, err
+	}
+	if err := json.Unmarshal(events, &sub.Events); err != nil {
+		return sub, fmt.Errorf("webhook %s events: %w", sub.ID, err)
+	}
+	return sub, nil
+}
+
+const deliveryCols = `id, subscription_id, event_id, event_type, payload, status, tries, attempts,
+	next_attempt_at, created_at, updated_at`
+
+// EnqueueDeliveries пропускает доставки подписок, удалённых между
+// фильтрацией события и вставкой.
+func (s *Store) EnqueueDeliveries(ctx context.Context, ds []webhook.Delivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range ds {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO public.webhook_deliveries (`+deliveryCols+`)
+			SELECT $1, $2, $3, $4, $5, $6, $7, '[]', $8, $9, $9
+			WHERE EXISTS (SELECT 1 FROM public.webhook_subscriptions WHERE id = $2)`,
+			d.ID, d.SubscriptionID, d.EventID, d.EventType, d.Payload, string(d.Status), d.Tries,
+			d.NextAttemptAt, d.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClaimDeliveries через SKIP LOCKED раздаёт разные доставки параллельным
+// инстансам и продлевает аренду одним запросом.
+func (s *Store) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE public.webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM public.webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryCols,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+func (s *Store) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	attempts, err := json.Marshal(nonNilAttempts(d.Attempts))
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE public.webhook_deliveries
+		SET status = $2, tries = $3, attempts = $4, next_attempt_at = $5, updated_at = $6
+		WHERE id = $1`,
+		d.ID, string(d.Status), d.Tries, attempts, d.NextAttemptAt, d.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetDelivery(ctx context.Context, id string) (webhook.Delivery, bool, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+deliveryCols+` FROM public.webhook_deliveries WHERE id = $1`, id)
+	if err != nil {
+		return webhook.Delivery{}, false, err
+	}
+	ds, err := collectDeliveries(rows)
+	if err != nil || len(ds) == 0 {
+		return webhook.Delivery{}, false, err
+	}
+	return ds[0], true, nil
+}
+
+func (s *Store) ListDeliveries(ctx context.Context, q webhook.DeliveryQuery) ([]webhook.Delivery, error) {
+	var where []string
+	var args []any
+	if q.SubscriptionID != "" {
+		args = append(args, q.SubscriptionID)
+		where = append(where, fmt.Sprintf("subscription_id = $%d", len(args)))
+	}
+	if q.Status != "" {
+		args = append(args, string(q.Status))
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	query := `SELECT ` + deliveryCols + ` FROM public.webhook_deliveries`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, q.Limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+func collectDeliveries(rows *sql.Rows) ([]webhook.Delivery, error) {
+	defer rows.Close()
+	var out []webhook.Delivery
+	for rows.Next() {
+		var d webhook.Delivery
+		var status string
+		var attempts []byte
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &status,
+			&d.Tries, &attempts, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		d.Status = webhook.DeliveryStatus(status)
+		if err := json.Unmarshal(attempts, &d.Attempts); err != nil {
+			return nil, fmt.Errorf("delivery %s attempts: %w", d.ID, err)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func nonNilAttempts(a []webhook.Attempt) []webhook.Attempt {
+	if a == nil {
+		return []webhook.Attempt{}
+	}
+	return a
+}
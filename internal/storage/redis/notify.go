@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Channel — канал PUBLISH (с префиксом ключей), в который каждая запись
+// ссылки публикует {"domain","code"} — тот же формат, что у триггера
+// Postgres, поэтому его понимает cache.Store.Receive.
+const Channel = "links"
+
+func (s *Store) channel() string { return s.prefix + Channel }
+
+func changed(domain, code string) string {
+	b, _ := json.Marshal(struct {
+		Domain string `json:"domain"`
+		Code   string `json:"code"`
+	}{domain, code})
+	return string(b)
+}
+
+// ListenResync подписывается на Channel и передаёт payload в fn. resync
+// вызывается после каждой (пере)подписки: сообщения за время обрыва
+// потеряны, и подписчик должен восстановиться сам, например сбросить кеш.
+func (s *Store) ListenResync(ctx context.Context, log *slog.Logger, fn func(payload string), resync func()) {
+	ps := s.c.Subscribe(ctx, s.channel())
+	// Receive не прерывается отменой ctx, только закрытием подписки.
+	stop := context.AfterFunc(ctx, func() { _ = ps.Close() })
+	defer stop()
+	defer ps.Close()
+	backoff := time.Second
+	for {
+		msg, err := ps.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// go-redis переподключается и подписывается заново сам при
+			// следующем Receive.
+			log.Warn("redis subscription lost", "channel", s.channel(), "err", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		switch m := msg.(type) {
+		case *goredis.Subscription:
+			if m.Kind == "subscribe" {
+				backoff = time.Second
+				if resync != nil {
+					resync()
+				}
+			}
+		case *goredis.Message:
+			fn(m.Payload)
+		}
+	}
+}
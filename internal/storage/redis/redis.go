@@ -0,0 +1,318 @@
+// Package redis — хранилище ссылок на Redis. Реализует только core.Store:
+// клики, ключи, роли и остальное при STORAGE_BACKEND=redis живут в памяти
+// инстанса. Все изменения ссылки — Lua-скрипты, поэтому атомарны. Redis
+// Cluster не поддерживается: ключи одной ссылки лежат в разных слотах.
+//
+// Ключи (prefix по умолчанию "shortener:"):
+//
+//	<prefix>link:<domain>:<code>     hash: original, owner, tenant, disabled, click_id_param, tags
+//	<prefix>orig:<domain>:<sha256>   string: код по SHA-256 тенанта, владельца и оригинала
+//	<prefix>codes:<domain>           zset всех кодов домена для List (score 0, порядок по коду)
+//
+// Каждое изменение ссылки тем же скриптом публикуется в канал
+// <prefix>links (см. ListenResync).
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+const DefaultPrefix = "shortener:"
+
+type Options struct {
+	// Prefix — префикс всех ключей; пусто — DefaultPrefix.
+	Prefix string
+	// TTL — время жизни новой ссылки; 0 — бессрочно. Отсчитывается от
+	// создания, изменения его не продлевают.
+	TTL time.Duration
+}
+
+type Store struct {
+	c      *goredis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// New подключается к Redis по URL вида redis://[:password@]host:port/db
+// (rediss:// — с TLS).
+func New(url string, o Options) (*Store, error) {
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	c := goredis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Ping(ctx).Err(); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	if o.Prefix == "" {
+		o.Prefix = DefaultPrefix
+	}
+	return &Store{c: c, prefix: o.Prefix, ttl: o.TTL}, nil
+}
+
+func (s *Store) Close() error { return s.c.Close() }
+
+func (s *Store) linkKey(domain, code string) string {
+	return s.prefix + "link:" + domain + ":" + code
+}
+
+// origKey хеширует оригинал вместе с тенантом и владельцем: URL бывают
+// длинными, а ключ нужен только для поиска.
+func (s *Store) origKey(k core.OriginKey) string {
+	h := sha256.Sum256([]byte(k.Tenant + "\x00" + k.Owner + "\x00" + k.Original))
+	return s.prefix + "orig:" + k.Domain + ":" + hex.EncodeToString(h[:])
+}
+
+func (s *Store) codesKey(domain string) string {
+	return s.prefix + "codes:" + domain
+}
+
+func (s *Store) GetByOriginal(ctx context.Context, k core.OriginKey) (string, bool, error) {
+	code, err := s.c.Get(ctx, s.origKey(k)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return code, true, nil
+}
+
+func (s *Store) GetByCode(ctx context.Context, domain, code string) (core.Link, bool, error) {
+	m, err := s.c.HGetAll(ctx, s.linkKey(domain, code)).Result()
+	if err != nil {
+		return core.Link{}, false, err
+	}
+	if len(m) == 0 {
+		return core.Link{}, false, nil
+	}
+	return toLink(domain, code, m), true, nil
+}
+
+// GetByCodeTTL — GetByCode с оставшимся временем жизни ссылки; 0 —
+// бессрочная. Нужен кешу: истечение по TTL ничего не публикует.
+func (s *Store) GetByCodeTTL(ctx context.Context, domain, code string) (core.Link, bool, time.Duration, error) {
+	var get *goredis.MapStringStringCmd
+	var ttl *goredis.DurationCmd
+	if _, err := s.c.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		get = p.HGetAll(ctx, s.linkKey(domain, code))
+		ttl = p.PTTL(ctx, s.linkKey(domain, code))
+		return nil
+	}); err != nil {
+		return core.Link{}, false, 0, err
+	}
+	m := get.Val()
+	if len(m) == 0 {
+		return core.Link{}, false, 0, nil
+	}
+	return toLink(domain, code, m), true, max(ttl.Val(), 0), nil
+}
+
+// createScript — вставка, если свободны и оригинал, и код. Оригинал
+// проверяется первым, как в памяти.
+var createScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then return 'dup_origin' end
+if redis.call('EXISTS', KEYS[1]) == 1 then return 'dup_code' end
+redis.call('HSET', KEYS[1], 'original', ARGV[2], 'owner', ARGV[3], 'tenant', ARGV[4], 'disabled', '0', 'click_id_param', ARGV[5], 'tags', ARGV[7])
+redis.call('SET', KEYS[2], ARGV[1])
+local ttl = tonumber(ARGV[6])
+if ttl > 0 then
+  redis.call('PEXPIRE', KEYS[1], ttl)
+  redis.call('PEXPIRE', KEYS[2], ttl)
+end
+redis.call('ZADD', KEYS[3], 0, ARGV[1])
+redis.call('PUBLISH', ARGV[8], ARGV[9])
+return 'ok'
+`)
+
+func (s *Store) Create(ctx context.Context, link core.Link) error {
+	res, err := createScript.Run(ctx, s.c,
+		[]string{s.linkKey(link.Domain, link.Code), s.origKey(link.OriginKey()), s.codesKey(link.Domain)},
+		link.Code, link.Original, link.Owner, link.Tenant, link.ClickIDParam, s.ttl.Milliseconds(), strings.Join(link.Tags, " "),
+		s.channel(), changed(link.Domain, link.Code),
+	).Text()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case "dup_origin":
+		return core.ErrDupOrigin
+	case "dup_code":
+		return core.ErrDupCode
+	}
+	return nil
+}
+
+var updateScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+redis.call('HSET', KEYS[1], 'click_id_param', ARGV[1], 'tags', ARGV[2])
+redis.call('PUBLISH', ARGV[3], ARGV[4])
+return 1
+`)
+
+func (s *Store) Update(ctx context.Context, link core.Link) error {
+	n, err := updateScript.Run(ctx, s.c, []string{s.linkKey(link.Domain, link.Code)},
+		link.ClickIDParam, strings.Join(link.Tags, " "), s.channel(), changed(link.Domain, link.Code),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+var setDisabledScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return {} end
+redis.call('HSET', KEYS[1], 'disabled', ARGV[1])
+redis.call('PUBLISH', ARGV[2], ARGV[3])
+return redis.call('HGETALL', KEYS[1])
+`)
+
+func (s *Store) SetDisabled(ctx context.Context, domain, code string, disabled bool) (core.Link, error) {
+	flag := "0"
+	if disabled {
+		flag = "1"
+	}
+	pairs, err := setDisabledScript.Run(ctx, s.c, []string{s.linkKey(domain, code)}, flag, s.channel(), changed(domain, code)).StringSlice()
+	if err != nil {
+		return core.Link{}, err
+	}
+	if len(pairs) == 0 {
+		return core.Link{}, core.ErrNotFound
+	}
+	m := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m[pairs[i]] = pairs[i+1]
+	}
+	return toLink(domain, code, m), nil
+}
+
+// deleteScript удаляет ссылку, если её оригинал всё ещё ARGV[1], и
+// освобождает оригинал, если он указывает на этот код.
+var deleteScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[1], 'original') ~= ARGV[1] then return 0 end
+redis.call('DEL', KEYS[1])
+if redis.call('GET', KEYS[2]) == ARGV[2] then redis.call('DEL', KEYS[2]) end
+redis.call('ZREM', KEYS[3], ARGV[2])
+redis.call('PUBLISH', ARGV[3], ARGV[4])
+return 1
+`)
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	vals, err := s.c.HMGet(ctx, s.linkKey(domain, code), "original", "owner", "tenant").Result()
+	if err != nil {
+		return err
+	}
+	original, ok := vals[0].(string)
+	if !ok {
+		return core.ErrNotFound
+	}
+	owner, _ := vals[1].(string)
+	tenant, _ := vals[2].(string)
+	k := core.OriginKey{Tenant: tenant, Owner: owner, Domain: domain, Original: original}
+	n, err := deleteScript.Run(ctx, s.c,
+		[]string{s.linkKey(domain, code), s.origKey(k), s.codesKey(domain)},
+		original, code, s.channel(), changed(domain, code),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// listBatch — сколько кодов читать из zset за раз при фильтрации по
+// тенанту.
+const listBatch = 256
+
+// List идёт по кодам домена по возрастанию и фильтрует ссылки на стороне
+// приложения. Коды истёкших по TTL ссылок попутно убираются из zset.
+func (s *Store) List(ctx context.Context, f core.LinkFilter) ([]core.Link, error) {
+	var out []core.Link
+	after := f.After
+	for len(out) < f.Limit {
+		from := "-"
+		if after != "" {
+			from = "(" + after
+		}
+		codes, err := s.c.ZRangeByLex(ctx, s.codesKey(f.Domain), &goredis.ZRangeBy{Min: from, Max: "+", Count: listBatch}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(codes) == 0 {
+			break
+		}
+		cmds := make([]*goredis.MapStringStringCmd, len(codes))
+		if _, err := s.c.Pipelined(ctx, func(p goredis.Pipeliner) error {
+			for i, code := range codes {
+				cmds[i] = p.HGetAll(ctx, s.linkKey(f.Domain, code))
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		expired := []any{s.linkKey(f.Domain, "")}
+		for i, code := range codes {
+			m := cmds[i].Val()
+			if len(m) == 0 {
+				expired = append(expired, code)
+				continue
+			}
+			link := toLink(f.Domain, code, m)
+			if !f.Match(link) {
+				continue
+			}
+			if out = append(out, link); len(out) == f.Limit {
+				break
+			}
+		}
+		if len(expired) > 1 {
+			_ = pruneScript.Run(ctx, s.c, []string{s.codesKey(f.Domain)}, expired...).Err()
+		}
+		after = codes[len(codes)-1]
+		if len(codes) < listBatch {
+			break
+		}
+	}
+	return out, nil
+}
+
+// pruneScript убирает из zset коды, чьих ссылок больше нет; ARGV[1] —
+// префикс ключа ссылки. Код, созданный заново между чтением и очисткой,
+// остаётся.
+var pruneScript = goredis.NewScript(`
+for i = 2, #ARGV do
+  if redis.call('EXISTS', ARGV[1] .. ARGV[i]) == 0 then
+    redis.call('ZREM', KEYS[1], ARGV[i])
+  end
+end
+return 0
+`)
+
+func toLink(domain, code string, m map[string]string) core.Link {
+	return core.Link{
+		Domain:   domain,
+		Code:     code,
+		Original: m["original"],
+		Owner:    m["owner"],
+		Tenant:   m["tenant"],
+		Disabled: m["disabled"] == "1",
+		Settings: core.Settings{ClickIDParam: m["click_id_param"], Tags: strings.Fields(m["tags"])},
+	}
+}
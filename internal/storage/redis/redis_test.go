@@ -0,0 +1,259 @@
+package redis_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/cache"
+	redisstore "github.com/Shyyw1e/ozon-bank-url-test/internal/storage/redis"
+)
+
+func newStore(t *testing.T, o redisstore.Options) (*redisstore.Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s, err := redisstore.New("redis://"+mr.Addr(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, mr
+}
+
+func TestStore_CreateDuplicatesAndUpdates(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStore(t, redisstore.Options{})
+
+	link := core.Link{Code: "AAAAAAAAAA", Original: "https://example.com", Owner: "u1", Tenant: "acme"}
+	if err := s.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(ctx, core.Link{Code: "BBBBBBBBBB", Original: link.Original, Owner: "u1", Tenant: "acme"}); err != core.ErrDupOrigin {
+		t.Fatalf("same original: %v, want ErrDupOrigin", err)
+	}
+	// у другого владельца тот же оригинал — своя ссылка
+	if err := s.Create(ctx, core.Link{Code: "DDDDDDDDDD", Original: link.Original, Owner: "u2", Tenant: "acme"}); err != nil {
+		t.Fatalf("other owner: %v", err)
+	}
+	if err := s.Create(ctx, core.Link{Code: link.Code, Original: "https://other.example"}); err != core.ErrDupCode {
+		t.Fatalf("same code: %v, want ErrDupCode", err)
+	}
+	// на другом домене и код, и оригинал свободны
+	if err := s.Create(ctx, core.Link{Domain: "go.acme.com", Code: link.Code, Original: link.Original}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, found, err := s.GetByOriginal(ctx, link.OriginKey())
+	if err != nil || !found || code != link.Code {
+		t.Fatalf("GetByOriginal = %q, %v, %v", code, found, err)
+	}
+	link.ClickIDParam, link.Tags = "clid", []string{"spring", "promo"}
+	if err := s.Update(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.SetDisabled(ctx, "", link.Code, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link.Disabled = true
+	if !reflect.DeepEqual(got, link) {
+		t.Fatalf("SetDisabled = %+v, want %+v", got, link)
+	}
+	if got, found, _ := s.GetByCode(ctx, "", link.Code); !found || !reflect.DeepEqual(got, link) {
+		t.Fatalf("GetByCode = %+v, %v", got, found)
+	}
+
+	if err := s.Delete(ctx, "", link.Code); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "", link.Code); err != core.ErrNotFound {
+		t.Fatalf("second Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Update(ctx, link); err != core.ErrNotFound {
+		t.Fatalf("Update after delete: %v, want ErrNotFound", err)
+	}
+	if _, err := s.SetDisabled(ctx, "", link.Code, false); err != core.ErrNotFound {
+		t.Fatalf("SetDisabled after delete: %v, want ErrNotFound", err)
+	}
+	// оригинал освобождён
+	if err := s.Create(ctx, core.Link{Code: "CCCCCCCCCC", Original: link.Original, Owner: "u1", Tenant: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_ConcurrentCreateOfOneOriginal(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStore(t, redisstore.Options{})
+
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Create(ctx, core.Link{Code: fmt.Sprintf("CODE%06d", i), Original: "https://example.com"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	ok := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			ok++
+		case core.ErrDupOrigin:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d creates succeeded, want 1", ok)
+	}
+}
+
+func TestStore_ListAndTTL(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newStore(t, redisstore.Options{Prefix: "t:", TTL: time.Hour})
+
+	for i, tenant := range []string{"acme", "other", "acme", "acme"} {
+		link := core.Link{Code: fmt.Sprintf("CODE%06d", i), Original: fmt.Sprintf("https://example.com/%d", i), Tenant: tenant}
+		if err := s.Create(ctx, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := s.List(ctx, core.LinkFilter{Tenant: "acme", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].Code != "CODE000000" || page[1].Code != "CODE000002" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page, _ = s.List(ctx, core.LinkFilter{Tenant: "acme", After: page[1].Code, Limit: 2})
+	if len(page) != 1 || page[0].Code != "CODE000003" {
+		t.Fatalf("second page = %+v", page)
+	}
+	if ttl := mr.TTL("t:link::CODE000000"); ttl != time.Hour {
+		t.Fatalf("TTL = %v, want 1h", ttl)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if _, found, _ := s.GetByCode(ctx, "", "CODE000000"); found {
+		t.Fatal("link outlived its TTL")
+	}
+	if _, found, _ := s.GetByOriginal(ctx, core.OriginKey{Original: "https://example.com/0"}); found {
+		t.Fatal("original outlived its TTL")
+	}
+	if page, _ := s.List(ctx, core.LinkFilter{AnyTenant: true, Limit: 10}); len(page) != 0 {
+		t.Fatalf("List after expiry = %+v", page)
+	}
+	if n, _ := mr.ZMembers("t:codes:"); len(n) != 0 {
+		t.Fatalf("expired codes left in the index: %v", n)
+	}
+}
+
+// Изменение через один инстанс сбрасывает кеш другого: оба смотрят в один
+// Redis, и скрипты записи публикуют изменённые ключи.
+func TestStore_CacheInvalidationAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	open := func() (*redisstore.Store, *cache.Store) {
+		s, err := redisstore.New("redis://"+mr.Addr(), redisstore.Options{TTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		return s, cache.New(s, cache.Options{Size: 10, TTL: time.Hour})
+	}
+	_, a := open()
+	sb, b := open()
+
+	lctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	subscribed := make(chan struct{}, 1)
+	go func() {
+		defer close(done)
+		sb.ListenResync(lctx, slog.New(slog.NewTextHandler(io.Discard, nil)), b.Receive, func() {
+			b.Purge()
+			select {
+			case subscribed <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	t.Cleanup(func() { cancel(); <-done })
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no subscription")
+	}
+
+	link := core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}
+	// отсутствие кода на b сбрасывается созданием на a
+	if _, found, _ := b.GetByCode(ctx, "", link.Code); found {
+		t.Fatal("found before create")
+	}
+	if err := a.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "create on b", func() bool {
+		_, found, _ := b.GetByCode(ctx, "", link.Code)
+		return found
+	})
+	if _, err := a.SetDisabled(ctx, "", link.Code, true); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "disable on b", func() bool {
+		got, _, _ := b.GetByCode(ctx, "", link.Code)
+		return got.Disabled
+	})
+	if err := a.Delete(ctx, "", link.Code); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "delete on b", func() bool {
+		_, found, _ := b.GetByCode(ctx, "", link.Code)
+		return !found
+	})
+}
+
+func TestStore_GetByCodeTTL(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newStore(t, redisstore.Options{TTL: time.Hour})
+	if err := s.Create(ctx, core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(20 * time.Minute)
+	if _, found, ttl, err := s.GetByCodeTTL(ctx, "", "AAAAAAAAAA"); err != nil || !found || ttl != 40*time.Minute {
+		t.Fatalf("GetByCodeTTL = %v, %v, %v", found, ttl, err)
+	}
+	if _, found, _, _ := s.GetByCodeTTL(ctx, "", "BBBBBBBBBB"); found {
+		t.Fatal("missing code found")
+	}
+
+	forever, _ := newStore(t, redisstore.Options{})
+	if err := forever.Create(ctx, core.Link{Code: "AAAAAAAAAA", Original: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, ttl, _ := forever.GetByCodeTTL(ctx, "", "AAAAAAAAAA"); !found || ttl != 0 {
+		t.Fatalf("link without TTL: %v, %v", found, ttl)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
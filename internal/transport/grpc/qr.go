@@ -0,0 +1,69 @@
+package grpctransport
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/qr"
+)
+
+// GetQRCode рисует QR-код короткой ссылки. У gRPC нет хоста запроса,
+// поэтому адрес берётся только из PUBLIC_BASE_URL.
+func (s *server) GetQRCode(ctx context.Context, req *shortenerv1.GetQRCodeRequest) (*shortenerv1.GetQRCodeResponse, error) {
+	if req == nil || !core.IsValidCode(req.Code) {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+	o := qr.Options{
+		Format:     qr.Format(strings.ToLower(req.Format)),
+		Size:       int(req.Size),
+		Level:      req.Ecc,
+		Foreground: req.Foreground,
+		Background: req.Background,
+	}
+	if req.Margin != nil {
+		m := int(*req.Margin)
+		o.Margin = &m
+	}
+	o, err := o.Normalize()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	link, err := s.svc.ResolveLink(ctx, req.Domain, req.Code)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return nil, status.Error(codes.NotFound, "not found")
+		}
+		s.log.Error("GetQRCode failed", "code", req.Code, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if link.Disabled {
+		return nil, status.Error(codes.FailedPrecondition, "link disabled")
+	}
+	short, ok := s.svc.ShortURL(link)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "PUBLIC_BASE_URL is not configured")
+	}
+
+	img, err := qr.Render(short, o)
+	switch {
+	case err == nil:
+	case errors.Is(err, qr.ErrTooSmall):
+		return nil, status.Error(codes.InvalidArgument, "size too small for this link")
+	default:
+		s.log.Error("qr render failed", "code", req.Code, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &shortenerv1.GetQRCodeResponse{
+		Image:       img,
+		ContentType: o.Format.ContentType(),
+		ShortUrl:    short,
+		Etag:        qr.ETag(short, o),
+	}, nil
+}
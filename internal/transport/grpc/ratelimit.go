@@ -0,0 +1,59 @@
+package grpctransport
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// WithRateLimit ограничивает Shorten и Resolve политиками Create и Resolve.
+func WithRateLimit(l ratelimit.Limiter, p ratelimit.Policies) Option {
+	return func(s *server) { s.limiter, s.policies = l, p }
+}
+
+// rateLimitInterceptor стоит после authInterceptor: ключ клиента зависит
+// от Principal. Отказ — RESOURCE_EXHAUSTED с заголовком retry-after.
+func rateLimitInterceptor(log *slog.Logger, l ratelimit.Limiter, ps ratelimit.Policies) grpc.UnaryServerInterceptor {
+	policies := map[string]ratelimit.Policy{
+		shortenerv1.Shortener_Shorten_FullMethodName:   ps.Create,
+		shortenerv1.Shortener_Resolve_FullMethodName:   ps.Resolve,
+		shortenerv1.Shortener_GetQRCode_FullMethodName: ps.Resolve,
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		p, ok := policies[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		d, ok := ratelimit.Check(ctx, log, l, p, ratelimit.ClientKey(ctx, peerIP(ctx)))
+		if !ok {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(
+				"retry-after", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))),
+				"ratelimit-limit", strconv.Itoa(d.Limit),
+				"ratelimit-remaining", "0",
+			))
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", d.RetryAfter.Round(1e6))
+		}
+		return handler(ctx, req)
+	}
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -2,33 +2,81 @@ package grpctransport
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"log/slog"
 	"net"
 
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	health "google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
 type server struct {
 	shortenerv1.UnimplementedShortenerServer
-	log *slog.Logger
-	svc *core.Shortener
+	log   *slog.Logger
+	svc   *core.Shortener
+	stats analytics.StatsReader
+	hub   *stream.Hub
+	keys  *auth.Keys
+	jwt   *auth.JWTVerifier
+
+	limiter  ratelimit.Limiter
+	policies ratelimit.Policies
+}
+
+type Option func(*server)
+
+// WithStats включает GetStats; без него метод отвечает Unimplemented.
+func WithStats(stats analytics.StatsReader) Option {
+	return func(s *server) { s.stats = stats }
+}
+
+// WithClickStream включает WatchClicks.
+func WithClickStream(hub *stream.Hub) Option {
+	return func(s *server) { s.hub = hub }
+}
+
+// WithAuth требует API-ключ с нужным scope на методах Shortener.
+func WithAuth(keys *auth.Keys) Option {
+	return func(s *server) { s.keys = keys }
 }
 
-func NewGRPCServer(log *slog.Logger, svc *core.Shortener) *grpc.Server {
+// WithJWT принимает JWT в метаданных authorization наравне с API-ключами.
+func WithJWT(v *auth.JWTVerifier) Option {
+	return func(s *server) { s.jwt = v }
+}
+
+func NewGRPCServer(log *slog.Logger, svc *core.Shortener, opts ...Option) *grpc.Server {
+	s := &server{log: log, svc: svc}
+	for _, opt := range opts {
+		opt(s)
+	}
+	authn := auth.Combine(s.keys, s.jwt)
 	grpcSrv := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(
 			recoveryInterceptor(log),
+			requestInfoInterceptor(),
 			loggingInterceptor(log),
+			authInterceptor(log, authn),
+			rateLimitInterceptor(log, s.limiter, s.policies),
+		),
+		grpc.ChainStreamInterceptor(
+			streamRecoveryInterceptor(log),
+			streamAuthInterceptor(log, authn),
 		),
 	)
-	s := &server{log: log, svc: svc}
 	shortenerv1.RegisterShortenerServer(grpcSrv, s)
 
 	// Health + Reflection
@@ -43,26 +91,45 @@ func (s *server) Shorten(ctx context.Context, req *shortenerv1.ShortenRequest) (
 	if req == nil || req.Url == "" {
 		return nil, status.Error(codes.InvalidArgument, "url is required")
 	}
-	code, err := s.svc.Create(ctx, req.Url)
+	link, err := s.svc.CreateLink(ctx, core.CreateRequest{
+		URL:      req.Url,
+		Domain:   req.Domain,
+		Settings: core.Settings{ClickIDParam: req.ClickIdParam},
+	})
 	if err != nil {
+		var qe *core.QuotaError
+		if errors.As(err, &qe) {
+			return nil, status.Error(codes.ResourceExhausted, qe.Error())
+		}
 		switch err {
 		case core.ErrInvalidURL:
 			return nil, status.Error(codes.InvalidArgument, "invalid url")
+		case core.ErrInvalidSettings:
+			return nil, status.Error(codes.InvalidArgument, "invalid click_id_param")
 		case core.ErrConflict:
 			return nil, status.Error(codes.Aborted, "too many collisions")
+		case core.ErrForbidden:
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		case core.ErrBannedDomain:
+			return nil, status.Error(codes.InvalidArgument, "destination domain is banned")
+		case core.ErrUnknownDomain:
+			return nil, status.Error(codes.InvalidArgument, "unknown domain")
+		case core.ErrDomainNotAllowed:
+			return nil, status.Error(codes.PermissionDenied, "domain not allowed")
 		default:
 			s.log.Error("Shorten failed", "err", err)
 			return nil, status.Error(codes.Internal, "internal error")
 		}
 	}
-	return &shortenerv1.ShortenResponse{Code: code}, nil
+	short, _ := s.svc.ShortURL(link)
+	return &shortenerv1.ShortenResponse{Code: link.Code, Domain: s.svc.Domains().Host(link.Domain), ShortUrl: short}, nil
 }
 
 func (s *server) Resolve(ctx context.Context, req *shortenerv1.ResolveRequest) (*shortenerv1.ResolveResponse, error) {
 	if req == nil || !core.IsValidCode(req.Code) {
 		return nil, status.Error(codes.InvalidArgument, "invalid code")
 	}
-	orig, err := s.svc.Resolve(ctx, req.Code)
+	link, err := s.svc.ResolveLink(ctx, req.Domain, req.Code)
 	if err != nil {
 		if err == core.ErrNotFound {
 			return nil, status.Error(codes.NotFound, "not found")
@@ -70,7 +137,10 @@ func (s *server) Resolve(ctx context.Context, req *shortenerv1.ResolveRequest) (
 		s.log.Error("Resolve failed", "code", req.Code, "err", err)
 		return nil, status.Error(codes.Internal, "internal error")
 	}
-	return &shortenerv1.ResolveResponse{Url: orig}, nil
+	if link.Disabled {
+		return nil, status.Error(codes.FailedPrecondition, "link disabled")
+	}
+	return &shortenerv1.ResolveResponse{Url: link.Original}, nil
 }
 
 // ---- interceptors ----
@@ -92,6 +162,25 @@ func loggingInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
 	}
 }
 
+// requestInfoInterceptor кладёт в контекст адрес клиента и x-request-id
+// из метаданных (или новый) для журнала аудита.
+func requestInfoInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ri := core.RequestInfo{IP: peerIP(ctx)}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("x-request-id"); len(v) > 0 {
+				ri.ID = v[0]
+			}
+		}
+		if ri.ID == "" {
+			var b [8]byte
+			_, _ = rand.Read(b[:])
+			ri.ID = hex.EncodeToString(b[:])
+		}
+		return handler(core.WithRequestInfo(ctx, ri), req)
+	}
+}
+
 func recoveryInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 		defer func() {
@@ -104,6 +193,18 @@ func recoveryInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
 	}
 }
 
+func streamRecoveryInterceptor(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("panic recovered", "method", info.FullMethod, "panic", r)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
 // Утилита для запуска (можно использовать из main)
 func ListenAndServe(grpcSrv *grpc.Server, addr string) (net.Listener, error) {
 	lis, err := net.Listen("tcp", addr)
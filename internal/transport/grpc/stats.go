@@ -0,0 +1,89 @@
+package grpctransport
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+func (s *server) GetStats(ctx context.Context, req *shortenerv1.GetStatsRequest) (*shortenerv1.GetStatsResponse, error) {
+	if s.stats == nil {
+		return nil, status.Error(codes.Unimplemented, "stats are disabled")
+	}
+	if req == nil || !core.IsValidCode(req.Code) {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+
+	q := analytics.StatsQuery{Code: req.Code, Top: int(req.Top), IncludeBots: req.IncludeBots}
+	if req.From != nil {
+		q.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		q.To = req.To.AsTime()
+	}
+	if err := q.Normalize(time.Now()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid range")
+	}
+
+	link, err := s.svc.StatsLink(ctx, req.Domain, req.Code)
+	if err != nil {
+		switch err {
+		case core.ErrNotFound:
+			return nil, status.Error(codes.NotFound, "not found")
+		case core.ErrForbidden:
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		s.log.Error("Resolve failed", "code", req.Code, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	q.Domain = link.Domain
+
+	st, err := s.stats.Stats(ctx, q)
+	if err != nil {
+		s.log.Error("GetStats failed", "code", req.Code, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &shortenerv1.GetStatsResponse{
+		Code:         req.Code,
+		Total:        st.Total,
+		Bots:         st.Bots,
+		Uniques:      st.Uniques,
+		Hourly:       toPBPoints(st.Hourly),
+		Daily:        toPBPoints(st.Daily),
+		DailyUniques: toPBPoints(st.DailyUniques),
+
+		Conversions:      st.Conversions,
+		ConversionValue:  st.ConversionValue,
+		DailyConversions: toPBPoints(st.DailyConversions),
+
+		TopReferrers: toPBCounts(st.TopReferrers),
+		TopCountries: toPBCounts(st.TopCountries),
+		TopCities:    toPBCounts(st.TopCities),
+		TopDevices:   toPBCounts(st.TopDevices),
+		TopOs:        toPBCounts(st.TopOS),
+		TopBrowsers:  toPBCounts(st.TopBrowsers),
+	}, nil
+}
+
+func toPBPoints(ps []analytics.Point) []*shortenerv1.StatsPoint {
+	out := make([]*shortenerv1.StatsPoint, len(ps))
+	for i, p := range ps {
+		out[i] = &shortenerv1.StatsPoint{At: timestamppb.New(p.At), Clicks: p.Clicks}
+	}
+	return out
+}
+
+func toPBCounts(cs []analytics.Count) []*shortenerv1.DimensionCount {
+	out := make([]*shortenerv1.DimensionCount, len(cs))
+	for i, c := range cs {
+		out[i] = &shortenerv1.DimensionCount{Value: c.Value, Clicks: c.Clicks}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package grpctransport
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/api/shortener/v1"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+func (s *server) WatchClicks(req *shortenerv1.WatchClicksRequest, ss grpc.ServerStreamingServer[shortenerv1.ClickEvent]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unimplemented, "click stream is disabled")
+	}
+	if req == nil || !core.IsValidCode(req.Code) {
+		return status.Error(codes.InvalidArgument, "invalid code")
+	}
+	ctx := ss.Context()
+	link, err := s.svc.StatsLink(ctx, req.Domain, req.Code)
+	if err != nil {
+		switch err {
+		case core.ErrNotFound:
+			return status.Error(codes.NotFound, "not found")
+		case core.ErrForbidden:
+			return status.Error(codes.PermissionDenied, "forbidden")
+		}
+		s.log.Error("Resolve failed", "code", req.Code, "err", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	sub := s.hub.Subscribe(link.Domain, link.Code)
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Evicted() {
+					return status.Error(codes.ResourceExhausted, "slow consumer")
+				}
+				return nil
+			}
+			if err := ss.Send(&shortenerv1.ClickEvent{
+				Code:     e.Code,
+				At:       timestamppb.New(e.At),
+				Referrer: e.Referrer,
+				ClickId:  e.ClickID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+)
+
+type auditEntryResponse struct {
+	Seq         int64           `json:"seq"`
+	At          time.Time       `json:"at"`
+	Actor       string          `json:"actor,omitempty"`
+	ActorUser   string          `json:"actor_user,omitempty"`
+	ActorTenant string          `json:"actor_tenant,omitempty"`
+	Action      string          `json:"action"`
+	Outcome     string          `json:"outcome"`
+	Tenant      string          `json:"tenant,omitempty"`
+	Target      string          `json:"target,omitempty"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	RequestID   string          `json:"request_id,omitempty"`
+	SourceIP    string          `json:"source_ip,omitempty"`
+	PrevHash    string          `json:"prev_hash"`
+	Hash        string          `json:"hash"`
+}
+
+// GET /api/v1/audit?actor=&tenant=&target=&action=&outcome=&from=&to=&after_seq=&limit=
+// Записи по возрастанию seq; next_after_seq — курсор следующей страницы.
+func auditHandler(log *slog.Logger, l *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		q := audit.Query{
+			Actor:   qs.Get("actor"),
+			Tenant:  qs.Get("tenant"),
+			Target:  qs.Get("target"),
+			Action:  qs.Get("action"),
+			Outcome: qs.Get("outcome"),
+		}
+		var err error
+		if q.From, err = parseTimeParam(r, "from"); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		if q.To, err = parseTimeParam(r, "to"); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		if v := qs.Get("after_seq"); v != "" {
+			if q.AfterSeq, err = strconv.ParseInt(v, 10, 64); err != nil || q.AfterSeq < 0 {
+				http.Error(w, "invalid after_seq", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := qs.Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		entries, err := l.Query(r.Context(), q)
+		if err != nil {
+			log.Error("audit query failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Entries      []auditEntryResponse `json:"entries"`
+			NextAfterSeq int64                `json:"next_after_seq,omitempty"`
+		}{Entries: make([]auditEntryResponse, len(entries))}
+		for i, e := range entries {
+			resp.Entries[i] = auditEntryResponse(e)
+		}
+		if n := len(entries); n > 0 {
+			resp.NextAfterSeq = entries[n-1].Seq
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// GET /api/v1/audit/verify — проверка всей цепочки. 200 и ok=false, если
+// она разорвана: broken_at и reason указывают первое нарушение.
+func verifyAuditHandler(log *slog.Logger, l *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, err := l.Verify(r.Context())
+		if err != nil {
+			log.Error("audit verify failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			OK       bool   `json:"ok"`
+			Entries  int64  `json:"entries"`
+			Head     string `json:"head,omitempty"`
+			BrokenAt int64  `json:"broken_at,omitempty"`
+			Reason   string `json:"reason,omitempty"`
+		}{OK: v.Broken == nil, Entries: v.Entries, Head: v.Head}
+		if v.Broken != nil {
+			resp.BrokenAt, resp.Reason = v.Broken.Seq, v.Broken.Reason
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,158 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// requireScope пропускает запрос с API-ключом или JWT, у которого есть
+// scope, и кладёт core.Principal в контекст. Без WithAuth и WithJWT —
+// пропускает всё.
+func requireScope(log *slog.Logger, authn auth.Authenticator, scope core.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authn == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authn.Authenticate(r.Context(), bearerToken(r))
+			if err != nil {
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					log.Error("authenticate failed", "err", err)
+					http.Error(w, "internal error", http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !p.Can(scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(scope)+`"`)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(core.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// bearerToken берёт ключ из "Authorization: Bearer …" или X-API-Key.
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+type keyResponse struct {
+	Prefix     string       `json:"prefix"`
+	Name       string       `json:"name"`
+	Scopes     []core.Scope `json:"scopes"`
+	Token      string       `json:"token,omitempty"` // только в ответе на выпуск
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty"`
+}
+
+func toKeyResponse(k auth.Key) keyResponse {
+	opt := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	return keyResponse{
+		Prefix:     k.Prefix,
+		Name:       k.Name,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  opt(k.ExpiresAt),
+		LastUsedAt: opt(k.LastUsedAt),
+		RevokedAt:  opt(k.RevokedAt),
+	}
+}
+
+// POST /api/v1/keys {"name": "crm", "scopes": ["links:write"], "expires_in": "720h"}
+// expires_at (RFC3339) или expires_in; без них ключ бессрочный.
+func issueKeyHandler(log *slog.Logger, keys *auth.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name      string       `json:"name"`
+			Scopes    []core.Scope `json:"scopes"`
+			ExpiresAt time.Time    `json:"expires_at"`
+			ExpiresIn string       `json:"expires_in"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresIn != "" {
+			d, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid expires_in", http.StatusBadRequest)
+				return
+			}
+			req.ExpiresAt = time.Now().Add(d)
+		}
+
+		token, k, err := keys.Issue(r.Context(), req.Name, req.Scopes, req.ExpiresAt)
+		switch {
+		case err == nil:
+		case errors.Is(err, auth.ErrInvalidKey):
+			http.Error(w, "invalid scopes or expiry", http.StatusBadRequest)
+			return
+		default:
+			log.Error("issue key failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := toKeyResponse(k)
+		resp.Token = token
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func listKeysHandler(log *slog.Logger, keys *auth.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ks, err := keys.List(r.Context())
+		if err != nil {
+			log.Error("list keys failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		out := make([]keyResponse, len(ks))
+		for i, k := range ks {
+			out[i] = toKeyResponse(k)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Keys []keyResponse `json:"keys"`
+		}{out})
+	}
+}
+
+// DELETE /api/v1/keys/{prefix} — отзыв; запись остаётся в списке с revoked_at.
+func revokeKeyHandler(log *slog.Logger, keys *auth.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch err := keys.Revoke(r.Context(), chi.URLParam(r, "prefix")); {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, auth.ErrKeyNotFound):
+			http.NotFound(w, r)
+		default:
+			log.Error("revoke key failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
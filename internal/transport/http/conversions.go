@@ -0,0 +1,65 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+)
+
+// POST /api/v1/conversions {"click_id": "...", "event": "purchase", "value": 9.99}
+// 201 — конверсия записана, 200 — такой постбек уже был. Подпись click id
+// проверяется без похода в хранилище.
+func conversionHandler(log *slog.Logger, ids *analytics.ClickIDs, sink analytics.ConversionSink) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ClickID string  `json:"click_id"`
+			Event   string  `json:"event"`
+			Value   float64 `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		domain, code, ok := ids.Parse(req.ClickID)
+		if !ok {
+			http.Error(w, analytics.ErrBadClickID.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Event == "" {
+			req.Event = analytics.DefaultEvent
+		}
+		if !analytics.ValidEvent(req.Event) {
+			http.Error(w, analytics.ErrBadEvent.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c := analytics.Conversion{
+			ClickID: req.ClickID,
+			Domain:  domain,
+			Code:    code,
+			Event:   req.Event,
+			Value:   req.Value,
+			At:      time.Now().UTC(),
+		}
+		created, err := sink.WriteConversion(r.Context(), c)
+		if err != nil {
+			log.Error("conversion failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		_ = json.NewEncoder(w).Encode(struct {
+			Domain  string `json:"domain,omitempty"`
+			Code    string `json:"code"`
+			Event   string `json:"event"`
+			Created bool   `json:"created"`
+		}{Domain: domain, Code: code, Event: c.Event, Created: created})
+	}
+}
@@ -0,0 +1,89 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// queryDomain — домен ссылки в API управления: ?domain=<host>, пусто —
+// домен по умолчанию.
+func queryDomain(r *http.Request) string {
+	return r.URL.Query().Get("domain")
+}
+
+// hostDomain — домен ссылки для публичных путей (редирект, жалоба): хост
+// запроса, а если такой домен не обслуживается — домен по умолчанию.
+func hostDomain(d *core.Domains, r *http.Request) string {
+	if key, ok := d.Key(requestHost(r)); ok {
+		return key
+	}
+	return ""
+}
+
+type tenantDomainsResponse struct {
+	Tenant  string   `json:"tenant"`
+	Domains []string `json:"domains"`
+	Custom  bool     `json:"custom"`
+}
+
+// mountDomains — обслуживаемые домены и домены тенантов.
+//
+//	GET    /api/v1/domains
+//	GET    /api/v1/tenants/{tenant}/domains
+//	PUT    /api/v1/tenants/{tenant}/domains {"domains": ["go.example.com"]}
+//	DELETE /api/v1/tenants/{tenant}/domains
+func mountDomains(r chi.Router, log *slog.Logger, svc *core.Shortener, need func(core.Scope) func(http.Handler) http.Handler) {
+	r.With(need(core.ScopeLinksRead)).Get("/api/v1/domains", func(w http.ResponseWriter, r *http.Request) {
+		d := svc.Domains()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Default string   `json:"default"`
+			Domains []string `json:"domains"`
+		}{d.Default(), d.Names()})
+	})
+	r.With(need(core.ScopeLinksRead)).Get("/api/v1/tenants/{tenant}/domains", func(w http.ResponseWriter, r *http.Request) {
+		tenant := chi.URLParam(r, "tenant")
+		list, custom, err := svc.TenantDomains(r.Context(), tenant)
+		writeTenantDomains(w, r, log, tenantDomainsResponse{tenant, list, custom}, err)
+	})
+	r.With(need(core.ScopeAdmin)).Put("/api/v1/tenants/{tenant}/domains", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Domains []string `json:"domains"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		tenant := chi.URLParam(r, "tenant")
+		list, err := svc.SetTenantDomains(r.Context(), tenant, req.Domains)
+		writeTenantDomains(w, r, log, tenantDomainsResponse{tenant, list, len(req.Domains) > 0}, err)
+	})
+	r.With(need(core.ScopeAdmin)).Delete("/api/v1/tenants/{tenant}/domains", func(w http.ResponseWriter, r *http.Request) {
+		_, err := svc.SetTenantDomains(r.Context(), chi.URLParam(r, "tenant"), nil)
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeTenantDomains(w, r, log, tenantDomainsResponse{}, err)
+	})
+}
+
+func writeTenantDomains(w http.ResponseWriter, r *http.Request, log *slog.Logger, resp tenantDomainsResponse, err error) {
+	switch {
+	case err == nil:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	case errors.Is(err, core.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, core.ErrUnknownDomain):
+		http.Error(w, "unknown domain", http.StatusBadRequest)
+	default:
+		log.Error("tenant domains failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
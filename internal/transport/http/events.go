@@ -0,0 +1,77 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// sseHeartbeat — как часто слать комментарий-пинг, чтобы прокси не
+// закрывали молчащее соединение.
+var sseHeartbeat = 15 * time.Second
+
+// GET /api/v1/urls/{code}/events — Server-Sent Events с кликами по ссылке.
+// Каждый клик — событие "click" с JSON stream.Event. Отставшего клиента
+// отключают событием "evicted"; EventSource переподключится сам.
+func eventsHandler(log *slog.Logger, svc *core.Shortener, hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+		link, err := svc.StatsLink(r.Context(), queryDomain(r), code)
+		if err != nil {
+			switch err {
+			case core.ErrNotFound:
+				http.NotFound(w, r)
+				return
+			case core.ErrForbidden:
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			log.Error("resolve failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		sub := hub.Subscribe(link.Domain, link.Code)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-sub.C:
+				if !ok {
+					if sub.Evicted() {
+						fmt.Fprint(w, "event: evicted\ndata: {\"reason\":\"slow consumer\"}\n\n")
+						flusher.Flush()
+					}
+					return
+				}
+				b, _ := json.Marshal(e)
+				fmt.Fprintf(w, "event: click\ndata: %s\n\n", b)
+			}
+			flusher.Flush()
+		}
+	}
+}
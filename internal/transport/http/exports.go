@@ -0,0 +1,248 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/export"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+type exportRequest struct {
+	Domain string    `json:"domain"`
+	Codes  []string  `json:"codes"`
+	Owner  string    `json:"owner"`
+	Tag    string    `json:"tag"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Format string    `json:"format"`
+	Gzip   bool      `json:"gzip"`
+}
+
+type exportJobResponse struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Domain      string     `json:"domain,omitempty"`
+	Codes       []string   `json:"codes"`
+	From        time.Time  `json:"from"`
+	To          time.Time  `json:"to"`
+	Format      string     `json:"format"`
+	Gzip        bool       `json:"gzip"`
+	Rows        int64      `json:"rows"`
+	Bytes       int64      `json:"bytes"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func toExportJobResponse(job export.Job) exportJobResponse {
+	resp := exportJobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Domain:    job.Query.Domain,
+		Codes:     job.Query.Codes,
+		From:      job.Query.From,
+		To:        job.Query.To,
+		Format:    string(job.Options.Format),
+		Gzip:      job.Options.Gzip,
+		Rows:      job.Rows,
+		Bytes:     job.Size,
+		Error:     job.Error,
+		CreatedAt: job.Created,
+	}
+	if !job.Finished.IsZero() {
+		resp.FinishedAt = &job.Finished
+	}
+	if job.Status == export.StatusDone {
+		resp.DownloadURL = "/api/v1/exports/" + job.ID + "/download"
+	}
+	return resp
+}
+
+// GET /api/v1/exports/clicks?domain=&code=...&code=...&owner=&tag=&from=&to=&format=csv|ndjson|parquet&gzip=
+// Синхронная выгрузка потоком, ограничена таймаутом запроса; большие
+// выгрузки — через POST /api/v1/exports.
+func exportClicksHandler(log *slog.Logger, svc *core.Shortener, src analytics.ClickScanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		sel := core.LinkSelector{Domain: queryDomain(r), Codes: qs["code"], Owner: qs.Get("owner"), Tag: qs.Get("tag")}
+		var (
+			q   analytics.ClickQuery
+			err error
+		)
+		if q.From, err = parseTimeParam(r, "from"); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		if q.To, err = parseTimeParam(r, "to"); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		if q.Domain, q.Codes, err = svc.StatsLinks(r.Context(), sel, analytics.MaxExportCodes); err != nil {
+			exportAuthError(w, log, err)
+			return
+		}
+		if err := q.Normalize(time.Now()); err != nil {
+			http.Error(w, "invalid query", http.StatusBadRequest)
+			return
+		}
+		var o export.Options
+		if o.Format, err = export.ParseFormat(r.URL.Query().Get("format")); err != nil {
+			http.Error(w, "invalid format", http.StatusBadRequest)
+			return
+		}
+		if v := r.URL.Query().Get("gzip"); v != "" {
+			if o.Gzip, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid gzip", http.StatusBadRequest)
+				return
+			}
+		}
+
+		name := o.Filename("clicks-" + q.From.Format("20060102") + "-" + q.To.Format("20060102"))
+		w.Header().Set("Content-Type", o.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+		cw := &countingWriter{w: w}
+		rows, err := export.Run(r.Context(), src, q, cw, o)
+		if err == nil {
+			return
+		}
+		log.Error("export failed", "codes", len(q.Codes), "rows", rows, "err", err)
+		if cw.n == 0 {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		// Тело уже частично отправлено: рвём соединение, чтобы клиент не
+		// принял обрезанный файл за целый.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// POST /api/v1/exports {"domain": "", "codes": [...], "owner": "", "tag": "", "from": "...", "to": "...", "format": "parquet", "gzip": true}
+func startExportHandler(log *slog.Logger, svc *core.Shortener, jobs *export.Jobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		format, err := export.ParseFormat(req.Format)
+		if err != nil {
+			http.Error(w, "invalid format", http.StatusBadRequest)
+			return
+		}
+		q := analytics.ClickQuery{From: req.From, To: req.To}
+		sel := core.LinkSelector{Domain: req.Domain, Codes: req.Codes, Owner: req.Owner, Tag: req.Tag}
+		if q.Domain, q.Codes, err = svc.StatsLinks(r.Context(), sel, analytics.MaxExportCodes); err != nil {
+			exportAuthError(w, log, err)
+			return
+		}
+		if err := q.Normalize(time.Now()); err != nil {
+			http.Error(w, "invalid query", http.StatusBadRequest)
+			return
+		}
+
+		p, _ := core.PrincipalFrom(r.Context())
+		job, err := jobs.Start(p.ID, q, export.Options{Format: format, Gzip: req.Gzip})
+		if err != nil {
+			if errors.Is(err, export.ErrQueueFull) {
+				w.Header().Set("Retry-After", "60")
+				http.Error(w, "export queue is full", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/v1/exports/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(toExportJobResponse(job))
+	}
+}
+
+// exportAuthError отвечает на ошибку выборки ссылок выгрузки.
+func exportAuthError(w http.ResponseWriter, log *slog.Logger, err error) {
+	switch err {
+	case core.ErrNotFound:
+		http.Error(w, "link not found", http.StatusNotFound)
+	case core.ErrForbidden:
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case core.ErrUnknownDomain:
+		http.Error(w, "unknown domain", http.StatusBadRequest)
+	case core.ErrTooManyLinks:
+		http.Error(w, "too many links, at most "+strconv.Itoa(analytics.MaxExportCodes), http.StatusBadRequest)
+	default:
+		log.Error("export authorize failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// ownsJob — задачу видят только её создатель и admin. Чужая задача
+// неотличима от несуществующей.
+func ownsJob(r *http.Request, job export.Job) bool {
+	p, _ := core.PrincipalFrom(r.Context())
+	return p.ID == job.Principal || p.Can(core.ScopeAdmin)
+}
+
+// GET /api/v1/exports/{id}
+func exportJobHandler(jobs *export.Jobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobs.Get(chi.URLParam(r, "id"))
+		if !ok || !ownsJob(r, job) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toExportJobResponse(job))
+	}
+}
+
+// GET /api/v1/exports/{id}/download
+func downloadExportHandler(log *slog.Logger, jobs *export.Jobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if job, ok := jobs.Get(id); !ok || !ownsJob(r, job) {
+			http.NotFound(w, r)
+			return
+		}
+		f, job, err := jobs.Open(id)
+		switch {
+		case errors.Is(err, export.ErrJobNotFound):
+			http.NotFound(w, r)
+			return
+		case errors.Is(err, export.ErrJobNotReady):
+			http.Error(w, "export is "+string(job.Status), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("export open failed", "id", job.ID, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", job.Options.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="`+job.Options.Filename("clicks-"+job.ID)+`"`)
+		http.ServeContent(w, r, "", job.Finished, f)
+	}
+}
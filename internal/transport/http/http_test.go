@@ -1,17 +1,31 @@
 package httptransport
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/export"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/topk"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
 )
 
 func testLogger() *slog.Logger {
@@ -30,7 +44,10 @@ func newTestRouter(t *testing.T) http.Handler {
 }
 
 func TestPOST_Create_OK(t *testing.T) {
-	h := newTestRouter(t)
+	// X-Forwarded-Proto учитывается только от доверенного прокси; httptest
+	// шлёт запросы с 192.0.2.1
+	svc := core.NewShortener(memory.New(), core.NewCode)
+	h := NewRouter(testLogger(), svc, WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}))
 
 	body := `{"url":"https://example.com/path?q=1"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(body))
@@ -176,3 +193,907 @@ func TestGET_JSON_Resolve_OK(t *testing.T) {
 		t.Fatalf("url=%q, want %q", resp.URL, orig)
 	}
 }
+
+func TestGET_Stats_OK(t *testing.T) {
+	st := memory.New()
+	svc := core.NewShortener(st, core.NewCode)
+	code, err := svc.Create(context.Background(), "https://example.com/stats")
+	if err != nil {
+		t.Fatalf("prep Create err: %v", err)
+	}
+	// Клики идут через Recorder, чтобы посетителя считал настоящий
+	// VisitorHash: один и тот же IP и User-Agent в разные сутки.
+	rec := analytics.NewRecorder(testLogger(), st, analytics.Options{FlushInterval: time.Hour, VisitorSalt: []byte("salt")})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { rec.Run(ctx); close(done) }()
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	const ip, ua = "203.0.113.7", "Mozilla/5.0 (X11; Linux x86_64) Firefox/126.0"
+	for _, c := range []analytics.Click{
+		{Code: code, At: at, Referrer: "https://t.me/channel", IP: ip, UserAgent: ua},
+		{Code: code, At: at.Add(time.Hour), Referrer: "https://t.me/other", IP: ip, UserAgent: ua},
+		{Code: code, At: at.Add(24 * time.Hour), IP: ip, UserAgent: ua},
+		{Code: code, At: at, Referrer: "https://slack.com", Bot: true, IP: "198.51.100.1", UserAgent: "Slackbot"},
+	} {
+		rec.Record(c)
+	}
+	cancel()
+	<-done
+	h := NewRouter(testLogger(), svc, WithStats(st))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+code+"/stats?from=2024-05-01&to=2024-05-03", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, want 200; body=%q", rr.Code, rr.Body.String())
+	}
+	var resp statsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if resp.Total != 3 || len(resp.Daily) != 2 || len(resp.Hourly) != 3 {
+		t.Fatalf("unexpected stats: %+v", resp)
+	}
+	if resp.Uniques != 1 || len(resp.DailyUniques) != 2 {
+		t.Fatalf("one visitor over two days: uniques=%d daily=%+v", resp.Uniques, resp.DailyUniques)
+	}
+	if resp.Bots != 1 {
+		t.Fatalf("bots=%d, want 1", resp.Bots)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+code+"/stats?from=2024-05-01&to=2024-05-03&include_bots=true", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var withBots statsResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &withBots)
+	if withBots.Total != 4 || withBots.Uniques != 1 {
+		t.Fatalf("include_bots: total=%d uniques=%d, want 4 and 1", withBots.Total, withBots.Uniques)
+	}
+	if len(resp.TopReferrers) == 0 || resp.TopReferrers[0].Value != "t.me" || resp.TopReferrers[0].Clicks != 2 {
+		t.Fatalf("unexpected top referrers: %+v", resp.TopReferrers)
+	}
+}
+
+func TestGET_Stats_UnknownCode(t *testing.T) {
+	st := memory.New()
+	h := NewRouter(testLogger(), core.NewShortener(st, core.NewCode), WithStats(st))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/NO_SUCH__1/stats", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status=%d, want 404", rr.Code)
+	}
+}
+
+func TestGET_StatsTop_CountsRedirects(t *testing.T) {
+	st := memory.New()
+	svc := core.NewShortener(st, core.NewCode)
+	code, err := svc.Create(context.Background(), "https://example.com/hot")
+	if err != nil {
+		t.Fatalf("prep Create err: %v", err)
+	}
+	h := NewRouter(testLogger(), svc, WithHotLinks(topk.New(time.Minute, 60, 10)))
+
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/stats/top?window=5m", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, want 200", rr.Code)
+	}
+	var resp struct {
+		Links []topEntry `json:"links"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if len(resp.Links) != 1 || resp.Links[0].Code != code || resp.Links[0].Clicks != 3 {
+		t.Fatalf("unexpected top: %+v", resp.Links)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/stats/top?window=5h", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("window over max: status=%d, want 400", rr.Code)
+	}
+}
+
+func TestClickID_RedirectAndConversion(t *testing.T) {
+	st := memory.New()
+	svc := core.NewShortener(st, core.NewCode)
+	ids := analytics.NewClickIDs([]byte("test-secret"))
+	h := NewRouter(testLogger(), svc, WithStats(st), WithClickIDs(ids), WithConversions(st))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls",
+		strings.NewReader(`{"url":"https://shop.example/p?a=1#top","click_id_param":"clid"}`))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Code         string `json:"code"`
+		ClickIDParam string `json:"click_id_param"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ClickIDParam != "clid" {
+		t.Fatalf("click_id_param=%q, want clid", created.ClickIDParam)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+created.Code, nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("redirect status=%d", rr.Code)
+	}
+	loc := rr.Header().Get("Location")
+	prefix := "https://shop.example/p?a=1&clid="
+	if !strings.HasPrefix(loc, prefix) || !strings.HasSuffix(loc, "#top") {
+		t.Fatalf("Location=%q, want %s<id>#top", loc, prefix)
+	}
+	clickID := strings.TrimSuffix(strings.TrimPrefix(loc, prefix), "#top")
+	if _, code, ok := ids.Parse(clickID); !ok || code != created.Code {
+		t.Fatalf("click id %q does not verify: code=%q ok=%v", clickID, code, ok)
+	}
+
+	postback := func(body string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/conversions", strings.NewReader(body)))
+		return rr.Code
+	}
+	body := `{"click_id":"` + clickID + `","event":"purchase","value":12.5}`
+	if got := postback(body); got != http.StatusCreated {
+		t.Fatalf("first postback status=%d, want 201", got)
+	}
+	if got := postback(body); got != http.StatusOK {
+		t.Fatalf("repeated postback status=%d, want 200", got)
+	}
+	forged := `{"click_id":"` + created.Code + `.AAAAAAAAAAAAAAAAAAAAAA","event":"purchase"}`
+	if got := postback(forged); got != http.StatusBadRequest {
+		t.Fatalf("forged postback status=%d, want 400", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+created.Code+"/stats", nil))
+	var stats statsResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &stats)
+	if stats.Conversions != 1 || stats.ConversionValue != 12.5 || len(stats.DailyConversions) != 1 {
+		t.Fatalf("unexpected conversions: %d %v %+v", stats.Conversions, stats.ConversionValue, stats.DailyConversions)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/urls/"+created.Code, strings.NewReader(`{"click_id_param":""}`))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("patch status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+created.Code, nil))
+	if loc := rr.Header().Get("Location"); loc != "https://shop.example/p?a=1#top" {
+		t.Fatalf("after disabling Location=%q", loc)
+	}
+}
+
+func TestGET_ExportClicks_CSV(t *testing.T) {
+	st := memory.New()
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	_ = st.WriteClicks(context.Background(), []analytics.Click{
+		{Code: "AAAAAAAAAA", At: at, Country: "RU"},
+		{Code: "AAAAAAAAAA", At: at.Add(time.Minute), Bot: true},
+		{Code: "BBBBBBBBBB", At: at},
+	})
+	codes := []string{"AAAAAAAAAA", "BBBBBBBBBB"}
+	svc := core.NewShortener(st, func(int) (string, error) {
+		c := codes[0]
+		codes = codes[1:]
+		return c, nil
+	})
+	if _, err := svc.Create(context.Background(), "https://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateLink(context.Background(), core.CreateRequest{
+		URL:      "https://example.com/b",
+		Settings: core.Settings{Tags: []string{"spring"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewRouter(testLogger(), svc, WithExports(st, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/exports/clicks?code=AAAAAAAAAA&from=2024-05-01&to=2024-05-02", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.Contains(cd, "clicks-20240501-20240502.csv") {
+		t.Fatalf("Content-Disposition=%q", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "code,clicked_at,") {
+		t.Fatalf("unexpected csv: %q", rr.Body.String())
+	}
+
+	// метка раскрывается в коды ссылок вместе с явно перечисленными
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/exports/clicks?tag=spring&code=AAAAAAAAAA&from=2024-05-01&to=2024-05-02&format=ndjson", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if n := strings.Count(rr.Body.String(), "\n"); rr.Code != http.StatusOK || n != 3 || !strings.Contains(rr.Body.String(), `"BBBBBBBBBB"`) {
+		t.Fatalf("by tag: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	for _, q := range []string{"from=2024-05-01", "tag=autumn&from=2024-05-01"} {
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/exports/clicks?"+q, nil)
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status=%d, want 400", q, rr.Code)
+		}
+	}
+}
+
+func TestGET_Events_StreamsRedirects(t *testing.T) {
+	st := memory.New()
+	svc := core.NewShortener(st, core.NewCode)
+	code, err := svc.Create(context.Background(), "https://example.com/live")
+	if err != nil {
+		t.Fatalf("prep Create err: %v", err)
+	}
+	// поток должен пережить таймаут обычных запросов
+	defer func(d time.Duration) { requestTimeout = d }(requestTimeout)
+	requestTimeout = 50 * time.Millisecond
+
+	hub := stream.NewHub(testLogger(), stream.Options{})
+	srv := httptest.NewServer(NewRouter(testLogger(), svc, WithClickStream(hub)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/urls/" + code + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type=%q", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	lines.Scan() // ": connected"
+	time.Sleep(3 * requestTimeout)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/"+code, nil)
+	req.Header.Set("Referer", "https://t.me/chan")
+	if _, err := client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for lines.Scan() && len(got) < 2 {
+		if l := lines.Text(); l != "" {
+			got = append(got, l)
+		}
+	}
+	if len(got) != 2 || got[0] != "event: click" || !strings.Contains(got[1], `"referrer":"t.me"`) {
+		t.Fatalf("unexpected stream: %q", got)
+	}
+
+	rr := httptest.NewRecorder()
+	NewRouter(testLogger(), svc, WithClickStream(hub)).ServeHTTP(rr,
+		httptest.NewRequest(http.MethodGet, "/api/v1/urls/NO_SUCH__1/events", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown code: status=%d, want 404", rr.Code)
+	}
+}
+
+// denyCodes запрещает статистику перечисленных кодов.
+type denyCodes map[string]bool
+
+func (d denyCodes) Authorize(ctx context.Context, p core.Principal, a core.Action, link core.Link) error {
+	if a == core.ActionStats && d[link.Code] {
+		return core.ErrForbidden
+	}
+	return nil
+}
+
+func TestExports_AuthorizeCodesAndJobOwner(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	keys := auth.NewKeys(st)
+	admin := "sk_aaaaaaaaaaaa_" + strings.Repeat("a", 40)
+	alice := "sk_bbbbbbbbbbbb_" + strings.Repeat("b", 40)
+	bob := "sk_cccccccccccc_" + strings.Repeat("c", 40)
+	for token, scopes := range map[string][]core.Scope{
+		admin: {core.ScopeAdmin},
+		alice: {core.ScopeStatsRead},
+		bob:   {core.ScopeStatsRead},
+	} {
+		if err := keys.Ensure(ctx, token, token[3:15], scopes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	codes := []string{"AAAAAAAAAA", "BBBBBBBBBB"}
+	svc := core.NewShortener(st, func(int) (string, error) {
+		c := codes[0]
+		codes = codes[1:]
+		return c, nil
+	}, core.WithAuthorizer(denyCodes{"BBBBBBBBBB": true}))
+	for _, u := range []string{"https://example.com/a", "https://example.com/b"} {
+		if _, err := svc.CreateLink(ctx, core.CreateRequest{URL: u, Settings: core.Settings{Tags: []string{"spring"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := export.NewJobs(testLogger(), st, export.JobsOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewRouter(testLogger(), svc, WithAuth(keys), WithExports(st, jobs))
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodGet, "/api/v1/exports/clicks?code=AAAAAAAAAA&code=BBBBBBBBBB", alice, ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("sync export of a forbidden code: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/api/v1/exports/clicks?code=CCCCCCCCCC", alice, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("sync export of an unknown code: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/v1/exports", alice, `{"codes":["AAAAAAAAAA","BBBBBBBBBB"]}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("job with a forbidden code: status=%d", rr.Code)
+	}
+
+	rr := do(http.MethodPost, "/api/v1/exports", alice, `{"codes":["AAAAAAAAAA"]}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("start: status=%d body=%s", rr.Code, rr.Body)
+	}
+	var job exportJobResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &job)
+
+	for _, path := range []string{"/api/v1/exports/" + job.ID, "/api/v1/exports/" + job.ID + "/download"} {
+		if rr := do(http.MethodGet, path, bob, ""); rr.Code != http.StatusNotFound {
+			t.Fatalf("%s by another principal: status=%d", path, rr.Code)
+		}
+	}
+	for _, token := range []string{alice, admin} {
+		if rr := do(http.MethodGet, "/api/v1/exports/"+job.ID, token, ""); rr.Code != http.StatusOK {
+			t.Fatalf("job by owner or admin: status=%d", rr.Code)
+		}
+	}
+
+	// выборка по метке пропускает ссылки без права на статистику
+	rr = do(http.MethodPost, "/api/v1/exports", alice, `{"tag":"spring"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("start by tag: status=%d body=%s", rr.Code, rr.Body)
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	if len(job.Codes) != 1 || job.Codes[0] != "AAAAAAAAAA" {
+		t.Fatalf("codes by tag: %v", job.Codes)
+	}
+}
+
+func TestTrustedRealIP(t *testing.T) {
+	var got string
+	h := TrustedRealIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }),
+	)
+
+	tests := []struct {
+		name           string
+		remote         string
+		want           string
+		xff, forwarded string
+	}{
+		{"trusted_proxy", "10.1.2.3:5555", "203.0.113.9", "", ""},
+		{"untrusted_client", "198.51.100.7:1234", "198.51.100.7:1234", "", ""},
+		{"spoofed_left_of_proxy", "10.1.2.3:5555", "203.0.113.9", "1.1.1.1, 203.0.113.9, 10.9.9.9", ""},
+		{"forwarded_header", "10.1.2.3:5555", "2001:db8::7", "", `for="[2001:db8::7]:4711";proto=https, for=10.0.0.2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			switch {
+			case tt.forwarded != "":
+				req.Header.Set("Forwarded", tt.forwarded)
+			case tt.xff != "":
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			default:
+				req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("RemoteAddr=%q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhooksAPI_AndDeleteLink(t *testing.T) {
+	st := memory.New()
+	d := webhook.NewDispatcher(testLogger(), st, webhook.Options{})
+	svc := core.NewShortener(st, core.NewCode, core.WithEvents(d))
+	h := NewRouter(testLogger(), svc, WithWebhooks(d))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/v1/webhooks", `{"url":"https://crm.example.com/hook","events":["link.deleted"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create webhook: status=%d body=%s", rr.Code, rr.Body)
+	}
+	var sub struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &sub)
+	if sub.ID == "" || sub.Secret == "" {
+		t.Fatalf("create webhook: %s", rr.Body)
+	}
+	if rr := do(http.MethodPost, "/api/v1/webhooks", `{"url":"https://x.example","events":["user.*"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad filter: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/api/v1/webhooks/"+sub.ID, ""); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), sub.Secret) {
+		t.Fatalf("get webhook: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodPatch, "/api/v1/webhooks/"+sub.ID, `{"active":false}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"active":false`) {
+		t.Fatalf("pause webhook: status=%d body=%s", rr.Code, rr.Body)
+	}
+
+	code, err := svc.Create(context.Background(), "https://example.com/gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(http.MethodDelete, "/api/v1/urls/"+code, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete link: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/"+code, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("deleted link redirect: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/api/v1/urls/"+code, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("delete again: status=%d", rr.Code)
+	}
+
+	if rr := do(http.MethodGet, "/api/v1/webhooks/"+sub.ID+"/deliveries?status=bogus", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad status filter: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/api/v1/webhooks/dead-letters", ""); rr.Code != http.StatusOK {
+		t.Fatalf("dead letters: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/api/v1/webhooks/"+sub.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete webhook: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/api/v1/webhooks/"+sub.ID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("deleted webhook: status=%d", rr.Code)
+	}
+}
+
+func TestAuth_ScopesAndPublicRedirect(t *testing.T) {
+	st := memory.New()
+	keys := auth.NewKeys(st)
+	ctx := context.Background()
+	admin := "sk_aaaaaaaaaaaa_" + strings.Repeat("a", 40)
+	if err := keys.Ensure(ctx, admin, "bootstrap", []core.Scope{core.ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	svc := core.NewShortener(st, core.NewCode)
+	h := NewRouter(testLogger(), svc, WithAuth(keys), WithStats(st))
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/api/v1/urls", "", `{"url":"https://example.com/a"}`); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("anonymous create: status=%d", rr.Code)
+	}
+
+	rr := do(http.MethodPost, "/api/v1/keys", admin, `{"name":"writer","scopes":["links:write"],"expires_in":"24h"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("issue key: status=%d body=%s", rr.Code, rr.Body)
+	}
+	var issued struct {
+		Prefix string `json:"prefix"`
+		Token  string `json:"token"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &issued)
+
+	rr = do(http.MethodPost, "/api/v1/urls", issued.Token, `{"url":"https://example.com/a"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create with key: status=%d", rr.Code)
+	}
+	var created struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+
+	if rr := do(http.MethodGet, "/api/v1/urls/"+created.Code+"/stats", issued.Token, ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("stats without stats:read: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/api/v1/keys", issued.Token, ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("keys without admin: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/"+created.Code, "", ""); rr.Code != http.StatusFound {
+		t.Fatalf("public redirect: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/healthz", "", ""); rr.Code != http.StatusOK {
+		t.Fatalf("healthz: status=%d", rr.Code)
+	}
+
+	if rr := do(http.MethodDelete, "/api/v1/keys/"+issued.Prefix, admin, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/v1/urls", issued.Token, `{"url":"https://example.com/b"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: status=%d", rr.Code)
+	}
+	rr = do(http.MethodGet, "/api/v1/keys", admin, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"revoked_at"`) || strings.Contains(rr.Body.String(), issued.Token) {
+		t.Fatalf("list keys: status=%d body=%s", rr.Code, rr.Body)
+	}
+}
+
+func TestRateLimit_CreateReturns429(t *testing.T) {
+	svc := core.NewShortener(memory.New(), core.NewCode)
+	h := NewRouter(testLogger(), svc, WithRateLimit(ratelimit.NewMemory(), ratelimit.Policies{
+		Create: ratelimit.Policy{Name: "create", Rate: 1.0 / 60, Burst: 2},
+	}))
+	post := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"url":"https://example.com/rl"}`))
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := post("198.51.100.1"); rr.Code >= 300 || rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: status=%d headers=%v", i, rr.Code, rr.Header())
+		}
+	}
+	rr := post("198.51.100.1")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("over limit: status=%d headers=%v", rr.Code, rr.Header())
+	}
+	if rr := post("198.51.100.2"); rr.Code >= 300 {
+		t.Fatalf("other client: status=%d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/AAAAAAAAAA", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code == http.StatusTooManyRequests || rr.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("disabled resolve policy: status=%d headers=%v", rr.Code, rr.Header())
+	}
+}
+
+func TestQuotas_EnforcedOnCreateAndAdjustable(t *testing.T) {
+	st := memory.New()
+	st.SetDefaultQuota(core.Quota{MaxLinks: 2})
+	keys := auth.NewKeys(st)
+	ctx := context.Background()
+	admin := "sk_bbbbbbbbbbbb_" + strings.Repeat("b", 40)
+	if err := keys.Ensure(ctx, admin, "bootstrap", []core.Scope{core.ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	svc := core.NewShortener(st, core.NewCode)
+	h := NewRouter(testLogger(), svc, WithAuth(keys), WithQuotas(st))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+admin)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	user := core.WithPrincipal(ctx, core.Principal{ID: "u1", Owner: "u1", Tenant: "acme", Scopes: []core.Scope{core.ScopeLinksWrite}})
+	var codes []string
+	for _, u := range []string{"https://example.com/q1", "https://example.com/q2"} {
+		link, err := svc.CreateLink(user, core.CreateRequest{URL: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, link.Code)
+	}
+	_, err := svc.CreateLink(user, core.CreateRequest{URL: "https://example.com/q3"})
+	var qe *core.QuotaError
+	if !errors.As(err, &qe) || qe.Kind != core.QuotaActiveLinks || !errors.Is(err, core.ErrQuotaExceeded) {
+		t.Fatalf("over quota err=%v", err)
+	}
+	if _, err := svc.CreateLink(user, core.CreateRequest{URL: "https://example.com/q1"}); err != nil {
+		t.Fatalf("repeat of a known url must not hit the quota: %v", err)
+	}
+
+	rr := do(http.MethodGet, "/api/v1/tenants/acme/quota", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"usage":{"active_links":2,"monthly_creates":2}`) {
+		t.Fatalf("usage: status=%d body=%s", rr.Code, rr.Body)
+	}
+
+	if rr := do(http.MethodPut, "/api/v1/tenants/acme/quota", `{"active_links":10,"monthly_creates":3}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"custom":true`) {
+		t.Fatalf("set quota: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if _, err := svc.CreateLink(user, core.CreateRequest{URL: "https://example.com/q3"}); err != nil {
+		t.Fatalf("after raising quota: %v", err)
+	}
+	if err := svc.Delete(user, "", codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.CreateLink(user, core.CreateRequest{URL: "https://example.com/q4"})
+	if !errors.As(err, &qe) || qe.Kind != core.QuotaMonthlyCreates {
+		t.Fatalf("deleting must not refund monthly creates: err=%v", err)
+	}
+
+	if rr := do(http.MethodDelete, "/api/v1/tenants/acme/quota", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("reset quota: status=%d", rr.Code)
+	}
+	rr = do(http.MethodGet, "/api/v1/quota?tenant=acme", "")
+	if !strings.Contains(rr.Body.String(), `"custom":false`) || !strings.Contains(rr.Body.String(), `"active_links":2,"monthly_creates":3}`) {
+		t.Fatalf("after reset: status=%d body=%s", rr.Code, rr.Body)
+	}
+}
+
+func TestModeration_ReportInterstitialAndDisable(t *testing.T) {
+	st := memory.New()
+	svc := core.NewShortener(st, core.NewCode)
+	mod := moderation.New(testLogger(), st, svc, moderation.Options{AutoDisable: 2, Interstitial: true})
+	h := NewRouter(testLogger(), svc, WithModeration(mod))
+	link, err := svc.CreateLink(context.Background(), core.CreateRequest{URL: "https://example.com/mod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, ctype, body, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ctype != "" {
+			req.Header.Set("Content-Type", ctype)
+		}
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodGet, "/"+link.Code+"/report", "", "", "10.0.0.1"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `value="phishing"`) {
+		t.Fatalf("form: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodPost, "/"+link.Code+"/report", "application/json", `{"reason":"bogus"}`, "10.0.0.1"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad reason: status=%d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/"+link.Code+"/report", "application/x-www-form-urlencoded", "reason=phishing&details=login+page", "10.0.0.1"); rr.Code != http.StatusAccepted {
+		t.Fatalf("form report: status=%d body=%s", rr.Code, rr.Body)
+	}
+
+	rr := do(http.MethodGet, "/"+link.Code, "", "", "10.0.0.9")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `href="http://example.com/`+link.Code+`?go=1"`) {
+		t.Fatalf("interstitial: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodGet, "/"+link.Code+"?go=1", "", "", "10.0.0.9"); rr.Code != http.StatusFound {
+		t.Fatalf("bypass: status=%d", rr.Code)
+	}
+
+	if rr := do(http.MethodPost, "/"+link.Code+"/report", "application/json", `{"reason":"malware"}`, "10.0.0.2"); rr.Code != http.StatusAccepted {
+		t.Fatalf("json report: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodGet, "/"+link.Code+"?go=1", "", "", "10.0.0.9"); rr.Code != http.StatusGone {
+		t.Fatalf("auto-disabled redirect: status=%d", rr.Code)
+	}
+	rr = do(http.MethodGet, "/api/v1/moderation/cases?status=disabled", "", "", "10.0.0.9")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"code":"`+link.Code+`"`) || !strings.Contains(rr.Body.String(), `"reports":2`) {
+		t.Fatalf("queue: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodPost, "/api/v1/moderation/cases/"+link.Code+"/restore", "application/json", `{"note":"ok"}`, "10.0.0.9"); rr.Code != http.StatusOK {
+		t.Fatalf("restore: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodGet, "/"+link.Code, "", "", "10.0.0.9"); rr.Code != http.StatusFound {
+		t.Fatalf("after restore: status=%d", rr.Code)
+	}
+
+	if rr := do(http.MethodPut, "/api/v1/moderation/bans/example.com", "application/json", `{"reason":"test"}`, "10.0.0.9"); rr.Code != http.StatusOK {
+		t.Fatalf("ban: status=%d body=%s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodGet, "/"+link.Code, "", "", "10.0.0.9"); rr.Code != http.StatusGone {
+		t.Fatalf("banned domain redirect: status=%d", rr.Code)
+	}
+}
+
+func TestDomains_RedirectByHostAndShortURL(t *testing.T) {
+	d, err := core.NewDomains([]string{"sho.rt", "go.acme.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := memory.New()
+	svc := core.NewShortener(st, func(int) (string, error) { return "SameCode01", nil }, core.WithDomains(d, st))
+	h := NewRouter(testLogger(), svc)
+
+	create := func(url, domain string) map[string]string {
+		body, _ := json.Marshal(map[string]string{"url": url, "domain": domain})
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/urls", bytes.NewReader(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("create on %q: status=%d body=%s", domain, rr.Code, rr.Body)
+		}
+		var resp map[string]string
+		_ = json.NewDecoder(rr.Body).Decode(&resp)
+		return resp
+	}
+	a := create("https://example.com/a", "")
+	b := create("https://example.com/b", "go.acme.com")
+	if a["short_url"] != "http://sho.rt/SameCode01" || b["short_url"] != "http://go.acme.com/SameCode01" {
+		t.Fatalf("short urls: %q, %q", a["short_url"], b["short_url"])
+	}
+
+	redirect := func(host string) string {
+		req := httptest.NewRequest(http.MethodGet, "/SameCode01", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Header().Get("Location")
+	}
+	if loc := redirect("go.acme.com"); loc != "https://example.com/b" {
+		t.Fatalf("go.acme.com -> %q", loc)
+	}
+	if loc := redirect("sho.rt:8080"); loc != "https://example.com/a" {
+		t.Fatalf("sho.rt -> %q", loc)
+	}
+	if loc := redirect("10.0.0.1"); loc != "https://example.com/a" {
+		t.Fatalf("unknown host should use the default domain, got %q", loc)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/urls/SameCode01?domain=go.acme.com", nil))
+	if !strings.Contains(rr.Body.String(), `"domain":"go.acme.com"`) || !strings.Contains(rr.Body.String(), "example.com/b") {
+		t.Fatalf("resolve with ?domain: %s", rr.Body)
+	}
+	body, _ := json.Marshal(map[string]string{"url": "https://example.com/c", "domain": "other.example"})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/urls", bytes.NewReader(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown domain status=%d, want 400", rr.Code)
+	}
+}
+
+func TestDomains_SameCodeStatsAreSeparate(t *testing.T) {
+	d, err := core.NewDomains([]string{"sho.rt", "go.acme.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := memory.New()
+	svc := core.NewShortener(st, func(int) (string, error) { return "SameCode01", nil }, core.WithDomains(d, st))
+	for _, domain := range []string{"", "go.acme.com"} {
+		if _, err := svc.CreateLink(context.Background(), core.CreateRequest{URL: "https://example.com/" + domain, Domain: domain}); err != nil {
+			t.Fatalf("create on %q: %v", domain, err)
+		}
+	}
+	rec := analytics.NewRecorder(testLogger(), st, analytics.Options{BufferSize: 10, BatchSize: 10, FlushInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { rec.Run(ctx); close(done) }()
+	h := NewRouter(testLogger(), svc, WithStats(st), WithClickRecorder(rec), WithHotLinks(topk.New(time.Minute, 60, 10)))
+
+	for _, host := range []string{"sho.rt", "sho.rt", "go.acme.com"} {
+		req := httptest.NewRequest(http.MethodGet, "/SameCode01", nil)
+		req.Host = host
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	cancel()
+	<-done
+
+	stats := func(domain string) statsResponse {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/urls/SameCode01/stats?domain="+domain, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("stats on %q: status=%d body=%s", domain, rr.Code, rr.Body)
+		}
+		var resp statsResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+	if a := stats(""); a.Domain != "sho.rt" || a.Total != 2 {
+		t.Fatalf("default domain: domain=%q total=%d, want sho.rt and 2", a.Domain, a.Total)
+	}
+	if b := stats("go.acme.com"); b.Domain != "go.acme.com" || b.Total != 1 {
+		t.Fatalf("go.acme.com: domain=%q total=%d, want 1", b.Domain, b.Total)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/stats/top", nil))
+	var top struct {
+		Links []topEntry `json:"links"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &top)
+	want := []topEntry{{Domain: "sho.rt", Code: "SameCode01", Clicks: 2}, {Domain: "go.acme.com", Code: "SameCode01", Clicks: 1}}
+	if !reflect.DeepEqual(top.Links, want) {
+		t.Fatalf("top=%+v, want %+v", top.Links, want)
+	}
+}
+
+func TestShortURL_IgnoresUntrustedHeadersAndUsesBaseURL(t *testing.T) {
+	create := func(h http.Handler) string {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"url":"https://example.com/x"}`))
+		req.Host = "sho.rt"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "evil.example")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var resp struct {
+			Code     string `json:"code"`
+			ShortURL string `json:"short_url"`
+		}
+		_ = json.NewDecoder(rr.Body).Decode(&resp)
+		return strings.TrimSuffix(resp.ShortURL, resp.Code)
+	}
+
+	svc := core.NewShortener(memory.New(), core.NewCode)
+	if got := create(NewRouter(testLogger(), svc)); got != "http://sho.rt/" {
+		t.Fatalf("untrusted client: %q", got)
+	}
+	trusted := WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})
+	if got := create(NewRouter(testLogger(), svc, trusted)); got != "https://evil.example/" {
+		t.Fatalf("trusted proxy: %q", got)
+	}
+	base, _ := url.Parse("https://s.example.com/go/")
+	svc = core.NewShortener(memory.New(), core.NewCode, core.WithPublicBaseURL(base))
+	if got := create(NewRouter(testLogger(), svc, trusted)); got != "https://s.example.com/go/" {
+		t.Fatalf("base url: %q", got)
+	}
+}
+
+func TestQR_PNGAndSVGWithCaching(t *testing.T) {
+	svc := core.NewShortener(memory.New(), core.NewCode)
+	link, err := svc.CreateLink(context.Background(), core.CreateRequest{URL: "https://example.com/print"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewRouter(testLogger(), svc)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+link.Code+"/qr?size=128", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("png: status=%d type=%q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("\x89PNG")) {
+		t.Fatal("body is not a PNG")
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || !strings.Contains(rr.Header().Get("Cache-Control"), "max-age=") {
+		t.Fatalf("caching headers: etag=%q cache-control=%q", etag, rr.Header().Get("Cache-Control"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+link.Code+"/qr?size=128", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("revalidation: status=%d len=%d", rr.Code, rr.Body.Len())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/urls/"+link.Code+"/qr?format=svg&fg=ff0000", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `fill="#ff0000"`) {
+		t.Fatalf("svg: status=%d body=%.120s", rr.Code, rr.Body)
+	}
+
+	for path, want := range map[string]int{
+		"/" + link.Code + "/qr?ecc=Z": http.StatusBadRequest,
+		"/ZZZZZZZZZZ/qr":              http.StatusNotFound,
+	} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: status=%d, want %d", path, rr.Code, want)
+		}
+	}
+}
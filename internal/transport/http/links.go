@@ -0,0 +1,167 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+type linkResponse struct {
+	Domain       string   `json:"domain,omitempty"`
+	URL          string   `json:"url"`
+	Owner        string   `json:"owner,omitempty"`
+	Tenant       string   `json:"tenant,omitempty"`
+	ClickIDParam string   `json:"click_id_param,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Disabled     bool     `json:"disabled,omitempty"`
+}
+
+// toLinkResponse показывает домен именем хоста; без настроенных доменов
+// он пуст.
+func toLinkResponse(d *core.Domains, link core.Link) linkResponse {
+	return linkResponse{
+		Domain:       d.Host(link.Domain),
+		URL:          link.Original,
+		Owner:        link.Owner,
+		Tenant:       link.Tenant,
+		ClickIDParam: link.ClickIDParam,
+		Tags:         link.Tags,
+		Disabled:     link.Disabled,
+	}
+}
+
+// PATCH /api/v1/urls/{code}?domain= {"click_id_param": "gclid", "tags": ["spring"]}
+// Меняются только переданные поля. Пустая строка выключает click id,
+// пустой список снимает метки.
+func updateLinkHandler(log *slog.Logger, svc *core.Shortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+
+		var req struct {
+			ClickIDParam *string   `json:"click_id_param"`
+			Tags         *[]string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		domain := queryDomain(r)
+		link, err := svc.ResolveLink(r.Context(), domain, code)
+		if err == nil {
+			settings := link.Settings
+			if req.ClickIDParam != nil {
+				settings.ClickIDParam = *req.ClickIDParam
+			}
+			if req.Tags != nil {
+				settings.Tags = *req.Tags
+			}
+			link, err = svc.UpdateSettings(r.Context(), domain, code, settings)
+		}
+		switch err {
+		case nil:
+		case core.ErrNotFound:
+			http.NotFound(w, r)
+			return
+		case core.ErrForbidden:
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		case core.ErrInvalidSettings:
+			http.Error(w, "invalid click_id_param or tags", http.StatusBadRequest)
+			return
+		default:
+			log.Error("update failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toLinkResponse(svc.Domains(), link))
+	}
+}
+
+// DELETE /api/v1/urls/{code}?domain=
+// Клики и конверсии удалённой ссылки остаются в аналитике.
+func deleteLinkHandler(log *slog.Logger, svc *core.Shortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+		switch err := svc.Delete(r.Context(), queryDomain(r), code); err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case core.ErrNotFound:
+			http.NotFound(w, r)
+		case core.ErrForbidden:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			log.Error("delete failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// GET /api/v1/urls?domain=&owner=&tag=&after=<code>&limit=50 — ссылки
+// домена, видимые вызывающему, по возрастанию кода. next_after — курсор
+// следующей страницы.
+func listLinksHandler(log *slog.Logger, svc *core.Shortener) http.HandlerFunc {
+	type item struct {
+		Code string `json:"code"`
+		linkResponse
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		req := core.ListRequest{Domain: queryDomain(r), Owner: qs.Get("owner"), Tag: qs.Get("tag"), After: qs.Get("after")}
+		if v := qs.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			req.Limit = n
+		}
+		links, next, err := svc.List(r.Context(), req)
+		switch err {
+		case nil:
+		case core.ErrForbidden:
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		case core.ErrUnknownDomain:
+			http.Error(w, "unknown domain", http.StatusBadRequest)
+			return
+		default:
+			log.Error("list failed", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := struct {
+			Links     []item `json:"links"`
+			NextAfter string `json:"next_after,omitempty"`
+		}{Links: make([]item, len(links)), NextAfter: next}
+		for i, l := range links {
+			resp.Links[i] = item{Code: l.Code, linkResponse: toLinkResponse(svc.Domains(), l)}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// withClickID выпускает click id и дописывает его к URL назначения. При
+// ошибке редирект уходит на исходный URL без ID: клик важнее атрибуции.
+func withClickID(log *slog.Logger, ids *analytics.ClickIDs, link core.Link) (string, string) {
+	id, err := ids.New(link.Domain, link.Code)
+	if err != nil {
+		log.Error("click id failed", "code", link.Code, "err", err)
+		return link.Original, ""
+	}
+	target, err := core.AppendParam(link.Original, link.ClickIDParam, id)
+	if err != nil {
+		log.Error("click id failed", "code", link.Code, "err", err)
+		return link.Original, ""
+	}
+	return target, id
+}
@@ -2,10 +2,13 @@ package httptransport
 
 import (
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"time"
 
 	// "github.com/go-chi/chi/v5"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
@@ -32,4 +35,37 @@ func LoggingMiddleware(log *slog.Logger) func(next http.Handler) http.Handler {
 			next.ServeHTTP(ww, r)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func fromTrustedProxy(r *http.Request, trusted []netip.Prefix) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestInfo кладёт в контекст request id и адрес клиента для журнала
+// аудита; ставится после RequestID и TrustedRealIP.
+func requestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := core.WithRequestInfo(r.Context(), core.RequestInfo{
+			ID: middleware.GetReqID(r.Context()),
+			IP: clientIP(r),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,281 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	reportPage = template.Must(template.New("report").Parse(`<!doctype html>
+<html lang="ru"><head><meta charset="utf-8"><title>Пожаловаться на ссылку</title></head>
+<body>
+<h1>Пожаловаться на ссылку /{{.Code}}</h1>
+{{if .Done}}<p>Спасибо, жалоба принята.</p>{{else}}
+<form method="post">
+<p><label>Причина
+<select name="reason">{{range .Reasons}}<option value="{{.}}">{{.}}</option>{{end}}</select></label></p>
+<p><label>Подробности<br><textarea name="details" rows="5" cols="60"></textarea></label></p>
+<p><button type="submit">Отправить</button></p>
+</form>{{end}}
+</body></html>
+`))
+	warningPage = template.Must(template.New("warning").Parse(`<!doctype html>
+<html lang="ru"><head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Осторожно</title></head>
+<body>
+<h1>На эту ссылку поступили жалобы</h1>
+<p>Ссылка ведёт на <code>{{.Target}}</code>. Модераторы ещё не проверили её.</p>
+<p><a href="{{.Continue}}" rel="noreferrer">Всё равно перейти</a></p>
+</body></html>
+`))
+)
+
+type reportView struct {
+	Code    string
+	Reasons []moderation.Reason
+	Done    bool
+}
+
+// moderate применяет решения модераторов к редиректу и сообщает, ответил
+// ли он сам. Отключённая ссылка не редиректит и без модерации. Переход со
+// страницы предупреждения ведёт на короткую ссылку с учётом домена и
+// PUBLIC_BASE_URL, как и остальные выдаваемые ссылки.
+func moderate(w http.ResponseWriter, r *http.Request, log *slog.Logger, svc *core.Shortener, m *moderation.Moderator, link core.Link) bool {
+	verdict := moderation.Allow
+	if m != nil {
+		verdict = m.Verdict(link)
+	} else if link.Disabled {
+		verdict = moderation.Disabled
+	}
+	switch verdict {
+	case moderation.Disabled, moderation.Banned:
+		http.Error(w, "link disabled", http.StatusGone)
+		return true
+	case moderation.Warn:
+		if r.URL.Query().Get("go") == "1" {
+			return false
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		view := struct{ Continue, Target string }{absoluteURL(r, svc, link) + "?go=1", link.Original}
+		if err := warningPage.Execute(w, view); err != nil {
+			log.Error("render warning failed", "code", link.Code, "err", err)
+		}
+		return true
+	}
+	return false
+}
+
+func reportFormHandler(log *slog.Logger, svc *core.Shortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+		if _, err := svc.ResolveLink(r.Context(), hostDomain(svc.Domains(), r), code); err != nil {
+			if err == core.ErrNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			log.Error("resolve failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		renderReport(w, log, reportView{Code: code, Reasons: moderation.Reasons}, http.StatusOK)
+	}
+}
+
+// POST /{code}/report — JSON {"reason": "phishing", "details": "..."} или
+// форма со страницы GET /{code}/report. Ответ 202: решение за модератором.
+func reportHandler(log *slog.Logger, svc *core.Shortener, m *moderation.Moderator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		isJSON := ct == "application/json"
+
+		var req struct {
+			Reason  moderation.Reason `json:"reason"`
+			Details string            `json:"details"`
+		}
+		if isJSON {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		} else {
+			r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			req.Reason, req.Details = moderation.Reason(r.PostForm.Get("reason")), r.PostForm.Get("details")
+		}
+
+		_, err := m.Report(r.Context(), hostDomain(svc.Domains(), r), code, req.Reason, req.Details, clientIP(r))
+		switch {
+		case err == nil:
+		case errors.Is(err, core.ErrNotFound):
+			http.NotFound(w, r)
+			return
+		case errors.Is(err, moderation.ErrInvalidReason):
+			http.Error(w, "invalid reason", http.StatusBadRequest)
+			return
+		default:
+			log.Error("report failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !isJSON {
+			renderReport(w, log, reportView{Code: code, Done: true}, http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "received"})
+	}
+}
+
+func renderReport(w http.ResponseWriter, log *slog.Logger, v reportView, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := reportPage.Execute(w, v); err != nil {
+		log.Error("render report form failed", "code", v.Code, "err", err)
+	}
+}
+
+// mountModeration — очередь модерации и запреты доменов, только admin.
+//
+//	GET  /api/v1/moderation/cases?status=open&limit=100
+//	GET  /api/v1/moderation/cases/{code}?domain=
+//	POST /api/v1/moderation/cases/{code}/disable|restore|dismiss?domain= {"note": "..."}
+//	GET  /api/v1/moderation/bans
+//	PUT  /api/v1/moderation/bans/{domain} {"reason": "..."}
+//	DELETE /api/v1/moderation/bans/{domain}
+func mountModeration(r chi.Router, log *slog.Logger, m *moderation.Moderator) {
+	r.Get("/api/v1/moderation/cases", func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := queryLimit(w, r)
+		if !ok {
+			return
+		}
+		cases, err := m.Cases(r.Context(), moderation.Status(r.URL.Query().Get("status")), limit)
+		if err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"cases": cases})
+	})
+	r.Get("/api/v1/moderation/cases/{code}", func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := queryLimit(w, r)
+		if !ok {
+			return
+		}
+		c, reports, err := m.Case(r.Context(), queryDomain(r), chi.URLParam(r, "code"), limit)
+		if err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			moderation.Case
+			Recent []moderation.Report `json:"recent_reports"`
+		}{c, reports})
+	})
+	r.Post("/api/v1/moderation/cases/{code}/disable", decideHandler(log, m.Disable))
+	r.Post("/api/v1/moderation/cases/{code}/restore", decideHandler(log, m.Restore))
+	r.Post("/api/v1/moderation/cases/{code}/dismiss", decideHandler(log, m.Dismiss))
+
+	r.Get("/api/v1/moderation/bans", func(w http.ResponseWriter, r *http.Request) {
+		bans, err := m.Bans(r.Context())
+		if err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"bans": bans})
+	})
+	r.Put("/api/v1/moderation/bans/{domain}", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+		b, err := m.BanDomain(r.Context(), chi.URLParam(r, "domain"), req.Reason)
+		if err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(b)
+	})
+	r.Delete("/api/v1/moderation/bans/{domain}", func(w http.ResponseWriter, r *http.Request) {
+		if err := m.UnbanDomain(r.Context(), chi.URLParam(r, "domain")); err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// decideHandler — решение модератора по делу с необязательной заметкой.
+func decideHandler(log *slog.Logger, decide func(ctx context.Context, domain, code, note string) (moderation.Case, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Note string `json:"note"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+		c, err := decide(r.Context(), queryDomain(r), chi.URLParam(r, "code"), req.Note)
+		if err != nil {
+			writeModerationError(w, r, log, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	}
+}
+
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 100, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > 1000 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func writeModerationError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, core.ErrNotFound), errors.Is(err, moderation.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, core.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, moderation.ErrInvalidDomain):
+		http.Error(w, "invalid domain", http.StatusBadRequest)
+	case errors.Is(err, moderation.ErrInvalidStatus):
+		http.Error(w, "invalid status", http.StatusBadRequest)
+	case errors.Is(err, moderation.ErrCaseClosed):
+		http.Error(w, "case is not open", http.StatusConflict)
+	default:
+		log.Error("moderation request failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
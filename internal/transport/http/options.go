@@ -0,0 +1,143 @@
+package httptransport
+
+import (
+	"net/netip"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/export"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/topk"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/audit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/moderation"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
+)
+
+type Option func(*options)
+
+type options struct {
+	clicks *analytics.Recorder
+	stats  analytics.StatsReader
+	hot    *topk.Tracker
+
+	clickIDs    *analytics.ClickIDs
+	conversions analytics.ConversionSink
+
+	exportSrc  analytics.ClickScanner
+	exportJobs *export.Jobs
+
+	stream *stream.Hub
+
+	webhooks *webhook.Dispatcher
+
+	keys *auth.Keys
+	jwt  *auth.JWTVerifier
+
+	limiter  ratelimit.Limiter
+	policies ratelimit.Policies
+
+	quotas core.QuotaStore
+
+	rbac *authz.RBAC
+
+	audit *audit.Log
+
+	moderation *moderation.Moderator
+
+	trustedProxies []netip.Prefix
+}
+
+// WithClickRecorder включает запись кликов на GET /{code}.
+func WithClickRecorder(rec *analytics.Recorder) Option {
+	return func(o *options) { o.clicks = rec }
+}
+
+// WithStats включает GET /api/v1/urls/{code}/stats.
+func WithStats(stats analytics.StatsReader) Option {
+	return func(o *options) { o.stats = stats }
+}
+
+// WithHotLinks скармливает редиректы трекеру горячих кодов и включает
+// GET /api/v1/stats/top.
+func WithHotLinks(t *topk.Tracker) Option {
+	return func(o *options) { o.hot = t }
+}
+
+// WithClickIDs включает подстановку click id в редиректы ссылок, у которых
+// задан click_id_param.
+func WithClickIDs(ids *analytics.ClickIDs) Option {
+	return func(o *options) { o.clickIDs = ids }
+}
+
+// WithConversions включает POST /api/v1/conversions. Работает вместе с
+// WithClickIDs: без него проверить click id нечем.
+func WithConversions(sink analytics.ConversionSink) Option {
+	return func(o *options) { o.conversions = sink }
+}
+
+// WithExports включает GET /api/v1/exports/clicks, а с jobs — ещё и
+// фоновые выгрузки /api/v1/exports.
+func WithExports(src analytics.ClickScanner, jobs *export.Jobs) Option {
+	return func(o *options) { o.exportSrc, o.exportJobs = src, jobs }
+}
+
+// WithClickStream публикует редиректы в hub и включает
+// GET /api/v1/urls/{code}/events.
+func WithClickStream(hub *stream.Hub) Option {
+	return func(o *options) { o.stream = hub }
+}
+
+// WithTrustedProxies задаёт сети прокси, которым разрешено передавать адрес
+// клиента в заголовках. Без них заголовки игнорируются.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(o *options) { o.trustedProxies = prefixes }
+}
+
+// WithWebhooks включает API подписок и событие link.clicked на редиректе.
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(o *options) { o.webhooks = d }
+}
+
+// WithAuth требует API-ключ с нужным scope на всех маршрутах API и
+// включает выпуск и отзыв ключей. Редирект /{code}, health и метрики
+// остаются открытыми.
+func WithAuth(keys *auth.Keys) Option {
+	return func(o *options) { o.keys = keys }
+}
+
+// WithJWT принимает JWT в Authorization: Bearer наравне с API-ключами.
+func WithJWT(v *auth.JWTVerifier) Option {
+	return func(o *options) { o.jwt = v }
+}
+
+// WithRateLimit включает ограничение частоты на создание, JSON-resolve и
+// редирект; выключенная (нулевая) политика не ограничивает.
+func WithRateLimit(l ratelimit.Limiter, p ratelimit.Policies) Option {
+	return func(o *options) { o.limiter, o.policies = l, p }
+}
+
+// WithQuotas включает API квот тенантов: GET /api/v1/quota и админские
+// /api/v1/tenants/{tenant}/quota.
+func WithQuotas(q core.QuotaStore) Option {
+	return func(o *options) { o.quotas = q }
+}
+
+// WithRoles включает управление ролями тенантов и доступами к ссылкам.
+// Сами проверки делает core.Shortener с core.WithAuthorizer.
+func WithRoles(rbac *authz.RBAC) Option {
+	return func(o *options) { o.rbac = rbac }
+}
+
+// WithAudit включает чтение журнала аудита и проверку его цепочки.
+func WithAudit(l *audit.Log) Option {
+	return func(o *options) { o.audit = l }
+}
+
+// WithModeration включает жалобы на ссылки (/{code}/report), проверку
+// редиректов по решениям модераторов и /api/v1/moderation для admin.
+func WithModeration(m *moderation.Moderator) Option {
+	return func(o *options) { o.moderation = m }
+}
@@ -0,0 +1,191 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// forwarded — схема и хост исходного запроса со слов доверенного прокси.
+type forwarded struct {
+	proto, host string
+}
+
+type forwardedKey struct{}
+
+// TrustedRealIP учитывает заголовки прокси только у запросов, пришедших
+// напрямую от доверенного прокси: Forwarded (RFC 7239), а без него —
+// X-Forwarded-For/-Proto/-Host, X-Real-IP и True-Client-IP. Адрес клиента
+// — первый справа в цепочке, не принадлежащий доверенным сетям; схема и
+// хост запоминаются для построения коротких ссылок. Остальным клиентам
+// подменить адрес, схему или хост заголовком нельзя.
+func TrustedRealIP(trusted []netip.Prefix) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !fromTrustedProxy(r, trusted) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			f, chain := proxyHeaders(r.Header)
+			if ip := clientFromChain(chain, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			if f != (forwarded{}) {
+				r = r.WithContext(context.WithValue(r.Context(), forwardedKey{}, f))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// proxyHeaders возвращает схему и хост, которые видел первый прокси, и
+// цепочку адресов от клиента к ближайшему прокси.
+func proxyHeaders(h http.Header) (f forwarded, chain []string) {
+	if vals := h.Values("Forwarded"); len(vals) > 0 {
+		for _, elem := range splitList(vals) {
+			var proto, host string
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				v = strings.Trim(v, `"`)
+				switch strings.ToLower(k) {
+				case "for":
+					chain = append(chain, nodeAddr(v))
+				case "proto":
+					proto = v
+				case "host":
+					host = v
+				}
+			}
+			if f.proto == "" {
+				f.proto = validProto(proto)
+			}
+			if f.host == "" {
+				f.host = validHost(host)
+			}
+		}
+		return f, chain
+	}
+
+	chain = splitList(h.Values("X-Forwarded-For"))
+	if len(chain) == 0 {
+		for _, k := range []string{"X-Real-IP", "True-Client-IP"} {
+			if v := strings.TrimSpace(h.Get(k)); v != "" {
+				chain = []string{v}
+				break
+			}
+		}
+	}
+	if protos := splitList(h.Values("X-Forwarded-Proto")); len(protos) > 0 {
+		f.proto = validProto(protos[0])
+	}
+	if hosts := splitList(h.Values("X-Forwarded-Host")); len(hosts) > 0 {
+		f.host = validHost(hosts[0])
+	}
+	return f, chain
+}
+
+// clientFromChain идёт по цепочке справа налево, пропуская доверенные
+// прокси. Нераспознанный адрес обрывает цепочку: левее него заголовку
+// верить нельзя.
+func clientFromChain(chain []string, trusted []netip.Prefix) string {
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(chain[i])
+		if err != nil {
+			return ""
+		}
+		addr = addr.Unmap()
+		if i == 0 || !trustedAddr(addr, trusted) {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
+func trustedAddr(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAddr достаёт IP из узла Forwarded: "192.0.2.1", "192.0.2.1:4711",
+// "[2001:db8::1]:4711". "unknown" и скрытые имена возвращаются как есть и
+// дальше не разбираются.
+func nodeAddr(v string) string {
+	if strings.HasPrefix(v, "[") {
+		if end := strings.IndexByte(v, ']'); end > 0 {
+			return v[1:end]
+		}
+		return v
+	}
+	if host, _, ok := strings.Cut(v, ":"); ok && strings.Count(v, ":") == 1 {
+		return host
+	}
+	return v
+}
+
+func splitList(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func validProto(p string) string {
+	switch p = strings.ToLower(p); p {
+	case "http", "https":
+		return p
+	}
+	return ""
+}
+
+func validHost(h string) string {
+	if h == "" || strings.ContainsAny(h, "/\\@?# \t") {
+		return ""
+	}
+	return h
+}
+
+// requestHost — хост, на который пришёл клиент: от доверенного прокси —
+// из его заголовков, иначе Host запроса.
+func requestHost(r *http.Request) string {
+	if f, ok := r.Context().Value(forwardedKey{}).(forwarded); ok && f.host != "" {
+		return f.host
+	}
+	return r.Host
+}
+
+// absoluteURL строит короткую ссылку. С PUBLIC_BASE_URL адрес не зависит
+// от запроса. Без него схема берётся из TLS или от доверенного прокси, а
+// хост — домен ссылки; Host запроса (с портом) используется, если это тот
+// же домен или домены не настроены.
+func absoluteURL(r *http.Request, svc *core.Shortener, link core.Link) string {
+	if u, ok := svc.ShortURL(link); ok {
+		return u
+	}
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	if f, ok := r.Context().Value(forwardedKey{}).(forwarded); ok && f.proto != "" {
+		proto = f.proto
+	}
+	host := requestHost(r)
+	if d := svc.Domains(); d != nil && core.HostName(host) != d.Host(link.Domain) {
+		host = d.Host(link.Domain)
+	}
+	return proto + "://" + host + "/" + link.Code
+}
@@ -0,0 +1,91 @@
+package httptransport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/qr"
+	"github.com/go-chi/chi/v5"
+)
+
+// qrMaxAge — сколько клиенты и CDN могут хранить картинку: код ссылки и
+// её адрес не меняются, а опции входят в URL.
+const qrMaxAge = 24 * 60 * 60
+
+// GET /{code}/qr и GET /api/v1/urls/{code}/qr?domain=
+// ?format=png|svg&size=256&margin=4&ecc=L|M|Q|H&fg=000000&bg=ffffff
+//
+// public=true — публичный путь: домен ссылки берётся из хоста запроса.
+func qrHandler(log *slog.Logger, svc *core.Shortener, public bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+		opts, err := qr.ParseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		domain := queryDomain(r)
+		if public {
+			domain = hostDomain(svc.Domains(), r)
+		}
+		link, err := svc.ResolveLink(r.Context(), domain, code)
+		switch {
+		case err == nil:
+		case errors.Is(err, core.ErrNotFound):
+			http.NotFound(w, r)
+			return
+		default:
+			log.Error("resolve failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if link.Disabled {
+			http.Error(w, "link disabled", http.StatusGone)
+			return
+		}
+
+		short := absoluteURL(r, svc, link)
+		etag := qr.ETag(short, opts)
+		h := w.Header()
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(qrMaxAge))
+		if _, ok := svc.ShortURL(link); !ok {
+			// адрес в картинке зависит от хоста и схемы запроса
+			h.Set("Vary", "Host, Forwarded, X-Forwarded-Host, X-Forwarded-Proto")
+		}
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		img, err := qr.Render(short, opts)
+		switch {
+		case err == nil:
+		case errors.Is(err, qr.ErrTooSmall):
+			http.Error(w, "size too small for this link", http.StatusBadRequest)
+			return
+		default:
+			log.Error("qr render failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		h.Set("Content-Type", opts.Format.ContentType())
+		h.Set("Content-Length", strconv.Itoa(len(img)))
+		_, _ = w.Write(img)
+	}
+}
+
+// etagMatch — слабое сравнение для If-None-Match (RFC 9110, 13.1.2).
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
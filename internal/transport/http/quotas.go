@@ -0,0 +1,103 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+type quotaResponse struct {
+	Tenant string `json:"tenant"`
+	Custom bool   `json:"custom"`
+	Month  string `json:"month"`
+	Limits struct {
+		ActiveLinks    int `json:"active_links"`
+		MonthlyCreates int `json:"monthly_creates"`
+	} `json:"limits"`
+	Usage struct {
+		ActiveLinks    int `json:"active_links"`
+		MonthlyCreates int `json:"monthly_creates"`
+	} `json:"usage"`
+}
+
+func writeQuota(w http.ResponseWriter, log *slog.Logger, r *http.Request, quotas core.QuotaStore, tenant string) {
+	u, err := quotas.QuotaUsage(r.Context(), tenant, time.Now())
+	if err != nil {
+		log.Error("quota usage failed", "tenant", tenant, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var resp quotaResponse
+	resp.Tenant, resp.Custom, resp.Month = u.Tenant, u.Custom, u.Month.Format("2006-01")
+	resp.Limits.ActiveLinks, resp.Limits.MonthlyCreates = u.Quota.MaxLinks, u.Quota.MaxMonthlyCreates
+	resp.Usage.ActiveLinks, resp.Usage.MonthlyCreates = u.ActiveLinks, u.MonthlyCreates
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GET /api/v1/quota — квота и потребление тенанта вызывающего. Без
+// тенанта в учётных данных (сервисный ключ, выключенная аутентификация)
+// тенант задаётся ?tenant=, что разрешено только admin.
+func ownQuotaHandler(log *slog.Logger, quotas core.QuotaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.URL.Query().Get("tenant")
+		if p, ok := core.PrincipalFrom(r.Context()); ok && !p.Can(core.ScopeAdmin) {
+			if tenant != "" && tenant != p.Tenant {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			tenant = p.Tenant
+		}
+		if tenant == "" {
+			http.Error(w, "tenant is required", http.StatusBadRequest)
+			return
+		}
+		writeQuota(w, log, r, quotas, tenant)
+	}
+}
+
+func tenantQuotaHandler(log *slog.Logger, quotas core.QuotaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeQuota(w, log, r, quotas, chi.URLParam(r, "tenant"))
+	}
+}
+
+// PUT /api/v1/tenants/{tenant}/quota {"active_links": 1000, "monthly_creates": 5000}
+// 0 — без ограничения. Уже созданные ссылки квота не трогает.
+func setQuotaHandler(log *slog.Logger, quotas core.QuotaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ActiveLinks    int `json:"active_links"`
+			MonthlyCreates int `json:"monthly_creates"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ActiveLinks < 0 || req.MonthlyCreates < 0 {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		tenant := chi.URLParam(r, "tenant")
+		q := core.Quota{MaxLinks: req.ActiveLinks, MaxMonthlyCreates: req.MonthlyCreates}
+		if err := quotas.SetQuota(r.Context(), tenant, &q); err != nil {
+			log.Error("set quota failed", "tenant", tenant, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		writeQuota(w, log, r, quotas, tenant)
+	}
+}
+
+// DELETE /api/v1/tenants/{tenant}/quota — вернуть квоту по умолчанию.
+func resetQuotaHandler(log *slog.Logger, quotas core.QuotaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := chi.URLParam(r, "tenant")
+		if err := quotas.SetQuota(r.Context(), tenant, nil); err != nil {
+			log.Error("reset quota failed", "tenant", tenant, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
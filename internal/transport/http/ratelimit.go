@@ -0,0 +1,41 @@
+package httptransport
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+)
+
+// rateLimit ограничивает запросы клиента по политике p. Ключ —
+// ratelimit.ClientKey, поэтому на маршрутах с аутентификацией middleware
+// должен стоять после requireScope.
+func rateLimit(log *slog.Logger, l ratelimit.Limiter, p ratelimit.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || !p.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, ok := ratelimit.Check(r.Context(), log, l, p, ratelimit.ClientKey(r.Context(), clientIP(r)))
+			if d.Limit > 0 {
+				h := w.Header()
+				h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+				h.Set("RateLimit-Remaining", strconv.Itoa(max(d.Remaining, 0)))
+				h.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+			}
+			if !ok {
+				w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,113 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/authz"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+type assignmentResponse struct {
+	User      string     `json:"user"`
+	Role      authz.Role `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// mountRoles — роли тенанта и доступы к ссылкам. Кто чем может управлять,
+// решает authz.RBAC; scope здесь — только нижняя граница.
+func mountRoles(r chi.Router, log *slog.Logger, svc *core.Shortener, rbac *authz.RBAC, need func(core.Scope) func(http.Handler) http.Handler) {
+	r.With(need(core.ScopeLinksRead)).Get("/api/v1/tenants/{tenant}/roles", func(w http.ResponseWriter, r *http.Request) {
+		as, err := rbac.Roles(r.Context(), chi.URLParam(r, "tenant"))
+		writeAssignments(w, r, log, as, err)
+	})
+	// PUT /api/v1/tenants/{tenant}/roles/{user} {"role": "editor"}
+	r.With(need(core.ScopeLinksWrite)).Put("/api/v1/tenants/{tenant}/roles/{user}", func(w http.ResponseWriter, r *http.Request) {
+		role, ok := decodeRole(w, r)
+		if !ok {
+			return
+		}
+		err := rbac.Assign(r.Context(), chi.URLParam(r, "tenant"), chi.URLParam(r, "user"), role)
+		writeRoleResult(w, r, log, err)
+	})
+	r.With(need(core.ScopeLinksWrite)).Delete("/api/v1/tenants/{tenant}/roles/{user}", func(w http.ResponseWriter, r *http.Request) {
+		err := rbac.Unassign(r.Context(), chi.URLParam(r, "tenant"), chi.URLParam(r, "user"))
+		writeRoleResult(w, r, log, err)
+	})
+
+	r.With(need(core.ScopeLinksRead)).Get("/api/v1/urls/{code}/shares", func(w http.ResponseWriter, r *http.Request) {
+		link, err := svc.ResolveLink(r.Context(), queryDomain(r), chi.URLParam(r, "code"))
+		var as []authz.Assignment
+		if err == nil {
+			as, err = rbac.Shares(r.Context(), link)
+		}
+		writeAssignments(w, r, log, as, err)
+	})
+	// PUT /api/v1/urls/{code}/shares/{user} {"role": "viewer"}
+	r.With(need(core.ScopeLinksWrite)).Put("/api/v1/urls/{code}/shares/{user}", func(w http.ResponseWriter, r *http.Request) {
+		role, ok := decodeRole(w, r)
+		if !ok {
+			return
+		}
+		link, err := svc.ResolveLink(r.Context(), queryDomain(r), chi.URLParam(r, "code"))
+		if err == nil {
+			err = rbac.Share(r.Context(), link, chi.URLParam(r, "user"), role)
+		}
+		writeRoleResult(w, r, log, err)
+	})
+	r.With(need(core.ScopeLinksWrite)).Delete("/api/v1/urls/{code}/shares/{user}", func(w http.ResponseWriter, r *http.Request) {
+		link, err := svc.ResolveLink(r.Context(), queryDomain(r), chi.URLParam(r, "code"))
+		if err == nil {
+			err = rbac.Unshare(r.Context(), link, chi.URLParam(r, "user"))
+		}
+		writeRoleResult(w, r, log, err)
+	})
+}
+
+func decodeRole(w http.ResponseWriter, r *http.Request) (authz.Role, bool) {
+	var req struct {
+		Role authz.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return "", false
+	}
+	return req.Role, true
+}
+
+func writeAssignments(w http.ResponseWriter, r *http.Request, log *slog.Logger, as []authz.Assignment, err error) {
+	if err != nil {
+		writeRoleResult(w, r, log, err)
+		return
+	}
+	out := make([]assignmentResponse, len(as))
+	for i, a := range as {
+		out[i] = assignmentResponse{User: a.User, Role: a.Role, CreatedAt: a.CreatedAt}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Assignments []assignmentResponse `json:"assignments"`
+	}{out})
+}
+
+func writeRoleResult(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, core.ErrNotFound), errors.Is(err, authz.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, core.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, authz.ErrInvalidRole):
+		http.Error(w, "invalid role", http.StatusBadRequest)
+	case errors.Is(err, authz.ErrSharesUnsupported):
+		http.Error(w, "link shares are not supported", http.StatusNotImplemented)
+	default:
+		log.Error("role update failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -2,26 +2,46 @@ package httptransport
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/stream"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/auth"
 	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/ratelimit"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// requestTimeout — дедлайн обычного запроса; синхронные выгрузки
+// укладываются в него же.
+var requestTimeout = 10 * time.Second
 
-func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
-	r := chi.NewRouter()
+func NewRouter(log *slog.Logger, svc *core.Shortener, opts ...Option) http.Handler{
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mux := chi.NewRouter()
+
+	mux.Use(middleware.RequestID)
+	mux.Use(TrustedRealIP(o.trustedProxies))
+	mux.Use(requestInfo)
+	mux.Use(middleware.Recoverer)
 
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(10*time.Second))
+	mux.Use(LoggingMiddleware(log))
 
-	r.Use(LoggingMiddleware(log))
+	// Все маршруты, кроме долгоживущих потоков, регистрируются на r и
+	// получают таймаут запроса. Потоки (SSE) монтируются прямо на mux: у
+	// них нет дедлайна, они сами следят за отключением клиента.
+	r := mux.With(middleware.Timeout(requestTimeout))
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -31,13 +51,28 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ready"))
 	})
-	r.Post("/api/v1/urls", func(w http.ResponseWriter, r *http.Request) {
+	authn := auth.Combine(o.keys, o.jwt)
+	need := func(scope core.Scope) func(http.Handler) http.Handler {
+		return requireScope(log, authn, scope)
+	}
+
+	limit := func(p ratelimit.Policy) func(http.Handler) http.Handler {
+		return rateLimit(log, o.limiter, p)
+	}
+
+	r.With(need(core.ScopeLinksWrite), limit(o.policies.Create)).Post("/api/v1/urls", func(w http.ResponseWriter, r *http.Request) {
 		type RequestPOST struct {
-			URL string `json:"url"`
+			URL          string   `json:"url"`
+			Domain       string   `json:"domain"`
+			ClickIDParam string   `json:"click_id_param"`
+			Tags         []string `json:"tags"`
 		}
 		type ResponsePOST struct {
-			Code     string `json:"code"`
-			ShortURL string `json:"short_url"`
+			Domain       string   `json:"domain,omitempty"`
+			Code         string   `json:"code"`
+			ShortURL     string   `json:"short_url"`
+			ClickIDParam string   `json:"click_id_param,omitempty"`
+			Tags         []string `json:"tags,omitempty"`
 		}
 
 		var req RequestPOST
@@ -47,13 +82,32 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 			return
 		}
 
-		code, err := svc.Create(r.Context(), req.URL)
+		link, err := svc.CreateLink(r.Context(), core.CreateRequest{
+			URL:      req.URL,
+			Domain:   req.Domain,
+			Settings: core.Settings{ClickIDParam: req.ClickIDParam, Tags: req.Tags},
+		})
 		if err != nil {
+			var qe *core.QuotaError
+			if errors.As(err, &qe) {
+				http.Error(w, qe.Error(), http.StatusForbidden)
+				return
+			}
 			switch err {
 			case core.ErrInvalidURL:
 				http.Error(w, "invalid url", http.StatusBadRequest)
+			case core.ErrInvalidSettings:
+				http.Error(w, "invalid click_id_param or tags", http.StatusBadRequest)
 			case core.ErrConflict:
 				http.Error(w, "too many collisions", http.StatusConflict)
+			case core.ErrForbidden:
+				http.Error(w, "forbidden", http.StatusForbidden)
+			case core.ErrBannedDomain:
+				http.Error(w, "destination domain is banned", http.StatusBadRequest)
+			case core.ErrUnknownDomain:
+				http.Error(w, "unknown domain", http.StatusBadRequest)
+			case core.ErrDomainNotAllowed:
+				http.Error(w, "domain not allowed", http.StatusForbidden)
 			default:
 				log.Error("create failed", "err", err)
 				http.Error(w, "internal error", http.StatusInternalServerError)
@@ -61,19 +115,22 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 			return
 		}
 
-		short := absoluteURL(r, code)
+		short := absoluteURL(r, svc, link)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Location", short)
 		w.WriteHeader(http.StatusCreated)
 
 		_ = json.NewEncoder(w).Encode(ResponsePOST{
-			Code:     code,
-			ShortURL: short,
+			Domain:       svc.Domains().Host(link.Domain),
+			Code:         link.Code,
+			ShortURL:     short,
+			ClickIDParam: link.ClickIDParam,
+			Tags:         link.Tags,
 		})
 	})
 
-	r.Get("/{code}", func(w http.ResponseWriter, r *http.Request) {
+	r.With(limit(o.policies.Redirect)).Get("/{code}", func(w http.ResponseWriter, r *http.Request) {
 		code := chi.URLParam(r, "code")
 		if !core.IsValidCode(code) {
 			log.Error("invalid code")
@@ -81,13 +138,53 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 			return 
 		}
 
-		original, err := svc.Resolve(r.Context(), code)
+		link, err := svc.ResolveLink(r.Context(), hostDomain(svc.Domains(), r), code)
 		switch err {
 		case core.ErrNotFound:
 			http.NotFound(w, r)
 			return 
 		case nil:
-			http.Redirect(w, r, original, http.StatusFound)
+			if moderate(w, r, log, svc, o.moderation, link) {
+				return
+			}
+			target, clickID := link.Original, ""
+			if link.ClickIDParam != "" && o.clickIDs != nil {
+				target, clickID = withClickID(log, o.clickIDs, link)
+			}
+			if o.hot != nil {
+				o.hot.Observe(link.Domain, code)
+			}
+			if o.stream != nil {
+				o.stream.Publish(stream.Event{
+					Domain:   link.Domain,
+					Code:     code,
+					At:       time.Now().UTC(),
+					Referrer: analytics.ReferrerHost(r.Referer()),
+					ClickID:  clickID,
+				})
+			}
+			if o.webhooks != nil {
+				o.webhooks.Publish(webhook.EventLinkClicked, webhook.ClickData{
+					Domain:   link.Domain,
+					Code:     code,
+					At:       time.Now().UTC(),
+					Referrer: analytics.ReferrerHost(r.Referer()),
+					ClickID:  clickID,
+				})
+			}
+			if o.clicks != nil {
+				o.clicks.Record(analytics.Click{
+					Domain:    link.Domain,
+					Code:      code,
+					At:        time.Now().UTC(),
+					Referrer:  r.Referer(),
+					UserAgent: r.UserAgent(),
+					IP:        clientIP(r),
+					RequestID: middleware.GetReqID(r.Context()),
+					ClickID:   clickID,
+				})
+			}
+			http.Redirect(w, r, target, http.StatusFound)
 		default:
 			log.Error("resolve failed", "code", code, "err", err)
             http.Error(w, "internal error", http.StatusInternalServerError)
@@ -96,10 +193,10 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 
 	})
 
-	r.Get("/api/v1/urls/{code}", func(w http.ResponseWriter, r *http.Request) {
+	r.With(need(core.ScopeLinksRead), limit(o.policies.Resolve)).Get("/api/v1/urls/{code}", func(w http.ResponseWriter, r *http.Request) {
 		code := chi.URLParam(r, "code")
 
-		original, err := svc.Resolve(r.Context(), code)
+		link, err := svc.ResolveLink(r.Context(), queryDomain(r), code)
 		if err != nil {
 			if err == core.ErrNotFound {
 				http.NotFound(w, r)
@@ -111,21 +208,94 @@ func NewRouter(log *slog.Logger, svc *core.Shortener) http.Handler{
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(struct {
-			URL string `json:"url"`
-		}{URL: original})
+		_ = json.NewEncoder(w).Encode(toLinkResponse(svc.Domains(), link))
 	})
 
+	r.With(limit(o.policies.Redirect)).Get("/{code}/qr", qrHandler(log, svc, true))
+	r.With(need(core.ScopeLinksRead), limit(o.policies.Resolve)).Get("/api/v1/urls/{code}/qr", qrHandler(log, svc, false))
+	r.With(need(core.ScopeLinksRead)).Get("/api/v1/urls", listLinksHandler(log, svc))
+	r.With(need(core.ScopeLinksWrite)).Patch("/api/v1/urls/{code}", updateLinkHandler(log, svc))
+	r.With(need(core.ScopeLinksWrite)).Delete("/api/v1/urls/{code}", deleteLinkHandler(log, svc))
+
+	if o.stats != nil {
+		r.With(need(core.ScopeStatsRead)).Get("/api/v1/urls/{code}/stats", statsHandler(log, svc, o.stats))
+	}
+	if o.hot != nil {
+		r.With(need(core.ScopeStatsRead)).Get("/api/v1/stats/top", topHandler(svc, o.hot))
+	}
+	if o.stream != nil {
+		mux.With(need(core.ScopeStatsRead)).Get("/api/v1/urls/{code}/events", eventsHandler(log, svc, o.stream))
+	}
+	if o.exportSrc != nil {
+		r.With(need(core.ScopeStatsRead)).Get("/api/v1/exports/clicks", exportClicksHandler(log, svc, o.exportSrc))
+	}
+	if o.exportJobs != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeStatsRead))
+			r.Post("/api/v1/exports", startExportHandler(log, svc, o.exportJobs))
+			r.Get("/api/v1/exports/{id}", exportJobHandler(o.exportJobs))
+			r.Get("/api/v1/exports/{id}/download", downloadExportHandler(log, o.exportJobs))
+		})
+	}
+	if o.webhooks != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeAdmin))
+			mountWebhooks(r, log, o.webhooks)
+		})
+	}
+	if o.keys != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeAdmin))
+			r.Post("/api/v1/keys", issueKeyHandler(log, o.keys))
+			r.Get("/api/v1/keys", listKeysHandler(log, o.keys))
+			r.Delete("/api/v1/keys/{prefix}", revokeKeyHandler(log, o.keys))
+		})
+	}
+	if o.rbac != nil {
+		mountRoles(r, log, svc, o.rbac, need)
+	}
+	if o.audit != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeAdmin))
+			r.Get("/api/v1/audit", auditHandler(log, o.audit))
+			r.Get("/api/v1/audit/verify", verifyAuditHandler(log, o.audit))
+		})
+	}
+	if o.moderation != nil {
+		r.Get("/{code}/report", reportFormHandler(log, svc))
+		r.With(limit(o.policies.Report)).Post("/{code}/report", reportHandler(log, svc, o.moderation))
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeAdmin))
+			mountModeration(r, log, o.moderation)
+		})
+	}
+	if svc.Domains() != nil {
+		mountDomains(r, log, svc, need)
+	}
+	if o.quotas != nil {
+		r.With(need(core.ScopeLinksRead)).Get("/api/v1/quota", ownQuotaHandler(log, o.quotas))
+		r.Group(func(r chi.Router) {
+			r.Use(need(core.ScopeAdmin))
+			r.Get("/api/v1/tenants/{tenant}/quota", tenantQuotaHandler(log, o.quotas))
+			r.Put("/api/v1/tenants/{tenant}/quota", setQuotaHandler(log, o.quotas))
+			r.Delete("/api/v1/tenants/{tenant}/quota", resetQuotaHandler(log, o.quotas))
+		})
+	}
+	// Постбек аутентифицируется подписью click id, ключ не нужен.
+	if o.clickIDs != nil && o.conversions != nil {
+		r.Post("/api/v1/conversions", conversionHandler(log, o.clickIDs, o.conversions))
+	}
 
 	r.Handle("/metrics", promhttp.Handler())
 
-	return r
+	return mux
 }
 
-func absoluteURL(r *http.Request, code string) string {
-	proto := r.Header.Get("X-Forwarded-Proto")
-	if proto == "" {
-		proto = "http"
+// clientIP возвращает адрес клиента; после middleware.RealIP в RemoteAddr
+// может лежать голый IP без порта.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
 	}
-	return proto + "://" + r.Host + "/" + code
+	return r.RemoteAddr
 }
@@ -0,0 +1,202 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/analytics/topk"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+type statsPoint struct {
+	At     time.Time `json:"at"`
+	Clicks int64     `json:"clicks"`
+}
+
+type statsCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+type statsResponse struct {
+	Domain       string       `json:"domain"`
+	Code         string       `json:"code"`
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Total        int64        `json:"total"`
+	Bots         int64        `json:"bots"`
+	Uniques      int64        `json:"uniques"`
+	Hourly       []statsPoint `json:"hourly"`
+	Daily        []statsPoint `json:"daily"`
+	DailyUniques []statsPoint `json:"daily_uniques"`
+
+	Conversions      int64        `json:"conversions"`
+	ConversionValue  float64      `json:"conversion_value"`
+	DailyConversions []statsPoint `json:"daily_conversions"`
+
+	TopReferrers []statsCount `json:"top_referrers"`
+	TopCountries []statsCount `json:"top_countries"`
+	TopCities    []statsCount `json:"top_cities"`
+	TopDevices   []statsCount `json:"top_devices"`
+	TopOS        []statsCount `json:"top_os"`
+	TopBrowsers  []statsCount `json:"top_browsers"`
+}
+
+// GET /api/v1/urls/{code}/stats?domain=&from=&to=&top=&include_bots=
+// from/to — RFC3339 или YYYY-MM-DD, по умолчанию последние 7 дней.
+// Боты по умолчанию исключены из счётчиков и топов.
+func statsHandler(log *slog.Logger, svc *core.Shortener, stats analytics.StatsReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+
+		q := analytics.StatsQuery{Code: code}
+		var err error
+		if q.From, err = parseTimeParam(r, "from"); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		if q.To, err = parseTimeParam(r, "to"); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		if v := r.URL.Query().Get("top"); v != "" {
+			if q.Top, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid top", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("include_bots"); v != "" {
+			if q.IncludeBots, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid include_bots", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := q.Normalize(time.Now()); err != nil {
+			http.Error(w, "invalid range", http.StatusBadRequest)
+			return
+		}
+
+		link, err := svc.StatsLink(r.Context(), queryDomain(r), code)
+		if err != nil {
+			switch err {
+			case core.ErrNotFound:
+				http.NotFound(w, r)
+				return
+			case core.ErrForbidden:
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			log.Error("resolve failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		q.Domain = link.Domain
+
+		st, err := stats.Stats(r.Context(), q)
+		if err != nil {
+			log.Error("stats failed", "code", code, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(statsResponse{
+			Domain:       svc.Domains().Host(link.Domain),
+			Code:         code,
+			From:         q.From,
+			To:           q.To,
+			Total:        st.Total,
+			Bots:         st.Bots,
+			Uniques:      st.Uniques,
+			Hourly:       toStatsPoints(st.Hourly),
+			Daily:        toStatsPoints(st.Daily),
+			DailyUniques: toStatsPoints(st.DailyUniques),
+
+			Conversions:      st.Conversions,
+			ConversionValue:  st.ConversionValue,
+			DailyConversions: toStatsPoints(st.DailyConversions),
+
+			TopReferrers: toStatsCounts(st.TopReferrers),
+			TopCountries: toStatsCounts(st.TopCountries),
+			TopCities:    toStatsCounts(st.TopCities),
+			TopDevices:   toStatsCounts(st.TopDevices),
+			TopOS:        toStatsCounts(st.TopOS),
+			TopBrowsers:  toStatsCounts(st.TopBrowsers),
+		})
+	}
+}
+
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func toStatsPoints(ps []analytics.Point) []statsPoint {
+	out := make([]statsPoint, len(ps))
+	for i, p := range ps {
+		out[i] = statsPoint{At: p.At, Clicks: p.Clicks}
+	}
+	return out
+}
+
+func toStatsCounts(cs []analytics.Count) []statsCount {
+	out := make([]statsCount, len(cs))
+	for i, c := range cs {
+		out[i] = statsCount{Value: c.Value, Clicks: c.Clicks}
+	}
+	return out
+}
+
+type topEntry struct {
+	Domain string `json:"domain"`
+	Code   string `json:"code"`
+	Clicks uint64 `json:"clicks"`
+}
+
+// GET /api/v1/stats/top?window=5m&k=10
+// Счётчики — оценки Count-Min Sketch, могут быть немного завышены.
+func topHandler(svc *core.Shortener, hot *topk.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window := 5 * time.Minute
+		if v := r.URL.Query().Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 || d > hot.MaxWindow() {
+				http.Error(w, "invalid window", http.StatusBadRequest)
+				return
+			}
+			window = d
+		}
+		k := analytics.DefaultTop
+		if v := r.URL.Query().Get("k"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > analytics.MaxTop {
+				http.Error(w, "invalid k", http.StatusBadRequest)
+				return
+			}
+			k = n
+		}
+
+		top := hot.Top(window, k)
+		links := make([]topEntry, len(top))
+		for i, e := range top {
+			links[i] = topEntry{Domain: svc.Domains().Host(e.Domain), Code: e.Code, Clicks: e.Clicks}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Window string     `json:"window"`
+			Links  []topEntry `json:"links"`
+		}{Window: window.String(), Links: links})
+	}
+}
@@ -0,0 +1,247 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
+	"github.com/go-chi/chi/v5"
+)
+
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"` // только в ответе на создание
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toWebhookResponse(s webhook.Subscription) webhookResponse {
+	events := s.Events
+	if events == nil {
+		events = []string{}
+	}
+	return webhookResponse{
+		ID:        s.ID,
+		URL:       s.URL,
+		Events:    events,
+		Active:    s.Active,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
+
+type deliveryResponse struct {
+	ID             string            `json:"id"`
+	SubscriptionID string            `json:"subscription_id"`
+	EventID        string            `json:"event_id"`
+	EventType      string            `json:"event_type"`
+	Status         string            `json:"status"`
+	Tries          int               `json:"tries"`
+	Attempts       []webhook.Attempt `json:"attempts"`
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Payload        json.RawMessage   `json:"payload"`
+}
+
+func toDeliveryResponse(d webhook.Delivery) deliveryResponse {
+	resp := deliveryResponse{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         string(d.Status),
+		Tries:          d.Tries,
+		Attempts:       d.Attempts,
+		CreatedAt:      d.CreatedAt,
+		Payload:        d.Payload,
+	}
+	if resp.Attempts == nil {
+		resp.Attempts = []webhook.Attempt{}
+	}
+	if d.Status == webhook.StatusPending {
+		resp.NextAttemptAt = &d.NextAttemptAt
+	}
+	return resp
+}
+
+func mountWebhooks(r chi.Router, log *slog.Logger, d *webhook.Dispatcher) {
+	r.Post("/api/v1/webhooks", createWebhookHandler(log, d))
+	r.Get("/api/v1/webhooks", listWebhooksHandler(log, d))
+	r.Get("/api/v1/webhooks/dead-letters", deliveriesHandler(log, d, true))
+	r.Post("/api/v1/webhooks/deliveries/{id}/retry", redeliverHandler(log, d))
+	r.Get("/api/v1/webhooks/{id}", getWebhookHandler(log, d))
+	r.Patch("/api/v1/webhooks/{id}", updateWebhookHandler(log, d))
+	r.Delete("/api/v1/webhooks/{id}", deleteWebhookHandler(log, d))
+	r.Get("/api/v1/webhooks/{id}/deliveries", deliveriesHandler(log, d, false))
+}
+
+// webhookError отвечает на ошибки Dispatcher; true — ответ уже записан.
+func webhookError(log *slog.Logger, w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, webhook.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrForbiddenAddress),
+		errors.Is(err, webhook.ErrInvalidEvents):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, webhook.ErrDeliveryNotDead):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error("webhook api failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// POST /api/v1/webhooks {"url": "...", "events": ["link.*"], "secret": ""}
+// Пустой secret генерируется и возвращается один раз в ответе.
+func createWebhookHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+			Secret string   `json:"secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		sub, err := d.Subscribe(r.Context(), req.URL, req.Events, req.Secret)
+		if webhookError(log, w, err) {
+			return
+		}
+		resp := toWebhookResponse(sub)
+		resp.Secret = sub.Secret
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/v1/webhooks/"+sub.ID)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func listWebhooksHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := d.Subscriptions(r.Context())
+		if webhookError(log, w, err) {
+			return
+		}
+		out := make([]webhookResponse, len(subs))
+		for i, s := range subs {
+			out[i] = toWebhookResponse(s)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Webhooks []webhookResponse `json:"webhooks"`
+		}{out})
+	}
+}
+
+func getWebhookHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, err := d.Subscription(r.Context(), chi.URLParam(r, "id"))
+		if webhookError(log, w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toWebhookResponse(sub))
+	}
+}
+
+// PATCH /api/v1/webhooks/{id} {"url": "...", "events": [...], "active": false}
+// Неактивная подписка копит доставки и отправит их после включения.
+func updateWebhookHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL    *string   `json:"url"`
+			Events *[]string `json:"events"`
+			Active *bool     `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		sub, err := d.UpdateSubscription(r.Context(), chi.URLParam(r, "id"), webhook.SubscriptionPatch{
+			URL:    req.URL,
+			Events: req.Events,
+			Active: req.Active,
+		})
+		if webhookError(log, w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toWebhookResponse(sub))
+	}
+}
+
+func deleteWebhookHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if webhookError(log, w, d.Unsubscribe(r.Context(), chi.URLParam(r, "id"))) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /api/v1/webhooks/{id}/deliveries?status=pending|delivered|dead&limit=
+// GET /api/v1/webhooks/dead-letters?limit= — dead letters всех подписок.
+func deliveriesHandler(log *slog.Logger, d *webhook.Dispatcher, deadOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := webhook.DeliveryQuery{Status: webhook.DeliveryStatus(r.URL.Query().Get("status"))}
+		if deadOnly {
+			q.Status = webhook.StatusDead
+		} else {
+			q.SubscriptionID = chi.URLParam(r, "id")
+			if _, err := d.Subscription(r.Context(), q.SubscriptionID); webhookError(log, w, err) {
+				return
+			}
+		}
+		switch q.Status {
+		case "", webhook.StatusPending, webhook.StatusDelivered, webhook.StatusDead:
+		default:
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+
+		ds, err := d.Deliveries(r.Context(), q)
+		if webhookError(log, w, err) {
+			return
+		}
+		out := make([]deliveryResponse, len(ds))
+		for i, dl := range ds {
+			out[i] = toDeliveryResponse(dl)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Deliveries []deliveryResponse `json:"deliveries"`
+		}{out})
+	}
+}
+
+// POST /api/v1/webhooks/deliveries/{id}/retry — вернуть dead letter в очередь.
+func redeliverHandler(log *slog.Logger, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dl, err := d.Redeliver(r.Context(), chi.URLParam(r, "id"))
+		if webhookError(log, w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(toDeliveryResponse(dl))
+	}
+}
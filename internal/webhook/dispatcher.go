@@ -0,0 +1,395 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+type Options struct {
+	MaxAttempts int           // после стольких неудач доставка уходит в dead letter
+	Backoff     time.Duration // задержка перед второй попыткой, дальше удваивается
+	MaxBackoff  time.Duration
+	Timeout     time.Duration // на одну попытку
+	Workers     int
+	Poll        time.Duration // как часто искать доставки, которым пора
+	Queue       int           // буфер событий до записи в Store
+	// AllowNets — частные сети, куда всё же можно слать вебхуки
+	// (внутренние получатели). Остальные частные и служебные адреса
+	// отклоняются при создании подписки и при соединении.
+	AllowNets []netip.Prefix
+	// Client заменяет стандартный клиент; проверку адресов при
+	// соединении он должен делать сам.
+	Client *http.Client
+}
+
+// maxLogAttempts ограничивает журнал попыток одной доставки.
+const maxLogAttempts = 50
+
+// Dispatcher принимает события, раскладывает их по подходящим подпискам в
+// Store и доставляет в фоне. Очередь доставок живёт в Store, поэтому
+// переживает рестарт и делится между инстансами.
+type Dispatcher struct {
+	log    *slog.Logger
+	store  Store
+	opts   Options
+	guard  guard
+	client *http.Client
+	queue  chan envelope
+	wake   chan struct{}
+
+	mu   sync.RWMutex
+	subs []Subscription // кэш для фильтрации в Publish
+}
+
+func NewDispatcher(log *slog.Logger, store Store, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 2 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = time.Second
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = 10000
+	}
+	g := guard{allow: opts.AllowNets}
+	client := opts.Client
+	if client == nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		// Через прокси адрес получателя не проверить.
+		tr.Proxy = nil
+		tr.DialContext = (&net.Dialer{
+			Timeout:   opts.Timeout,
+			KeepAlive: 30 * time.Second,
+			Control:   g.control,
+		}).DialContext
+		client = &http.Client{
+			Transport: tr,
+			Timeout:   opts.Timeout,
+			// Редирект получателя — ошибка доставки, а не повод слать
+			// подписанное тело на другой адрес.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	return &Dispatcher{
+		log:    log,
+		store:  store,
+		opts:   opts,
+		guard:  g,
+		client: client,
+		queue:  make(chan envelope, opts.Queue),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// envelope — тело запроса доставки.
+type envelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type LinkData struct {
+	Domain       string   `json:"domain,omitempty"`
+	Code         string   `json:"code"`
+	URL          string   `json:"url"`
+	ClickIDParam string   `json:"click_id_param,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Disabled     bool     `json:"disabled,omitempty"`
+}
+
+type ClickData struct {
+	Domain   string    `json:"domain,omitempty"`
+	Code     string    `json:"code"`
+	At       time.Time `json:"at"`
+	Referrer string    `json:"referrer,omitempty"`
+	ClickID  string    `json:"click_id,omitempty"`
+}
+
+// Publish ставит событие в очередь, если на него есть подписчики. Не
+// блокирует: при переполнении событие теряется и учитывается в метрике.
+func (d *Dispatcher) Publish(typ string, data any) {
+	if !d.wanted(typ) {
+		return
+	}
+	e := envelope{ID: newID(), Type: typ, CreatedAt: time.Now().UTC(), Data: data}
+	select {
+	case d.queue <- e:
+	default:
+		eventsDropped.Inc()
+	}
+}
+
+// PublishLinkEvent реализует core.EventSink.
+func (d *Dispatcher) PublishLinkEvent(ctx context.Context, e core.LinkEvent) {
+	d.Publish(string(e.Type), LinkData{
+		Domain:       e.Link.Domain,
+		Code:         e.Link.Code,
+		URL:          e.Link.Original,
+		ClickIDParam: e.Link.ClickIDParam,
+		Tags:         e.Link.Tags,
+		Disabled:     e.Link.Disabled,
+	})
+}
+
+func (d *Dispatcher) wanted(typ string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, s := range d.subs {
+		if s.Wants(typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload перечитывает подписки из Store. Вызывается после изменений через
+// API и периодически, чтобы увидеть изменения с других инстансов.
+func (d *Dispatcher) Reload(ctx context.Context) error {
+	subs, err := d.store.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.subs = subs
+	d.mu.Unlock()
+	return nil
+}
+
+// Run обрабатывает очередь событий и доставки до отмены ctx. События,
+// оставшиеся в очереди, при остановке сохраняются в Store.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if err := d.Reload(ctx); err != nil {
+		d.log.Error("webhook subscriptions load failed", "err", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.runEnqueue(ctx)
+	}()
+
+	poll := time.NewTicker(d.opts.Poll)
+	defer poll.Stop()
+	reload := time.NewTicker(30 * time.Second)
+	defer reload.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-reload.C:
+			if err := d.Reload(ctx); err != nil {
+				d.log.Error("webhook subscriptions reload failed", "err", err)
+			}
+		case <-poll.C:
+			d.deliverDue(ctx)
+		case <-d.wake:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) runEnqueue(ctx context.Context) {
+	for {
+		select {
+		case e := <-d.queue:
+			d.enqueue(ctx, e)
+		case <-ctx.Done():
+			dctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case e := <-d.queue:
+					d.enqueue(dctx, e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, e envelope) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.log.Error("webhook payload encode failed", "type", e.Type, "err", err)
+		return
+	}
+	d.mu.RLock()
+	var ds []Delivery
+	for _, s := range d.subs {
+		if s.Wants(e.Type) {
+			ds = append(ds, Delivery{
+				ID:             newID(),
+				SubscriptionID: s.ID,
+				EventID:        e.ID,
+				EventType:      e.Type,
+				Payload:        body,
+				Status:         StatusPending,
+				NextAttemptAt:  e.CreatedAt,
+				CreatedAt:      e.CreatedAt,
+				UpdatedAt:      e.CreatedAt,
+			})
+		}
+	}
+	d.mu.RUnlock()
+	if len(ds) == 0 {
+		return
+	}
+	if err := d.store.EnqueueDeliveries(ctx, ds); err != nil {
+		d.log.Error("webhook enqueue failed", "type", e.Type, "n", len(ds), "err", err)
+		eventsDropped.Inc()
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue выбирает доставки, которым пора, пачками и отправляет их
+// параллельно в Workers потоков, пока очередь не опустеет.
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	batch := d.opts.Workers * 4
+	for ctx.Err() == nil {
+		ds, err := d.store.ClaimDeliveries(ctx, time.Now().UTC(), 2*d.opts.Timeout, batch)
+		if err != nil {
+			d.log.Error("webhook claim failed", "err", err)
+			return
+		}
+		sem := make(chan struct{}, d.opts.Workers)
+		var wg sync.WaitGroup
+		for _, dl := range ds {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(dl Delivery) {
+				defer func() { <-sem; wg.Done() }()
+				d.attempt(ctx, dl)
+			}(dl)
+		}
+		wg.Wait()
+		if len(ds) < batch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, dl Delivery) {
+	sub, found, err := d.store.GetSubscription(ctx, dl.SubscriptionID)
+	if err != nil || !found {
+		return // подписку удалили вместе с доставками
+	}
+	now := time.Now().UTC()
+	if !sub.Active {
+		// Приостановленная подписка копит события без траты попыток.
+		dl.NextAttemptAt = now.Add(d.opts.Poll * 30)
+		d.save(dl)
+		return
+	}
+
+	status, err := d.send(ctx, sub, dl, now)
+	a := Attempt{At: now, StatusCode: status, DurationMS: time.Since(now).Milliseconds()}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	dl.Attempts = append(dl.Attempts, a)
+	if len(dl.Attempts) > maxLogAttempts {
+		dl.Attempts = dl.Attempts[len(dl.Attempts)-maxLogAttempts:]
+	}
+	dl.UpdatedAt = time.Now().UTC()
+
+	switch {
+	case err == nil:
+		attemptsTotal.WithLabelValues("ok").Inc()
+		dl.Status = StatusDelivered
+	default:
+		attemptsTotal.WithLabelValues("error").Inc()
+		dl.Tries++
+		if dl.Tries >= d.opts.MaxAttempts {
+			dl.Status = StatusDead
+			deadLetters.Inc()
+			d.log.Warn("webhook dead letter", "delivery", dl.ID, "subscription", sub.ID, "err", err)
+		} else {
+			dl.NextAttemptAt = dl.UpdatedAt.Add(d.backoff(dl.Tries))
+		}
+	}
+	d.save(dl)
+}
+
+// save пишет результат попытки даже при остановке: иначе доставку
+// повторят после истечения аренды, хотя она уже прошла.
+func (d *Dispatcher) save(dl Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.store.SaveDelivery(ctx, dl); err != nil {
+		d.log.Error("webhook delivery save failed", "delivery", dl.ID, "err", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, dl Delivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "url-shortener-webhooks/1")
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, dl.ID)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, dl.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusError{code: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+type statusError struct{ code int }
+
+func (e *statusError) Error() string { return "unexpected status " + http.StatusText(e.code) }
+
+// backoff — экспоненциальная задержка перед попыткой tries+1 с джиттером
+// в половину интервала, чтобы повторы к одному получателю не шли залпом.
+func (d *Dispatcher) backoff(tries int) time.Duration {
+	delay := d.opts.Backoff
+	for i := 1; i < tries && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// forbiddenNets — частные и служебные сети (RFC 6890 и соседи), куда
+// вебхуки не ходят без явного Options.AllowNets: иначе подписка
+// становится способом достучаться до внутренних сервисов и метаданных
+// облака от имени сервера.
+var forbiddenNets = func() []netip.Prefix {
+	var out []netip.Prefix
+	for _, s := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"192.88.99.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"64:ff9b::/96",
+		"64:ff9b:1::/48",
+		"100::/64",
+		"2001::/23",
+		"2001:db8::/32",
+		"2002::/16",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	} {
+		out = append(out, netip.MustParsePrefix(s))
+	}
+	return out
+}()
+
+// guard решает, можно ли слать запрос на адрес.
+type guard struct {
+	allow []netip.Prefix
+}
+
+func (g guard) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range g.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	for _, p := range forbiddenNets {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsGlobalUnicast()
+}
+
+// checkURL проверяет адреса хоста подписки. Имя, которое сейчас не
+// резолвится, не отклоняется: окончательную проверку делает control при
+// каждом соединении, в том числе после смены DNS-записи.
+func (g guard) checkURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.allowed(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !g.allowed(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// control — net.Dialer.Control: проверяет уже разрешённый адрес прямо
+// перед connect, поэтому подмена DNS после создания подписки не помогает.
+func (g guard) control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook dial %s: %w", address, err)
+	}
+	if !g.allowed(ap.Addr()) {
+		return fmt.Errorf("webhook dial %s: %w", address, ErrForbiddenAddress)
+	}
+	return nil
+}
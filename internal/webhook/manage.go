@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// SubscriptionPatch — частичное изменение подписки; nil — не менять.
+type SubscriptionPatch struct {
+	URL    *string
+	Events *[]string
+	Active *bool
+}
+
+// Subscribe создаёт подписку. Пустой secret генерируется; вернуть его
+// можно только здесь.
+func (d *Dispatcher) Subscribe(ctx context.Context, url string, events []string, secret string) (Subscription, error) {
+	normalized, err := core.ValidateURL(url)
+	if err != nil {
+		return Subscription{}, ErrInvalidURL
+	}
+	if err := d.guard.checkURL(ctx, normalized); err != nil {
+		return Subscription{}, err
+	}
+	if err := ValidateEvents(events); err != nil {
+		return Subscription{}, err
+	}
+	if secret == "" {
+		secret = newID() + newID()
+	}
+	now := time.Now().UTC()
+	s := Subscription{
+		ID:        newID(),
+		URL:       normalized,
+		Secret:    secret,
+		Events:    events,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.store.CreateSubscription(ctx, s); err != nil {
+		return Subscription{}, err
+	}
+	return s, d.Reload(ctx)
+}
+
+func (d *Dispatcher) UpdateSubscription(ctx context.Context, id string, p SubscriptionPatch) (Subscription, error) {
+	s, err := d.Subscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if p.URL != nil {
+		if s.URL, err = core.ValidateURL(*p.URL); err != nil {
+			return Subscription{}, ErrInvalidURL
+		}
+		if err := d.guard.checkURL(ctx, s.URL); err != nil {
+			return Subscription{}, err
+		}
+	}
+	if p.Events != nil {
+		if err := ValidateEvents(*p.Events); err != nil {
+			return Subscription{}, err
+		}
+		s.Events = *p.Events
+	}
+	if p.Active != nil {
+		s.Active = *p.Active
+	}
+	s.UpdatedAt = time.Now().UTC()
+	if err := d.store.UpdateSubscription(ctx, s); err != nil {
+		return Subscription{}, err
+	}
+	return s, d.Reload(ctx)
+}
+
+func (d *Dispatcher) Unsubscribe(ctx context.Context, id string) error {
+	if err := d.store.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+	return d.Reload(ctx)
+}
+
+func (d *Dispatcher) Subscription(ctx context.Context, id string) (Subscription, error) {
+	s, found, err := d.store.GetSubscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if !found {
+		return Subscription{}, ErrNotFound
+	}
+	return s, nil
+}
+
+func (d *Dispatcher) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	return d.store.ListSubscriptions(ctx)
+}
+
+// Deliveries — журнал доставок; с Status: StatusDead — dead letters.
+func (d *Dispatcher) Deliveries(ctx context.Context, q DeliveryQuery) ([]Delivery, error) {
+	if q.Limit <= 0 || q.Limit > 500 {
+		q.Limit = 100
+	}
+	return d.store.ListDeliveries(ctx, q)
+}
+
+// Redeliver возвращает доставку из dead letters в очередь с новым циклом
+// попыток; журнал прошлых попыток сохраняется.
+func (d *Dispatcher) Redeliver(ctx context.Context, id string) (Delivery, error) {
+	dl, found, err := d.store.GetDelivery(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !found {
+		return Delivery{}, ErrNotFound
+	}
+	if dl.Status != StatusDead {
+		return Delivery{}, ErrDeliveryNotDead
+	}
+	now := time.Now().UTC()
+	dl.Status, dl.Tries, dl.NextAttemptAt, dl.UpdatedAt = StatusPending, 0, now, now
+	if err := d.store.SaveDelivery(ctx, dl); err != nil {
+		return Delivery{}, err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return dl, nil
+}
@@ -0,0 +1,21 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	attemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shortener_webhook_attempts_total",
+		Help: "Webhook delivery attempts, by result (ok|error).",
+	}, []string{"result"})
+	deadLetters = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_webhook_dead_letters_total",
+		Help: "Webhook deliveries moved to dead letters after exhausting retries.",
+	})
+	eventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shortener_webhook_events_dropped_total",
+		Help: "Webhook events lost because the in-memory queue was full or enqueue failed.",
+	})
+)
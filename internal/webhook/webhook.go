@@ -0,0 +1,145 @@
+// Package webhook доставляет события ссылок и кликов во внешние системы:
+// подписки с фильтрами по типам, подпись HMAC-SHA256, повторы с
+// экспоненциальной задержкой и dead letter после MaxAttempts неудач.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+)
+
+// Типы событий; жизненный цикл ссылок совпадает с core.EventType.
+const (
+	EventLinkCreated = string(core.LinkCreated)
+	EventLinkUpdated = string(core.LinkUpdated)
+	EventLinkDeleted = string(core.LinkDeleted)
+	EventLinkClicked = "link.clicked"
+)
+
+var knownEvents = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkClicked}
+
+var (
+	ErrNotFound        = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("invalid webhook url")
+	ErrInvalidEvents   = errors.New("invalid webhook event filter")
+	ErrDeliveryNotDead = errors.New("delivery is not in dead letters")
+)
+
+// ErrForbiddenAddress — url ведёт в частную или служебную сеть не из
+// Options.AllowNets.
+var ErrForbiddenAddress = errors.New("webhook url points to a private or special-use address")
+
+// Subscription — получатель событий. Events — фильтр: точные типы или
+// префикс с "*" ("link.*"); пустой фильтр — все события.
+type Subscription struct {
+	ID        string
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Wants сообщает, подходит ли событие под фильтр подписки.
+func (s Subscription) Wants(typ string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, f := range s.Events {
+		if f == typ || f == "*" || strings.HasSuffix(f, "*") && strings.HasPrefix(typ, strings.TrimSuffix(f, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEvents проверяет фильтр: каждый элемент — известный тип или
+// шаблон, под который подходит хотя бы один известный тип.
+func ValidateEvents(events []string) error {
+	for _, f := range events {
+		probe := Subscription{Active: true, Events: []string{f}}
+		ok := false
+		for _, typ := range knownEvents {
+			ok = ok || probe.Wants(typ)
+		}
+		if !ok {
+			return ErrInvalidEvents
+		}
+	}
+	return nil
+}
+
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "pending"
+	StatusDelivered DeliveryStatus = "delivered"
+	StatusDead      DeliveryStatus = "dead"
+)
+
+// Attempt — одна попытка доставки для журнала.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Delivery — событие, поставленное в очередь конкретной подписке.
+// Payload — готовое тело запроса, подписывается как есть.
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	EventID        string
+	EventType      string
+	Payload        []byte
+	Status         DeliveryStatus
+	Tries          int       // неудачных попыток в текущем цикле; Redeliver обнуляет
+	Attempts       []Attempt // журнал, последние maxLogAttempts
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type DeliveryQuery struct {
+	SubscriptionID string         // пусто — все подписки
+	Status         DeliveryStatus // пусто — любой
+	Limit          int
+}
+
+// Store хранит подписки и очередь доставок (memory, postgres).
+type Store interface {
+	CreateSubscription(ctx context.Context, s Subscription) error
+	// UpdateSubscription и DeleteSubscription возвращают ErrNotFound;
+	// удаление подписки удаляет и её доставки.
+	UpdateSubscription(ctx context.Context, s Subscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetSubscription(ctx context.Context, id string) (Subscription, bool, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+
+	EnqueueDeliveries(ctx context.Context, ds []Delivery) error
+	// ClaimDeliveries забирает до limit ожидающих доставок с
+	// NextAttemptAt <= now и сдвигает им NextAttemptAt на lease, чтобы
+	// другой инстанс не взял их повторно, пока идёт попытка.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	SaveDelivery(ctx context.Context, d Delivery) error
+	GetDelivery(ctx context.Context, id string) (Delivery, bool, error)
+	// ListDeliveries возвращает доставки от новых к старым.
+	ListDeliveries(ctx context.Context, q DeliveryQuery) ([]Delivery, error)
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
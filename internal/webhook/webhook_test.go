@@ -0,0 +1,276 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/core"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/storage/memory"
+	"github.com/Shyyw1e/ozon-bank-url-test/internal/webhook"
+)
+
+type received struct {
+	event string
+	body  map[string]any
+}
+
+// receiver — тестовый получатель: проверяет подпись и отвечает status.
+type receiver struct {
+	t      *testing.T
+	secret string
+	status int
+
+	mu  sync.Mutex
+	got []received
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := webhook.Verify(rc.secret, r.Header.Get(webhook.HeaderSignature), body, time.Now(), time.Minute); err != nil {
+		rc.t.Errorf("signature: %v", err)
+	}
+	var m map[string]any
+	_ = json.Unmarshal(body, &m)
+	rc.mu.Lock()
+	rc.got = append(rc.got, received{event: r.Header.Get(webhook.HeaderEvent), body: m})
+	status := rc.status
+	rc.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) events() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.got...)
+}
+
+func startDispatcher(t *testing.T, opts webhook.Options) (*webhook.Dispatcher, *memory.Store) {
+	t.Helper()
+	st := memory.New()
+	opts.Poll = 10 * time.Millisecond
+	if opts.AllowNets == nil {
+		// httptest слушает loopback.
+		opts.AllowNets = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	}
+	d := webhook.NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), st, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { d.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done })
+	return d, st
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_DeliversSignedLifecycleEvents(t *testing.T) {
+	rc := &receiver{t: t, secret: "s3cret", status: http.StatusOK}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	d, st := startDispatcher(t, webhook.Options{})
+	ctx := context.Background()
+	if _, err := d.Subscribe(ctx, srv.URL, []string{"link.created", "link.deleted"}, rc.secret); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := core.NewShortener(st, core.NewCode, core.WithEvents(d))
+	code, err := svc.Create(ctx, "https://example.com/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdateSettings(ctx, "", code, core.Settings{ClickIDParam: "gclid"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, "", code); err != nil {
+		t.Fatal(err)
+	}
+	d.Publish(webhook.EventLinkClicked, webhook.ClickData{Code: code})
+
+	waitFor(t, "two deliveries", func() bool { return len(rc.events()) >= 2 })
+	// Доставки идут параллельно, порядок между событиями не гарантирован.
+	byType := map[string]map[string]any{}
+	for _, r := range rc.events() {
+		data, _ := r.body["data"].(map[string]any)
+		byType[r.event] = data
+	}
+	if len(byType) != 2 || byType["link.created"] == nil || byType["link.deleted"] == nil {
+		t.Fatalf("events=%v, want created and deleted (updated and clicked filtered out)", byType)
+	}
+	if data := byType["link.created"]; data["code"] != code || data["url"] != "https://example.com/hook" {
+		t.Fatalf("payload data=%v", data)
+	}
+
+	waitFor(t, "delivered status", func() bool {
+		ds, _ := d.Deliveries(ctx, webhook.DeliveryQuery{Status: webhook.StatusDelivered})
+		return len(ds) == 2
+	})
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rc.events()); n != 2 {
+		t.Fatalf("received %d requests, want exactly 2", n)
+	}
+}
+
+func TestDispatcher_RetriesThenDeadLetter(t *testing.T) {
+	rc := &receiver{t: t, secret: "k", status: http.StatusInternalServerError}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	d, _ := startDispatcher(t, webhook.Options{
+		MaxAttempts: 3,
+		Backoff:     10 * time.Millisecond,
+		MaxBackoff:  20 * time.Millisecond,
+	})
+	ctx := context.Background()
+	sub, err := d.Subscribe(ctx, srv.URL, []string{"link.*"}, rc.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Publish(webhook.EventLinkClicked, webhook.ClickData{Code: "abc"})
+
+	var dead []webhook.Delivery
+	waitFor(t, "dead letter", func() bool {
+		dead, _ = d.Deliveries(ctx, webhook.DeliveryQuery{Status: webhook.StatusDead})
+		return len(dead) == 1
+	})
+	if n := len(rc.events()); n != 3 {
+		t.Fatalf("attempts=%d, want 3", n)
+	}
+	if len(dead[0].Attempts) != 3 || dead[0].Attempts[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("attempt log=%+v", dead[0].Attempts)
+	}
+	if dead[0].SubscriptionID != sub.ID {
+		t.Fatalf("subscription=%q, want %q", dead[0].SubscriptionID, sub.ID)
+	}
+
+	if _, err := d.Redeliver(ctx, "missing"); err != webhook.ErrNotFound {
+		t.Fatalf("Redeliver(missing) err=%v", err)
+	}
+	rc.mu.Lock()
+	rc.status = http.StatusNoContent
+	rc.mu.Unlock()
+	if _, err := d.Redeliver(ctx, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "redelivery", func() bool {
+		ds, _ := d.Deliveries(ctx, webhook.DeliveryQuery{Status: webhook.StatusDelivered})
+		return len(ds) == 1 && len(ds[0].Attempts) == 4
+	})
+	if _, err := d.Redeliver(ctx, dead[0].ID); err != webhook.ErrDeliveryNotDead {
+		t.Fatalf("Redeliver(delivered) err=%v", err)
+	}
+}
+
+func TestSubscribe_RejectsPrivateAddresses(t *testing.T) {
+	d, _ := startDispatcher(t, webhook.Options{AllowNets: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}})
+	ctx := context.Background()
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[::ffff:192.168.1.1]/hook",
+		"http://[fd00::1]/hook",
+	} {
+		if _, err := d.Subscribe(ctx, u, nil, ""); !errors.Is(err, webhook.ErrForbiddenAddress) {
+			t.Errorf("Subscribe(%s) err=%v, want ErrForbiddenAddress", u, err)
+		}
+	}
+	if _, err := d.Subscribe(ctx, "http://10.1.2.3/hook", nil, ""); err != nil {
+		t.Fatalf("allow-listed net: %v", err)
+	}
+	sub, err := d.Subscribe(ctx, "https://93.184.215.14/hook", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.UpdateSubscription(ctx, sub.ID, webhook.SubscriptionPatch{URL: ptr("http://192.168.0.10/")}); !errors.Is(err, webhook.ErrForbiddenAddress) {
+		t.Fatalf("UpdateSubscription err=%v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddressAtDial(t *testing.T) {
+	rc := &receiver{t: t, secret: "k", status: http.StatusOK}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	// Подписка в обход Subscribe — как если бы DNS-запись сменилась на
+	// внутренний адрес уже после проверки.
+	// Пустой, но не nil AllowNets отключает разрешение loopback из startDispatcher.
+	d, st := startDispatcher(t, webhook.Options{AllowNets: []netip.Prefix{}, MaxAttempts: 1})
+	ctx := context.Background()
+	if err := st.CreateSubscription(ctx, webhook.Subscription{ID: "s1", URL: srv.URL, Secret: rc.secret, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d.Publish(webhook.EventLinkClicked, webhook.ClickData{Code: "abc"})
+
+	var dead []webhook.Delivery
+	waitFor(t, "dead letter", func() bool {
+		dead, _ = d.Deliveries(ctx, webhook.DeliveryQuery{Status: webhook.StatusDead})
+		return len(dead) == 1
+	})
+	if n := len(rc.events()); n != 0 {
+		t.Fatalf("receiver got %d requests from a loopback dial", n)
+	}
+	if a := dead[0].Attempts; len(a) != 1 || !strings.Contains(a[0].Error, "private or special-use") {
+		t.Fatalf("attempt log=%+v", a)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestSubscriptionFilters(t *testing.T) {
+	if err := webhook.ValidateEvents([]string{"link.*", "link.clicked", "*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.ValidateEvents([]string{"user.created"}); err != webhook.ErrInvalidEvents {
+		t.Fatalf("err=%v, want ErrInvalidEvents", err)
+	}
+	s := webhook.Subscription{Active: true, Events: []string{"link.c*"}}
+	if !s.Wants("link.created") || !s.Wants("link.clicked") || s.Wants("link.deleted") {
+		t.Fatal("prefix filter mismatch")
+	}
+	s.Active = false
+	if s.Wants("link.created") {
+		t.Fatal("inactive subscription wants events")
+	}
+}
+
+func TestVerify_RejectsTamperedAndStale(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"1"}`)
+	sig := webhook.Sign("k", now, body)
+	if err := webhook.Verify("k", sig, body, now, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if webhook.Verify("k", sig, []byte(`{"id":"2"}`), now, time.Minute) == nil {
+		t.Fatal("tampered body accepted")
+	}
+	if webhook.Verify("other", sig, body, now, time.Minute) == nil {
+		t.Fatal("wrong secret accepted")
+	}
+	if webhook.Verify("k", sig, body, now.Add(10*time.Minute), time.Minute) == nil {
+		t.Fatal("stale signature accepted")
+	}
+}